@@ -46,6 +46,7 @@ public final class GioView extends SurfaceView implements Choreographer.FrameCal
 	private final float scrollYScale;
 
 	private long nhandle;
+	private int inputType;
 
 	public GioView(Context context) {
 		this(context, null);
@@ -170,9 +171,45 @@ public final class GioView extends SurfaceView implements Choreographer.FrameCal
 	}
 
 	@Override public InputConnection onCreateInputConnection(EditorInfo outAttrs) {
+		outAttrs.inputType = inputType;
 		return new InputConnection(this);
 	}
 
+	void setInputHint(int hint) {
+		// Map key.InputHint to an EditorInfo input type.
+		final int typ;
+		switch (hint) {
+		case 1: // HintText
+			typ = EditorInfo.TYPE_CLASS_TEXT;
+			break;
+		case 2: // HintNumeric
+			typ = EditorInfo.TYPE_CLASS_NUMBER | EditorInfo.TYPE_NUMBER_FLAG_DECIMAL | EditorInfo.TYPE_NUMBER_FLAG_SIGNED;
+			break;
+		case 3: // HintEmail
+			typ = EditorInfo.TYPE_CLASS_TEXT | EditorInfo.TYPE_TEXT_VARIATION_EMAIL_ADDRESS;
+			break;
+		case 4: // HintURL
+			typ = EditorInfo.TYPE_CLASS_TEXT | EditorInfo.TYPE_TEXT_VARIATION_URI;
+			break;
+		case 5: // HintTelephone
+			typ = EditorInfo.TYPE_CLASS_PHONE;
+			break;
+		case 6: // HintPassword
+			typ = EditorInfo.TYPE_CLASS_TEXT | EditorInfo.TYPE_TEXT_VARIATION_PASSWORD;
+			break;
+		default:
+			typ = EditorInfo.TYPE_NULL;
+		}
+		post(new Runnable() {
+			@Override public void run() {
+				if (inputType != typ) {
+					inputType = typ;
+					imm.restartInput(GioView.this);
+				}
+			}
+		});
+	}
+
 	void showTextInput() {
 		post(new Runnable() {
 			@Override public void run() {
@@ -80,6 +80,7 @@ type window struct {
 	mgetFontScale      C.jmethodID
 	mshowTextInput     C.jmethodID
 	mhideTextInput     C.jmethodID
+	msetInputHint      C.jmethodID
 	mpostFrameCallback C.jmethodID
 	msetCursor         C.jmethodID
 }
@@ -203,6 +204,7 @@ func Java_org_gioui_GioView_onCreateView(env *C.JNIEnv, class C.jclass, view C.j
 		mgetFontScale:      getMethodID(env, class, "getFontScale", "()F"),
 		mshowTextInput:     getMethodID(env, class, "showTextInput", "()V"),
 		mhideTextInput:     getMethodID(env, class, "hideTextInput", "()V"),
+		msetInputHint:      getMethodID(env, class, "setInputHint", "(I)V"),
 		mpostFrameCallback: getMethodID(env, class, "postFrameCallback", "()V"),
 		msetCursor:         getMethodID(env, class, "setCursor", "(Landroid/content/Context;I)V"),
 	}
@@ -543,6 +545,15 @@ func (w *window) ShowTextInput(show bool) {
 	})
 }
 
+func (w *window) SetInputHint(hint key.InputHint) {
+	if w.view == 0 {
+		return
+	}
+	runInJVM(javaVM(), func(env *C.JNIEnv) {
+		callVoidMethod(env, w.view, w.msetInputHint, jvalue(hint))
+	})
+}
+
 func javaString(env *C.JNIEnv, str string) C.jstring {
 	if str == "" {
 		return 0
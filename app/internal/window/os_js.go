@@ -16,6 +16,7 @@ import (
 	"gioui.org/io/key"
 	"gioui.org/io/pointer"
 	"gioui.org/io/system"
+	"gioui.org/io/transfer"
 	"gioui.org/unit"
 )
 
@@ -182,6 +183,20 @@ func (w *window) addEventListeners() {
 		w.pointerEvent(pointer.Scroll, float32(dx), float32(dy), e)
 		return nil
 	})
+	w.addEventListener(w.cnv, "dragover", func(this js.Value, args []js.Value) interface{} {
+		// Accept drops.
+		args[0].Call("preventDefault")
+		return nil
+	})
+	w.addEventListener(w.cnv, "drop", func(this js.Value, args []js.Value) interface{} {
+		e := args[0]
+		e.Call("preventDefault")
+		txt := e.Get("dataTransfer").Call("getData", "text/plain").String()
+		if txt != "" {
+			w.w.Event(transfer.DropEvent{Position: w.eventPos(e), Text: txt})
+		}
+		return nil
+	})
 	w.addEventListener(w.cnv, "touchstart", func(this js.Value, args []js.Value) interface{} {
 		w.touchEvent(pointer.Press, args[0])
 		if w.requestFocus {
@@ -231,8 +246,14 @@ func (w *window) addEventListeners() {
 		w.composing = true
 		return nil
 	})
+	w.addEventListener(w.tarea, "compositionupdate", func(this js.Value, args []js.Value) interface{} {
+		w.w.Event(key.PreeditEvent{Text: args[0].Get("data").String()})
+		return nil
+	})
 	w.addEventListener(w.tarea, "compositionend", func(this js.Value, args []js.Value) interface{} {
 		w.composing = false
+		// Remove the composed text before committing the result.
+		w.w.Event(key.PreeditEvent{})
 		w.flushInput()
 		return nil
 	})
@@ -273,6 +294,11 @@ func (w *window) keyEvent(e js.Value, ks key.State) {
 			Modifiers: modifiersFor(e),
 			State:     ks,
 		}
+		// The code of letter keys is "Key" followed by the letter
+		// of the key on a US QWERTY keyboard.
+		if c := e.Get("code").String(); len(c) == 4 && strings.HasPrefix(c, "Key") {
+			cmd.Physical = c[3:]
+		}
 		w.w.Event(cmd)
 	}
 }
@@ -347,17 +373,10 @@ func (w *window) touchIDFor(touch js.Value) pointer.ID {
 
 func (w *window) pointerEvent(typ pointer.Type, dx, dy float32, e js.Value) {
 	e.Call("preventDefault")
-	x, y := e.Get("clientX").Float(), e.Get("clientY").Float()
-	rect := w.cnv.Call("getBoundingClientRect")
-	x -= rect.Get("left").Float()
-	y -= rect.Get("top").Float()
+	pos := w.eventPos(e)
 	w.mu.Lock()
 	scale := w.scale
 	w.mu.Unlock()
-	pos := f32.Point{
-		X: float32(x) * scale,
-		Y: float32(y) * scale,
-	}
 	scroll := f32.Point{
 		X: dx * scale,
 		Y: dy * scale,
@@ -385,6 +404,22 @@ func (w *window) pointerEvent(typ pointer.Type, dx, dy float32, e js.Value) {
 	})
 }
 
+// eventPos returns the position of the mouse event e in window
+// pixels.
+func (w *window) eventPos(e js.Value) f32.Point {
+	x, y := e.Get("clientX").Float(), e.Get("clientY").Float()
+	rect := w.cnv.Call("getBoundingClientRect")
+	x -= rect.Get("left").Float()
+	y -= rect.Get("top").Float()
+	w.mu.Lock()
+	scale := w.scale
+	w.mu.Unlock()
+	return f32.Point{
+		X: float32(x) * scale,
+		Y: float32(y) * scale,
+	}
+}
+
 func (w *window) addEventListener(this js.Value, event string, f func(this js.Value, args []js.Value) interface{}) {
 	jsf := w.funcOf(f)
 	this.Call("addEventListener", event, jsf)
@@ -442,6 +477,22 @@ func (w *window) WriteClipboard(s string) {
 	w.clipboard.Call("writeText", s)
 }
 
+func (w *window) WriteClipboardHTML(text, html string) {
+	item := js.Global().Get("ClipboardItem")
+	if w.clipboard.IsUndefined() || w.clipboard.Get("write").IsUndefined() || item.IsUndefined() {
+		w.WriteClipboard(text)
+		return
+	}
+	blob := func(s, typ string) js.Value {
+		return js.Global().Get("Blob").New([]interface{}{s}, map[string]interface{}{"type": typ})
+	}
+	data := item.New(map[string]interface{}{
+		"text/plain": blob(text, "text/plain"),
+		"text/html":  blob(html, "text/html"),
+	})
+	w.clipboard.Call("write", []interface{}{data})
+}
+
 func (w *window) SetCursor(name pointer.CursorName) {
 	style := w.cnv.Get("style")
 	style.Set("cursor", string(name))
@@ -459,6 +510,22 @@ func (w *window) ShowTextInput(show bool) {
 	}()
 }
 
+func (w *window) SetInputHint(hint key.InputHint) {
+	// Select the virtual keyboard with the inputmode attribute.
+	mode := "text"
+	switch hint {
+	case key.HintNumeric:
+		mode = "decimal"
+	case key.HintEmail:
+		mode = "email"
+	case key.HintURL:
+		mode = "url"
+	case key.HintTelephone:
+		mode = "tel"
+	}
+	w.tarea.Set("inputMode", mode)
+}
+
 // Close the window. Not implemented for js.
 func (w *window) Close() {}
 
@@ -157,7 +157,7 @@ func (w *window) setStage(stage system.Stage) {
 }
 
 //export gio_onKeys
-func gio_onKeys(view C.CFTypeRef, cstr *C.char, ti C.double, mods C.NSUInteger, keyDown C.bool) {
+func gio_onKeys(view C.CFTypeRef, cstr *C.char, keyCode C.ushort, ti C.double, mods C.NSUInteger, keyDown C.bool) {
 	str := C.GoString(cstr)
 	kmods := convertMods(mods)
 	ks := key.Release
@@ -169,6 +169,7 @@ func gio_onKeys(view C.CFTypeRef, cstr *C.char, ti C.double, mods C.NSUInteger,
 		if n, ok := convertKey(k); ok {
 			w.w.Event(key.Event{
 				Name:      n,
+				Physical:  keyCodeLetter(keyCode),
 				Modifiers: kmods,
 				State:     ks,
 			})
@@ -387,6 +388,20 @@ func Main() {
 	C.gio_main()
 }
 
+// keyCodeLetter returns the letter of the virtual key code on a US
+// QWERTY keyboard, or the empty string if the key is not a letter.
+func keyCodeLetter(code C.ushort) string {
+	// The letter keys from kVK_ANSI_A (0x00) to kVK_ANSI_M (0x2e).
+	const letters = "ASDFHGZXCV BQWERYT             OU IP LJ K    NM"
+	if int(code) >= len(letters) {
+		return ""
+	}
+	if l := letters[code]; l != ' ' {
+		return string(l)
+	}
+	return ""
+}
+
 func convertKey(k rune) (string, bool) {
 	var n string
 	switch k {
@@ -241,6 +241,7 @@ func windowProc(hwnd syscall.Handle, msg uint32, wParam, lParam uintptr) uintptr
 		if n, ok := convertKeyCode(wParam); ok {
 			e := key.Event{
 				Name:      n,
+				Physical:  scanCodeLetter(lParam),
 				Modifiers: getModifiers(),
 				State:     key.Press,
 			}
@@ -608,6 +609,22 @@ func (w *window) Close() {
 	windows.PostMessage(w.hwnd, windows.WM_CLOSE, 0, 0)
 }
 
+// scanCodeLetter returns the letter of the key of a keyboard message
+// on a US QWERTY keyboard, or the empty string if the key is not a
+// letter.
+func scanCodeLetter(lParam uintptr) string {
+	// The letter keys from scan codes 0x10 (Q) to 0x32 (M).
+	const letters = "QWERTYUIOP    ASDFGHJKL     ZXCVBNM"
+	code := lParam >> 16 & 0xff
+	if code < 0x10 || code >= 0x10+uintptr(len(letters)) {
+		return ""
+	}
+	if l := letters[code-0x10]; l != ' ' {
+		return string(l)
+	}
+	return ""
+}
+
 func convertKeyCode(code uintptr) (string, bool) {
 	if '0' <= code && code <= '9' || 'A' <= code && code <= 'Z' {
 		return string(rune(code)), true
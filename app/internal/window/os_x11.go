@@ -62,8 +62,13 @@ type x11Window struct {
 		targets C.Atom
 		// "CLIPBOARD".
 		clipboard C.Atom
+		// "PRIMARY".
+		primary C.Atom
 		// "CLIPBOARD_CONTENT", the clipboard destination property.
 		clipboardContent C.Atom
+		// "PRIMARY_CONTENT", the primary selection destination
+		// property.
+		primaryContent C.Atom
 		// "WM_DELETE_WINDOW"
 		evDelWindow C.Atom
 		// "ATOM"
@@ -89,6 +94,16 @@ type x11Window struct {
 		read    bool
 		write   *string
 		content []byte
+		// pending is set while a read is waiting for its
+		// SelectionNotify.
+		pending bool
+	}
+	// primary is the state of the primary selection.
+	primary struct {
+		read    bool
+		write   *string
+		content []byte
+		pending bool
 	}
 	cursor pointer.CursorName
 }
@@ -116,6 +131,20 @@ func (w *x11Window) WriteClipboard(s string) {
 	w.wakeup()
 }
 
+func (w *x11Window) ReadPrimary() {
+	w.mu.Lock()
+	w.primary.read = true
+	w.mu.Unlock()
+	w.wakeup()
+}
+
+func (w *x11Window) WritePrimary(s string) {
+	w.mu.Lock()
+	w.primary.write = &s
+	w.mu.Unlock()
+	w.wakeup()
+}
+
 func (w *x11Window) SetCursor(name pointer.CursorName) {
 	if name == pointer.CursorNone {
 		w.cursor = name
@@ -253,8 +282,13 @@ loop:
 		writeClipboard := w.clipboard.write
 		w.clipboard.read = false
 		w.clipboard.write = nil
+		readPrimary := w.primary.read
+		writePrimary := w.primary.write
+		w.primary.read = false
+		w.primary.write = nil
 		w.mu.Unlock()
 		if readClipboard {
+			w.clipboard.pending = true
 			C.XDeleteProperty(w.x, w.xw, w.atoms.clipboardContent)
 			C.XConvertSelection(w.x, w.atoms.clipboard, w.atoms.utf8string, w.atoms.clipboardContent, w.xw, C.CurrentTime)
 		}
@@ -262,6 +296,15 @@ loop:
 			w.clipboard.content = []byte(*writeClipboard)
 			C.XSetSelectionOwner(w.x, w.atoms.clipboard, w.xw, C.CurrentTime)
 		}
+		if readPrimary {
+			w.primary.pending = true
+			C.XDeleteProperty(w.x, w.xw, w.atoms.primaryContent)
+			C.XConvertSelection(w.x, w.atoms.primary, w.atoms.utf8string, w.atoms.primaryContent, w.xw, C.CurrentTime)
+		}
+		if writePrimary != nil {
+			w.primary.content = []byte(*writePrimary)
+			C.XSetSelectionOwner(w.x, w.atoms.primary, w.xw, C.CurrentTime)
+		}
 	}
 	w.w.Event(system.DestroyEvent{Err: nil})
 }
@@ -418,13 +461,26 @@ func (h *x11EventHandler) handleEvents() bool {
 			// redraw will be done by a later expose event
 		case C.SelectionNotify:
 			cevt := (*C.XSelectionEvent)(unsafe.Pointer(xev))
-			prop := w.atoms.clipboardContent
-			if cevt.property != prop {
+			var pending *bool
+			var prop C.Atom
+			switch cevt.selection {
+			case w.atoms.clipboard:
+				pending, prop = &w.clipboard.pending, w.atoms.clipboardContent
+			case w.atoms.primary:
+				pending, prop = &w.primary.pending, w.atoms.primaryContent
+			}
+			if pending == nil || !*pending {
 				break
 			}
-			if cevt.selection != w.atoms.clipboard {
+			if cevt.property == C.None {
+				// The owner refused the conversion.
+				*pending = false
 				break
 			}
+			if cevt.property != prop {
+				break
+			}
+			*pending = false
 			var text C.XTextProperty
 			if st := C.XGetTextProperty(w.x, w.xw, &text, prop); st == 0 {
 				// Failed; ignore.
@@ -435,13 +491,17 @@ func (h *x11EventHandler) handleEvents() bool {
 				break
 			}
 			str := C.GoStringN((*C.char)(unsafe.Pointer(text.value)), C.int(text.nitems))
-			w.w.Event(clipboard.Event{Text: str})
+			w.w.Event(clipboard.Event{Text: str, Primary: cevt.selection == w.atoms.primary})
 		case C.SelectionRequest:
 			cevt := (*C.XSelectionRequestEvent)(unsafe.Pointer(xev))
-			if cevt.selection != w.atoms.clipboard || cevt.property == C.None {
+			if cevt.selection != w.atoms.clipboard && cevt.selection != w.atoms.primary || cevt.property == C.None {
 				// Unsupported clipboard or obsolete requestor.
 				break
 			}
+			content := w.clipboard.content
+			if cevt.selection == w.atoms.primary {
+				content = w.primary.content
+			}
 			notify := func() {
 				var xev C.XEvent
 				ev := (*C.XSelectionEvent)(unsafe.Pointer(&xev))
@@ -474,7 +534,6 @@ func (h *x11EventHandler) handleEvents() bool {
 				// ...then notify the requestor.
 				notify()
 			case w.atoms.plaintext, w.atoms.utf8string, w.atoms.gtk_text_buffer_contents:
-				content := w.clipboard.content
 				var ptr *C.uchar
 				if len(content) > 0 {
 					ptr = (*C.uchar)(unsafe.Pointer(&content[0]))
@@ -605,7 +664,9 @@ func newX11Window(gioWin Callbacks, opts *Options) error {
 	w.atoms.gtk_text_buffer_contents = w.atom("GTK_TEXT_BUFFER_CONTENTS", false)
 	w.atoms.evDelWindow = w.atom("WM_DELETE_WINDOW", false)
 	w.atoms.clipboard = w.atom("CLIPBOARD", false)
+	w.atoms.primary = w.atom("PRIMARY", false)
 	w.atoms.clipboardContent = w.atom("CLIPBOARD_CONTENT", false)
+	w.atoms.primaryContent = w.atom("PRIMARY_CONTENT", false)
 	w.atoms.atom = w.atom("ATOM", false)
 	w.atoms.targets = w.atom("TARGETS", false)
 
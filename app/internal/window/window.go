@@ -9,6 +9,7 @@ import (
 
 	"gioui.org/gpu/backend"
 	"gioui.org/io/event"
+	"gioui.org/io/key"
 	"gioui.org/io/pointer"
 	"gioui.org/io/system"
 	"gioui.org/unit"
@@ -68,6 +69,31 @@ type Driver interface {
 	Close()
 }
 
+// PrimaryDriver is implemented by drivers for platforms with a
+// primary selection.
+type PrimaryDriver interface {
+	// ReadPrimary requests the primary selection content.
+	ReadPrimary()
+	// WritePrimary requests a primary selection write.
+	WritePrimary(s string)
+}
+
+// InputHintDriver is implemented by drivers for platforms with
+// on-screen keyboards adapting to the type of input.
+type InputHintDriver interface {
+	// SetInputHint updates the type of input expected by the
+	// virtual keyboard.
+	SetInputHint(hint key.InputHint)
+}
+
+// HTMLClipboardDriver is implemented by drivers for platforms with
+// rich clipboard contents.
+type HTMLClipboardDriver interface {
+	// WriteClipboardHTML requests a clipboard write of text along
+	// with its HTML representation.
+	WriteClipboardHTML(text, html string)
+}
+
 type windowRendezvous struct {
 	in   chan windowAndOptions
 	out  chan windowAndOptions
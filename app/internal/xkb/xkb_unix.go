@@ -159,9 +159,20 @@ func (x *Context) DispatchKey(keyCode uint32, state key.State) (events []event.E
 		x.utf8Buf = make([]byte, 1)
 	}
 	sym := C.xkb_state_key_get_one_sym(x.state, kc)
-	if name, ok := convertKeysym(sym); ok {
+	// xkb key codes are evdev codes offset by 8.
+	physical := qwertyLetter(keyCode - 8)
+	name, ok := convertKeysym(sym)
+	if !ok && physical != "" {
+		// Name letters of non-Latin layouts after their upper
+		// case form.
+		if r := rune(C.xkb_keysym_to_utf32(sym)); unicode.IsLetter(r) {
+			name, ok = string(unicode.ToUpper(r)), true
+		}
+	}
+	if ok {
 		cmd := key.Event{
 			Name:      name,
+			Physical:  physical,
 			Modifiers: x.Modifiers(),
 			State:     state,
 		}
@@ -230,6 +241,20 @@ func (x *Context) UpdateMask(depressed, latched, locked, depressedGroup, latched
 		C.xkb_layout_index_t(depressedGroup), C.xkb_layout_index_t(latchedGroup), C.xkb_layout_index_t(lockedGroup))
 }
 
+// qwertyLetter returns the letter of the evdev key code on a US QWERTY
+// keyboard, or the empty string if the key is not a letter.
+func qwertyLetter(code uint32) string {
+	// The letter keys from KEY_Q (16) to KEY_M (50).
+	const letters = "QWERTYUIOP    ASDFGHJKL     ZXCVBNM"
+	if code < 16 || code >= 16+uint32(len(letters)) {
+		return ""
+	}
+	if l := letters[code-16]; l != ' ' {
+		return string(l)
+	}
+	return ""
+}
+
 func convertKeysym(s C.xkb_keysym_t) (string, bool) {
 	if 'a' <= s && s <= 'z' {
 		return string(rune(s - 'a' + 'A')), true
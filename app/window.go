@@ -10,6 +10,7 @@ import (
 
 	"gioui.org/app/internal/window"
 	"gioui.org/io/event"
+	"gioui.org/io/key"
 	"gioui.org/io/pointer"
 	"gioui.org/io/profile"
 	"gioui.org/io/router"
@@ -50,6 +51,7 @@ type Window struct {
 
 	queue  queue
 	cursor pointer.CursorName
+	hint   key.InputHint
 
 	callbacks callbacks
 }
@@ -169,12 +171,42 @@ func (w *Window) processFrame(frameStart time.Time, size image.Point, frame *op.
 	case router.TextInputClose:
 		w.driver.ShowTextInput(false)
 	}
+	if hint := w.queue.q.TextInputHint(); hint != w.hint {
+		w.hint = hint
+		if d, ok := w.driver.(window.InputHintDriver); ok {
+			d.SetInputHint(hint)
+		}
+	}
 	if txt, ok := w.queue.q.WriteClipboard(); ok {
-		go w.WriteClipboard(txt)
+		if html := w.queue.q.ClipboardHTML(); html != "" {
+			go w.driverDo(func() {
+				if d, ok := w.driver.(window.HTMLClipboardDriver); ok {
+					d.WriteClipboardHTML(txt, html)
+				} else {
+					w.driver.WriteClipboard(txt)
+				}
+			})
+		} else {
+			go w.WriteClipboard(txt)
+		}
 	}
 	if w.queue.q.ReadClipboard() {
 		go w.ReadClipboard()
 	}
+	if txt, ok := w.queue.q.WritePrimary(); ok {
+		go w.driverDo(func() {
+			if d, ok := w.driver.(window.PrimaryDriver); ok {
+				d.WritePrimary(txt)
+			}
+		})
+	}
+	if w.queue.q.ReadPrimary() {
+		go w.driverDo(func() {
+			if d, ok := w.driver.(window.PrimaryDriver); ok {
+				d.ReadPrimary()
+			}
+		})
+	}
 	if w.queue.q.Profiling() {
 		frameDur := time.Since(frameStart)
 		frameDur = frameDur.Truncate(100 * time.Microsecond)
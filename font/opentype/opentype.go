@@ -7,6 +7,7 @@ package opentype
 import (
 	"bytes"
 	"io"
+	"sync"
 	"unicode"
 	"unicode/utf8"
 
@@ -22,7 +23,8 @@ import (
 // Font implements text.Face. It's methods are safe to use
 // concurrently.
 type Font struct {
-	font *sfnt.Font
+	font  *sfnt.Font
+	ascii asciiCache
 }
 
 // Collection is a collection of one or more fonts. When used as a text.Face,
@@ -30,6 +32,7 @@ type Font struct {
 // that supports it.
 type Collection struct {
 	fonts []*opentype
+	ascii asciiCache
 }
 
 type opentype struct {
@@ -44,6 +47,34 @@ type glyph struct {
 	Advance fixed.Int26_6
 }
 
+// asciiCache maps a ppem to the glyph data of the ASCII runes
+// at that size. It holds the maxASCIISizes most recently used sizes,
+// such that animated zooming doesn't grow it without bound. It is
+// safe for concurrent use.
+type asciiCache struct {
+	mu         sync.Mutex
+	m          map[fixed.Int26_6]*asciiElem
+	head, tail *asciiElem
+}
+
+type asciiElem struct {
+	next, prev *asciiElem
+	ppem       fixed.Int26_6
+	glyphs     *asciiGlyphs
+}
+
+const maxASCIISizes = 32
+
+// asciiGlyphs contains the font and advance for every ASCII rune,
+// allowing layout to skip the per-rune glyph lookups for the
+// overwhelmingly common case of ASCII text. It is immutable once
+// created.
+type asciiGlyphs struct {
+	fonts [utf8.RuneSelf]*opentype
+	advs  [utf8.RuneSelf]fixed.Int26_6
+	valid [utf8.RuneSelf]bool
+}
+
 // NewFont parses an SFNT font, such as TTF or OTF data, from a []byte
 // data source.
 func Parse(src []byte) (*Font, error) {
@@ -115,7 +146,8 @@ func (f *Font) Layout(ppem fixed.Int26_6, maxWidth int, txt io.Reader) ([]text.L
 	}
 	fonts := []*opentype{{Font: f.font, Hinting: font.HintingFull}}
 	var buf sfnt.Buffer
-	return layoutText(&buf, ppem, maxWidth, fonts, glyphs)
+	ascii := f.ascii.lookup(&buf, ppem, fonts)
+	return layoutText(&buf, ppem, maxWidth, fonts, ascii, glyphs)
 }
 
 func (f *Font) Shape(ppem fixed.Int26_6, str text.Layout) op.CallOp {
@@ -135,7 +167,8 @@ func (c *Collection) Layout(ppem fixed.Int26_6, maxWidth int, txt io.Reader) ([]
 		return nil, err
 	}
 	var buf sfnt.Buffer
-	return layoutText(&buf, ppem, maxWidth, c.fonts, glyphs)
+	ascii := c.ascii.lookup(&buf, ppem, c.fonts)
+	return layoutText(&buf, ppem, maxWidth, c.fonts, ascii, glyphs)
 }
 
 func (c *Collection) Shape(ppem fixed.Int26_6, str text.Layout) op.CallOp {
@@ -155,7 +188,56 @@ func fontForGlyph(buf *sfnt.Buffer, fonts []*opentype, r rune) *opentype {
 	return fonts[0] // Use replacement character from the first font if necessary
 }
 
-func layoutText(sbuf *sfnt.Buffer, ppem fixed.Int26_6, maxWidth int, fonts []*opentype, glyphs []glyph) ([]text.Line, error) {
+// lookup returns the ASCII glyph data for ppem, computing it if
+// necessary.
+func (c *asciiCache) lookup(buf *sfnt.Buffer, ppem fixed.Int26_6, fonts []*opentype) *asciiGlyphs {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if e, ok := c.m[ppem]; ok {
+		c.remove(e)
+		c.insert(e)
+		return e.glyphs
+	}
+	if c.m == nil {
+		c.m = make(map[fixed.Int26_6]*asciiElem)
+		c.head = new(asciiElem)
+		c.tail = new(asciiElem)
+		c.head.prev = c.tail
+		c.tail.next = c.head
+	}
+	g := new(asciiGlyphs)
+	for r := rune(0); r < utf8.RuneSelf; r++ {
+		f := fontForGlyph(buf, fonts, r)
+		if f == nil {
+			continue
+		}
+		g.fonts[r] = f
+		g.advs[r], g.valid[r] = f.GlyphAdvance(buf, ppem, r)
+	}
+	e := &asciiElem{ppem: ppem, glyphs: g}
+	c.m[ppem] = e
+	c.insert(e)
+	if len(c.m) > maxASCIISizes {
+		oldest := c.tail.next
+		c.remove(oldest)
+		delete(c.m, oldest.ppem)
+	}
+	return g
+}
+
+func (c *asciiCache) remove(e *asciiElem) {
+	e.next.prev = e.prev
+	e.prev.next = e.next
+}
+
+func (c *asciiCache) insert(e *asciiElem) {
+	e.next = c.head
+	e.prev = c.head.prev
+	e.prev.next = e
+	e.next.prev = e
+}
+
+func layoutText(sbuf *sfnt.Buffer, ppem fixed.Int26_6, maxWidth int, fonts []*opentype, ascii *asciiGlyphs, glyphs []glyph) ([]text.Line, error) {
 	var lines []text.Line
 	var nextLine text.Line
 	updateBounds := func(f *opentype) {
@@ -201,16 +283,24 @@ func layoutText(sbuf *sfnt.Buffer, ppem fixed.Int26_6, maxWidth int, fonts []*op
 		g := &glyphs[prev.idx]
 		next := state{
 			r:   g.Rune,
-			f:   fontForGlyph(sbuf, fonts, g.Rune),
 			idx: prev.idx + 1,
 			len: prev.len + utf8.RuneLen(g.Rune),
 			x:   prev.x + prev.adv,
 		}
-		if next.f != nil {
-			if next.f != prev.f {
+		if r := g.Rune; ascii != nil && 0 <= r && r < utf8.RuneSelf {
+			// Fast path: use the cached font and advance.
+			next.f, next.adv, next.valid = ascii.fonts[r], ascii.advs[r], ascii.valid[r]
+			if next.f != nil && next.f != prev.f {
 				updateBounds(next.f)
 			}
-			next.adv, next.valid = next.f.GlyphAdvance(sbuf, ppem, g.Rune)
+		} else {
+			next.f = fontForGlyph(sbuf, fonts, g.Rune)
+			if next.f != nil {
+				if next.f != prev.f {
+					updateBounds(next.f)
+				}
+				next.adv, next.valid = next.f.GlyphAdvance(sbuf, ppem, g.Rune)
+			}
 		}
 		if g.Rune == '\n' {
 			// The newline is zero width; use the previous
@@ -7,6 +7,7 @@ import (
 	"fmt"
 	"io/ioutil"
 	"os"
+	"reflect"
 	"strings"
 	"testing"
 
@@ -115,6 +116,56 @@ func TestEmptyString(t *testing.T) {
 	}
 }
 
+func TestASCIILayout(t *testing.T) {
+	face, err := Parse(goregular.TTF)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ppem := fixed.I(20)
+	const txt = "Hello, World! AVAV\nTo\tgo é∂"
+	var buf sfnt.Buffer
+	fonts := []*opentype{{Font: face.font, Hinting: font.HintingFull}}
+	glyphs, err := readGlyphs(strings.NewReader(txt))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want, err := layoutText(&buf, ppem, 100, fonts, nil, glyphs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := face.Layout(ppem, 100, strings.NewReader(txt))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("ASCII fast path layout mismatch:\ngot  %+v\nwant %+v", got, want)
+	}
+}
+
+func TestASCIICacheSize(t *testing.T) {
+	face, err := Parse(goregular.TTF)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var buf sfnt.Buffer
+	fonts := []*opentype{{Font: face.font, Hinting: font.HintingFull}}
+	var c asciiCache
+	first := c.lookup(&buf, fixed.I(1), fonts)
+	for i := 2; i <= 2*maxASCIISizes; i++ {
+		c.lookup(&buf, fixed.I(i), fonts)
+		// Keep the first size in use.
+		if got := c.lookup(&buf, fixed.I(1), fonts); got != first {
+			t.Fatalf("size 1 evicted after %d sizes", i)
+		}
+	}
+	if n := len(c.m); n != maxASCIISizes {
+		t.Errorf("got %d cached sizes, want %d", n, maxASCIISizes)
+	}
+	if _, ok := c.m[fixed.I(2)]; ok {
+		t.Error("least recently used size not evicted")
+	}
+}
+
 func decompressFontFile(name string) (*Font, []byte, error) {
 	f, err := os.Open(name)
 	if err != nil {
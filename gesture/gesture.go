@@ -10,6 +10,7 @@ and scrolling.
 package gesture
 
 import (
+	"image"
 	"math"
 	"runtime"
 	"time"
@@ -54,7 +55,8 @@ type ClickEvent struct {
 	Source    pointer.Source
 	Modifiers key.Modifiers
 	// NumClicks records successive clicks occurring
-	// within a short duration of each other.
+	// within a short duration of each other. For TypePress
+	// events it is the number of clicks the press is part of.
 	NumClicks int
 }
 
@@ -80,7 +82,7 @@ type Scroll struct {
 	grab      bool
 	last      int
 	// Leftover scroll.
-	scroll float32
+	scroll f32.Point
 }
 
 type ScrollState uint8
@@ -90,6 +92,9 @@ type Axis uint8
 const (
 	Horizontal Axis = iota
 	Vertical
+	// Both allows movement along both axes. It is only
+	// meaningful for Drag gestures.
+	Both
 )
 
 const (
@@ -171,7 +176,13 @@ func (c *Click) Events(q event.Queue) []ClickEvent {
 				break
 			}
 			c.pressed = true
-			events = append(events, ClickEvent{Type: TypePress, Position: e.Position, Source: e.Source, Modifiers: e.Modifiers})
+			// Report the number of clicks the press will
+			// complete, if released in time.
+			clicks := 1
+			if c.clicks > 0 && e.Time-c.clickedAt < doubleClickDuration {
+				clicks = c.clicks + 1
+			}
+			events = append(events, ClickEvent{Type: TypePress, Position: e.Position, Source: e.Source, Modifiers: e.Modifiers, NumClicks: clicks})
 		case pointer.Leave:
 			if !c.pressed {
 				c.pid = e.PointerID
@@ -210,11 +221,28 @@ func (s *Scroll) Stop() {
 }
 
 // Scroll detects the scrolling distance from the available events and
-// ongoing fling gestures.
+// ongoing fling gestures. Vertical wheel movements with the Shift
+// modifier held scroll a Horizontal axis.
 func (s *Scroll) Scroll(cfg unit.Metric, q event.Queue, t time.Time, axis Axis) int {
+	d := s.scrollDist(cfg, q, t, axis, false)
+	if axis == Horizontal {
+		return d.X
+	}
+	return d.Y
+}
+
+// ScrollBoth is like Scroll, except that it also reports mouse wheel
+// and trackpad scrolling across axis. Touch drags and flings scroll
+// along axis only. Vertical wheel movements with the Shift modifier
+// held scroll horizontally.
+func (s *Scroll) ScrollBoth(cfg unit.Metric, q event.Queue, t time.Time, axis Axis) image.Point {
+	return s.scrollDist(cfg, q, t, axis, true)
+}
+
+func (s *Scroll) scrollDist(cfg unit.Metric, q event.Queue, t time.Time, axis Axis, both bool) image.Point {
 	if s.axis != axis {
 		s.axis = axis
-		return 0
+		return image.Point{}
 	}
 	total := 0
 	for _, evt := range q.Events(s) {
@@ -255,15 +283,18 @@ func (s *Scroll) Scroll(cfg unit.Metric, q event.Queue, t time.Time, axis Axis)
 			if e.Priority < pointer.Foremost {
 				continue
 			}
-			switch s.axis {
-			case Horizontal:
-				s.scroll += e.Scroll.X
-			case Vertical:
-				s.scroll += e.Scroll.Y
+			d := e.Scroll
+			if e.Modifiers.Contain(key.ModShift) && d.X == 0 && (both || s.axis == Horizontal) {
+				d.X, d.Y = d.Y, 0
+			}
+			switch {
+			case both:
+				s.scroll = s.scroll.Add(d)
+			case s.axis == Horizontal:
+				s.scroll.X += d.X
+			case s.axis == Vertical:
+				s.scroll.Y += d.Y
 			}
-			iscroll := int(s.scroll)
-			s.scroll -= float32(iscroll)
-			total += iscroll
 		case pointer.Drag:
 			if !s.dragging || s.pid != e.PointerID {
 				continue
@@ -284,7 +315,15 @@ func (s *Scroll) Scroll(cfg unit.Metric, q event.Queue, t time.Time, axis Axis)
 		}
 	}
 	total += s.flinger.Tick(t)
-	return total
+	// Keep the fractional wheel scroll for the next call.
+	wheel := image.Point{X: int(s.scroll.X), Y: int(s.scroll.Y)}
+	s.scroll = s.scroll.Sub(f32.Point{X: float32(wheel.X), Y: float32(wheel.Y)})
+	if s.axis == Horizontal {
+		wheel.X += total
+	} else {
+		wheel.Y += total
+	}
+	return wheel
 }
 
 func (s *Scroll) val(p f32.Point) float32 {
@@ -377,6 +416,8 @@ func (a Axis) String() string {
 		return "Horizontal"
 	case Vertical:
 		return "Vertical"
+	case Both:
+		return "Both"
 	default:
 		panic("invalid Axis")
 	}
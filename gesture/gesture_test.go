@@ -1,13 +1,17 @@
 package gesture
 
 import (
+	"image"
 	"testing"
 	"time"
 
+	"gioui.org/f32"
 	"gioui.org/io/event"
+	"gioui.org/io/key"
 	"gioui.org/io/pointer"
 	"gioui.org/io/router"
 	"gioui.org/op"
+	"gioui.org/unit"
 )
 
 func TestMouseClicks(t *testing.T) {
@@ -60,6 +64,34 @@ func TestMouseClicks(t *testing.T) {
 	}
 }
 
+func TestScrollShift(t *testing.T) {
+	var (
+		scroll Scroll
+		ops    op.Ops
+		r      router.Router
+	)
+	// The first call sets the axis.
+	scroll.Scroll(unit.Metric{}, &r, time.Time{}, Horizontal)
+	scroll.Add(&ops)
+	r.Frame(&ops)
+	r.Add(
+		pointer.Event{Type: pointer.Scroll, Scroll: f32.Point{Y: 5}, Modifiers: key.ModShift},
+		pointer.Event{Type: pointer.Scroll, Scroll: f32.Point{X: 2}},
+	)
+	if got, want := scroll.Scroll(unit.Metric{}, &r, time.Time{}, Horizontal), 7; got != want {
+		t.Errorf("got horizontal scroll %d, want %d", got, want)
+	}
+
+	scroll.ScrollBoth(unit.Metric{}, &r, time.Time{}, Vertical)
+	r.Add(
+		pointer.Event{Type: pointer.Scroll, Scroll: f32.Point{Y: 3}},
+		pointer.Event{Type: pointer.Scroll, Scroll: f32.Point{Y: 4}, Modifiers: key.ModShift},
+	)
+	if got, want := scroll.ScrollBoth(unit.Metric{}, &r, time.Time{}, Vertical), image.Pt(4, 3); got != want {
+		t.Errorf("got scroll %v, want %v", got, want)
+	}
+}
+
 func mouseClickEvents(times ...time.Duration) []event.Event {
 	press := pointer.Event{
 		Type:    pointer.Press,
@@ -34,6 +34,7 @@ const (
 	TypePath
 	TypeStroke
 	TypeDash
+	TypeTransferTarget
 )
 
 const (
@@ -49,9 +50,9 @@ const (
 	TypeAreaLen            = 1 + 1 + 4*4
 	TypePointerInputLen    = 1 + 1 + 1
 	TypePassLen            = 1 + 1
-	TypeClipboardReadLen   = 1
-	TypeClipboardWriteLen  = 1
-	TypeKeyInputLen        = 1
+	TypeClipboardReadLen   = 1 + 1
+	TypeClipboardWriteLen  = 1 + 1
+	TypeKeyInputLen        = 1 + 1
 	TypeKeyFocusLen        = 1 + 1
 	TypeKeySoftKeyboardLen = 1 + 1
 	TypePushLen            = 1
@@ -63,6 +64,7 @@ const (
 	TypePathLen            = 1 + 4
 	TypeStrokeLen          = 1 + 4 + 4 + 1 + 1
 	TypeDashLen            = 1 + 4 + 1
+	TypeTransferTargetLen  = 1
 )
 
 func (t OpType) Size() int {
@@ -93,14 +95,15 @@ func (t OpType) Size() int {
 		TypePathLen,
 		TypeStrokeLen,
 		TypeDashLen,
+		TypeTransferTargetLen,
 	}[t-firstOpIndex]
 }
 
 func (t OpType) NumRefs() int {
 	switch t {
-	case TypeKeyInput, TypePointerInput, TypeProfile, TypeCall, TypeClipboardRead, TypeClipboardWrite, TypeCursor:
+	case TypeKeyInput, TypePointerInput, TypeProfile, TypeCall, TypeClipboardRead, TypeCursor, TypeTransferTarget:
 		return 1
-	case TypeImage:
+	case TypeImage, TypeClipboardWrite:
 		return 2
 	default:
 		return 0
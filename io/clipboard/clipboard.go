@@ -11,27 +11,49 @@ import (
 // Event is generated when the clipboard content is requested.
 type Event struct {
 	Text string
+	// Primary reports whether Text is the content of the
+	// primary selection.
+	Primary bool
 }
 
 // ReadOp requests the text of the clipboard, delivered to
 // the current handler through an Event.
 type ReadOp struct {
 	Tag event.Tag
+	// Primary selects the primary selection instead of the
+	// clipboard. The primary selection is the most recently
+	// selected text on platforms such as X11, and is usually
+	// pasted by a middle click. On other platforms, reads from
+	// the primary selection are ignored.
+	Primary bool
 }
 
 // WriteOp copies Text to the clipboard.
 type WriteOp struct {
 	Text string
+	// HTML, if set, is an HTML representation of Text, written
+	// alongside it on platforms with rich clipboard contents.
+	HTML string
+	// Primary selects the primary selection instead of the
+	// clipboard. On platforms without a primary selection,
+	// writes to it are ignored.
+	Primary bool
 }
 
 func (h ReadOp) Add(o *op.Ops) {
 	data := o.Write1(opconst.TypeClipboardReadLen, h.Tag)
 	data[0] = byte(opconst.TypeClipboardRead)
+	if h.Primary {
+		data[1] = 1
+	}
 }
 
 func (h WriteOp) Add(o *op.Ops) {
-	data := o.Write1(opconst.TypeClipboardWriteLen, &h.Text)
+	data := o.Write2(opconst.TypeClipboardWriteLen, &h.Text, &h.HTML)
 	data[0] = byte(opconst.TypeClipboardWrite)
+	if h.Primary {
+		data[1] = 1
+	}
 }
 
 func (Event) ImplementsEvent() {}
@@ -23,8 +23,33 @@ import (
 // focused key handler.
 type InputOp struct {
 	Tag event.Tag
+	// Hint describes the type of text expected by Tag, for
+	// selecting the layout of on-screen keyboards.
+	Hint InputHint
 }
 
+// InputHint describes the type of text expected by an input handler.
+type InputHint uint8
+
+const (
+	// HintAny hints that any input is expected.
+	HintAny InputHint = iota
+	// HintText hints that text input is expected. It may activate
+	// auto-correction and suggestions.
+	HintText
+	// HintNumeric hints that numeric input is expected.
+	HintNumeric
+	// HintEmail hints that email input is expected.
+	HintEmail
+	// HintURL hints that URL input is expected.
+	HintURL
+	// HintTelephone hints that telephone number input is expected.
+	HintTelephone
+	// HintPassword hints that password input is expected. It may
+	// disable auto-correction and suggestions.
+	HintPassword
+)
+
 // SoftKeyboardOp shows or hide the on-screen keyboard, if available.
 type SoftKeyboardOp struct {
 	Show bool
@@ -51,6 +76,11 @@ type Event struct {
 	// modifiers are ignored. For example, the "shift-1" and "ctrl-shift-1"
 	// combinations both give the Name "!" with the US keyboard layout.
 	Name string
+	// Physical is the name of the letter key at the same position on
+	// a US QWERTY keyboard, regardless of the keyboard layout. It is
+	// empty for other keys and on platforms that don't report the
+	// position of keys.
+	Physical string
 	// Modifiers is the set of active modifiers when the key was pressed.
 	Modifiers Modifiers
 	// State is the state of the key when the event was fired.
@@ -62,6 +92,17 @@ type EditEvent struct {
 	Text string
 }
 
+// A PreeditEvent is generated while an input method composes text,
+// before the result is committed with an EditEvent. Only the browser
+// port generates PreeditEvents so far; elsewhere input methods commit
+// their text directly.
+type PreeditEvent struct {
+	// Text is the text being composed. It replaces the text of
+	// any earlier PreeditEvent. Empty Text ends the composition
+	// without committing any text.
+	Text string
+}
+
 // State is the state of a key during an event.
 type State uint8
 
@@ -121,6 +162,7 @@ func (m Modifiers) Contain(m2 Modifiers) bool {
 func (h InputOp) Add(o *op.Ops) {
 	data := o.Write1(opconst.TypeKeyInputLen, h.Tag)
 	data[0] = byte(opconst.TypeKeyInput)
+	data[1] = byte(h.Hint)
 }
 
 func (h SoftKeyboardOp) Add(o *op.Ops) {
@@ -139,9 +181,10 @@ func (h FocusOp) Add(o *op.Ops) {
 	}
 }
 
-func (EditEvent) ImplementsEvent()  {}
-func (Event) ImplementsEvent()      {}
-func (FocusEvent) ImplementsEvent() {}
+func (EditEvent) ImplementsEvent()    {}
+func (PreeditEvent) ImplementsEvent() {}
+func (Event) ImplementsEvent()        {}
+func (FocusEvent) ImplementsEvent()   {}
 
 func (e Event) String() string {
 	return fmt.Sprintf("%v %v %v}", e.Name, e.Modifiers, e.State)
@@ -13,7 +13,11 @@ type clipboardQueue struct {
 	// request avoid read clipboard every frame while waiting.
 	requested bool
 	text      *string
-	reader    ops.Reader
+	// html is the HTML representation of the text most recently
+	// returned by WriteClipboard.
+	html     *string
+	lastHTML string
+	reader   ops.Reader
 }
 
 // WriteClipboard returns the most recent text to be copied
@@ -23,10 +27,17 @@ func (q *clipboardQueue) WriteClipboard() (string, bool) {
 		return "", false
 	}
 	text := *q.text
-	q.text = nil
+	q.lastHTML = *q.html
+	q.text, q.html = nil, nil
 	return text, true
 }
 
+// ClipboardHTML returns the HTML representation of the text most
+// recently returned by WriteClipboard, or the empty string.
+func (q *clipboardQueue) ClipboardHTML() string {
+	return q.lastHTML
+}
+
 // ReadClipboard reports if any new handler is waiting
 // to read the clipboard.
 func (q *clipboardQueue) ReadClipboard() bool {
@@ -49,6 +60,7 @@ func (q *clipboardQueue) ProcessWriteClipboard(d []byte, refs []interface{}) {
 		panic("invalid op")
 	}
 	q.text = refs[0].(*string)
+	q.html = refs[1].(*string)
 }
 
 func (q *clipboardQueue) ProcessReadClipboard(d []byte, refs []interface{}) {
@@ -102,6 +102,25 @@ func TestQueueProcessWriteClipboard(t *testing.T) {
 	ops.Reset()
 }
 
+func TestQueuePrimary(t *testing.T) {
+	ops, router := new(op.Ops), new(Router)
+	var handler [2]int
+	clipboard.ReadOp{Tag: &handler[0]}.Add(ops)
+	clipboard.ReadOp{Tag: &handler[1], Primary: true}.Add(ops)
+	clipboard.WriteOp{Text: "primary", Primary: true}.Add(ops)
+	router.Frame(ops)
+	assertClipboardWriteOp(t, router, "")
+	if text, ok := router.WritePrimary(); !ok || text != "primary" {
+		t.Errorf("got primary %q, %v, expected %q", text, ok, "primary")
+	}
+	if !router.ReadPrimary() {
+		t.Error("missing primary request")
+	}
+	router.Add(clipboard.Event{Text: "Text", Primary: true})
+	assertClipboardEvent(t, router.Events(&handler[0]), false)
+	assertClipboardEvent(t, router.Events(&handler[1]), true)
+}
+
 func assertClipboardEvent(t *testing.T, events []event.Event, expected bool) {
 	t.Helper()
 	var evtClipboard int
@@ -17,6 +17,7 @@ type keyQueue struct {
 	handlers map[event.Tag]*keyHandler
 	reader   ops.Reader
 	state    TextInputState
+	hint     key.InputHint
 }
 
 type keyHandler struct {
@@ -24,6 +25,7 @@ type keyHandler struct {
 	// in the current frame.
 	visible bool
 	new     bool
+	hint    key.InputHint
 }
 
 type listenerPriority uint8
@@ -47,6 +49,12 @@ func (q *keyQueue) InputState() TextInputState {
 	return q.state
 }
 
+// InputHint returns the input hint of the focused handler as
+// determined in Frame.
+func (q *keyQueue) InputHint() key.InputHint {
+	return q.hint
+}
+
 func (q *keyQueue) Frame(root *op.Ops, events *handlerEvents) {
 	if q.handlers == nil {
 		q.handlers = make(map[event.Tag]*keyHandler)
@@ -86,6 +94,10 @@ func (q *keyQueue) Frame(root *op.Ops, events *handlerEvents) {
 		}
 	}
 	q.state = keyboard
+	q.hint = key.HintAny
+	if h, ok := q.handlers[q.focus]; ok {
+		q.hint = h.hint
+	}
 }
 
 func (q *keyQueue) Push(e event.Event, events *handlerEvents) {
@@ -123,6 +135,7 @@ loop:
 				q.handlers[op.Tag] = h
 			}
 			h.visible = true
+			h.hint = op.Hint
 			tag = op.Tag
 		case opconst.TypePush:
 			newK, newPri, newKeyboard := q.resolveFocus(events)
@@ -149,7 +162,8 @@ func decodeKeyInputOp(d []byte, refs []interface{}) key.InputOp {
 		panic("invalid op")
 	}
 	return key.InputOp{
-		Tag: refs[0].(event.Tag),
+		Tag:  refs[0].(event.Tag),
+		Hint: key.InputHint(d[1]),
 	}
 }
 
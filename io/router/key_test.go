@@ -87,6 +87,28 @@ func TestKeySoftKeyboardNoFocus(t *testing.T) {
 	assertKeyboard(t, r, TextInputOpen)
 }
 
+func TestKeyInputHint(t *testing.T) {
+	handlers := make([]int, 2)
+	ops := new(op.Ops)
+	r := new(Router)
+
+	key.InputOp{Tag: &handlers[0], Hint: key.HintEmail}.Add(ops)
+	key.InputOp{Tag: &handlers[1], Hint: key.HintNumeric}.Add(ops)
+	key.FocusOp{Focus: true}.Add(ops)
+	r.Frame(ops)
+	if got := r.TextInputHint(); got != key.HintNumeric {
+		t.Errorf("got hint %v, want HintNumeric", got)
+	}
+
+	ops.Reset()
+	key.InputOp{Tag: &handlers[0], Hint: key.HintEmail}.Add(ops)
+	key.FocusOp{Focus: false}.Add(ops)
+	r.Frame(ops)
+	if got := r.TextInputHint(); got != key.HintAny {
+		t.Errorf("got hint %v without focus, want HintAny", got)
+	}
+}
+
 func TestKeyRemoveFocus(t *testing.T) {
 	handlers := make([]int, 2)
 	ops := new(op.Ops)
@@ -11,6 +11,7 @@ import (
 	"gioui.org/internal/ops"
 	"gioui.org/io/event"
 	"gioui.org/io/pointer"
+	"gioui.org/io/transfer"
 	"gioui.org/op"
 )
 
@@ -18,6 +19,7 @@ type pointerQueue struct {
 	hitTree  []hitNode
 	areas    []areaNode
 	cursors  []cursorNode
+	targets  []targetNode
 	cursor   pointer.CursorName
 	handlers map[event.Tag]*pointerHandler
 	pointers []pointerInfo
@@ -41,6 +43,12 @@ type cursorNode struct {
 	area int
 }
 
+// targetNode is a drop target.
+type targetNode struct {
+	tag  event.Tag
+	area int
+}
+
 type pointerInfo struct {
 	id       pointer.ID
 	pressed  bool
@@ -126,6 +134,11 @@ func (q *pointerQueue) collectHandlers(r *ops.Reader, events *handlerEvents, t f
 				name: encOp.Refs[0].(pointer.CursorName),
 				area: len(q.areas) - 1,
 			})
+		case opconst.TypeTransferTarget:
+			q.targets = append(q.targets, targetNode{
+				tag:  encOp.Refs[0].(event.Tag),
+				area: area,
+			})
 		}
 	}
 }
@@ -190,6 +203,7 @@ func (q *pointerQueue) Frame(root *op.Ops, events *handlerEvents) {
 	q.hitTree = q.hitTree[:0]
 	q.areas = q.areas[:0]
 	q.cursors = q.cursors[:0]
+	q.targets = q.targets[:0]
 	q.reader.Reset(root)
 	q.collectHandlers(&q.reader, events, f32.Affine2D{}, -1, -1, false)
 	for k, h := range q.handlers {
@@ -291,6 +305,19 @@ func (q *pointerQueue) Push(e pointer.Event, events *handlerEvents) {
 	}
 }
 
+// Drop delivers e to the topmost drop target under its position.
+func (q *pointerQueue) Drop(e transfer.DropEvent, events *handlerEvents) {
+	for i := len(q.targets) - 1; i >= 0; i-- {
+		t := q.targets[i]
+		if !q.hit(t.area, e.Position) {
+			continue
+		}
+		e.Position = q.invTransform(t.area, e.Position)
+		events.Add(t.tag, e)
+		return
+	}
+}
+
 func (q *pointerQueue) deliverEvent(p *pointerInfo, events *handlerEvents, e pointer.Event) {
 	foremost := true
 	for _, k := range p.handlers {
@@ -11,6 +11,7 @@ import (
 	"gioui.org/f32"
 	"gioui.org/io/event"
 	"gioui.org/io/pointer"
+	"gioui.org/io/transfer"
 	"gioui.org/op"
 )
 
@@ -416,6 +417,46 @@ func TestMultitouch(t *testing.T) {
 	assertEventSequence(t, r.Events(h2), pointer.Cancel, pointer.Enter, pointer.Press, pointer.Release)
 }
 
+func TestTransferDrop(t *testing.T) {
+	outer, inner := new(int), new(int)
+	var ops op.Ops
+	pointer.Rect(image.Rect(0, 0, 100, 100)).Add(&ops)
+	transfer.TargetOp{Tag: outer}.Add(&ops)
+	st := op.Push(&ops)
+	op.Offset(f32.Pt(50, 50)).Add(&ops)
+	pointer.Rect(image.Rect(0, 0, 20, 20)).Add(&ops)
+	transfer.TargetOp{Tag: inner}.Add(&ops)
+	st.Pop()
+
+	var r Router
+	r.Frame(&ops)
+	tests := []struct {
+		pos   f32.Point
+		tag   event.Tag
+		local f32.Point
+	}{
+		{pos: f32.Pt(10, 10), tag: outer, local: f32.Pt(10, 10)},
+		{pos: f32.Pt(60, 55), tag: inner, local: f32.Pt(10, 5)},
+		{pos: f32.Pt(150, 10)},
+	}
+	for _, test := range tests {
+		r.Add(transfer.DropEvent{Position: test.pos, Text: "text"})
+		for _, tag := range []event.Tag{outer, inner} {
+			evts := r.Events(tag)
+			if tag != test.tag {
+				if len(evts) > 0 {
+					t.Errorf("drop at %v: unexpected events %v", test.pos, evts)
+				}
+				continue
+			}
+			want := []event.Event{transfer.DropEvent{Position: test.local, Text: "text"}}
+			if !reflect.DeepEqual(evts, want) {
+				t.Errorf("drop at %v: got %v, want %v", test.pos, evts, want)
+			}
+		}
+	}
+}
+
 // addPointerHandler adds a pointer.InputOp for the tag in a
 // rectangular area.
 func addPointerHandler(ops *op.Ops, tag event.Tag, area image.Rectangle) {
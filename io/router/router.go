@@ -21,6 +21,7 @@ import (
 	"gioui.org/io/key"
 	"gioui.org/io/pointer"
 	"gioui.org/io/profile"
+	"gioui.org/io/transfer"
 	"gioui.org/op"
 )
 
@@ -30,6 +31,8 @@ type Router struct {
 	pqueue pointerQueue
 	kqueue keyQueue
 	cqueue clipboardQueue
+	// primary is the queue for the primary selection.
+	primary clipboardQueue
 
 	handlers handlerEvents
 
@@ -88,10 +91,16 @@ func (q *Router) Add(events ...event.Event) bool {
 			q.profile = e
 		case pointer.Event:
 			q.pqueue.Push(e, &q.handlers)
-		case key.EditEvent, key.Event, key.FocusEvent:
+		case transfer.DropEvent:
+			q.pqueue.Drop(e, &q.handlers)
+		case key.EditEvent, key.PreeditEvent, key.Event, key.FocusEvent:
 			q.kqueue.Push(e, &q.handlers)
 		case clipboard.Event:
-			q.cqueue.Push(e, &q.handlers)
+			if e.Primary {
+				q.primary.Push(e, &q.handlers)
+			} else {
+				q.cqueue.Push(e, &q.handlers)
+			}
 		}
 	}
 	return q.handlers.HadEvents()
@@ -103,18 +112,43 @@ func (q *Router) TextInputState() TextInputState {
 	return q.kqueue.InputState()
 }
 
+// TextInputHint returns the input hint of the focused key handler
+// from the most recent call to Frame.
+func (q *Router) TextInputHint() key.InputHint {
+	return q.kqueue.InputHint()
+}
+
 // WriteClipboard returns the most recent text to be copied
 // to the clipboard, if any.
 func (q *Router) WriteClipboard() (string, bool) {
 	return q.cqueue.WriteClipboard()
 }
 
+// ClipboardHTML returns the HTML representation of the text most
+// recently returned by WriteClipboard, or the empty string if none
+// was given.
+func (q *Router) ClipboardHTML() string {
+	return q.cqueue.ClipboardHTML()
+}
+
 // ReadClipboard reports if any new handler is waiting
 // to read the clipboard.
 func (q *Router) ReadClipboard() bool {
 	return q.cqueue.ReadClipboard()
 }
 
+// WritePrimary returns the most recent text to be copied
+// to the primary selection, if any.
+func (q *Router) WritePrimary() (string, bool) {
+	return q.primary.WriteClipboard()
+}
+
+// ReadPrimary reports if any new handler is waiting
+// to read the primary selection.
+func (q *Router) ReadPrimary() bool {
+	return q.primary.ReadClipboard()
+}
+
 // Cursor returns the last cursor set.
 func (q *Router) Cursor() pointer.CursorName {
 	return q.pqueue.cursor
@@ -137,13 +171,22 @@ func (q *Router) collect() {
 			q.profiling = true
 			q.profHandlers[op.Tag] = struct{}{}
 		case opconst.TypeClipboardRead:
-			q.cqueue.ProcessReadClipboard(encOp.Data, encOp.Refs)
+			q.clipboardQueue(encOp.Data).ProcessReadClipboard(encOp.Data, encOp.Refs)
 		case opconst.TypeClipboardWrite:
-			q.cqueue.ProcessWriteClipboard(encOp.Data, encOp.Refs)
+			q.clipboardQueue(encOp.Data).ProcessWriteClipboard(encOp.Data, encOp.Refs)
 		}
 	}
 }
 
+// clipboardQueue returns the queue for the clipboard operation
+// encoded in d.
+func (q *Router) clipboardQueue(d []byte) *clipboardQueue {
+	if d[1] != 0 {
+		return &q.primary
+	}
+	return &q.cqueue
+}
+
 // Profiling reports whether there was profile handlers in the
 // most recent Frame call.
 func (q *Router) Profiling() bool {
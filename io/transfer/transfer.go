@@ -0,0 +1,42 @@
+// SPDX-License-Identifier: Unlicense OR MIT
+
+/*
+Package transfer implements drag and drop of data from other
+applications.
+
+A TargetOp declares a handler for dropped data in the current pointer
+area, and the handler receives a DropEvent when data is dropped onto
+it. Drops are routed to the topmost target whose area contains the
+drop position.
+*/
+package transfer
+
+import (
+	"gioui.org/f32"
+	"gioui.org/internal/opconst"
+	"gioui.org/io/event"
+	"gioui.org/op"
+)
+
+// DropEvent is generated when text is dropped onto a target.
+type DropEvent struct {
+	// Position is the drop position in the coordinate space of
+	// the target.
+	Position f32.Point
+	// Text is the dropped text. Dropped files are delivered as
+	// their paths, one per line.
+	Text string
+}
+
+// TargetOp declares a handler for drops in the current pointer
+// area.
+type TargetOp struct {
+	Tag event.Tag
+}
+
+func (op TargetOp) Add(o *op.Ops) {
+	data := o.Write1(opconst.TypeTransferTargetLen, op.Tag)
+	data[0] = byte(opconst.TypeTransferTarget)
+}
+
+func (DropEvent) ImplementsEvent() {}
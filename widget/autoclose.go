@@ -0,0 +1,83 @@
+// SPDX-License-Identifier: Unlicense OR MIT
+
+package widget
+
+import (
+	"unicode"
+	"unicode/utf8"
+)
+
+// defaultClosePairs are the pairs closed by AutoClose if ClosePairs
+// is empty.
+const defaultClosePairs = `()[]{}""''`
+
+// closePair returns the closing rune of the pair opened by r, if any,
+// and reports whether r closes a pair.
+func (e *Editor) closePair(r rune) (close rune, opens, closes bool) {
+	pairs := []rune(e.ClosePairs)
+	if len(pairs) == 0 {
+		pairs = []rune(defaultClosePairs)
+	}
+	for i := 0; i+1 < len(pairs); i += 2 {
+		if pairs[i] == r {
+			close, opens = pairs[i+1], true
+		}
+		if pairs[i+1] == r {
+			closes = true
+		}
+	}
+	return close, opens, closes
+}
+
+// typeText inserts the text s typed at the caret. If AutoClose is
+// set, a single typed opening rune of ClosePairs wraps the selection
+// in its pair or inserts the closing rune after the caret, and a
+// typed closing rune steps over the same rune after the caret.
+func (e *Editor) typeText(s string) {
+	r, n := utf8.DecodeRuneInString(s)
+	if !e.AutoClose || n != len(s) {
+		e.append(s)
+		return
+	}
+	close, opens, closes := e.closePair(r)
+	start, end := e.selectionBytes()
+	switch {
+	case opens && start != end:
+		sel := e.rr.substring(start, end)
+		e.replace(start, end, s+sel+string(close))
+		e.moveCaret(start+n, start+n+len(sel))
+	case closes && start == end && end < e.rr.len() && e.runeAfter(end) == r:
+		e.moveCaret(end+n, end+n)
+	case opens && start == end && e.canClose(end, r == close):
+		e.replace(start, end, s+string(close))
+		e.moveCaret(start+n, start+n)
+	default:
+		e.append(s)
+	}
+}
+
+// canClose reports whether a pair opened at the byte offset idx is
+// closed automatically, which it is if it is followed by whitespace,
+// a closing rune or nothing. A quote, whose opening and closing runes
+// are the same, must not follow a word.
+func (e *Editor) canClose(idx int, quote bool) bool {
+	if idx < e.rr.len() {
+		next := e.runeAfter(idx)
+		if _, _, closes := e.closePair(next); !closes && !unicode.IsSpace(next) {
+			return false
+		}
+	}
+	if quote && idx > 0 {
+		prev, _ := e.rr.runeBefore(idx)
+		if e.wordClass(prev) == classWord {
+			return false
+		}
+	}
+	return true
+}
+
+// runeAfter returns the rune at the byte offset idx.
+func (e *Editor) runeAfter(idx int) rune {
+	r, _ := e.rr.runeAt(idx)
+	return r
+}
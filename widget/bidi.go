@@ -0,0 +1,343 @@
+// SPDX-License-Identifier: Unlicense OR MIT
+
+package widget
+
+import (
+	"strings"
+	"unicode"
+
+	"gioui.org/text"
+
+	"golang.org/x/image/math/fixed"
+)
+
+// rtlScripts are the scripts written right to left.
+var rtlScripts = []*unicode.RangeTable{
+	unicode.Arabic,
+	unicode.Hebrew,
+	unicode.Nko,
+	unicode.Samaritan,
+	unicode.Mandaic,
+	unicode.Syriac,
+	unicode.Thaana,
+	unicode.Adlam,
+}
+
+// direction returns the direction of a strongly directional rune r.
+// The ok result is false for runes without a strong direction.
+func direction(r rune) (rtl, ok bool) {
+	switch {
+	case unicode.In(r, rtlScripts...):
+		return true, true
+	case unicode.IsLetter(r):
+		return false, true
+	}
+	return false, false
+}
+
+// bidiClass is the bidirectional class of a rune, reduced to the
+// classes distinguished by bidiLevels.
+type bidiClass uint8
+
+const (
+	bidiNeutral bidiClass = iota
+	bidiL
+	bidiR
+	// bidiEN is a European number.
+	bidiEN
+)
+
+// bidiLine is the visual order of a line with right-to-left text.
+type bidiLine struct {
+	// levels are the embedding levels of the runes in logical
+	// order. Odd levels are right to left.
+	levels []uint8
+	// order is the logical index of the rune at each visual
+	// position.
+	order []int
+	// visual is the layout of the line in visual order.
+	visual text.Layout
+}
+
+// classify returns the bidirectional class of r.
+func classify(r rune) bidiClass {
+	if rtl, ok := direction(r); ok {
+		if rtl {
+			return bidiR
+		}
+		return bidiL
+	}
+	if '0' <= r && r <= '9' || unicode.Is(unicode.Nd, r) {
+		return bidiEN
+	}
+	return bidiNeutral
+}
+
+// bidiLevels resolves the embedding levels of the runes of a line in
+// a paragraph of direction rtl. It implements the subset of the
+// Unicode bidirectional algorithm without explicit embeddings and
+// arabic numbers: numbers following left to right text are left to
+// right (W7), neutrals between runs of the same direction take that
+// direction and the paragraph direction otherwise (N1, N2), and
+// trailing whitespace is at the paragraph level (L1).
+func bidiLevels(s string, rtl bool) []uint8 {
+	classes := make([]bidiClass, 0, len(s))
+	base, prev := uint8(0), bidiL
+	if rtl {
+		base, prev = 1, bidiR
+	}
+	for _, r := range s {
+		c := classify(r)
+		switch c {
+		case bidiL, bidiR:
+			prev = c
+		case bidiEN:
+			if prev == bidiL {
+				c = bidiL
+			}
+		}
+		classes = append(classes, c)
+	}
+	// strong returns the direction of c for resolving neutrals.
+	strong := func(c bidiClass) bidiClass {
+		if c == bidiEN {
+			return bidiR
+		}
+		return c
+	}
+	sos := bidiL
+	if rtl {
+		sos = bidiR
+	}
+	for i := 0; i < len(classes); {
+		if classes[i] != bidiNeutral {
+			i++
+			continue
+		}
+		j := i
+		for j < len(classes) && classes[j] == bidiNeutral {
+			j++
+		}
+		before, after := sos, sos
+		if i > 0 {
+			before = strong(classes[i-1])
+		}
+		if j < len(classes) {
+			after = strong(classes[j])
+		}
+		dir := sos
+		if before == after {
+			dir = before
+		}
+		for ; i < j; i++ {
+			classes[i] = dir
+		}
+	}
+	// Right to left runs are at level 1, and numbers and left to
+	// right runs inside right to left paragraphs at level 2 (I1, I2).
+	levels := make([]uint8, len(classes))
+	for i, c := range classes {
+		switch c {
+		case bidiR:
+			levels[i] = 1
+		case bidiEN:
+			levels[i] = 2
+		default:
+			levels[i] = 2 * base
+		}
+	}
+	runes := []rune(s)
+	for i := len(runes) - 1; i >= 0 && unicode.IsSpace(runes[i]); i-- {
+		levels[i] = base
+	}
+	return levels
+}
+
+// visualOrder returns the logical index of the rune at each visual
+// position of a line with the embedding levels, by reversing every
+// run at or above each odd level (L2).
+func visualOrder(levels []uint8) []int {
+	order := make([]int, len(levels))
+	var hi, lo uint8 = 0, 0xff
+	for i, l := range levels {
+		order[i] = i
+		if l > hi {
+			hi = l
+		}
+		if l&1 == 1 && l < lo {
+			lo = l
+		}
+	}
+	for lvl := hi; lvl >= lo && lvl > 0; lvl-- {
+		for i := 0; i < len(order); {
+			if levels[order[i]] < lvl {
+				i++
+				continue
+			}
+			j := i
+			for j < len(order) && levels[order[j]] >= lvl {
+				j++
+			}
+			for a, b := i, j-1; a < b; a, b = a+1, b-1 {
+				order[a], order[b] = order[b], order[a]
+			}
+			i = j
+		}
+	}
+	return order
+}
+
+// mirror returns the mirrored glyph of the bracket r, for display in
+// right-to-left runs.
+func mirror(r rune) rune {
+	switch r {
+	case '(':
+		return ')'
+	case ')':
+		return '('
+	case '[':
+		return ']'
+	case ']':
+		return '['
+	case '{':
+		return '}'
+	case '}':
+		return '{'
+	case '<':
+		return '>'
+	case '>':
+		return '<'
+	case '«':
+		return '»'
+	case '»':
+		return '«'
+	}
+	return r
+}
+
+// newBidiLine computes the visual order of the line l in a paragraph
+// of direction rtl. It reports false if the line is displayed in
+// logical order.
+func newBidiLine(l text.Layout, rtl bool) (bidiLine, bool) {
+	if !rtl && strings.IndexFunc(l.Text, func(r rune) bool {
+		d, ok := direction(r)
+		return ok && d
+	}) == -1 {
+		return bidiLine{}, false
+	}
+	b := bidiLine{levels: bidiLevels(l.Text, rtl)}
+	b.order = visualOrder(b.levels)
+	runes := []rune(l.Text)
+	var txt strings.Builder
+	b.visual.Advances = make([]fixed.Int26_6, len(b.order))
+	for v, j := range b.order {
+		r := runes[j]
+		if b.levels[j]&1 == 1 {
+			r = mirror(r)
+		}
+		txt.WriteRune(r)
+		b.visual.Advances[v] = l.Advances[j]
+	}
+	b.visual.Text = txt.String()
+	return b, true
+}
+
+// edges returns the left edge of every rune of the line with the
+// advances adv, in logical order.
+func (b bidiLine) edges(adv []fixed.Int26_6) []fixed.Int26_6 {
+	left := make([]fixed.Int26_6, len(adv))
+	var x fixed.Int26_6
+	for _, j := range b.order {
+		left[j] = x
+		x += adv[j]
+	}
+	return left
+}
+
+// caretX returns the offset from the line start of the caret before
+// the rune with logical index col, given the left edges of the runes.
+// The caret is at the leading edge of the rune, which is its right
+// edge in right-to-left runs, or at the trailing edge of the last
+// rune at the end of the line.
+func (b bidiLine) caretX(adv, left []fixed.Int26_6, col int) fixed.Int26_6 {
+	if len(adv) == 0 {
+		return 0
+	}
+	after := col >= len(adv)
+	if after {
+		col = len(adv) - 1
+	}
+	x := left[col]
+	if rtl := b.levels[col]&1 == 1; rtl != after {
+		x += adv[col]
+	}
+	return x
+}
+
+// visualCaret recomputes the horizontal caret position in reordered
+// lines, where it doesn't follow from the advances of the runes
+// before the caret.
+func (e *Editor) visualCaret() {
+	l := e.lines.entry(e.caret.line)
+	b := l.bidi
+	if b == nil {
+		return
+	}
+	adv := l.Layout.Advances
+	e.caret.x = e.lineAlign(l) + b.caretX(adv, b.edges(adv), e.caret.col)
+}
+
+// lineEntries returns the entries of lines, which must be whole
+// paragraphs, with their paragraph directions and the visual order
+// of lines with right-to-left text.
+// Following the Unicode bidirectional algorithm, the direction of a
+// paragraph is the direction of its first strongly directional rune,
+// and left to right if it has none.
+func lineEntries(lines []text.Line) []lineEntry {
+	entries := make([]lineEntry, len(lines))
+	start := 0
+	for i, l := range lines {
+		entries[i].Line = l
+		if i < len(lines)-1 && !strings.HasSuffix(l.Layout.Text, "\n") {
+			continue
+		}
+		// Lines start through i form a paragraph.
+		rtl := false
+	search:
+		for _, pl := range lines[start : i+1] {
+			for _, r := range pl.Layout.Text {
+				if d, ok := direction(r); ok {
+					rtl = d
+					break search
+				}
+			}
+		}
+		for ; start <= i; start++ {
+			entries[start].rtl = rtl
+			if b, ok := newBidiLine(lines[start].Layout, rtl); ok {
+				entries[start].bidi = &b
+			}
+		}
+	}
+	return entries
+}
+
+// mirrorAlignment swaps the Start and End alignments of
+// right-to-left lines.
+func mirrorAlignment(a text.Alignment, rtl bool) text.Alignment {
+	if !rtl {
+		return a
+	}
+	switch a {
+	case text.Start:
+		return text.End
+	case text.End:
+		return text.Start
+	}
+	return a
+}
+
+// lineAlign returns the horizontal offset of the line l.
+func (e *Editor) lineAlign(l lineEntry) fixed.Int26_6 {
+	return align(mirrorAlignment(e.Alignment, l.rtl), l.Width, e.viewSize.X)
+}
@@ -0,0 +1,119 @@
+// SPDX-License-Identifier: Unlicense OR MIT
+
+package widget
+
+import (
+	"image"
+	"strings"
+
+	"golang.org/x/image/math/fixed"
+)
+
+// block is the state of a rectangular selection, started by dragging
+// with Alt held.
+type block struct {
+	active bool
+	// start and end are the corners of the selection in text
+	// coordinates.
+	start, end image.Point
+}
+
+// blockRanges returns the byte ranges of the rectangular selection,
+// one per line.
+func (e *Editor) blockRanges() [][2]int {
+	e.makeValid()
+	l0, l1 := e.lineAt(e.block.start.Y), e.lineAt(e.block.end.Y)
+	if l0 > l1 {
+		l0, l1 = l1, l0
+	}
+	x0, x1 := fixed.I(e.block.start.X), fixed.I(e.block.end.X)
+	if x0 > x1 {
+		x0, x1 = x1, x0
+	}
+	var rs [][2]int
+	it := e.lines.atLine(l0)
+	idx := e.rr.byteOffset(it.m.runes)
+	for {
+		l, m, ok := it.next()
+		if !ok || m.line > l1 {
+			break
+		}
+		if r := l.run; r != nil {
+			idx += r.bytes
+			continue
+		}
+		advs := l.Layout.Advances
+		if strings.HasSuffix(l.Layout.Text, "\n") {
+			advs = advs[:len(advs)-1]
+		}
+		// at returns the offset of the rune boundary closest to x.
+		at := func(x fixed.Int26_6) int {
+			p := idx
+			cx := e.lineAlign(l)
+			for _, adv := range advs {
+				if cx+adv/2 >= x {
+					break
+				}
+				cx += adv
+				_, s := e.rr.runeAt(p)
+				p += s
+			}
+			return p
+		}
+		rs = append(rs, [2]int{at(x0), at(x1)})
+		for range l.Layout.Advances {
+			_, s := e.rr.runeAt(idx)
+			idx += s
+		}
+	}
+	return rs
+}
+
+// blockText returns the text of the rectangular selection, with the
+// lines separated by newlines.
+func (e *Editor) blockText() string {
+	var b strings.Builder
+	for i, r := range e.blockRanges() {
+		if i > 0 {
+			b.WriteByte('\n')
+		}
+		b.WriteString(e.rr.substring(r[0], r[1]))
+	}
+	return b.String()
+}
+
+// takeBlock deletes the text of the rectangular selection, if any,
+// and replaces it with a caret on every line, for typing over the
+// block. It reports whether any text was deleted.
+func (e *Editor) takeBlock() bool {
+	if !e.block.active {
+		return false
+	}
+	rs := e.blockRanges()
+	e.block.active = false
+	e.history.seal()
+	e.BeginGroup()
+	// Delete from the end to keep the offsets of the remaining
+	// ranges valid.
+	deleted := false
+	for i := len(rs) - 1; i >= 0; i-- {
+		if rs[i][0] != rs[i][1] {
+			e.replace(rs[i][0], rs[i][1], "")
+			deleted = true
+		}
+	}
+	e.EndGroup()
+	e.carets = e.carets[:0]
+	shift := 0
+	for i, r := range rs {
+		c := r[0] - shift
+		shift += r[1] - r[0]
+		if i == len(rs)-1 {
+			e.setSelection(c, c)
+		} else {
+			e.carets = append(e.carets, c)
+		}
+	}
+	e.mergeCarets()
+	return deleted
+}
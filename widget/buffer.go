@@ -3,31 +3,71 @@
 package widget
 
 import (
+	"bytes"
 	"fmt"
 	"io"
+	"sort"
 	"strings"
 	"unicode/utf8"
 )
 
 const bufferDebug = false
 
-// editBuffer implements a gap buffer for text editing.
+// editBuffer implements a piece table for text editing. The text is
+// the concatenation of pieces, each a slice of an append-only buffer.
+// Edits append the inserted text to the buffer and splice the pieces,
+// so their cost doesn't depend on the length of the text. Pieces may
+// also refer to a file, whose text is paged in when needed, such that
+// the text of files larger than memory is edited without loading it.
+//
+// The byte, rune and newline offsets of the pieces are indexed, such
+// that conversions between byte and rune offsets, and finding the
+// starts of lines, are binary searches over the pieces followed by a
+// scan of at most maxPieceLen bytes.
 type editBuffer struct {
 	// caret is the caret position in bytes.
 	caret int
 	// pos is the byte position for Read and ReadRune.
 	pos int
 
-	// The gap start and end in bytes.
-	gapstart, gapend int
-	text             []byte
+	// buf holds the text of the pieces, except for the pieces of
+	// file.
+	buf    []byte
+	file   *pagedFile
+	pieces []piece
+	// length is the length of the text in bytes, and runes its
+	// length in runes.
+	length, runes int
+	// starts holds the offsets of the starts of the pieces. Only
+	// the first indexed entries are valid; edits invalidate the
+	// entries from the edited piece on.
+	starts  []pieceStart
+	indexed int
 
 	// changed tracks whether the buffer content
 	// has changed since the last call to Changed.
 	changed bool
 }
 
-const minSpace = 5
+// piece is the range of buf, or of the file of the buffer if file is
+// set, between off and off+len, containing runes runes and lines
+// newlines.
+type piece struct {
+	off, len, runes, lines int
+	file                   bool
+}
+
+// pieceStart is the byte, rune and newline offset of the start of a
+// piece.
+type pieceStart struct {
+	bytes, runes, lines int
+}
+
+// maxPieceLen is the length in bytes above which inserted text is
+// not added to an existing piece, and is split into several pieces.
+// It bounds the cost of splitting a piece and of converting offsets
+// inside it.
+const maxPieceLen = 1 << 16
 
 func (e *editBuffer) Changed() bool {
 	c := e.changed
@@ -35,64 +75,202 @@ func (e *editBuffer) Changed() bool {
 	return c
 }
 
-func (e *editBuffer) deleteRunes(runes int) {
-	e.moveGap(0)
-	for ; runes < 0 && e.gapstart > 0; runes++ {
-		_, s := utf8.DecodeLastRune(e.text[:e.gapstart])
-		e.gapstart -= s
-		e.caret -= s
-		e.changed = e.changed || s > 0
-	}
-	for ; runes > 0 && e.gapend < len(e.text); runes-- {
-		_, s := utf8.DecodeRune(e.text[e.gapend:])
-		e.gapend += s
-		e.changed = e.changed || s > 0
-	}
+// replace replaces the bytes between start and end with s, and moves
+// the caret to the end of the inserted text.
+func (e *editBuffer) replace(start, end int, s string) {
+	e.splice(start, end, s)
+	e.caret = start + len(s)
+	e.changed = e.changed || start != end || len(s) > 0
 	e.dump()
 }
 
-// moveGap moves the gap to the caret position. After returning,
-// the gap is guaranteed to be at least space bytes long.
-func (e *editBuffer) moveGap(space int) {
-	if e.gapLen() < space {
-		if space < minSpace {
-			space = minSpace
-		}
-		txt := make([]byte, e.len()+space)
-		// Expand to capacity.
-		txt = txt[:cap(txt)]
-		gaplen := len(txt) - e.len()
-		if e.caret > e.gapstart {
-			copy(txt, e.text[:e.gapstart])
-			copy(txt[e.caret+gaplen:], e.text[e.caret:])
-			copy(txt[e.gapstart:], e.text[e.gapend:e.caret+e.gapLen()])
+// splice replaces the bytes between start and end with s.
+func (e *editBuffer) splice(start, end int, s string) {
+	i := e.split(start)
+	j := e.split(end)
+	removed := 0
+	for _, p := range e.pieces[i:j] {
+		removed += p.runes
+	}
+	var ins []piece
+	if len(s) > 0 {
+		runes := utf8.RuneCountInString(s)
+		off := len(e.buf)
+		e.buf = append(e.buf, s...)
+		if i > 0 && !e.pieces[i-1].file && e.pieces[i-1].off+e.pieces[i-1].len == off && e.pieces[i-1].len+len(s) <= maxPieceLen {
+			// Extend the previous piece, as when typing.
+			e.pieces[i-1].len += len(s)
+			e.pieces[i-1].runes += runes
+			e.pieces[i-1].lines += strings.Count(s, "\n")
 		} else {
-			copy(txt, e.text[:e.caret])
-			copy(txt[e.gapstart+gaplen:], e.text[e.gapend:])
-			copy(txt[e.caret+gaplen:], e.text[e.caret:e.gapstart])
+			ins = newPieces(off, s)
 		}
-		e.text = txt
-		e.gapstart = e.caret
-		e.gapend = e.gapstart + gaplen
-	} else {
-		if e.caret > e.gapstart {
-			copy(e.text[e.gapstart:], e.text[e.gapend:e.caret+e.gapLen()])
-		} else {
-			copy(e.text[e.caret+e.gapLen():], e.text[e.caret:e.gapstart])
+		e.runes += runes
+	}
+	e.pieces = append(e.pieces[:i], append(ins, e.pieces[j:]...)...)
+	e.length += len(s) - (end - start)
+	e.runes -= removed
+	e.invalidate(i)
+}
+
+// newPieces returns the pieces of the text s stored at the offset
+// off of buf, split at rune boundaries into pieces of at most
+// maxPieceLen bytes.
+func newPieces(off int, s string) []piece {
+	var ps []piece
+	for len(s) > 0 {
+		n := len(s)
+		if n > maxPieceLen {
+			n = maxPieceLen
+			// Back off to the start of the rune at n. Bytes further
+			// than utf8.UTFMax from a rune start are invalid and
+			// split anywhere.
+			for i := n; i > n-utf8.UTFMax; i-- {
+				if utf8.RuneStart(s[i]) {
+					n = i
+					break
+				}
+			}
 		}
-		l := e.gapLen()
-		e.gapstart = e.caret
-		e.gapend = e.gapstart + l
+		ps = append(ps, piece{off: off, len: n, runes: utf8.RuneCountInString(s[:n]), lines: strings.Count(s[:n], "\n")})
+		off += n
+		s = s[n:]
 	}
-	e.dump()
+	return ps
 }
 
-func (e *editBuffer) len() int {
-	return len(e.text) - e.gapLen()
+// split splits the piece containing the byte offset idx, if needed,
+// and returns the index of the piece starting at idx.
+func (e *editBuffer) split(idx int) int {
+	i, start := e.find(idx)
+	if i == len(e.pieces) || start == idx {
+		return i
+	}
+	p := e.pieces[i]
+	n := idx - start
+	// Count the runes and newlines of the shorter part.
+	var runes, lines int
+	if c := e.chunk(i); n <= p.len/2 {
+		runes = utf8.RuneCount(c[:n])
+		lines = bytes.Count(c[:n], newline)
+	} else {
+		runes = p.runes - utf8.RuneCount(c[n:])
+		lines = p.lines - bytes.Count(c[n:], newline)
+	}
+	e.pieces = append(e.pieces, piece{})
+	copy(e.pieces[i+2:], e.pieces[i+1:])
+	e.pieces[i] = piece{off: p.off, len: n, runes: runes, lines: lines, file: p.file}
+	e.pieces[i+1] = piece{off: p.off + n, len: p.len - n, runes: p.runes - runes, lines: p.lines - lines, file: p.file}
+	e.invalidate(i + 1)
+	return i + 1
+}
+
+// invalidate invalidates the index from the piece with index i on.
+func (e *editBuffer) invalidate(i int) {
+	if i < e.indexed {
+		e.indexed = i
+	}
+}
+
+// index updates the offsets of the starts of the pieces.
+func (e *editBuffer) index() {
+	n := len(e.pieces)
+	if e.indexed == n {
+		return
+	}
+	if cap(e.starts) < n {
+		starts := make([]pieceStart, n, n+n/2)
+		copy(starts, e.starts[:e.indexed])
+		e.starts = starts
+	}
+	e.starts = e.starts[:n]
+	var s pieceStart
+	if i := e.indexed; i > 0 {
+		p := e.pieces[i-1]
+		s = e.starts[i-1]
+		s.bytes += p.len
+		s.runes += p.runes
+		s.lines += p.lines
+	}
+	for i := e.indexed; i < n; i++ {
+		e.starts[i] = s
+		s.bytes += e.pieces[i].len
+		s.runes += e.pieces[i].runes
+		s.lines += e.pieces[i].lines
+	}
+	e.indexed = n
 }
 
-func (e *editBuffer) gapLen() int {
-	return e.gapend - e.gapstart
+// find returns the index of the piece containing the byte offset
+// idx, and the offset of its start. If idx is at the end of the
+// text, find returns the number of pieces and the text length.
+func (e *editBuffer) find(idx int) (int, int) {
+	e.index()
+	i := sort.Search(len(e.pieces), func(i int) bool {
+		return idx < e.starts[i].bytes+e.pieces[i].len
+	})
+	if i == len(e.pieces) {
+		return i, e.length
+	}
+	return i, e.starts[i].bytes
+}
+
+// byteOffset returns the byte offset of the rune offset runes,
+// clamped to the buffer bounds.
+func (e *editBuffer) byteOffset(runes int) int {
+	if runes <= 0 {
+		return 0
+	}
+	if runes >= e.runes {
+		return e.length
+	}
+	e.index()
+	i := sort.Search(len(e.pieces), func(i int) bool {
+		return runes < e.starts[i].runes+e.pieces[i].runes
+	})
+	idx := e.starts[i].bytes
+	c := e.chunk(i)
+	for n := runes - e.starts[i].runes; n > 0; n-- {
+		_, s := utf8.DecodeRune(c)
+		c = c[s:]
+		idx += s
+	}
+	return idx
+}
+
+// chunk returns the text of the piece with index i.
+func (e *editBuffer) chunk(i int) []byte {
+	return pieceText(e.buf, e.file, e.pieces[i])
+}
+
+// pieceText returns the text of the piece p of buf or file.
+func pieceText(buf []byte, file *pagedFile, p piece) []byte {
+	if p.file {
+		return file.slice(p.off, p.len)
+	}
+	return buf[p.off : p.off+p.len]
+}
+
+// moveRunes returns the byte offset runes away from idx, clamped to
+// the buffer bounds. The sign of runes specifies the direction.
+func (e *editBuffer) moveRunes(idx, runes int) int {
+	if runes > 64 || runes < -64 || idx == 0 {
+		// Convert through the index rather than stepping.
+		return e.byteOffset(e.runeOffset(idx) + runes)
+	}
+	for ; runes < 0 && idx > 0; runes++ {
+		_, s := e.runeBefore(idx)
+		idx -= s
+	}
+	for ; runes > 0 && idx < e.len(); runes-- {
+		_, s := e.runeAt(idx)
+		idx += s
+	}
+	return idx
+}
+
+func (e *editBuffer) len() int {
+	return e.length
 }
 
 func (e *editBuffer) Reset() {
@@ -104,20 +282,13 @@ func (e *editBuffer) Read(p []byte) (int, error) {
 		return 0, io.EOF
 	}
 	var total int
-	if e.pos < e.gapstart {
-		n := copy(p, e.text[e.pos:e.gapstart])
+	for len(p) > 0 && e.pos < e.len() {
+		i, start := e.find(e.pos)
+		n := copy(p, e.chunk(i)[e.pos-start:])
 		p = p[n:]
 		total += n
 		e.pos += n
 	}
-	if e.pos >= e.gapstart {
-		n := copy(p, e.text[e.pos+e.gapLen():])
-		total += n
-		e.pos += n
-	}
-	if e.pos > e.len() {
-		panic("hey!")
-	}
 	return total, nil
 }
 
@@ -133,35 +304,267 @@ func (e *editBuffer) ReadRune() (rune, int, error) {
 func (e *editBuffer) String() string {
 	var b strings.Builder
 	b.Grow(e.len())
-	b.Write(e.text[:e.gapstart])
-	b.Write(e.text[e.gapend:])
+	for i := range e.pieces {
+		b.Write(e.chunk(i))
+	}
+	return b.String()
+}
+
+// runeOffset returns the number of runes before the byte offset idx.
+func (e *editBuffer) runeOffset(idx int) int {
+	if idx <= 0 {
+		return 0
+	}
+	i, start := e.find(idx)
+	if i == len(e.pieces) {
+		return e.runes
+	}
+	return e.starts[i].runes + utf8.RuneCount(e.chunk(i)[:idx-start])
+}
+
+// newline is the line terminator counted by the index.
+var newline = []byte{'\n'}
+
+// newlines returns the number of newline characters in the buffer.
+func (e *editBuffer) newlines() int {
+	return e.linesBefore(e.length)
+}
+
+// linesBefore returns the number of newline characters before the
+// byte offset idx.
+func (e *editBuffer) linesBefore(idx int) int {
+	i, start := e.find(idx)
+	if i == len(e.pieces) {
+		if i == 0 {
+			return 0
+		}
+		p := e.pieces[i-1]
+		return e.starts[i-1].lines + p.lines
+	}
+	return e.starts[i].lines + bytes.Count(e.chunk(i)[:idx-start], newline)
+}
+
+// lineStart returns the byte offset of the start of the line
+// following the nth newline character, or the length of the text if
+// there are fewer newlines.
+func (e *editBuffer) lineStart(n int) int {
+	if n <= 0 {
+		return 0
+	}
+	e.index()
+	i := sort.Search(len(e.pieces), func(i int) bool {
+		return n <= e.starts[i].lines+e.pieces[i].lines
+	})
+	if i == len(e.pieces) {
+		return e.length
+	}
+	idx := e.starts[i].bytes
+	c := e.chunk(i)
+	for k := n - e.starts[i].lines; k > 0; k-- {
+		j := bytes.IndexByte(c, '\n') + 1
+		c = c[j:]
+		idx += j
+	}
+	return idx
+}
+
+// substring returns the text between the byte offsets start and end.
+func (e *editBuffer) substring(start, end int) string {
+	var b strings.Builder
+	b.Grow(end - start)
+	for start < end {
+		i, pstart := e.find(start)
+		c := e.chunk(i)[start-pstart:]
+		if len(c) > end-start {
+			c = c[:end-start]
+		}
+		b.Write(c)
+		start += len(c)
+	}
 	return b.String()
 }
 
+// prepend inserts s at the caret, leaving the caret before it.
 func (e *editBuffer) prepend(s string) {
-	e.moveGap(len(s))
-	copy(e.text[e.caret:], s)
-	e.gapstart += len(s)
+	e.splice(e.caret, e.caret, s)
 	e.changed = e.changed || len(s) > 0
 	e.dump()
 }
 
+// extend appends s to the text, leaving the caret in place.
+func (e *editBuffer) extend(s string) {
+	e.splice(e.length, e.length, s)
+	e.changed = e.changed || len(s) > 0
+	e.dump()
+}
+
+// extendFile appends the text p, read from the offset off of the file
+// of the buffer, as pieces referring to the file. The text is not
+// retained.
+func (e *editBuffer) extendFile(off int, p []byte) {
+	e.invalidate(len(e.pieces))
+	for len(p) > 0 {
+		n := len(p)
+		if n > maxPieceLen {
+			n = maxPieceLen
+			// Back off to the start of the rune at n, as in
+			// newPieces.
+			for i := n; i > n-utf8.UTFMax; i-- {
+				if utf8.RuneStart(p[i]) {
+					n = i
+					break
+				}
+			}
+		}
+		pc := piece{off: off, len: n, runes: utf8.RuneCount(p[:n]), lines: bytes.Count(p[:n], newline), file: true}
+		e.pieces = append(e.pieces, pc)
+		e.file.addBlock(off, n)
+		e.length += n
+		e.runes += pc.runes
+		off += n
+		p = p[n:]
+	}
+	e.changed = true
+	e.dump()
+}
+
 func (e *editBuffer) dump() {
 	if bufferDebug {
-		fmt.Printf("len(e.text) %d e.len() %d e.gapstart %d e.gapend %d e.caret %d txt:\n'%+x'<-%d->'%+x'\n", len(e.text), e.len(), e.gapstart, e.gapend, e.caret, e.text[:e.gapstart], e.gapLen(), e.text[e.gapend:])
+		fmt.Printf("e.len() %d pieces %v e.caret %d txt: %q\n", e.len(), e.pieces, e.caret, e.String())
 	}
 }
 
 func (e *editBuffer) runeBefore(idx int) (rune, int) {
-	if idx > e.gapstart {
-		idx += e.gapLen()
+	if idx <= 0 {
+		return utf8.RuneError, 0
 	}
-	return utf8.DecodeLastRune(e.text[:idx])
+	i, start := e.find(idx - 1)
+	return utf8.DecodeLastRune(e.chunk(i)[:idx-start])
 }
 
 func (e *editBuffer) runeAt(idx int) (rune, int) {
-	if idx >= e.gapstart {
-		idx += e.gapLen()
+	if idx >= e.len() {
+		return utf8.RuneError, 0
+	}
+	i, start := e.find(idx)
+	return utf8.DecodeRune(e.chunk(i)[idx-start:])
+}
+
+// reader returns a reader of the text as of the call. Later edits
+// don't affect the reader, because they never modify the bytes of
+// the pieces. If crlf is set, the reader expands line terminators to
+// "\r\n".
+func (e *editBuffer) reader(crlf bool) *bufferReader {
+	return &bufferReader{
+		buf:    e.buf,
+		file:   e.file,
+		pieces: append([]piece(nil), e.pieces...),
+		crlf:   crlf,
 	}
-	return utf8.DecodeRune(e.text[idx:])
 }
+
+// bufferReader reads a snapshot of the text of an editBuffer.
+type bufferReader struct {
+	buf    []byte
+	file   *pagedFile
+	pieces []piece
+	crlf   bool
+	// lf tracks whether the '\n' of an expanded line terminator
+	// is yet to be read.
+	lf bool
+}
+
+// next returns the text up to and including the next line
+// terminator, if expanding terminators, or the rest of the current
+// piece.
+func (r *bufferReader) next() []byte {
+	p := r.pieces[0]
+	c := pieceText(r.buf, r.file, p)
+	if r.crlf {
+		if i := bytes.IndexByte(c, '\n'); i >= 0 {
+			c = c[:i+1]
+		}
+	}
+	r.pieces[0] = piece{off: p.off + len(c), len: p.len - len(c), file: p.file}
+	if r.pieces[0].len == 0 {
+		r.pieces = r.pieces[1:]
+	}
+	return c
+}
+
+func (r *bufferReader) Read(p []byte) (int, error) {
+	n := 0
+	for len(p) > 0 {
+		if r.lf {
+			p[0] = '\n'
+			p = p[1:]
+			n++
+			r.lf = false
+			continue
+		}
+		if len(r.pieces) == 0 {
+			break
+		}
+		c := r.pieces[0]
+		m := c.len
+		if m > len(p) {
+			m = len(p)
+		}
+		chunk := pieceText(r.buf, r.file, c)[:m]
+		if r.crlf {
+			if i := bytes.IndexByte(chunk, '\n'); i >= 0 {
+				chunk = chunk[:i]
+				m = i + 1
+				r.lf = true
+			}
+		}
+		k := copy(p, chunk)
+		if r.lf {
+			p[k] = '\r'
+			k++
+		}
+		p = p[k:]
+		n += k
+		r.pieces[0] = piece{off: c.off + m, len: c.len - m, file: c.file}
+		if r.pieces[0].len == 0 {
+			r.pieces = r.pieces[1:]
+		}
+	}
+	if n == 0 && len(p) > 0 {
+		return 0, io.EOF
+	}
+	return n, nil
+}
+
+// WriteTo implements io.WriterTo. The pieces are written without
+// copying.
+func (r *bufferReader) WriteTo(w io.Writer) (int64, error) {
+	var total int64
+	if r.lf {
+		n, err := w.Write([]byte{'\n'})
+		total += int64(n)
+		if err != nil {
+			return total, err
+		}
+		r.lf = false
+	}
+	for len(r.pieces) > 0 {
+		c := r.next()
+		if r.crlf && c[len(c)-1] == '\n' {
+			n, err := w.Write(c[:len(c)-1])
+			total += int64(n)
+			if err != nil {
+				return total, err
+			}
+			c = crlf
+		}
+		n, err := w.Write(c)
+		total += int64(n)
+		if err != nil {
+			return total, err
+		}
+	}
+	return total, nil
+}
+
+var crlf = []byte("\r\n")
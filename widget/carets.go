@@ -0,0 +1,92 @@
+// SPDX-License-Identifier: Unlicense OR MIT
+
+package widget
+
+import "sort"
+
+// AddCaret adds a caret at the rune offset offset, in addition to
+// the primary caret. Typing, deletion, paste and caret movement
+// apply at every caret. Carets at the same position are merged.
+func (e *Editor) AddCaret(offset int) {
+	e.carets = append(e.carets, e.rr.moveRunes(0, offset))
+	e.mergeCarets()
+}
+
+// Carets returns the rune offsets of the carets added by AddCaret or
+// by Ctrl/Cmd-clicking, in increasing order. The primary caret is
+// not included.
+func (e *Editor) Carets() []int {
+	if len(e.carets) == 0 {
+		return nil
+	}
+	offs := make([]int, len(e.carets))
+	idx, n := 0, 0
+	for i, c := range e.carets {
+		for idx < c {
+			_, s := e.rr.runeAt(idx)
+			idx += s
+			n++
+		}
+		offs[i] = n
+	}
+	return offs
+}
+
+// CollapseCarets removes all carets but the primary caret. Pressing
+// Escape collapses the carets as well.
+func (e *Editor) CollapseCarets() {
+	e.carets = e.carets[:0]
+}
+
+// forEachCaret calls f for each caret, with the caret set to it. The
+// primary caret and its selection are visited last. Modifications
+// made by f are undone as a single step.
+func (e *Editor) forEachCaret(f func()) {
+	if len(e.carets) == 0 {
+		f()
+		return
+	}
+	e.BeginGroup()
+	defer e.EndGroup()
+	// Track the primary caret and anchor alongside the other
+	// carets while they are modified.
+	n := len(e.carets)
+	xoff := e.caret.xoff
+	e.carets = append(e.carets, e.caret.anchor, e.rr.caret)
+	for i := 0; i < n; i++ {
+		e.moveCaret(e.carets[i], e.carets[i])
+		f()
+		e.carets[i] = e.rr.caret
+	}
+	e.moveCaret(e.carets[n], e.carets[n+1])
+	e.caret.xoff = xoff
+	e.carets = e.carets[:n]
+	f()
+	e.mergeCarets()
+}
+
+// moveCaret sets the selection to the byte offsets anchor and caret,
+// updating the caret position if the layout is valid.
+func (e *Editor) moveCaret(anchor, caret int) {
+	e.caret.anchor = anchor
+	e.rr.caret = caret
+	e.caret.xoff = 0
+	if e.valid {
+		e.caret.line, e.caret.col, e.caret.x, e.caret.y = e.layoutCaret()
+	}
+}
+
+// mergeCarets sorts the carets and removes duplicates and carets
+// inside the primary selection.
+func (e *Editor) mergeCarets() {
+	sort.Ints(e.carets)
+	start, end := e.selectionBytes()
+	cs := e.carets[:0]
+	for _, c := range e.carets {
+		if start <= c && c <= end || len(cs) > 0 && c == cs[len(cs)-1] {
+			continue
+		}
+		cs = append(cs, c)
+	}
+	e.carets = cs
+}
@@ -0,0 +1,55 @@
+// SPDX-License-Identifier: Unlicense OR MIT
+
+package widget
+
+import (
+	"image"
+)
+
+// CaretRect returns the bounds of the caret, relative to the editor
+// origin and adjusted for scrolling. The bounds have zero width.
+// Popups such as completion lists are anchored to it.
+func (e *Editor) CaretRect() image.Rectangle {
+	e.makeValid()
+	return e.caretRect().Sub(e.scrollOff).Add(image.Point{X: e.gutter.width, Y: e.valign})
+}
+
+// caretRect returns the bounds of the caret in text coordinates.
+func (e *Editor) caretRect() image.Rectangle {
+	line, _, x, y := e.locate(e.rr.caret)
+	l := e.lines.entry(line)
+	return image.Rectangle{
+		Min: image.Point{X: x.Round(), Y: y - l.Ascent.Ceil()},
+		Max: image.Point{X: x.Round(), Y: y + l.Descent.Ceil()},
+	}
+}
+
+// CompletionPrefix returns the run of word runes before the caret,
+// such as the partial identifier to complete, and its start as a
+// rune offset. Letters, digits, connectors and the runes of WordChars
+// are word runes. The prefix is empty if the rune before the caret is
+// not a word rune.
+func (e *Editor) CompletionPrefix() (start int, prefix string) {
+	end := e.rr.caret
+	idx := end
+	for idx > 0 {
+		r, s := e.rr.runeBefore(idx)
+		if e.wordClass(r) != classWord {
+			break
+		}
+		idx -= s
+	}
+	return e.rr.runeOffset(idx), e.rr.substring(idx, end)
+}
+
+// Complete replaces the text between the rune offset start and the
+// caret with s, such as the prefix returned by CompletionPrefix with
+// an accepted completion. The replacement is undone as a single step,
+// and the caret is placed after it.
+func (e *Editor) Complete(start int, s string) {
+	bstart := e.rr.moveRunes(0, start)
+	e.history.seal()
+	e.replace(bstart, e.rr.caret, s)
+	e.history.seal()
+	e.caret.scroll = true
+}
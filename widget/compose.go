@@ -0,0 +1,120 @@
+// SPDX-License-Identifier: Unlicense OR MIT
+
+package widget
+
+import (
+	"image"
+
+	"gioui.org/layout"
+	"gioui.org/op"
+	"gioui.org/op/clip"
+	"gioui.org/op/paint"
+)
+
+// composition tracks the text being composed by an input method.
+// The text is part of the editor contents while it is composed, but
+// it is not recorded in the undo history until it is committed.
+type composition struct {
+	active bool
+	// start and end are the byte offsets of the composed text.
+	start, end int
+	// rev is the revision of the contents before the composition.
+	rev int
+}
+
+// compose replaces the composed text with s, starting a composition
+// at the selection if none is active. Empty s ends the composition.
+func (e *Editor) compose(s string) {
+	c := &e.composing
+	if !c.active {
+		if s == "" || e.readOnlyEdit(e.selectionBytes()) {
+			return
+		}
+		if start, end := e.selectionBytes(); start != end {
+			e.replace(start, end, "")
+		}
+		e.history.seal()
+		c.active = true
+		c.start, c.end = e.rr.caret, e.rr.caret
+		c.rev = e.rev.cur
+	}
+	e.modify(c.start, c.end, s)
+	c.end = c.start + len(s)
+	if s == "" {
+		c.active = false
+		e.rev.cur = c.rev
+	}
+	e.caret.scroll = true
+}
+
+// endComposition removes the composed text, if any.
+func (e *Editor) endComposition() {
+	if e.composing.active {
+		e.compose("")
+	}
+}
+
+// commitComposition keeps the composed text, if any, as if it was
+// typed.
+func (e *Editor) commitComposition() {
+	c := &e.composing
+	if !c.active {
+		return
+	}
+	s := e.rr.substring(c.start, c.end)
+	e.endComposition()
+	e.replace(c.start, c.start, s)
+	e.history.seal()
+}
+
+// Composition returns the rune offsets of the text being composed by
+// an input method, and whether a composition is active.
+func (e *Editor) Composition() (start, end int, ok bool) {
+	c := e.composing
+	if !c.active {
+		return 0, 0, false
+	}
+	return e.rr.runeOffset(c.start), e.rr.runeOffset(c.end), true
+}
+
+// CompositionRect returns the bounds of the first line of the text
+// being composed, or of the caret if no text is composed, relative to
+// the editor origin and adjusted for scrolling. Input method candidate
+// windows are positioned next to it. The bounds of the caret have
+// zero width.
+func (e *Editor) CompositionRect() image.Rectangle {
+	e.makeValid()
+	var r image.Rectangle
+	if c := e.composing; c.active && c.start != c.end {
+		if rs := e.regions(c.start, c.end); len(rs) > 0 {
+			r = rs[0]
+		}
+	} else {
+		r = e.caretRect()
+	}
+	return r.Sub(e.scrollOff).Add(image.Point{X: e.gutter.width, Y: e.valign})
+}
+
+// paintComposition underlines the composed text in the current
+// color.
+func (e *Editor) paintComposition(gtx layout.Context, cl image.Rectangle) {
+	c := e.composing
+	if !c.active || c.start == c.end {
+		return
+	}
+	thickness := (e.textSize / 16).Ceil()
+	if thickness < 1 {
+		thickness = 1
+	}
+	for _, r := range e.regions(c.start, c.end) {
+		r.Min.Y = r.Max.Y - thickness
+		r = r.Sub(e.scrollOff).Intersect(cl)
+		if r.Empty() {
+			continue
+		}
+		stack := op.Push(gtx.Ops)
+		clip.Rect(r).Add(gtx.Ops)
+		paint.PaintOp{}.Add(gtx.Ops)
+		stack.Pop()
+	}
+}
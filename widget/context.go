@@ -0,0 +1,95 @@
+// SPDX-License-Identifier: Unlicense OR MIT
+
+package widget
+
+import (
+	"image"
+	"math"
+	"time"
+
+	"gioui.org/f32"
+	"gioui.org/io/pointer"
+	"gioui.org/layout"
+	"gioui.org/op"
+)
+
+// A ContextEvent is generated when the Editor is right-clicked or
+// long-pressed, for opening a context menu at the pointer.
+type ContextEvent struct {
+	// Position is the pointer position relative to the editor.
+	Position f32.Point
+	// Selection reports whether the editor has a selection.
+	Selection bool
+}
+
+// longPressDuration is how long a touch press must be held to
+// generate a ContextEvent.
+const longPressDuration = 500 * time.Millisecond
+
+// contextPress tracks a touch press that may become a long press.
+type contextPress struct {
+	pressed bool
+	pid     pointer.ID
+	pos     f32.Point
+	// start is the frame time of the press.
+	start time.Time
+}
+
+// processContext converts secondary clicks and long presses to
+// ContextEvents.
+func (e *Editor) processContext(gtx layout.Context) {
+	p := &e.context
+	for _, evt := range gtx.Events(p) {
+		pe, ok := evt.(pointer.Event)
+		if !ok {
+			continue
+		}
+		switch pe.Type {
+		case pointer.Press:
+			switch {
+			case pe.Source == pointer.Mouse && pe.Buttons == pointer.ButtonRight:
+				e.contextMenu(pe.Position)
+			case pe.Source == pointer.Touch && !p.pressed:
+				*p = contextPress{pressed: true, pid: pe.PointerID, pos: pe.Position, start: gtx.Now}
+			}
+		case pointer.Release, pointer.Cancel:
+			if pe.PointerID == p.pid {
+				p.pressed = false
+			}
+		}
+	}
+	if !p.pressed {
+		return
+	}
+	if gtx.Now.Sub(p.start) >= longPressDuration {
+		p.pressed = false
+		e.contextMenu(p.pos)
+		return
+	}
+	op.InvalidateOp{At: p.start.Add(longPressDuration)}.Add(gtx.Ops)
+}
+
+// contextMenu generates a ContextEvent for the text area position
+// pos. The caret moves to pos unless pos is inside the selection.
+func (e *Editor) contextMenu(pos f32.Point) {
+	ipos := image.Point{
+		X: int(math.Round(float64(pos.X))),
+		Y: int(math.Round(float64(pos.Y))),
+	}
+	start, end := e.selectionBytes()
+	if idx := e.offsetAt(ipos); start == end || idx < start || idx >= end {
+		e.commitComposition()
+		e.history.seal()
+		e.moveCoord(ipos)
+		e.clearSelection()
+		e.carets = e.carets[:0]
+	}
+	e.requestFocus = true
+	start, end = e.selectionBytes()
+	e.events = append(e.events, ContextEvent{
+		Position:  pos.Add(e.textOffset()),
+		Selection: start != end,
+	})
+}
+
+func (ContextEvent) isEditorEvent() {}
@@ -0,0 +1,153 @@
+// SPDX-License-Identifier: Unlicense OR MIT
+
+package widget
+
+import (
+	"image"
+	"image/color"
+
+	"gioui.org/layout"
+	"gioui.org/op"
+	"gioui.org/op/clip"
+	"gioui.org/op/paint"
+)
+
+// DecorationKind is a set of ways to decorate a range of text.
+type DecorationKind uint8
+
+const (
+	// DecorationUnderline draws a line under the text.
+	DecorationUnderline DecorationKind = 1 << iota
+	// DecorationStrikethrough draws a line through the text.
+	DecorationStrikethrough
+	// DecorationBackground tints the background of the text.
+	DecorationBackground
+	// DecorationOutline draws a rectangle around the text.
+	DecorationOutline
+	// DecorationSquiggle draws a squiggly line under the text.
+	DecorationSquiggle
+)
+
+// DecorationStyle describes the decoration of a range of text.
+type DecorationStyle struct {
+	Kind  DecorationKind
+	Color color.NRGBA
+	// Tag identifies the decorations removed by ClearDecorations,
+	// for example all search highlights or all diagnostics of a
+	// checker.
+	Tag interface{}
+}
+
+// decoration is a decorated range of rune offsets.
+type decoration struct {
+	start, end int
+	style      DecorationStyle
+}
+
+// AddDecoration decorates the text between the rune offsets start
+// and end. Decorations are adjusted when the text they cover is
+// edited. A decoration of an empty range is drawn as a bar at its
+// offset, for example to mark the carets of other users.
+func (e *Editor) AddDecoration(start, end int, style DecorationStyle) {
+	if start > end {
+		start, end = end, start
+	}
+	e.decorations = append(e.decorations, decoration{start: start, end: end, style: style})
+}
+
+// ClearDecorations removes the decorations added with tag.
+func (e *Editor) ClearDecorations(tag interface{}) {
+	decs := e.decorations[:0]
+	for _, d := range e.decorations {
+		if d.style.Tag != tag {
+			decs = append(decs, d)
+		}
+	}
+	e.decorations = decs
+}
+
+// adjustDecorations updates the decorations for the replacement of
+// removed runes at the rune offset start with inserted runes.
+// Decorations whose text is removed are kept as empty ranges only if
+// they were empty before.
+func (e *Editor) adjustDecorations(start, removed, inserted int) {
+	decs := e.decorations[:0]
+	for _, d := range e.decorations {
+		empty := d.start == d.end
+		d.start = adjustOffset(d.start, start, removed, inserted, !empty)
+		d.end = adjustOffset(d.end, start, removed, inserted, false)
+		if d.start < d.end || empty {
+			if d.end < d.start {
+				d.end = d.start
+			}
+			decs = append(decs, d)
+		}
+	}
+	e.decorations = decs
+}
+
+// paintDecorations paints the decorations of the kinds in mask that
+// are visible through the clip rectangle cl.
+func (e *Editor) paintDecorations(gtx layout.Context, cl image.Rectangle, mask DecorationKind) {
+	if len(e.decorations) == 0 {
+		return
+	}
+	defer op.Push(gtx.Ops).Pop()
+	clip.Rect(cl).Add(gtx.Ops)
+	thickness := (e.textSize / 16).Ceil()
+	if thickness < 1 {
+		thickness = 1
+	}
+	for _, d := range e.decorations {
+		kind := d.style.Kind & mask
+		if kind == 0 {
+			continue
+		}
+		start := e.rr.moveRunes(0, d.start)
+		end := e.rr.moveRunes(start, d.end-d.start)
+		if start == end {
+			line, _, x, y := e.locate(start)
+			l := e.lines.entry(line)
+			r := image.Rect(x.Floor(), y-l.Ascent.Ceil(), x.Floor()+thickness, y+l.Descent.Ceil())
+			fillRect(gtx, d.style.Color, r.Sub(e.scrollOff))
+			continue
+		}
+		e.eachRegion(start, end, false, func(r image.Rectangle, baseline int) {
+			r = r.Sub(e.scrollOff)
+			baseline -= e.scrollOff.Y
+			if r.Intersect(cl).Empty() {
+				return
+			}
+			col := d.style.Color
+			if kind&DecorationBackground != 0 {
+				fillRect(gtx, col, r)
+			}
+			if kind&DecorationUnderline != 0 {
+				fillRect(gtx, col, image.Rect(r.Min.X, baseline+thickness, r.Max.X, baseline+2*thickness))
+			}
+			if kind&DecorationStrikethrough != 0 {
+				y := baseline - (baseline-r.Min.Y)*3/10
+				fillRect(gtx, col, image.Rect(r.Min.X, y-thickness/2, r.Max.X, y-thickness/2+thickness))
+			}
+			if kind&DecorationOutline != 0 {
+				stack := op.Push(gtx.Ops)
+				clip.Border{Rect: layout.FRect(r), Width: float32(thickness)}.Add(gtx.Ops)
+				paint.ColorOp{Color: col}.Add(gtx.Ops)
+				paint.PaintOp{}.Add(gtx.Ops)
+				stack.Pop()
+			}
+			if kind&DecorationSquiggle != 0 {
+				drawSquiggle(gtx, col, r.Min.X, r.Max.X, r.Max.Y-thickness, float32(thickness))
+			}
+		})
+	}
+}
+
+// fillRect fills r with col.
+func fillRect(gtx layout.Context, col color.NRGBA, r image.Rectangle) {
+	stack := op.Push(gtx.Ops)
+	clip.Rect(r).Add(gtx.Ops)
+	paint.ColorOp{Color: col}.Add(gtx.Ops)
+	paint.PaintOp{}.Add(gtx.Ops)
+	stack.Pop()
+}
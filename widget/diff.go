@@ -0,0 +1,359 @@
+// SPDX-License-Identifier: Unlicense OR MIT
+
+package widget
+
+import (
+	"image"
+	"strings"
+
+	"gioui.org/layout"
+	"gioui.org/op"
+	"gioui.org/op/clip"
+	"gioui.org/op/paint"
+)
+
+// DiffEditor displays an old and a new text side by side in two
+// Editors, with the lines that are unchanged between them aligned.
+// Gaps are added below the changed lines of the side with fewer
+// changed lines, and the editors scroll together.
+type DiffEditor struct {
+	// Old is the editor of the old text, displayed on the left.
+	Old Editor
+	// New is the editor of the new text, displayed on the right.
+	New Editor
+
+	// oldRev and newRev are the revisions of the texts of the
+	// last diff.
+	oldRev, newRev int
+	diffed         bool
+	hunks          []DiffHunk
+	// oldKinds and newKinds are the kinds of the logical lines of
+	// the editors.
+	oldKinds, newKinds []DiffLineKind
+	// scroll is the vertical scroll offset of both editors after
+	// the last Layout.
+	scroll int
+}
+
+// DiffLineKind describes how a line of a DiffEditor changed.
+type DiffLineKind uint8
+
+const (
+	// DiffUnchanged lines are in both texts.
+	DiffUnchanged DiffLineKind = iota
+	// DiffRemoved lines are only in the old text.
+	DiffRemoved
+	// DiffAdded lines are only in the new text.
+	DiffAdded
+)
+
+// A DiffHunk is a range of changed lines. The logical lines between
+// OldStart and OldEnd of the old text are replaced by the lines
+// between NewStart and NewEnd of the new text. Either range may be
+// empty.
+type DiffHunk struct {
+	OldStart, OldEnd int
+	NewStart, NewEnd int
+}
+
+// maxDiffCost is the maximum number of line insertions and removals
+// found by diffLines. Texts that differ more are replaced as a whole.
+const maxDiffCost = 2000
+
+// Layout lays out the editors side by side, each taking half the
+// width. The side function lays out and paints an editor, typically
+// with PaintLines and PaintGaps before its text.
+func (d *DiffEditor) Layout(gtx layout.Context, side func(gtx layout.Context, e *Editor) layout.Dimensions) layout.Dimensions {
+	if !d.update() {
+		op.InvalidateOp{}.Add(gtx.Ops)
+	}
+	cs := gtx.Constraints
+	half := cs.Max.X / 2
+	ogtx := gtx
+	ogtx.Constraints.Max.X = half
+	ogtx.Constraints.Min.X = half
+	ngtx := gtx
+	ngtx.Constraints.Max.X = cs.Max.X - half
+	ngtx.Constraints.Min.X = cs.Max.X - half
+
+	odims := side(ogtx, &d.Old)
+	if y := d.Old.scrollOff.Y; y != d.scroll {
+		d.sync(&d.New, y)
+	}
+	stack := op.Push(gtx.Ops)
+	op.Offset(layout.FPt(image.Point{X: half})).Add(gtx.Ops)
+	ndims := side(ngtx, &d.New)
+	stack.Pop()
+	d.scroll = d.New.scrollOff.Y
+	if d.scroll != d.Old.scrollOff.Y {
+		// The new editor scrolled by itself; the old editor
+		// follows in the next frame.
+		d.sync(&d.Old, d.scroll)
+		op.InvalidateOp{}.Add(gtx.Ops)
+	}
+	if d.Old.Revision() != d.oldRev || d.New.Revision() != d.newRev {
+		// Diff the edited texts in the next frame.
+		op.InvalidateOp{}.Add(gtx.Ops)
+	}
+
+	h := odims.Size.Y
+	if ndims.Size.Y > h {
+		h = ndims.Size.Y
+	}
+	return layout.Dimensions{
+		Size:     cs.Constrain(image.Point{X: half + ndims.Size.X, Y: h}),
+		Baseline: odims.Baseline,
+	}
+}
+
+// sync scrolls e vertically to y.
+func (d *DiffEditor) sync(e *Editor, y int) {
+	off := e.scrollOff
+	off.Y = y
+	e.SetScrollOff(off)
+}
+
+// update diffs the texts if they changed since the last diff, and
+// aligns the editors. It reports whether the editors could be
+// aligned.
+func (d *DiffEditor) update() bool {
+	if !d.diffed || d.Old.Revision() != d.oldRev || d.New.Revision() != d.newRev {
+		d.oldRev, d.newRev, d.diffed = d.Old.Revision(), d.New.Revision(), true
+		a, b := strings.Split(d.Old.Text(), "\n"), strings.Split(d.New.Text(), "\n")
+		d.oldKinds, d.newKinds = diffLines(a, b)
+		d.hunks = diffHunks(d.oldKinds, d.newKinds)
+	}
+	if d.Old.shaper == nil || d.New.shaper == nil {
+		// The editors have not been laid out yet.
+		return false
+	}
+	oh, nh := d.Old.logicalHeights(), d.New.logicalHeights()
+	if len(oh) != len(d.oldKinds) || len(nh) != len(d.newKinds) {
+		// The lines do not match the text, such as for masked
+		// editors.
+		return false
+	}
+	ogaps, ngaps := make(map[int]int), make(map[int]int)
+	for _, h := range d.hunks {
+		ho, hn := sum(oh[h.OldStart:h.OldEnd]), sum(nh[h.NewStart:h.NewEnd])
+		switch {
+		case ho > hn:
+			ngaps[h.NewEnd] += ho - hn
+		case hn > ho:
+			ogaps[h.OldEnd] += hn - ho
+		}
+	}
+	d.Old.SetLineGaps(ogaps)
+	d.New.SetLineGaps(ngaps)
+	return true
+}
+
+// Hunks returns the changed ranges of lines as of the last Layout, in
+// order.
+func (d *DiffEditor) Hunks() []DiffHunk {
+	return d.hunks
+}
+
+// LineKind returns how the logical line with index line of the editor
+// e changed as of the last Layout, where e is either d.Old or d.New.
+func (d *DiffEditor) LineKind(e *Editor, line int) DiffLineKind {
+	kinds := d.oldKinds
+	if e == &d.New {
+		kinds = d.newKinds
+	}
+	if line < 0 || line >= len(kinds) {
+		return DiffUnchanged
+	}
+	return kinds[line]
+}
+
+// PaintLines paints the background of the visible lines of e of the
+// kind in the current color, where e is either d.Old or d.New. The
+// backgrounds span the gutter and the text, but not the line gaps.
+func (d *DiffEditor) PaintLines(gtx layout.Context, e *Editor, kind DiffLineKind) {
+	d.paintRows(gtx, e, func(line, top, bottom int) (int, int) {
+		if d.LineKind(e, line) != kind {
+			return 0, 0
+		}
+		return top + e.gaps[line], bottom
+	})
+}
+
+// PaintGaps paints the visible line gaps of e in the current color.
+func (d *DiffEditor) PaintGaps(gtx layout.Context, e *Editor) {
+	d.paintRows(gtx, e, func(line, top, bottom int) (int, int) {
+		return top, top + e.gaps[line]
+	})
+}
+
+// paintRows paints the rows between the vertical positions returned
+// by row for the visible logical lines of e, in the current color.
+// The rows span the width of the editor, and top and bottom bound the
+// logical line in editor coordinates, including the gap above it.
+// The gap below the last line is passed as a line of its own.
+func (d *DiffEditor) paintRows(gtx layout.Context, e *Editor, row func(line, top, bottom int) (int, int)) {
+	width := e.gutter.width + e.viewSize.X
+	defer op.Push(gtx.Ops).Pop()
+	clip.Rect(image.Rectangle{Max: image.Point{X: width, Y: e.viewSize.Y}}).Add(gtx.Ops)
+	dy := e.valign - e.scrollOff.Y
+	paintRow := func(line, top, bottom int) {
+		y0, y1 := row(line, top, bottom)
+		if y0 >= y1 {
+			return
+		}
+		stack := op.Push(gtx.Ops)
+		clip.Rect(image.Rect(0, y0+dy, width, y1+dy)).Add(gtx.Ops)
+		paint.PaintOp{}.Add(gtx.Ops)
+		stack.Pop()
+	}
+	var (
+		last    ShapedLine
+		line    = -1
+		top     int
+		visible = true
+	)
+	it := e.ShapedLines(e.scrollOff.Y)
+	for {
+		l, ok := it.Next()
+		if !ok {
+			break
+		}
+		if line == -1 || !l.Continuation {
+			t := l.Baseline - l.Ascent.Ceil()
+			if line == -1 {
+				line = e.logicalLine(l.Index)
+				if l.Continuation {
+					// The gap is above the first
+					// line of the logical line.
+					t -= e.gaps[line]
+				}
+			} else {
+				paintRow(line, top, t)
+				line++
+			}
+			top = t
+			if top+dy > e.viewSize.Y {
+				visible = false
+				break
+			}
+		}
+		last = l
+	}
+	if line == -1 || !visible {
+		return
+	}
+	bottom := last.Baseline + last.Descent.Ceil()
+	g := e.gaps[line+1]
+	paintRow(line, top, bottom-g)
+	paintRow(line+1, bottom-g, bottom)
+}
+
+// sum returns the sum of vs.
+func sum(vs []int) int {
+	s := 0
+	for _, v := range vs {
+		s += v
+	}
+	return s
+}
+
+// diffLines finds the lines removed from a and the lines added to b
+// by a shortest edit from a to b, using the algorithm of Myers.
+func diffLines(a, b []string) (akinds, bkinds []DiffLineKind) {
+	akinds, bkinds = make([]DiffLineKind, len(a)), make([]DiffLineKind, len(b))
+	// Skip the common prefix and suffix.
+	pre := 0
+	for pre < len(a) && pre < len(b) && a[pre] == b[pre] {
+		pre++
+	}
+	suf := 0
+	for suf < len(a)-pre && suf < len(b)-pre && a[len(a)-1-suf] == b[len(b)-1-suf] {
+		suf++
+	}
+	a, b = a[pre:len(a)-suf], b[pre:len(b)-suf]
+	removed, added := akinds[pre:len(akinds)-suf], bkinds[pre:len(bkinds)-suf]
+	n, m := len(a), len(b)
+	max := n + m
+	if max == 0 {
+		return
+	}
+	// v holds the furthest x reached on every diagonal k = x - y,
+	// at index k+max. trace holds the diagonals -d through d of v
+	// after every step d.
+	v := make([]int, 2*max+2)
+	var trace [][]int
+	found := false
+	for d := 0; d <= max && d <= maxDiffCost && !found; d++ {
+		for k := -d; k <= d; k += 2 {
+			var x int
+			if k == -d || k != d && v[max+k-1] < v[max+k+1] {
+				x = v[max+k+1]
+			} else {
+				x = v[max+k-1] + 1
+			}
+			y := x - k
+			for x < n && y < m && a[x] == b[y] {
+				x++
+				y++
+			}
+			v[max+k] = x
+			if x >= n && y >= m {
+				found = true
+				break
+			}
+		}
+		trace = append(trace, append([]int(nil), v[max-d:max+d+1]...))
+	}
+	if !found {
+		for i := range removed {
+			removed[i] = DiffRemoved
+		}
+		for i := range added {
+			added[i] = DiffAdded
+		}
+		return
+	}
+	x, y := n, m
+	for d := len(trace) - 1; d > 0; d-- {
+		prev := trace[d-1]
+		// at returns the furthest x on diagonal k after step d-1.
+		at := func(k int) int { return prev[k+d-1] }
+		k := x - y
+		prevK := k - 1
+		if k == -d || k != d && at(k-1) < at(k+1) {
+			prevK = k + 1
+		}
+		prevX := at(prevK)
+		prevY := prevX - prevK
+		if prevK == k+1 {
+			added[prevY] = DiffAdded
+		} else {
+			removed[prevX] = DiffRemoved
+		}
+		x, y = prevX, prevY
+	}
+	return
+}
+
+// diffHunks groups the changed lines into hunks.
+func diffHunks(akinds, bkinds []DiffLineKind) []DiffHunk {
+	var hunks []DiffHunk
+	i, j := 0, 0
+	for i < len(akinds) || j < len(bkinds) {
+		h := DiffHunk{OldStart: i, NewStart: j}
+		for i < len(akinds) && akinds[i] == DiffRemoved {
+			i++
+		}
+		for j < len(bkinds) && bkinds[j] == DiffAdded {
+			j++
+		}
+		if i > h.OldStart || j > h.NewStart {
+			h.OldEnd, h.NewEnd = i, j
+			hunks = append(hunks, h)
+			continue
+		}
+		i++
+		j++
+	}
+	return hunks
+}
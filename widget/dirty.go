@@ -0,0 +1,65 @@
+// SPDX-License-Identifier: Unlicense OR MIT
+
+package widget
+
+// revisions identifies the states of the editor contents.
+type revisions struct {
+	// cur identifies the current contents, and clean the contents
+	// marked clean.
+	cur, clean int
+	// last is the most recently allocated identifier.
+	last int
+}
+
+// next returns a new revision identifier.
+func (r *revisions) next() int {
+	r.last++
+	return r.last
+}
+
+// Revision returns an identifier of the current contents. Every
+// modification changes the revision, and undo and redo restore the
+// revisions of the contents they restore.
+func (e *Editor) Revision() int {
+	return e.rev.cur
+}
+
+// Dirty reports whether the contents differ from the contents at the
+// most recent call to MarkClean, or from the initial empty contents
+// if MarkClean has never been called. Undoing changes back to the
+// clean contents makes the editor clean again.
+//
+// SetText, ReadFrom and SetReaderAt make the editor dirty, so call
+// MarkClean after loading a document.
+func (e *Editor) Dirty() bool {
+	return e.rev.cur != e.rev.clean
+}
+
+// MarkClean marks the current contents as clean, for example after
+// saving them.
+func (e *Editor) MarkClean() {
+	e.rev.clean = e.rev.cur
+}
+
+// renumber gives the states recorded in the history new revision
+// identifiers, after a change outside the history altered all of
+// them. The current state, before the change, had the revision old.
+func (h *editHistory) renumber(old int, r *revisions) {
+	ids := map[int]int{old: r.cur}
+	id := func(old int) int {
+		if n, ok := ids[old]; ok {
+			return n
+		}
+		n := r.next()
+		ids[old] = n
+		return n
+	}
+	for i := range h.undo {
+		m := &h.undo[i]
+		m.before, m.rev = id(m.before), id(m.rev)
+	}
+	for i := range h.redo {
+		m := &h.redo[i]
+		m.before, m.rev = id(m.before), id(m.rev)
+	}
+}
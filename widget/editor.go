@@ -6,9 +6,11 @@ import (
 	"bufio"
 	"bytes"
 	"image"
+	"image/color"
 	"io"
 	"math"
 	"runtime"
+	"sort"
 	"strings"
 	"time"
 	"unicode"
@@ -19,6 +21,7 @@ import (
 	"gioui.org/io/clipboard"
 	"gioui.org/io/key"
 	"gioui.org/io/pointer"
+	"gioui.org/io/transfer"
 	"gioui.org/layout"
 	"gioui.org/op"
 	"gioui.org/op/clip"
@@ -39,10 +42,163 @@ type Editor struct {
 	// Submit enabled translation of carriage return keys to SubmitEvents.
 	// If not enabled, carriage returns are inserted as newlines in the text.
 	Submit bool
+	// SubmitModifiers are the modifiers, such as key.ModShortcut,
+	// that must be held with Enter to generate a SubmitEvent. Enter
+	// without them inserts a newline. If zero, Enter submits and
+	// Shift+Enter inserts a newline.
+	SubmitModifiers key.Modifiers
+	// ClearOnSubmit clears the contents when a SubmitEvent is
+	// generated, in the same frame. The clearing can be undone.
+	ClearOnSubmit bool
+	// TraverseOnTab makes Tab and Shift+Tab release the focus and
+	// generate a TraverseEvent instead of inserting a tab, for
+	// moving between the fields of a form. SingleLine editors always
+	// traverse on Tab.
+	TraverseOnTab bool
+	// KeepSelection keeps the selection when the editor loses the
+	// focus, for example for toolbar buttons that act on it. The
+	// selection is painted with the InactiveColor of Highlight
+	// while the editor is unfocused.
+	KeepSelection bool
 	// Mask replaces the visual display of each rune in the contents with the given rune.
 	// Newline characters are not masked. When non-zero, the unmasked contents
 	// are accessed by Len, Text, and SetText.
 	Mask rune
+	// MaskReveal, if non-zero, shows the most recently typed rune
+	// unmasked for the duration, or until the next edit.
+	MaskReveal time.Duration
+	// CopyLine makes copying and cutting with no text selected copy
+	// or cut the current line, including its line terminator. If
+	// CopyLine is false, they do nothing without a selection.
+	CopyLine bool
+	// CopyHTML makes copying from an editor with styled spans also
+	// write an HTML representation of the text with its styles, for
+	// pasting into word processors.
+	CopyHTML bool
+	// MaxLen limits the editor contents to a maximum length in
+	// runes. Insertions past the limit are truncated, and a
+	// MaxLenEvent is generated. Zero means no limit.
+	MaxLen int
+	// Filter is the set of runes allowed in typed and pasted text.
+	// Other runes are dropped before insertion. If Filter is empty,
+	// all runes are allowed.
+	Filter string
+	// Number restricts typed and pasted text to numbers in the
+	// given format. Insertions that would make the contents an
+	// invalid number are dropped.
+	Number NumberFormat
+	// InputHint selects the on-screen keyboard layout. If it is
+	// key.HintAny and Number is set, key.HintNumeric is used.
+	InputHint key.InputHint
+	// Hint is the text displayed when the editor is empty and
+	// unfocused. The editor is sized to fit the hint.
+	Hint string
+	// LineNumbers adds a gutter with line numbers to the left of
+	// the text. Clicks in the gutter generate GutterClickEvents.
+	LineNumbers bool
+	// Gutter are custom gutter columns, placed left of the line
+	// numbers and painted by PaintGutter. Clicks in them generate
+	// GutterClickEvents.
+	Gutter []GutterColumn
+	// TabWidth is the distance between tab stops, measured in
+	// spaces. If zero, a width of 4 is used.
+	TabWidth int
+	// TabStops are the positions of the first tab stops, relative
+	// to the start of a line, for aligning columns. Past the last
+	// of them, tab stops are TabWidth spaces apart.
+	TabStops []unit.Value
+	// SoftTabs makes the Tab key insert spaces up to the next tab
+	// stop instead of a tab character.
+	SoftTabs bool
+	// AutoClose makes typing the opening rune of a pair of
+	// ClosePairs wrap the selection in the pair, or insert the
+	// closing rune after the caret, and typing a closing rune
+	// step over the same rune after the caret.
+	AutoClose bool
+	// ClosePairs lists the opening and closing runes of the pairs
+	// closed by AutoClose, such as "()<>". If empty, brackets,
+	// braces, parentheses and quotes are closed.
+	ClosePairs string
+	// CommentToken, such as "//", enables Ctrl+/ (Cmd+/ on macOS)
+	// to toggle the comments of the selected lines. See
+	// ToggleComment.
+	CommentToken string
+	// LineCommands enables Alt+Up and Alt+Down to move the
+	// selected lines, and DuplicateShortcut to duplicate them.
+	LineCommands bool
+	// DuplicateShortcut duplicates the selected lines if
+	// LineCommands is set. If zero, Ctrl+Shift+D (Cmd+Shift+D on
+	// macOS) is used.
+	DuplicateShortcut Shortcut
+	// Keymap selects the key bindings of the editor.
+	Keymap Keymap
+	// UndoGap, if non-zero, is the longest pause between edits
+	// that are undone together.
+	UndoGap time.Duration
+	// UndoWords makes typing undo one word at a time.
+	UndoWords bool
+	// WrapPolicy configures how lines too wide for the editor are
+	// broken. It is ignored in SingleLine mode.
+	WrapPolicy WrapPolicy
+	// Baseline selects the line whose baseline is reported in the
+	// dimensions of a multi-line editor.
+	Baseline BaselineMode
+	// VerticalAlignment positions text shorter than the editor,
+	// such as the text of a SingleLine editor in a taller form row.
+	// Start, End and Middle align the text with the top, the bottom
+	// or the middle of the editor. Baseline centers the part of the
+	// first line above its baseline, which looks centered for
+	// text without descenders.
+	VerticalAlignment layout.Alignment
+	// LineHeight, if non-zero, is the distance between the
+	// baselines of successive lines. Otherwise, LineHeightScale, if
+	// non-zero, scales the line height given by the font.
+	LineHeight      unit.Value
+	LineHeightScale float32
+	// LetterSpacing is added to the advance of every rune.
+	LetterSpacing unit.Value
+	// CaretStyle is the shape of the caret.
+	CaretStyle CaretStyle
+	// CaretWidth is the width of the bar caret and the thickness
+	// of the underline caret. If zero, a width of 1dp is used.
+	CaretWidth unit.Value
+	// Highlight configures the painting of the selection.
+	Highlight Highlight
+	// Shadow and Outline are painted behind the text by PaintText.
+	Shadow  TextShadow
+	Outline TextOutline
+	// Rulers are painted behind the text by PaintText.
+	Rulers []Ruler
+	// ShowWhitespace selects the whitespace marked by
+	// PaintWhitespace.
+	ShowWhitespace Whitespace
+	// WordChars lists runes, such as '-', that are treated as
+	// letters when moving and deleting by word.
+	WordChars string
+	// CaretMargin is the space kept between the caret and the
+	// edges of the editor when scrolling to the caret.
+	CaretMargin CaretMargin
+	// ScrollDuration, if non-zero, animates the scrolling after
+	// jumps by ScrollToLine, MoveTo and the Find methods over the
+	// duration.
+	ScrollDuration time.Duration
+	// DetectLinks underlines URLs in the contents. Clicking a URL
+	// with the shortcut modifier held, or tapping it, generates a
+	// LinkClickEvent.
+	DetectLinks bool
+	// LazyLayout shapes only the paragraphs near the viewport, for
+	// editing large documents. The other paragraphs are laid out
+	// with estimated widths until they are scrolled into view, so
+	// their line breaks and positions are approximate.
+	LazyLayout bool
+	// LineStyle, if set, returns the style of the logical line with
+	// the given zero-based index, for PaintText and
+	// PaintLineBackgrounds.
+	LineStyle func(line int) LineStyle
+	// Deltas enables the DeltaEvents describing each modification
+	// of the contents. Locating a modification takes time
+	// proportional to the length of the contents.
+	Deltas bool
 
 	eventKey     int
 	font         text.Font
@@ -51,15 +207,38 @@ type Editor struct {
 	blinkStart   time.Time
 	focused      bool
 	rr           editBuffer
+	history      editHistory
 	maskReader   maskReader
 	lastMask     rune
 	maxWidth     int
+	lastWrap     WrapPolicy
+	lineEnding   LineEnding
 	viewSize     image.Point
 	valid        bool
-	lines        []text.Line
+	relayout     relayout
+	lazy         lazyLayout
+	spacing      spacing
+	tabs         tabStops
+	gaps         map[int]int
+	reveal       maskReveal
+	rev          revisions
+	load         loader
+	lines        lineTree
+	hintLines    []text.Line
 	shapes       []line
+	spans        []Span
+	spanSegs     []spanSegment
+	marks        []Mark
+	readOnly     []ReadOnlyRange
+	decorations  []decoration
+	links        []link
+	lastLinks    bool
 	dims         layout.Dimensions
 	requestFocus bool
+	releaseFocus bool
+	// valign is the vertical offset of the text from the top of
+	// the editor, from VerticalAlignment.
+	valign int
 
 	caret struct {
 		on     bool
@@ -76,12 +255,57 @@ type Editor struct {
 		// (x, y) are the caret coordinates.
 		x fixed.Int26_6
 		y int
+
+		// anchor is the byte offset of the end of the selection
+		// opposite the caret. The selection is empty when anchor
+		// equals the caret offset.
+		anchor int
 	}
 
 	scroller  gesture.Scroll
 	scrollOff image.Point
 
+	// now is the frame time of the last processed events.
+	now time.Time
+	// lastCaret is the caret position of the last CaretEvent.
+	lastCaret struct {
+		line, col, idx int
+	}
+
 	clicker gesture.Click
+	dragger gesture.Drag
+	gutter  gutter
+	find    finder
+	block   block
+	// composing is the state of input method composition.
+	composing composition
+	vim       vimState
+	smooth    smoothScroll
+	kills     killRing
+	snippet   snippet
+	primary   primary
+	context   contextPress
+	hover     hover
+	// dropKey is the tag for text dropped from other applications.
+	dropKey int
+	// carets are the byte offsets of the carets added in addition
+	// to the primary caret, in increasing order.
+	carets []int
+	// drag tracks a mouse drag extending the selection.
+	drag struct {
+		active bool
+		// clicks is the number of clicks that started the drag.
+		// Double and triple clicks extend the selection by whole
+		// words and lines, respectively.
+		clicks int
+		// start and end are the byte offsets of the word or line
+		// initially selected by a multi-click.
+		start, end int
+		// moving is set when the selection is being dragged to
+		// the byte offset drop.
+		moving bool
+		drop   int
+	}
 
 	// events is the list of events not yet processed.
 	events []EditorEvent
@@ -89,6 +313,79 @@ type Editor struct {
 	prevEvents int
 }
 
+// CaretStyle is the shape of the caret of an Editor.
+type CaretStyle uint8
+
+const (
+	// CaretBar draws the caret as a vertical bar before the rune
+	// at the caret.
+	CaretBar CaretStyle = iota
+	// CaretBlock draws the caret as a block covering the rune at
+	// the caret.
+	CaretBlock
+	// CaretUnderline draws the caret as a line under the rune at
+	// the caret.
+	CaretUnderline
+)
+
+// CaretMargin configures the space kept around the caret when an
+// Editor scrolls to reveal it. Margins larger than half the editor
+// size are reduced.
+type CaretMargin struct {
+	// Lines is the vertical margin in lines of text.
+	Lines int
+	// Vertical is added to the vertical margin.
+	Vertical unit.Value
+	// Horizontal is the horizontal margin, used when lines are
+	// not wrapped.
+	Horizontal unit.Value
+}
+
+// Highlight configures the painting of selected text.
+type Highlight struct {
+	// Color is the color of the highlight. If zero, a translucent
+	// blue is used.
+	Color color.NRGBA
+	// InactiveColor is the color of the highlight of a selection
+	// kept by KeepSelection while the editor is unfocused. If zero,
+	// Color with half its alpha is used.
+	InactiveColor color.NRGBA
+	// CornerRadius rounds the corners of the highlight.
+	CornerRadius unit.Value
+	// LineTails extends the highlight of lines whose line
+	// terminator is selected to the right edge of the text area.
+	LineTails bool
+}
+
+// LineStyle is the style of a logical line of an Editor.
+type LineStyle struct {
+	// Background, if not transparent, is painted behind the line
+	// across the width of the editor by PaintLineBackgrounds.
+	Background color.NRGBA
+	// Color, if not transparent, replaces the current color for
+	// the text of the line without a Span.
+	Color color.NRGBA
+}
+
+// WrapPolicy configures line breaking in an Editor or a Label.
+type WrapPolicy uint8
+
+const (
+	// WrapWords breaks lines at word boundaries. Words wider than
+	// the editor are broken between runes.
+	WrapWords WrapPolicy = iota
+	// WrapRunes breaks lines after the last rune that fits.
+	WrapRunes
+	// WrapNone disables line breaking. Lines wider than the editor
+	// are scrolled horizontally.
+	WrapNone
+	// WrapParagraph breaks lines at word boundaries, choosing the
+	// breaks of every paragraph that make its lines the most even,
+	// for reading long texts. Edits may reflow a whole paragraph,
+	// so it is best suited for text that isn't edited.
+	WrapParagraph
+)
+
 type maskReader struct {
 	// rr is the underlying reader.
 	rr      io.RuneReader
@@ -97,12 +394,17 @@ type maskReader struct {
 	mask []byte
 	// overflow contains excess mask bytes left over after the last Read call.
 	overflow []byte
+	// reveal is the index of the rune left unmasked, or -1, and
+	// runes the number of runes read.
+	reveal, runes int
+	runeBuf       [utf8.UTFMax]byte
 }
 
 func (m *maskReader) Reset(r io.RuneReader, mr rune) {
 	m.rr = r
 	n := utf8.EncodeRune(m.maskBuf[:], mr)
 	m.mask = m.maskBuf[:n]
+	m.reveal, m.runes = -1, 0
 }
 
 // Read reads from the underlying reader and replaces every
@@ -118,11 +420,16 @@ func (m *maskReader) Read(b []byte) (n int, err error) {
 			if err != nil {
 				break
 			}
-			if r == '\n' {
+			switch {
+			case r == '\n':
 				replacement = []byte{'\n'}
-			} else {
+			case m.runes == m.reveal:
+				n := utf8.EncodeRune(m.runeBuf[:], r)
+				replacement = m.runeBuf[:n]
+			default:
 				replacement = m.mask
 			}
+			m.runes++
 		}
 		nn := copy(b, replacement)
 		m.overflow = replacement[nn:]
@@ -139,15 +446,90 @@ type EditorEvent interface {
 // A ChangeEvent is generated for every user change to the text.
 type ChangeEvent struct{}
 
+// A DeltaEvent is generated for every modification of the contents
+// when Deltas is set. Applying the deltas in order to the previous
+// contents yields the current contents. Offsets count line
+// terminators as one rune, and Inserted terminates lines with "\n",
+// regardless of LineEnding.
+type DeltaEvent struct {
+	// Start is the rune offset of the modification.
+	Start int
+	// Removed is the number of runes removed at Start.
+	Removed int
+	// Inserted is the text inserted at Start.
+	Inserted string
+}
+
 // A SubmitEvent is generated when Submit is set
 // and a carriage return key is pressed.
 type SubmitEvent struct {
 	Text string
 }
 
+// A TraverseEvent is generated when Tab or Shift+Tab releases the
+// focus of an editor with TraverseOnTab or SingleLine set. The
+// receiver moves the focus to the next or previous field, typically
+// with Focus.
+type TraverseEvent struct {
+	// Backward is set for Shift+Tab.
+	Backward bool
+}
+
+// A MaxLenEvent is generated when an insertion is truncated because
+// the editor contents would exceed MaxLen.
+type MaxLenEvent struct{}
+
+// A SelectEvent is generated when the user selects some text, or
+// changes the selection (e.g. with a shift-click or shift and the
+// arrow keys).
+type SelectEvent struct {
+	Text string
+	// Start and End are the rune offsets of the start and end of
+	// the selection, with Start <= End.
+	Start, End int
+	// StartLine, StartCol, EndLine and EndCol are the positions of
+	// Start and End, as returned by Editor.CaretPos.
+	StartLine, StartCol int
+	EndLine, EndCol     int
+}
+
+// A CaretEvent is generated when the caret moves.
+type CaretEvent struct {
+	// Line and Col are the caret position as returned by
+	// Editor.CaretPos.
+	Line, Col int
+	// Offset is the rune offset of the caret.
+	Offset int
+}
+
+// A Span applies a style to a range of the editor contents.
+type Span struct {
+	// Start and End are the rune offsets of the styled text.
+	Start, End int
+	// Font is the font of the styled text, or the zero Font for
+	// the editor font. The glyphs are positioned according to the
+	// editor font, so Font should have the same advances, such as
+	// a variant of a monospaced font.
+	Font text.Font
+	// Color is the text color, or the zero color for the current
+	// color.
+	Color color.NRGBA
+	// Underline draws a line under the text.
+	Underline bool
+}
+
 type line struct {
 	offset image.Point
 	clip   op.CallOp
+	// layout is the shaped text and runeOff its rune offset.
+	layout  text.Layout
+	runeOff int
+	// order is the logical index of the rune at each position of
+	// layout, or nil if layout is in logical order.
+	order []int
+	// index is the index of the line in Editor.lines, and para
+	// the index of its logical line.
+	index, para int
 }
 
 const (
@@ -164,6 +546,7 @@ func (e *Editor) Events() []EditorEvent {
 }
 
 func (e *Editor) processEvents(gtx layout.Context) {
+	e.now = gtx.Now
 	// Flush events from before the previous Layout.
 	n := copy(e.events, e.events[e.prevEvents:])
 	e.events = e.events[:n]
@@ -174,20 +557,36 @@ func (e *Editor) processEvents(gtx layout.Context) {
 		return
 	}
 	e.processPointer(gtx)
+	e.processGutter(gtx)
 	e.processKey(gtx)
+	e.processPrimary(gtx)
+	e.processContext(gtx)
+	e.processHover(gtx)
+	e.processDrop(gtx)
 }
 
 func (e *Editor) makeValid() {
 	if e.valid {
 		return
 	}
-	e.lines, e.dims = e.layoutText(e.shaper)
+	if !e.relayoutEdit() {
+		e.lines.set(e.layoutText(e.shaper))
+		e.dims = e.textDims()
+	}
+	e.relayout = relayout{}
+	e.layoutLines()
+	e.valid = true
+}
+
+// layoutLines updates the state derived from the lines.
+func (e *Editor) layoutLines() {
+	e.layoutLinks()
+	e.shapeCaret()
 	line, col, x, y := e.layoutCaret()
 	e.caret.line = line
 	e.caret.col = col
 	e.caret.x = x
 	e.caret.y = y
-	e.valid = true
 }
 
 func (e *Editor) processPointer(gtx layout.Context) {
@@ -201,28 +600,124 @@ func (e *Editor) processPointer(gtx layout.Context) {
 		axis = gesture.Vertical
 		smin, smax = sbounds.Min.Y, sbounds.Max.Y
 	}
-	sdist := e.scroller.Scroll(gtx.Metric, gtx, gtx.Now, axis)
-	var soff int
-	if e.SingleLine {
+	var sdist, soff int
+	switch {
+	case e.SingleLine:
+		sdist = e.scroller.Scroll(gtx.Metric, gtx, gtx.Now, axis)
 		e.scrollRel(sdist, 0)
 		soff = e.scrollOff.X
-	} else {
+	case e.WrapPolicy == WrapNone:
+		// Unwrapped lines scroll horizontally as well.
+		d := e.scroller.ScrollBoth(gtx.Metric, gtx, gtx.Now, axis)
+		if d.X != 0 {
+			e.smooth.active = false
+		}
+		sdist = d.Y
+		e.scrollRel(d.X, sdist)
+		soff = e.scrollOff.Y
+	default:
+		sdist = e.scroller.Scroll(gtx.Metric, gtx, gtx.Now, axis)
 		e.scrollRel(0, sdist)
 		soff = e.scrollOff.Y
 	}
+	if sdist != 0 {
+		e.smooth.active = false
+	}
 	for _, evt := range e.clicker.Events(gtx) {
 		switch {
 		case evt.Type == gesture.TypePress && evt.Source == pointer.Mouse,
 			evt.Type == gesture.TypeClick && evt.Source == pointer.Touch:
 			e.blinkStart = gtx.Now
-			e.moveCoord(image.Point{
+			pos := image.Point{
 				X: int(math.Round(float64(evt.Position.X))),
 				Y: int(math.Round(float64(evt.Position.Y))),
-			})
+			}
+			e.kills.next()
+			e.commitComposition()
+			if (evt.Source == pointer.Touch || evt.Modifiers == key.ModShortcut) && e.clickLink(pos) {
+				break
+			}
+			if e.startMove(evt, pos) {
+				e.requestFocus = true
+				break
+			}
+			prev := e.rr.caret
+			e.moveCoord(pos)
 			e.requestFocus = true
+			e.history.seal()
 			if e.scroller.State() != gesture.StateFlinging {
 				e.caret.scroll = true
 			}
+			e.drag.active = evt.Source == pointer.Mouse
+			e.drag.clicks = evt.NumClicks
+			switch {
+			case evt.NumClicks == 2:
+				e.drag.start, e.drag.end = e.wordBounds(e.rr.caret)
+				e.setSelection(e.drag.start, e.drag.end)
+			case evt.NumClicks >= 3:
+				e.drag.start, e.drag.end = e.lineBounds(e.rr.caret)
+				e.setSelection(e.drag.start, e.drag.end)
+			case evt.Modifiers == key.ModAlt && evt.Source == pointer.Mouse:
+				// Start a rectangular selection.
+				e.clearSelection()
+				e.carets = e.carets[:0]
+				p := image.Pt(int(evt.Position.X), int(evt.Position.Y)).Add(e.scrollOff)
+				e.block = block{active: true, start: p, end: p}
+			case evt.Modifiers == key.ModShortcut:
+				// Add a caret, keeping the previous one.
+				e.carets = append(e.carets, prev)
+				e.clearSelection()
+				e.mergeCarets()
+			case evt.Modifiers.Contain(key.ModShift):
+				e.events = append(e.events, e.selectEvent())
+			default:
+				e.clearSelection()
+				e.carets = e.carets[:0]
+			}
+			if evt.NumClicks > 1 && !e.drag.active {
+				e.events = append(e.events, e.selectEvent())
+			}
+		}
+	}
+	for _, evt := range e.dragger.Events(gtx.Metric, gtx, gesture.Both) {
+		switch evt.Type {
+		case pointer.Drag:
+			if !e.drag.active {
+				break
+			}
+			e.blinkStart = gtx.Now
+			pos := image.Point{
+				X: int(math.Round(float64(evt.Position.X))),
+				Y: int(math.Round(float64(evt.Position.Y))),
+			}
+			if e.drag.moving {
+				e.drag.drop = e.offsetAt(pos)
+				break
+			}
+			e.moveCoord(pos)
+			if e.drag.clicks > 1 {
+				e.extendSelection()
+			}
+			if e.block.active {
+				e.caret.anchor = e.rr.caret
+				e.block.end = image.Pt(int(evt.Position.X), int(evt.Position.Y)).Add(e.scrollOff)
+			}
+			e.caret.scroll = true
+		case pointer.Release, pointer.Cancel:
+			if !e.drag.active {
+				break
+			}
+			e.drag.active = false
+			if e.drag.moving {
+				e.drag.moving = false
+				if evt.Type == pointer.Release {
+					e.dropSelection(evt.Modifiers.Contain(key.ModShortcut))
+				}
+				break
+			}
+			if e.caret.anchor != e.rr.caret || e.block.active {
+				e.events = append(e.events, e.selectEvent())
+			}
 		}
 	}
 	if (sdist > 0 && soff >= smax) || (sdist < 0 && soff <= smin) {
@@ -239,30 +734,67 @@ func (e *Editor) processKey(gtx layout.Context) {
 		switch ke := ke.(type) {
 		case key.FocusEvent:
 			e.focused = ke.Focus
+			if !e.focused {
+				e.hideRevealed()
+				e.commitComposition()
+				e.formatNumber()
+				if !e.KeepSelection {
+					e.clearSelection()
+				}
+			}
 		case key.Event:
 			if !e.focused || ke.State != key.Press {
 				break
 			}
+			e.commitComposition()
+			anchor, caret := e.caret.anchor, e.rr.caret
 			if e.Submit && (ke.Name == key.NameReturn || ke.Name == key.NameEnter) {
-				if !ke.Modifiers.Contain(key.ModShift) {
-					e.events = append(e.events, SubmitEvent{
-						Text: e.Text(),
-					})
+				if e.submitKey(ke.Modifiers) {
+					e.submit()
 					continue
 				}
 			}
+			if ke.Name == key.NameTab && e.snippetTab(ke.Modifiers) {
+				e.caret.scroll = true
+				continue
+			}
+			if ke.Name == key.NameTab && e.traverse(ke.Modifiers) {
+				continue
+			}
 			if e.command(gtx, ke) {
 				e.caret.scroll = true
 				e.scroller.Stop()
 			}
+			if e.caret.anchor != e.rr.caret && (e.caret.anchor != anchor || e.rr.caret != caret) {
+				e.events = append(e.events, e.selectEvent())
+			}
+		case key.PreeditEvent:
+			e.scroller.Stop()
+			e.compose(ke.Text)
 		case key.EditEvent:
+			e.kills.next()
 			e.caret.scroll = true
 			e.scroller.Stop()
-			e.append(ke.Text)
+			e.endComposition()
+			if e.Keymap == VimKeymap && e.vim.mode != VimInsert {
+				e.vimInput(ke.Text)
+				break
+			}
+			if s := e.filter(ke.Text); s != "" {
+				e.takeBlock()
+				e.forEachCaret(func() { e.typeText(s) })
+				e.revealTyped(gtx, s)
+			}
 		case clipboard.Event:
+			e.kills.next()
 			e.caret.scroll = true
 			e.scroller.Stop()
-			e.append(ke.Text)
+			if s := e.filter(ke.Text); s != "" {
+				e.history.seal()
+				e.takeBlock()
+				e.forEachCaret(func() { e.append(s) })
+				e.history.seal()
+			}
 		}
 		if e.rr.Changed() {
 			e.events = append(e.events, ChangeEvent{})
@@ -270,45 +802,203 @@ func (e *Editor) processKey(gtx layout.Context) {
 	}
 }
 
+// selectEvent returns a SelectEvent for the current selection.
+func (e *Editor) selectEvent() SelectEvent {
+	e.makeValid()
+	start, end := e.selectionBytes()
+	evt := SelectEvent{
+		Text:  e.SelectedText(),
+		Start: e.rr.runeOffset(start),
+		End:   e.rr.runeOffset(end),
+	}
+	evt.StartLine, evt.StartCol, _, _ = e.locate(start)
+	evt.EndLine, evt.EndCol, _, _ = e.locate(end)
+	return evt
+}
+
+// caretEvent generates a CaretEvent if the caret has moved since the
+// last one.
+func (e *Editor) caretEvent() {
+	l := &e.lastCaret
+	if l.line == e.caret.line && l.col == e.caret.col && l.idx == e.rr.caret {
+		return
+	}
+	l.line, l.col, l.idx = e.caret.line, e.caret.col, e.rr.caret
+	e.events = append(e.events, CaretEvent{
+		Line:   l.line,
+		Col:    l.col,
+		Offset: e.Caret(),
+	})
+}
+
 func (e *Editor) moveLines(distance int) {
 	e.moveToLine(e.caret.x+e.caret.xoff, e.caret.line+distance)
 }
 
+// submit generates a SubmitEvent, and clears the contents if
+// ClearOnSubmit is set.
+func (e *Editor) submit() {
+	e.events = append(e.events, SubmitEvent{
+		Text: e.Text(),
+	})
+	if e.ClearOnSubmit && e.rr.len() > 0 {
+		e.history.seal()
+		e.replace(0, e.rr.len(), "")
+		e.history.seal()
+		e.caret.scroll = true
+	}
+}
+
+// traverse releases the focus and generates a TraverseEvent if Tab
+// with the modifiers m traverses, and reports whether it did.
+func (e *Editor) traverse(m key.Modifiers) bool {
+	if !e.TraverseOnTab && !e.SingleLine {
+		return false
+	}
+	if m != 0 && m != key.ModShift {
+		return false
+	}
+	e.releaseFocus = true
+	e.events = append(e.events, TraverseEvent{Backward: m == key.ModShift})
+	return true
+}
+
+// submitKey reports whether Enter with the modifiers m submits.
+func (e *Editor) submitKey(m key.Modifiers) bool {
+	if e.SubmitModifiers == 0 {
+		return !m.Contain(key.ModShift)
+	}
+	return m.Contain(e.SubmitModifiers)
+}
+
 func (e *Editor) command(gtx layout.Context, k key.Event) bool {
 	modSkip := key.ModCtrl
 	if runtime.GOOS == "darwin" {
 		modSkip = key.ModAlt
 	}
-	switch k.Name {
+	e.kills.next()
+	switch {
+	case e.Keymap == EmacsKeymap && e.emacsCommand(gtx, k):
+		return true
+	case e.Keymap == VimKeymap && e.vimCommand(k):
+		return true
+	case e.LineCommands && e.lineCommand(k):
+		return true
+	}
+	switch shortcutName(k) {
 	case key.NameReturn, key.NameEnter:
-		e.append("\n")
+		e.takeBlock()
+		e.forEachCaret(func() { e.append("\n") })
+	case key.NameTab:
+		if (k.Modifiers == 0 || k.Modifiers == key.ModShift) && e.indentSelection() {
+			if k.Modifiers == key.ModShift {
+				e.Outdent()
+			} else {
+				e.Indent()
+			}
+			break
+		}
+		if k.Modifiers != 0 {
+			return false
+		}
+		e.takeBlock()
+		e.forEachCaret(e.insertTab)
 	case key.NameDeleteBackward:
+		if e.takeBlock() {
+			break
+		}
 		if k.Modifiers == modSkip {
-			e.deleteWord(-1)
+			e.forEachCaret(func() { e.deleteWord(-1) })
 		} else {
-			e.Delete(-1)
+			e.forEachCaret(func() { e.Delete(-1) })
 		}
 	case key.NameDeleteForward:
+		if e.takeBlock() {
+			break
+		}
 		if k.Modifiers == modSkip {
-			e.deleteWord(1)
+			e.forEachCaret(func() { e.deleteWord(1) })
 		} else {
-			e.Delete(1)
+			e.forEachCaret(func() { e.Delete(1) })
+		}
+	case key.NameUpArrow, key.NameDownArrow, key.NameLeftArrow, key.NameRightArrow,
+		key.NamePageUp, key.NamePageDown, key.NameHome, key.NameEnd:
+		e.moveCarets(k.Name, k.Modifiers.Contain(modSkip), k.Modifiers.Contain(key.ModShift))
+	case key.NameEscape:
+		if len(e.carets) == 0 {
+			return false
+		}
+		e.CollapseCarets()
+	case "A":
+		if k.Modifiers != key.ModShortcut {
+			return false
+		}
+		e.setSelection(0, e.rr.len())
+	case "Z":
+		switch k.Modifiers {
+		case key.ModShortcut:
+			e.Undo()
+		case key.ModShortcut | key.ModShift:
+			e.Redo()
+		default:
+			return false
+		}
+	case "V":
+		if k.Modifiers != key.ModShortcut {
+			return false
+		}
+		clipboard.ReadOp{Tag: &e.eventKey}.Add(gtx.Ops)
+	case "C":
+		if k.Modifiers != key.ModShortcut {
+			return false
+		}
+		e.Copy(gtx)
+	case "X":
+		if k.Modifiers != key.ModShortcut {
+			return false
 		}
+		e.Cut(gtx)
+	case "/":
+		if k.Modifiers != key.ModShortcut || e.CommentToken == "" {
+			return false
+		}
+		e.ToggleComment()
+	default:
+		return false
+	}
+	return true
+}
+
+// moveCarets moves every caret as if the movement key name was
+// pressed, extending the selection if extend is set.
+func (e *Editor) moveCarets(name string, byWord, extend bool) {
+	e.history.seal()
+	e.forEachCaret(func() {
+		e.moveKey(name, byWord)
+	})
+	if !extend {
+		e.clearSelection()
+	}
+}
+
+// moveKey moves the caret according to the movement key name.
+func (e *Editor) moveKey(name string, byWord bool) {
+	switch name {
 	case key.NameUpArrow:
 		e.moveLines(-1)
 	case key.NameDownArrow:
 		e.moveLines(+1)
 	case key.NameLeftArrow:
-		if k.Modifiers == modSkip {
+		if byWord {
 			e.moveWord(-1)
 		} else {
-			e.Move(-1)
+			e.move(-1)
 		}
 	case key.NameRightArrow:
-		if k.Modifiers == modSkip {
+		if byWord {
 			e.moveWord(1)
 		} else {
-			e.Move(1)
+			e.move(1)
 		}
 	case key.NamePageUp:
 		e.movePages(-1)
@@ -318,20 +1008,88 @@ func (e *Editor) command(gtx layout.Context, k key.Event) bool {
 		e.moveStart()
 	case key.NameEnd:
 		e.moveEnd()
-	case "V":
-		if k.Modifiers != key.ModShortcut {
-			return false
-		}
-		clipboard.ReadOp{Tag: &e.eventKey}.Add(gtx.Ops)
-	case "C":
-		if k.Modifiers != key.ModShortcut {
-			return false
+	}
+}
+
+// Copy writes the selected text to the clipboard. See CopyLine for
+// the behavior when no text is selected.
+func (e *Editor) Copy(gtx layout.Context) {
+	if e.block.active {
+		clipboard.WriteOp{Text: e.blockText()}.Add(gtx.Ops)
+		return
+	}
+	start, end := e.copyRange()
+	if start == end {
+		return
+	}
+	e.clipboardOp(start, end).Add(gtx.Ops)
+}
+
+// copyRange returns the byte offsets of the text to copy or cut: the
+// selection, or the line of the caret if no text is selected and
+// CopyLine is set.
+func (e *Editor) copyRange() (start, end int) {
+	start, end = e.selectionBytes()
+	if start != end || !e.CopyLine {
+		return start, end
+	}
+	start, end = e.lineBounds(e.rr.caret)
+	if end < e.rr.len() {
+		_, s := e.rr.runeAt(end)
+		end += s
+	}
+	return start, end
+}
+
+// Cut writes the selected text to the clipboard and deletes it from
+// the editor. See CopyLine for the behavior when no text is selected.
+func (e *Editor) Cut(gtx layout.Context) {
+	if e.block.active {
+		clipboard.WriteOp{Text: e.blockText()}.Add(gtx.Ops)
+		e.takeBlock()
+		e.history.seal()
+		e.caret.scroll = true
+		return
+	}
+	start, end := e.copyRange()
+	if start == end {
+		return
+	}
+	e.clipboardOp(start, end).Add(gtx.Ops)
+	e.history.seal()
+	e.replace(start, end, "")
+	e.history.seal()
+	e.caret.scroll = true
+}
+
+// insertTab inserts a tab character, or spaces up to the next tab
+// stop if SoftTabs is set.
+func (e *Editor) insertTab() {
+	if !e.SoftTabs {
+		e.append("\t")
+		return
+	}
+	start, _ := e.selectionBytes()
+	tw := e.tabWidth()
+	e.append(strings.Repeat(" ", tw-e.column(start)%tw))
+}
+
+// column returns the column of the byte offset idx in its logical
+// line, with tabs expanded to the next tab stop.
+func (e *Editor) column(idx int) int {
+	start, _ := e.lineBounds(idx)
+	tw := e.tabWidth()
+	col := 0
+	for i := start; i < idx; {
+		r, s := e.rr.runeAt(i)
+		i += s
+		if r == '\t' {
+			col += tw - col%tw
+		} else {
+			col++
 		}
-		clipboard.WriteOp{Text: e.Text()}.Add(gtx.Ops)
-	default:
-		return false
 	}
-	return true
+	return col
 }
 
 // Focus requests the input focus for the Editor.
@@ -339,6 +1097,17 @@ func (e *Editor) Focus() {
 	e.requestFocus = true
 }
 
+// Blur releases the input focus of the Editor, if it is focused, and
+// hides the software keyboard, such as after a submit or when a
+// dialog opens. It also cancels a focus request by Focus in the same
+// frame.
+func (e *Editor) Blur() {
+	e.requestFocus = false
+	if e.focused {
+		e.releaseFocus = true
+	}
+}
+
 // Focused returns whether the editor is focused or not.
 func (e *Editor) Focused() bool {
 	return e.focused
@@ -352,10 +1121,26 @@ func (e *Editor) Layout(gtx layout.Context, sh text.Shaper, font text.Font, size
 		e.font = font
 		e.textSize = textSize
 	}
+	e.layoutGutter(gtx, sh, font, textSize)
+	if w := e.gutter.width; w > 0 {
+		cs := &gtx.Constraints
+		cs.Max.X -= w
+		if cs.Max.X < 0 {
+			cs.Max.X = 0
+		}
+		cs.Min.X -= w
+		if cs.Min.X < 0 {
+			cs.Min.X = 0
+		}
+	}
 	maxWidth := gtx.Constraints.Max.X
-	if e.SingleLine {
+	if e.SingleLine || e.WrapPolicy == WrapNone {
 		maxWidth = inf
 	}
+	if e.WrapPolicy != e.lastWrap {
+		e.lastWrap = e.WrapPolicy
+		e.invalidate()
+	}
 	if maxWidth != e.maxWidth {
 		e.maxWidth = maxWidth
 		e.invalidate()
@@ -368,18 +1153,54 @@ func (e *Editor) Layout(gtx layout.Context, sh text.Shaper, font text.Font, size
 		e.lastMask = e.Mask
 		e.invalidate()
 	}
+	if e.DetectLinks != e.lastLinks {
+		e.lastLinks = e.DetectLinks
+		e.invalidate()
+	}
+	if e.LazyLayout != e.lazy.enabled {
+		e.lazy.enabled = e.LazyLayout
+		e.invalidate()
+	}
+	if sp := newSpacing(gtx, e.LineHeight, e.LineHeightScale, e.LetterSpacing); sp != e.spacing {
+		e.spacing = sp
+		e.invalidate()
+	}
+	if ts := newTabStops(gtx, e.TabWidth, e.TabStops); !ts.equal(e.tabs) {
+		e.tabs = ts
+		e.invalidate()
+	}
 
+	e.loadNext(gtx)
+	e.expireReveal(gtx)
 	e.makeValid()
 	e.processEvents(gtx)
 	e.makeValid()
 
+	e.hintLines = nil
+	if e.Hint != "" && e.Len() == 0 {
+		e.hintLines = e.spacing.apply(sh.LayoutString(font, textSize, maxWidth, e.Hint), maxWidth)
+		if e.SingleLine && len(e.hintLines) > 1 {
+			e.hintLines = e.hintLines[:1]
+		}
+		cs := &gtx.Constraints
+		hint := cs.Constrain(linesDimens(e.hintLines).Size)
+		if cs.Min.X < hint.X {
+			cs.Min.X = hint.X
+		}
+		if cs.Min.Y < hint.Y {
+			cs.Min.Y = hint.Y
+		}
+	}
 	if viewSize := gtx.Constraints.Constrain(e.dims.Size); viewSize != e.viewSize {
 		e.viewSize = viewSize
 		e.invalidate()
 	}
 	e.makeValid()
+	e.caretEvent()
+	e.valign = e.alignOffset()
 
 	dims := e.layout(gtx)
+	dims.Size.X += e.gutter.width
 	pointer.Rect(image.Rectangle{Max: dims.Size}).Add(gtx.Ops)
 	pointer.CursorNameOp{Name: pointer.CursorText}.Add(gtx.Ops)
 	return dims
@@ -391,21 +1212,30 @@ func (e *Editor) layout(gtx layout.Context) layout.Dimensions {
 
 	if e.caret.scroll {
 		e.caret.scroll = false
-		e.scrollToCaret()
+		e.scrollToCaret(gtx)
 	}
+	e.animateScroll(gtx)
+	e.shapeVisible()
 
 	off := image.Point{
 		X: -e.scrollOff.X,
 		Y: -e.scrollOff.Y,
 	}
-	clip := textPadding(e.lines)
+	clip := textPadding(e.lines.ends())
 	clip.Max = clip.Max.Add(e.viewSize)
+	// Start at the lines just above the viewport.
+	lines := e.lines.atY(e.scrollOff.Y + clip.Min.Y)
+	m := lines.m
 	it := lineIterator{
-		Lines:     e.lines,
+		Tree:      &lines,
 		Clip:      clip,
 		Alignment: e.Alignment,
 		Width:     e.viewSize.X,
 		Offset:    off,
+		y:         fixed.I(m.y),
+		prevDesc:  m.prevDesc,
+		txtOff:    m.bytes,
+		runes:     m.runes,
 	}
 	e.shapes = e.shapes[:0]
 	for {
@@ -413,18 +1243,43 @@ func (e *Editor) layout(gtx layout.Context) layout.Dimensions {
 		if !ok {
 			break
 		}
+		idx, para := it.mark.line, it.mark.para
+		runeOff := it.runeOff
+		var order []int
+		if b := it.entry.bidi; b != nil {
+			// Reordered lines are shaped whole, in visual order.
+			layout, order = b.visual, b.order
+			off.X = e.lineAlign(it.entry).Floor() - e.scrollOff.X
+			runeOff = it.runes - len(it.entry.Layout.Advances)
+		}
 		path := e.shaper.Shape(e.font, e.textSize, layout)
-		e.shapes = append(e.shapes, line{off, path})
+		e.shapes = append(e.shapes, line{off, path, layout, runeOff, order, idx, para})
 	}
 
-	key.InputOp{Tag: &e.eventKey}.Add(gtx.Ops)
+	hint := e.InputHint
+	if hint == key.HintAny && e.Number.Mode != NumberNone {
+		hint = key.HintNumeric
+	}
+	key.InputOp{Tag: &e.eventKey, Hint: hint}.Add(gtx.Ops)
 	if e.requestFocus {
 		key.FocusOp{Focus: true}.Add(gtx.Ops)
 		key.SoftKeyboardOp{Show: true}.Add(gtx.Ops)
 	}
 	e.requestFocus = false
+	if e.releaseFocus {
+		// Release the focus in a separate stack, where it doesn't
+		// override focus requested elsewhere in the frame.
+		stack := op.Push(gtx.Ops)
+		key.FocusOp{Focus: false}.Add(gtx.Ops)
+		stack.Pop()
+		e.releaseFocus = false
+	}
+	stack := op.Push(gtx.Ops)
+	op.Offset(e.textOffset()).Add(gtx.Ops)
 	pointerPadding := gtx.Px(unit.Dp(4))
-	r := image.Rectangle{Max: e.viewSize}
+	// Cover the whole editor, regardless of the vertical
+	// alignment of the text.
+	r := image.Rectangle{Max: e.viewSize}.Sub(image.Point{Y: e.valign})
 	r.Min.X -= pointerPadding
 	r.Min.Y -= pointerPadding
 	r.Max.X += pointerPadding
@@ -432,6 +1287,18 @@ func (e *Editor) layout(gtx layout.Context) layout.Dimensions {
 	pointer.Rect(r).Add(gtx.Ops)
 	e.scroller.Add(gtx.Ops)
 	e.clicker.Add(gtx.Ops)
+	e.dragger.Add(gtx.Ops)
+	pointer.InputOp{Tag: &e.primary, Types: pointer.Press}.Add(gtx.Ops)
+	pointer.InputOp{Tag: &e.context, Types: pointer.Press | pointer.Release}.Add(gtx.Ops)
+	pointer.InputOp{Tag: &e.hover, Types: pointer.Move | pointer.Enter | pointer.Leave}.Add(gtx.Ops)
+	transfer.TargetOp{Tag: &e.dropKey}.Add(gtx.Ops)
+	stack.Pop()
+	if e.gutter.width > 0 {
+		stack := op.Push(gtx.Ops)
+		pointer.Rect(image.Rectangle{Max: image.Point{X: e.gutter.width, Y: e.viewSize.Y}}).Add(gtx.Ops)
+		e.gutter.clicker.Add(gtx.Ops)
+		stack.Pop()
+	}
 	e.caret.on = false
 	if e.focused {
 		now := gtx.Now
@@ -446,83 +1313,689 @@ func (e *Editor) layout(gtx layout.Context) layout.Dimensions {
 		e.caret.on = e.focused && (!blinking || dt%timePerBlink < timePerBlink/2)
 	}
 
-	return layout.Dimensions{Size: e.viewSize, Baseline: e.dims.Baseline}
+	base := baselineY(e.lines.ends(), e.dims.Size.Y, e.Baseline) - e.scrollOff.Y + e.valign
+	return layout.Dimensions{Size: e.viewSize, Baseline: e.viewSize.Y - base}
 }
 
+// PaintText paints the text in the current color. Text covered by
+// spans is painted in the style of the span, detected links are
+// underlined, and marked text is underlined with a squiggle.
+// Decorations are painted as well, backgrounds behind the text. Text
+// being composed by an input method is underlined. The shadow and
+// outline are painted in the font of the editor.
 func (e *Editor) PaintText(gtx layout.Context) {
-	cl := textPadding(e.lines)
+	defer op.Push(gtx.Ops).Pop()
+	op.Offset(e.textOffset()).Add(gtx.Ops)
+	cl := textPadding(e.lines.ends())
 	cl.Max = cl.Max.Add(e.viewSize)
+	e.paintRulers(gtx)
+	e.paintDecorations(gtx, cl, DecorationBackground)
+	for _, p := range textPasses(gtx, e.Shadow, e.Outline) {
+		if p.own() {
+			break
+		}
+		for _, shape := range e.shapes {
+			stack := op.Push(gtx.Ops)
+			op.Offset(layout.FPt(shape.offset)).Add(gtx.Ops)
+			p.paint(gtx, shape.clip)
+			stack.Pop()
+		}
+	}
 	for _, shape := range e.shapes {
 		stack := op.Push(gtx.Ops)
 		op.Offset(layout.FPt(shape.offset)).Add(gtx.Ops)
-		shape.clip.Add(gtx.Ops)
 		clip.Rect(cl.Sub(shape.offset)).Add(gtx.Ops)
-		paint.PaintOp{}.Add(gtx.Ops)
+		if e.LineStyle != nil {
+			if c := e.LineStyle(shape.para).Color; c != (color.NRGBA{}) {
+				paint.ColorOp{Color: c}.Add(gtx.Ops)
+			}
+		}
+		if len(e.spans) == 0 {
+			shape.clip.Add(gtx.Ops)
+			paint.PaintOp{}.Add(gtx.Ops)
+		} else {
+			e.paintSpans(gtx, shape)
+		}
+		e.paintLinks(gtx, shape)
 		stack.Pop()
 	}
+	e.paintDecorations(gtx, cl, ^DecorationBackground)
+	e.paintMarks(gtx, cl)
+	e.paintComposition(gtx, cl)
 }
 
-func (e *Editor) PaintCaret(gtx layout.Context) {
-	if !e.caret.on {
+// PaintLineBackgrounds paints the backgrounds of the lines styled by
+// LineStyle. Call it before PaintSelection, so the selection remains
+// visible.
+func (e *Editor) PaintLineBackgrounds(gtx layout.Context) {
+	if e.LineStyle == nil {
 		return
 	}
-	e.makeValid()
-	carWidth := fixed.I(gtx.Px(unit.Dp(1)))
-	carX := e.caret.x
-	carY := e.caret.y
-
 	defer op.Push(gtx.Ops).Pop()
-	carX -= carWidth / 2
-	carAsc, carDesc := -e.lines[e.caret.line].Bounds.Min.Y, e.lines[e.caret.line].Bounds.Max.Y
-	carRect := image.Rectangle{
-		Min: image.Point{X: carX.Ceil(), Y: carY - carAsc.Ceil()},
-		Max: image.Point{X: carX.Ceil() + carWidth.Ceil(), Y: carY + carDesc.Ceil()},
-	}
-	carRect = carRect.Add(image.Point{
-		X: -e.scrollOff.X,
-		Y: -e.scrollOff.Y,
-	})
-	cl := textPadding(e.lines)
-	// Account for caret width to each side.
-	whalf := (carWidth / 2).Ceil()
-	if cl.Max.X < whalf {
-		cl.Max.X = whalf
-	}
-	if cl.Min.X > -whalf {
-		cl.Min.X = -whalf
-	}
-	cl.Max = cl.Max.Add(e.viewSize)
-	carRect = cl.Intersect(carRect)
-	if !carRect.Empty() {
-		st := op.Push(gtx.Ops)
-		clip.Rect(carRect).Add(gtx.Ops)
-		paint.PaintOp{}.Add(gtx.Ops)
-		st.Pop()
+	op.Offset(e.textOffset()).Add(gtx.Ops)
+	cl := image.Rectangle{Max: e.viewSize}
+	for _, shape := range e.shapes {
+		bg := e.LineStyle(shape.para).Background
+		if bg == (color.NRGBA{}) {
+			continue
+		}
+		l := e.lines.entry(shape.index)
+		r := image.Rectangle{
+			Min: image.Point{Y: shape.offset.Y - l.Ascent.Ceil()},
+			Max: image.Point{X: e.viewSize.X, Y: shape.offset.Y + l.Descent.Ceil()},
+		}
+		fillRect(gtx, bg, r.Intersect(cl))
 	}
 }
 
-// Len is the length of the editor contents.
-func (e *Editor) Len() int {
-	return e.rr.len()
+// paintSpans paints a shaped line, split into runs of runes with the
+// same span.
+func (e *Editor) paintSpans(gtx layout.Context, shape line) {
+	l := shape.layout
+	c := e.spanCursor(shape.runeOff)
+	var x fixed.Int26_6
+	for i := 0; len(l.Advances) > 0; {
+		sp := c.at(shape.rune(i))
+		// Find the end of the run.
+		n, size := 0, 0
+		for n < len(l.Advances) && c.at(shape.rune(i+n)) == sp {
+			_, s := utf8.DecodeRuneInString(l.Text[size:])
+			size += s
+			n++
+		}
+		run := text.Layout{Text: l.Text[:size], Advances: l.Advances[:n]}
+		var w fixed.Int26_6
+		for _, adv := range run.Advances {
+			w += adv
+		}
+		stack := op.Push(gtx.Ops)
+		op.Offset(f32.Point{X: float32(x) / 64}).Add(gtx.Ops)
+		font := e.font
+		if sp != nil {
+			if sp.Font != (text.Font{}) {
+				font = sp.Font
+			}
+			if sp.Color != (color.NRGBA{}) {
+				paint.ColorOp{Color: sp.Color}.Add(gtx.Ops)
+			}
+			if sp.Underline {
+				thickness := (e.textSize / 16).Ceil()
+				if thickness < 1 {
+					thickness = 1
+				}
+				st := op.Push(gtx.Ops)
+				clip.Rect(image.Rect(0, thickness, w.Ceil(), 2*thickness)).Add(gtx.Ops)
+				paint.PaintOp{}.Add(gtx.Ops)
+				st.Pop()
+			}
+		}
+		e.shaper.Shape(font, e.textSize, run).Add(gtx.Ops)
+		paint.PaintOp{}.Add(gtx.Ops)
+		stack.Pop()
+		x += w
+		i += n
+		l.Text = l.Text[size:]
+		l.Advances = l.Advances[n:]
+	}
+}
+
+// rune returns the rune offset of the rune at position i of the
+// shaped line.
+func (l line) rune(i int) int {
+	if l.order != nil {
+		i = l.order[i]
+	}
+	return l.runeOff + i
+}
+
+// SetSpans replaces the styled spans of the editor. If spans
+// overlap, the latest span in the slice takes precedence. Spans
+// are adjusted when the text they cover is edited.
+func (e *Editor) SetSpans(spans []Span) {
+	e.spans = append(e.spans[:0], spans...)
+	e.segmentSpans()
+}
+
+// Spans returns the styled spans of the editor.
+func (e *Editor) Spans() []Span {
+	return e.spans
+}
+
+// spanSegment is a range of runes covered by the span with index
+// span, after resolving overlaps.
+type spanSegment struct {
+	start, end int
+	span       int
+}
+
+// segmentSpans splits the spans into non-overlapping segments sorted
+// by their start, each covered by the latest of the spans overlapping
+// it.
+func (e *Editor) segmentSpans() {
+	e.spanSegs = e.spanSegs[:0]
+	if len(e.spans) == 0 {
+		return
+	}
+	var bounds []int
+	for _, sp := range e.spans {
+		bounds = append(bounds, sp.Start, sp.End)
+	}
+	sort.Ints(bounds)
+	n := 1
+	for _, b := range bounds[1:] {
+		if b != bounds[n-1] {
+			bounds[n] = b
+			n++
+		}
+	}
+	bounds = bounds[:n]
+	// owners[k] is the index of the span covering the range between
+	// bounds[k] and bounds[k+1], or -1.
+	owners := make([]int, len(bounds)-1)
+	for k := range owners {
+		owners[k] = -1
+	}
+	for i, sp := range e.spans {
+		for k := sort.SearchInts(bounds, sp.Start); k < len(owners) && bounds[k] < sp.End; k++ {
+			owners[k] = i
+		}
+	}
+	for k, o := range owners {
+		if o == -1 {
+			continue
+		}
+		if n := len(e.spanSegs); n > 0 && e.spanSegs[n-1].span == o && e.spanSegs[n-1].end == bounds[k] {
+			e.spanSegs[n-1].end = bounds[k+1]
+			continue
+		}
+		e.spanSegs = append(e.spanSegs, spanSegment{start: bounds[k], end: bounds[k+1], span: o})
+	}
+}
+
+// spanCursor looks up the spans covering a sequence of nearby rune
+// offsets, such as the runes of a line in visual order, by moving an
+// index over the segments of the spans.
+type spanCursor struct {
+	e *Editor
+	// i is the index of the first segment ending after the last
+	// offset looked up.
+	i int
+}
+
+// spanCursor returns a cursor positioned at the rune offset r.
+func (e *Editor) spanCursor(r int) spanCursor {
+	segs := e.spanSegs
+	i := sort.Search(len(segs), func(i int) bool { return segs[i].end > r })
+	return spanCursor{e: e, i: i}
+}
+
+// at returns the span covering the rune offset r, or nil.
+func (c *spanCursor) at(r int) *Span {
+	segs := c.e.spanSegs
+	for c.i < len(segs) && segs[c.i].end <= r {
+		c.i++
+	}
+	for c.i > 0 && segs[c.i-1].end > r {
+		c.i--
+	}
+	if c.i == len(segs) || segs[c.i].start > r {
+		return nil
+	}
+	return &c.e.spans[segs[c.i].span]
+}
+
+// adjustSpans updates the spans for the replacement of removed runes
+// at the rune offset start with inserted runes.
+func (e *Editor) adjustSpans(start, removed, inserted int) {
+	spans := e.spans[:0]
+	for _, sp := range e.spans {
+		sp.Start = adjustOffset(sp.Start, start, removed, inserted, true)
+		sp.End = adjustOffset(sp.End, start, removed, inserted, false)
+		if sp.Start < sp.End {
+			spans = append(spans, sp)
+		}
+	}
+	e.spans = spans
+	e.segmentSpans()
+}
+
+// adjustOffset returns the offset p after replacing removed units at
+// offset start with inserted units. Offsets inside the removed range
+// are moved to the start of the inserted units, or to their end if
+// after is set.
+func adjustOffset(p, start, removed, inserted int, after bool) int {
+	switch {
+	case p <= start:
+		return p
+	case p < start+removed:
+		if after {
+			return start + inserted
+		}
+		return start
+	default:
+		return p - removed + inserted
+	}
+}
+
+// PaintHint paints the hint text if the editor is empty and unfocused.
+func (e *Editor) PaintHint(gtx layout.Context) {
+	if len(e.hintLines) == 0 || e.focused {
+		return
+	}
+	defer op.Push(gtx.Ops).Pop()
+	op.Offset(e.textOffset()).Add(gtx.Ops)
+	cl := textPadding(e.hintLines)
+	cl.Max = cl.Max.Add(e.viewSize)
+	it := lineIterator{
+		Lines:     e.hintLines,
+		Clip:      cl,
+		Alignment: e.Alignment,
+		Width:     e.viewSize.X,
+	}
+	for {
+		l, off, ok := it.Next()
+		if !ok {
+			break
+		}
+		stack := op.Push(gtx.Ops)
+		op.Offset(layout.FPt(off)).Add(gtx.Ops)
+		e.shaper.Shape(e.font, e.textSize, l).Add(gtx.Ops)
+		clip.Rect(cl.Sub(off)).Add(gtx.Ops)
+		paint.PaintOp{}.Add(gtx.Ops)
+		stack.Pop()
+	}
+}
+
+// PaintSelection paints the contrasting background for selected text.
+func (e *Editor) PaintSelection(gtx layout.Context) {
+	sel := [][2]int{{}}
+	if e.block.active {
+		sel = e.blockRanges()
+	} else {
+		sel[0][0], sel[0][1] = e.selectionBytes()
+	}
+	defer op.Push(gtx.Ops).Pop()
+	op.Offset(e.textOffset()).Add(gtx.Ops)
+	cl := textPadding(e.lines.ends())
+	cl.Max = cl.Max.Add(e.viewSize)
+	h := e.Highlight
+	if !e.focused {
+		h.Color = h.inactiveColor()
+	}
+	for _, s := range sel {
+		if s[0] == s[1] {
+			continue
+		}
+		for _, r := range e.lineRegions(s[0], s[1], h.LineTails && !e.block.active) {
+			r = r.Sub(e.scrollOff).Intersect(cl)
+			if !r.Empty() {
+				drawHighlight(gtx, h, r)
+			}
+		}
+	}
+}
+
+func (e *Editor) PaintCaret(gtx layout.Context) {
+	if !e.caret.on && !e.drag.moving {
+		return
+	}
+	e.makeValid()
+	defer op.Push(gtx.Ops).Pop()
+	op.Offset(e.textOffset()).Add(gtx.Ops)
+	if e.drag.moving {
+		// Indicate where the dragged text will be dropped.
+		line, col, x, y := e.locate(e.drag.drop)
+		e.paintCaret(gtx, CaretBar, line, col, x, y)
+	}
+	if !e.caret.on {
+		return
+	}
+	e.paintCaret(gtx, e.CaretStyle, e.caret.line, e.caret.col, e.caret.x, e.caret.y)
+	for _, c := range e.carets {
+		line, col, x, y := e.locate(c)
+		e.paintCaret(gtx, e.CaretStyle, line, col, x, y)
+	}
+}
+
+// paintCaret paints a caret of the given style at the baseline
+// position (carX, carY) of column col of line.
+func (e *Editor) paintCaret(gtx layout.Context, style CaretStyle, line, col int, carX fixed.Int26_6, carY int) {
+	carWidth := fixed.I(gtx.Px(unit.Dp(1)))
+	if e.CaretWidth.V > 0 {
+		carWidth = fixed.I(gtx.Px(e.CaretWidth))
+	}
+	l := e.lines.entry(line)
+	carAsc, carDesc := -l.Bounds.Min.Y, l.Bounds.Max.Y
+	var carRect image.Rectangle
+	switch style {
+	case CaretBlock, CaretUnderline:
+		adv := e.caretAdvance(line, col)
+		carRect = image.Rectangle{
+			Min: image.Point{X: carX.Floor(), Y: carY - carAsc.Ceil()},
+			Max: image.Point{X: (carX + adv).Ceil(), Y: carY + carDesc.Ceil()},
+		}
+		if style == CaretUnderline {
+			carRect.Min.Y = carRect.Max.Y - carWidth.Ceil()
+		}
+	default:
+		carX -= carWidth / 2
+		carRect = image.Rectangle{
+			Min: image.Point{X: carX.Ceil(), Y: carY - carAsc.Ceil()},
+			Max: image.Point{X: carX.Ceil() + carWidth.Ceil(), Y: carY + carDesc.Ceil()},
+		}
+	}
+	carRect = carRect.Add(image.Point{
+		X: -e.scrollOff.X,
+		Y: -e.scrollOff.Y,
+	})
+	cl := textPadding(e.lines.ends())
+	// Account for caret width to each side.
+	whalf := (carWidth / 2).Ceil()
+	if cl.Max.X < whalf {
+		cl.Max.X = whalf
+	}
+	if cl.Min.X > -whalf {
+		cl.Min.X = -whalf
+	}
+	cl.Max = cl.Max.Add(e.viewSize)
+	carRect = cl.Intersect(carRect)
+	if !carRect.Empty() {
+		st := op.Push(gtx.Ops)
+		clip.Rect(carRect).Add(gtx.Ops)
+		paint.PaintOp{}.Add(gtx.Ops)
+		st.Pop()
+	}
+}
+
+// caretAdvance returns the width of the rune at column col of line,
+// or half the text size when there is no visible rune there.
+func (e *Editor) caretAdvance(line, col int) fixed.Int26_6 {
+	advs := e.lines.entry(line).Layout.Advances
+	if col < len(advs) && advs[col] > 0 {
+		return advs[col]
+	}
+	return e.textSize / 2
+}
+
+// Len is the length of the editor contents.
+func (e *Editor) Len() int {
+	if e.lineEnding == LineEndingCRLF {
+		return e.rr.len() + e.rr.newlines()
+	}
+	return e.rr.len()
 }
 
-// Text returns the contents of the editor.
+// Text returns the contents of the editor, with line terminators
+// following LineEnding.
 func (e *Editor) Text() string {
+	if e.lineEnding == LineEndingCRLF {
+		return strings.ReplaceAll(e.rr.String(), "\n", "\r\n")
+	}
 	return e.rr.String()
 }
 
-// SetText replaces the contents of the editor.
+// WriteTo writes the contents of the editor to w, with line
+// terminators following LineEnding, without copying them to an
+// intermediate string.
+//
+// WriteTo implements io.WriterTo.
+func (e *Editor) WriteTo(w io.Writer) (int64, error) {
+	return e.rr.reader(e.lineEnding == LineEndingCRLF).WriteTo(w)
+}
+
+// Reader returns a reader of the contents of the editor, with line
+// terminators following LineEnding. The reader reads the contents as
+// of the call to Reader, regardless of later changes.
+func (e *Editor) Reader() io.Reader {
+	return e.rr.reader(e.lineEnding == LineEndingCRLF)
+}
+
+// Segment returns the contents between the rune offsets start and
+// end, with line terminators following LineEnding. The offsets are
+// clamped to the editor contents, and start may be larger than end.
+func (e *Editor) Segment(start, end int) string {
+	s, t := e.rr.moveRunes(0, start), e.rr.moveRunes(0, end)
+	if s > t {
+		s, t = t, s
+	}
+	seg := e.rr.substring(s, t)
+	if e.lineEnding == LineEndingCRLF {
+		seg = strings.ReplaceAll(seg, "\n", "\r\n")
+	}
+	return seg
+}
+
+// SetText replaces the contents of the editor and clears the undo
+// history. The line ending convention is detected from s.
 func (e *Editor) SetText(s string) {
+	var removed int
+	if e.Deltas {
+		removed = e.rr.runeOffset(e.rr.len())
+	}
+	e.lineEnding = detectLineEnding(s)
 	e.rr = editBuffer{}
+	e.history = editHistory{}
+	e.composing = composition{}
+	e.load = loader{}
+	e.carets = nil
 	e.caret.xoff = 0
+	e.caret.anchor = 0
 	e.prepend(s)
+	e.rev.cur = e.rev.next()
+	if e.Deltas && (removed > 0 || e.rr.len() > 0) {
+		e.events = append(e.events, DeltaEvent{Removed: removed, Inserted: e.rr.String()})
+	}
+}
+
+// SetSelection selects the text between the rune offsets start and
+// end, and moves the caret to end. The offsets are clamped to the
+// editor contents, and start may be larger than end.
+func (e *Editor) SetSelection(start, end int) {
+	e.setSelection(e.rr.moveRunes(0, start), e.rr.moveRunes(0, end))
+}
+
+// Selection returns the rune offsets of the selection. The caret is
+// at end, and start is the opposite end of the selection. Start
+// equals end when no text is selected.
+func (e *Editor) Selection() (start, end int) {
+	return e.rr.runeOffset(e.caret.anchor), e.rr.runeOffset(e.rr.caret)
+}
+
+// SelectedText returns the currently selected text, if any.
+func (e *Editor) SelectedText() string {
+	if e.block.active {
+		return e.blockText()
+	}
+	start, end := e.selectionBytes()
+	return e.rr.substring(start, end)
+}
+
+// ClearSelection clears the selection, leaving the caret in place.
+func (e *Editor) ClearSelection() {
+	e.clearSelection()
+}
+
+func (e *Editor) clearSelection() {
+	e.caret.anchor = e.rr.caret
+	e.block.active = false
+}
+
+// setSelection selects the text between the byte offsets anchor and
+// caret, and moves the caret.
+func (e *Editor) setSelection(anchor, caret int) {
+	e.setCaret(caret)
+	e.caret.anchor = anchor
+	e.block.active = false
+}
+
+// extendSelection extends the selection started by a multi-click
+// to include the whole word or line at the caret.
+func (e *Editor) extendSelection() {
+	bounds := e.wordBounds
+	if e.drag.clicks >= 3 {
+		bounds = e.lineBounds
+	}
+	start, end := bounds(e.rr.caret)
+	if e.rr.caret < e.drag.start {
+		e.setSelection(e.drag.end, start)
+	} else {
+		e.setSelection(e.drag.start, end)
+	}
+}
+
+// wordBounds returns the byte offsets of the start and end of the
+// word containing idx. A run of spaces counts as a word.
+func (e *Editor) wordBounds(idx int) (start, end int) {
+	// Use the word after idx, or the word before if idx is at the
+	// end of a line.
+	if r, _ := e.rr.runeAt(idx); idx == e.rr.len() || r == '\n' {
+		if idx == 0 {
+			return idx, idx
+		}
+		if r, _ := e.rr.runeBefore(idx); r == '\n' {
+			return idx, idx
+		}
+		return e.segmentStart(idx), idx
+	}
+	end = e.segmentEnd(idx)
+	return e.segmentStart(end), end
+}
+
+// lineBounds returns the byte offsets of the start and end of the
+// line containing idx, excluding the line terminator.
+func (e *Editor) lineBounds(idx int) (start, end int) {
+	start, end = idx, idx
+	for start > 0 {
+		r, s := e.rr.runeBefore(start)
+		if r == '\n' {
+			break
+		}
+		start -= s
+	}
+	for end < e.rr.len() {
+		r, s := e.rr.runeAt(end)
+		if r == '\n' {
+			break
+		}
+		end += s
+	}
+	return start, end
+}
+
+// selectionBytes returns the ordered byte offsets of the selection.
+func (e *Editor) selectionBytes() (start, end int) {
+	start, end = e.caret.anchor, e.rr.caret
+	if start > end {
+		start, end = end, start
+	}
+	return start, end
+}
+
+// setCaret moves the caret to the byte offset idx.
+func (e *Editor) setCaret(idx int) {
+	e.makeValid()
+	e.rr.caret = idx
+	e.caret.line, e.caret.col, e.caret.x, e.caret.y = e.layoutCaret()
+	e.caret.xoff = 0
+}
+
+// regions returns the rectangles, in text coordinates, covering the
+// text between the byte offsets start and end. There is at most one
+// rectangle per line.
+func (e *Editor) regions(start, end int) []image.Rectangle {
+	return e.lineRegions(start, end, false)
+}
+
+// lineRegions is like regions, but if tails is set it extends the
+// regions of lines whose selection continues past the end of the
+// line to the right edge of the text area.
+func (e *Editor) lineRegions(start, end int, tails bool) []image.Rectangle {
+	var rects []image.Rectangle
+	e.eachRegion(start, end, tails, func(r image.Rectangle, baseline int) {
+		rects = append(rects, r)
+	})
+	return rects
+}
+
+// eachRegion calls f with each rectangle of lineRegions and the
+// baseline of its line.
+func (e *Editor) eachRegion(start, end int, tails bool, f func(r image.Rectangle, baseline int)) {
+	e.makeValid()
+	it := e.lines.atRune(e.rr.runeOffset(start))
+	// The lines count the runes of masked text, not its bytes.
+	idx := e.rr.byteOffset(it.m.runes)
+	for {
+		l, m, ok := it.next()
+		if !ok || idx >= end {
+			break
+		}
+		if r := l.run; r != nil {
+			// Runs are not drawn.
+			idx += r.bytes
+			continue
+		}
+		y := m.baseline(l.Line)
+		x := e.lineAlign(l)
+		rect := func(minX, maxX fixed.Int26_6) image.Rectangle {
+			return image.Rectangle{
+				Min: image.Point{X: minX.Floor(), Y: y - l.Ascent.Ceil()},
+				Max: image.Point{X: maxX.Ceil(), Y: y + l.Descent.Ceil()},
+			}
+		}
+		if b := l.bidi; b != nil {
+			// The selected runes may be apart in visual order.
+			offs := make([]int, len(l.Layout.Advances))
+			for j := range offs {
+				offs[j] = idx
+				_, s := e.rr.runeAt(idx)
+				idx += s
+			}
+			var minX fixed.Int26_6
+			in := false
+			for _, j := range b.order {
+				sel := start <= offs[j] && offs[j] < end
+				switch {
+				case sel && !in:
+					minX = x
+				case !sel && in:
+					f(rect(minX, x), y)
+				}
+				in = sel
+				x += l.Layout.Advances[j]
+			}
+			if in {
+				f(rect(minX, x), y)
+			}
+			continue
+		}
+		var minX, maxX fixed.Int26_6
+		found := false
+		for _, adv := range l.Layout.Advances {
+			if start <= idx && idx < end {
+				if !found {
+					minX = x
+					found = true
+				}
+				maxX = x + adv
+			}
+			x += adv
+			_, s := e.rr.runeAt(idx)
+			idx += s
+		}
+		if edge := fixed.I(e.scrollOff.X + e.viewSize.X); tails && found && idx < end && maxX < edge {
+			maxX = edge
+		}
+		if found {
+			f(rect(minX, maxX), y)
+		}
+	}
 }
 
 func (e *Editor) scrollBounds() image.Rectangle {
 	var b image.Rectangle
 	if e.SingleLine {
-		if len(e.lines) > 0 {
-			b.Min.X = align(e.Alignment, e.lines[0].Width, e.viewSize.X).Floor()
+		if e.lines.len() > 0 {
+			b.Min.X = e.lineAlign(e.lines.entry(0)).Floor()
 			if b.Min.X > 0 {
 				b.Min.X = 0
 			}
@@ -530,10 +2003,136 @@ func (e *Editor) scrollBounds() image.Rectangle {
 		b.Max.X = e.dims.Size.X + b.Min.X - e.viewSize.X
 	} else {
 		b.Max.Y = e.dims.Size.Y - e.viewSize.Y
+		if e.WrapPolicy == WrapNone {
+			b.Max.X = e.dims.Size.X - e.viewSize.X
+		}
 	}
 	return b
 }
 
+// ScrollOff returns the scroll offset of the text.
+func (e *Editor) ScrollOff() image.Point {
+	return e.scrollOff
+}
+
+// SetScrollOff sets the scroll offset of the text. The offset is
+// clamped to the text bounds during the next Layout.
+func (e *Editor) SetScrollOff(off image.Point) {
+	e.scrollOff = off
+	e.caret.scroll = false
+	e.scroller.Stop()
+	e.smooth.active = false
+}
+
+// ContentSize returns the size of the laid out text.
+func (e *Editor) ContentSize() image.Point {
+	e.makeValid()
+	return e.dims.Size
+}
+
+// ViewportFraction returns the visible range of the text along the
+// scroll axis, as fractions of the content length. The scroll axis is
+// horizontal for SingleLine editors and vertical otherwise.
+func (e *Editor) ViewportFraction() (start, end float32) {
+	e.makeValid()
+	content, view, off := e.scrollAxis()
+	if content <= 0 || content <= view {
+		return 0, 1
+	}
+	start = float32(off) / float32(content)
+	end = float32(off+view) / float32(content)
+	if end > 1 {
+		end = 1
+	}
+	return start, end
+}
+
+// SetScrollFraction scrolls the start of the viewport to the
+// fraction f of the content length along the scroll axis. The
+// offset is clamped to the text bounds during the next Layout.
+func (e *Editor) SetScrollFraction(f float32) {
+	e.makeValid()
+	content, _, _ := e.scrollAxis()
+	pos := int(f*float32(content) + .5)
+	off := e.scrollOff
+	if e.SingleLine {
+		off.X = pos + e.scrollBounds().Min.X
+	} else {
+		off.Y = pos
+	}
+	e.SetScrollOff(off)
+}
+
+// scrollAxis returns the content length, viewport length and
+// viewport offset along the scroll axis.
+func (e *Editor) scrollAxis() (content, view, off int) {
+	if e.SingleLine {
+		return e.dims.Size.X, e.viewSize.X, e.scrollOff.X - e.scrollBounds().Min.X
+	}
+	return e.dims.Size.Y, e.viewSize.Y, e.scrollOff.Y
+}
+
+// ScrollToLine scrolls the start of the logical line with the
+// zero-based index n to the top of the editor. Lines are separated
+// by line terminators.
+func (e *Editor) ScrollToLine(n int) {
+	e.makeValid()
+	if last := e.lines.total().newlines; n > last {
+		// Scroll to the last logical line.
+		n = last
+	}
+	if n < 0 {
+		n = 0
+	}
+	it := e.lines.atPara(n)
+	if l, ok := it.entry(); ok && l.run != nil {
+		e.shapeRun(n, n+1)
+		e.layoutLines()
+		it = e.lines.atPara(n)
+	}
+	var top int
+	if l, m, ok := it.next(); ok {
+		top = m.baseline(l.Line) - l.Ascent.Ceil()
+	}
+	e.jump()
+	e.SetScrollOff(image.Point{X: e.scrollOff.X, Y: top})
+}
+
+// VisibleLines returns the range of lines, as indices into the
+// lines counted by NumLines, that are at least partially visible.
+// The range is empty if there are no visible lines.
+func (e *Editor) VisibleLines() (first, last int) {
+	e.makeValid()
+	first, last = e.lines.len(), -1
+	it := e.lines.atY(e.scrollOff.Y)
+	for {
+		l, m, ok := it.next()
+		if !ok {
+			break
+		}
+		y := m.baseline(l.Line)
+		if y+l.Descent.Ceil() <= e.scrollOff.Y {
+			continue
+		}
+		if y-l.Ascent.Ceil() >= e.scrollOff.Y+e.viewSize.Y {
+			break
+		}
+		l0, l1 := m.line, m.line
+		if l.run != nil {
+			l0 += l.runLine(m, e.scrollOff.Y)
+			l1 += l.runLine(m, e.scrollOff.Y+e.viewSize.Y-1)
+		}
+		if l0 < first {
+			first = l0
+		}
+		last = l1
+	}
+	if last == -1 {
+		return 0, -1
+	}
+	return first, last
+}
+
 func (e *Editor) scrollRel(dx, dy int) {
 	e.scrollAbs(e.scrollOff.X+dx, e.scrollOff.Y+dy)
 }
@@ -556,51 +2155,247 @@ func (e *Editor) scrollAbs(x, y int) {
 	}
 }
 
-func (e *Editor) moveCoord(pos image.Point) {
-	var (
-		prevDesc fixed.Int26_6
-		carLine  int
-		y        int
-	)
-	for _, l := range e.lines {
-		y += (prevDesc + l.Ascent).Ceil()
-		prevDesc = l.Descent
-		if y+prevDesc.Ceil() >= pos.Y+e.scrollOff.Y {
-			break
+// offsetAt returns the byte offset closest to pos, without moving
+// the caret.
+func (e *Editor) offsetAt(pos image.Point) int {
+	caret, idx := e.caret, e.rr.caret
+	e.moveCoord(pos)
+	off := e.rr.caret
+	e.caret, e.rr.caret = caret, idx
+	return off
+}
+
+// startMove starts dragging the selection if the press evt at pos is
+// inside it, and reports whether it did.
+func (e *Editor) startMove(evt gesture.ClickEvent, pos image.Point) bool {
+	start, end := e.selectionBytes()
+	if evt.Source != pointer.Mouse || evt.NumClicks != 1 || evt.Modifiers != 0 || start == end || e.block.active {
+		return false
+	}
+	off := e.offsetAt(pos)
+	if off <= start || off >= end {
+		return false
+	}
+	e.drag.active = true
+	e.drag.clicks = 1
+	e.drag.moving = true
+	e.drag.drop = off
+	return true
+}
+
+// DropText inserts text dropped at pos, relative to the editor, and
+// selects it. The editor calls it for the transfer.DropEvents it
+// receives; call it directly for drops the window doesn't deliver.
+func (e *Editor) DropText(pos image.Point, text string) {
+	e.makeValid()
+	pos = pos.Sub(image.Point{X: e.gutter.width, Y: e.valign})
+	off := e.offsetAt(pos)
+	if text = e.filter(text); text == "" {
+		return
+	}
+	e.history.seal()
+	e.replace(off, off, text)
+	e.history.seal()
+	e.setSelection(off, e.rr.caret)
+	e.caret.scroll = true
+}
+
+// processDrop inserts text dropped onto the editor.
+func (e *Editor) processDrop(gtx layout.Context) {
+	for _, evt := range gtx.Events(&e.dropKey) {
+		de, ok := evt.(transfer.DropEvent)
+		if !ok {
+			continue
 		}
-		carLine++
+		pos := de.Position.Add(e.textOffset())
+		e.commitComposition()
+		e.DropText(image.Point{
+			X: int(math.Round(float64(pos.X))),
+			Y: int(math.Round(float64(pos.Y))),
+		}, de.Text)
+		e.requestFocus = true
+	}
+}
+
+// dropSelection moves the dragged selection to the drop offset, or
+// copies it if copy is set. Dropping a moved selection inside itself
+// places the caret at the drop offset instead.
+func (e *Editor) dropSelection(copy bool) {
+	start, end := e.selectionBytes()
+	d := e.drag.drop
+	if !copy && start <= d && d <= end {
+		e.setSelection(d, d)
+		return
+	}
+	txt := e.rr.substring(start, end)
+	e.history.seal()
+	e.BeginGroup()
+	switch {
+	case copy:
+		e.replace(d, d, txt)
+	case d > end:
+		e.replace(d, d, txt)
+		n := e.rr.caret - d
+		e.replace(start, end, "")
+		d -= end - start
+		e.rr.caret = d + n
+	default:
+		e.replace(start, end, "")
+		e.replace(d, d, txt)
 	}
+	e.EndGroup()
+	e.setSelection(d, e.rr.caret)
+	e.caret.scroll = true
+}
+
+func (e *Editor) moveCoord(pos image.Point) {
+	carLine := e.lineAt(pos.Y + e.scrollOff.Y)
 	x := fixed.I(pos.X + e.scrollOff.X)
 	e.moveToLine(x, carLine)
 	e.caret.xoff = 0
 }
 
-func (e *Editor) layoutText(s text.Shaper) ([]text.Line, layout.Dimensions) {
+// lineAt returns the index of the line at the vertical position y in
+// text coordinates. Positions past the last line return the number
+// of lines.
+func (e *Editor) lineAt(y int) int {
+	if e.lines.total().height() < y {
+		return e.lines.len()
+	}
+	// Find the first line whose bottom is at or below y.
+	l, _, s := e.lines.find(func(s lineSum) bool { return s.height() < y })
+	if l.run != nil {
+		return s.lines + l.runLine(s.mark(), y)
+	}
+	return s.lines
+}
+
+func (e *Editor) layoutText(s text.Shaper) []lineEntry {
 	e.rr.Reset()
 	var r io.Reader = &e.rr
 	if e.Mask != 0 {
 		e.maskReader.Reset(&e.rr, e.Mask)
+		if e.reveal.active {
+			e.maskReader.reveal = e.reveal.rune
+		}
 		r = &e.maskReader
 	}
+	var lines []lineEntry
+	switch {
+	case e.isLazy():
+		return e.layoutLazy()
+	case s != nil:
+		lines = lineEntries(e.shapeText(s, r))
+	default:
+		ls, _ := nullLayout(r)
+		lines = lineEntries(ls)
+	}
+	return e.applyGaps(lines, 0, true)
+}
+
+// shapeText shapes and breaks the text read from r into lines.
+func (e *Editor) shapeText(s text.Shaper, r io.Reader) []text.Line {
 	var lines []text.Line
-	if s != nil {
-		lines, _ = s.Layout(e.font, e.textSize, e.maxWidth, r)
+	if (e.WrapPolicy == WrapRunes || e.WrapPolicy == WrapParagraph) && !e.SingleLine {
+		lines, _ = s.Layout(e.font, e.textSize, inf, r)
+		lines = e.tabs.expand(s, e.font, e.textSize, lines)
+		lines = e.spacing.apply(lines, inf)
+		lines = wrapLines(lines, e.WrapPolicy, e.maxWidth)
 	} else {
-		lines, _ = nullLayout(r)
-	}
-	dims := linesDimens(lines)
-	for i := 0; i < len(lines)-1; i++ {
-		// To avoid layout flickering while editing, assume a soft newline takes
-		// up all available space.
-		if layout := lines[i].Layout; len(layout.Text) > 0 {
-			r := layout.Text[len(layout.Text)-1]
-			if r != '\n' {
-				dims.Size.X = e.maxWidth
+		lines, _ = s.Layout(e.font, e.textSize, e.maxWidth, r)
+		lines = e.tabs.expand(s, e.font, e.textSize, lines)
+		lines = e.spacing.apply(lines, e.maxWidth)
+	}
+	return lines
+}
+
+// textDims returns the dimensions of the text laid out in lines.
+func (e *Editor) textDims() layout.Dimensions {
+	s := e.lines.total()
+	h := s.height()
+	dims := layout.Dimensions{
+		Size: image.Point{X: s.width.Ceil(), Y: h},
+	}
+	if s.lines > 0 {
+		dims.Baseline = h - s.asc.Ceil()
+	}
+	// To avoid layout flickering while editing, assume a soft newline takes
+	// up all available space.
+	soft := s.soft
+	if s.lines > 0 && e.lines.entry(s.lines-1).sum().soft > 0 {
+		// The last line doesn't continue on another line.
+		soft--
+	}
+	if soft > 0 {
+		dims.Size.X = e.maxWidth
+	}
+	return dims
+}
+
+// alignOffset returns the vertical offset of the text for
+// VerticalAlignment.
+func (e *Editor) alignOffset() int {
+	free := e.viewSize.Y - e.dims.Size.Y
+	if free <= 0 || e.lines.len() == 0 {
+		return 0
+	}
+	switch e.VerticalAlignment {
+	case layout.Middle:
+		return free / 2
+	case layout.End:
+		return free
+	case layout.Baseline:
+		asc := e.lines.entry(0).Ascent.Ceil()
+		if off := (e.viewSize.Y - asc) / 2; off < free {
+			return off
+		}
+		return free
+	default:
+		return 0
+	}
+}
+
+// tabWidth returns the distance between tab stops, in spaces.
+func (e *Editor) tabWidth() int {
+	if e.TabWidth > 0 {
+		return e.TabWidth
+	}
+	return 4
+}
+
+// breakRunes breaks lines wider than maxWidth after the last rune
+// that fits.
+func breakRunes(lines []text.Line, maxWidth int) []text.Line {
+	maxX := fixed.I(maxWidth)
+	var res []text.Line
+	for _, l := range lines {
+		for l.Width > maxX {
+			var x fixed.Int26_6
+			n, idx := 0, 0
+			for i, r := range l.Layout.Text {
+				adv := l.Layout.Advances[n]
+				if n > 0 && x+adv > maxX {
+					break
+				}
+				x += adv
+				n++
+				idx = i + utf8.RuneLen(r)
+			}
+			if n == len(l.Layout.Advances) {
 				break
 			}
+			head := l
+			head.Layout = text.Layout{Text: l.Layout.Text[:idx], Advances: l.Layout.Advances[:n:n]}
+			head.Width = x
+			head.Bounds.Max.X -= l.Width - x
+			res = append(res, head)
+			l.Layout = text.Layout{Text: l.Layout.Text[idx:], Advances: l.Layout.Advances[n:]}
+			l.Width -= x
+			l.Bounds.Max.X -= x
 		}
+		res = append(res, l)
 	}
-	return lines, dims
+	return res
 }
 
 // CaretPos returns the line & column numbers of the caret.
@@ -609,70 +2404,360 @@ func (e *Editor) CaretPos() (line, col int) {
 	return e.caret.line, e.caret.col
 }
 
+// MoveTo moves the caret to the column col of the logical line
+// line, and clears the selection. Lines are separated by line
+// terminators, and both line and col are zero-based and clamped to
+// valid positions. If scroll is set, the caret is scrolled into
+// view.
+func (e *Editor) MoveTo(line, col int, scroll bool) {
+	idx := e.lineOffset(line)
+	_, end := e.lineBounds(idx)
+	for ; col > 0 && idx < end; col-- {
+		_, s := e.rr.runeAt(idx)
+		idx += s
+	}
+	e.history.seal()
+	e.setSelection(idx, idx)
+	if scroll {
+		e.jump()
+		e.caret.scroll = true
+	}
+}
+
+// lineOffset returns the byte offset of the start of the logical
+// line with the zero-based index line, clamped to the last line.
+func (e *Editor) lineOffset(line int) int {
+	idx := 0
+	for ; line > 0; line-- {
+		_, end := e.lineBounds(idx)
+		if end == e.rr.len() {
+			break
+		}
+		idx = end + 1
+	}
+	return idx
+}
+
+// Caret returns the rune offset of the caret.
+func (e *Editor) Caret() int {
+	return e.rr.runeOffset(e.rr.caret)
+}
+
+// SetCaret moves the caret to the rune offset offset, clears the
+// selection and scrolls the caret into view. Offsets outside the
+// text are clamped to its bounds.
+func (e *Editor) SetCaret(offset int) {
+	e.history.seal()
+	e.setSelection(e.rr.moveRunes(0, offset), e.rr.moveRunes(0, offset))
+	e.caret.scroll = true
+}
+
 // CaretCoords returns the coordinates of the caret, relative to the
 // editor itself.
 func (e *Editor) CaretCoords() f32.Point {
 	e.makeValid()
-	return f32.Pt(float32(e.caret.x)/64, float32(e.caret.y))
+	return f32.Pt(float32(e.caret.x)/64, float32(e.caret.y)).Add(e.textOffset())
 }
 
 func (e *Editor) layoutCaret() (line, col int, x fixed.Int26_6, y int) {
-	var idx int
-	var prevDesc fixed.Int26_6
-loop:
-	for {
-		x = 0
-		col = 0
-		l := e.lines[line]
-		y += (prevDesc + l.Ascent).Ceil()
-		prevDesc = l.Descent
-		for _, adv := range l.Layout.Advances {
-			if idx == e.rr.caret {
-				break loop
-			}
-			x += adv
-			_, s := e.rr.runeAt(idx)
-			idx += s
-			col++
-		}
-		if line == len(e.lines)-1 || idx > e.rr.caret {
-			break
+	return e.locate(e.rr.caret)
+}
+
+// locate returns the line, column and coordinates of the byte offset
+// caret.
+func (e *Editor) locate(caret int) (line, col int, x fixed.Int26_6, y int) {
+	r := e.rr.runeOffset(caret)
+	it := e.lines.atRune(r)
+	l, m, _ := it.next()
+	line, y = m.line, m.baseline(l.Line)
+	adv := l.Layout.Advances
+	col = r - m.runes
+	if col > len(adv) {
+		col = len(adv)
+	}
+	if b := l.bidi; b != nil {
+		x = b.caretX(adv, b.edges(adv), col)
+	} else {
+		for _, a := range adv[:col] {
+			x += a
 		}
-		line++
 	}
-	x += align(e.Alignment, e.lines[line].Width, e.viewSize.X)
+	x += e.lineAlign(l)
 	return
 }
 
 func (e *Editor) invalidate() {
 	e.valid = false
+	e.relayout.full = true
 }
 
 // Delete runes from the caret position. The sign of runes specifies the
-// direction to delete: positive is forward, negative is backward.
+// direction to delete: positive is forward, negative is backward. If
+// text is selected, Delete deletes the selection instead.
 func (e *Editor) Delete(runes int) {
-	e.rr.deleteRunes(runes)
-	e.caret.xoff = 0
-	e.invalidate()
+	if start, end := e.selectionBytes(); start != end {
+		e.replace(start, end, "")
+		return
+	}
+	end := e.rr.moveRunes(e.rr.caret, runes)
+	e.replace(e.rr.caret, end, "")
 }
 
-// Insert inserts text at the caret, moving the caret forward.
+// Insert inserts text at the caret, moving the caret forward. Selected
+// text is replaced by the insertion. The insertion is undone as a
+// single step.
 func (e *Editor) Insert(s string) {
+	e.history.seal()
 	e.append(s)
+	e.history.seal()
 	e.caret.scroll = true
-	e.invalidate()
 }
 
+// ApplyEdit replaces the text between the rune offsets start and end
+// with s, transforming the caret, selection, scroll position and
+// ranges such as decorations across the change instead of moving the
+// caret to the change. It is meant for changes made by others, such
+// as collaborators.
+//
+// If remote is set, the change is not recorded in the undo history
+// and ignores MaxLen. The undo history is transformed across it, or
+// cleared if the change overlaps the changes it records. Otherwise
+// the change is undone as a single step.
+func (e *Editor) ApplyEdit(start, end int, s string, remote bool) {
+	bstart, bend := e.rr.moveRunes(0, start), e.rr.moveRunes(0, end)
+	if bstart > bend {
+		bstart, bend = bend, bstart
+	}
+	anchor, dy, anchored := e.scrollAnchor()
+	caret, sel := e.rr.caret, e.caret.anchor
+	carets := append([]int(nil), e.carets...)
+	comp := e.composing
+	size := e.rr.len()
+	if remote {
+		s = normalizeNewlines(s)
+		if e.SingleLine {
+			s = strings.ReplaceAll(s, "\n", " ")
+		}
+		if bstart == bend && s == "" {
+			return
+		}
+		old := e.rev.cur
+		e.history.rebase(bstart, bend, len(s))
+		e.modify(bstart, bend, s)
+		e.history.renumber(old, &e.rev)
+	} else {
+		e.history.seal()
+		e.replace(bstart, bend, s)
+		e.history.seal()
+	}
+	removed, n := bend-bstart, e.rr.len()-size+bend-bstart
+	e.rr.caret = adjustOffset(caret, bstart, removed, n, false)
+	e.caret.anchor = adjustOffset(sel, bstart, removed, n, false)
+	for i, c := range carets {
+		carets[i] = adjustOffset(c, bstart, removed, n, false)
+	}
+	e.carets = carets
+	if comp.active {
+		if remote {
+			e.composing.rev = e.rev.next()
+		}
+		e.composing.start = adjustOffset(comp.start, bstart, removed, n, false)
+		e.composing.end = adjustOffset(comp.end, bstart, removed, n, false)
+	}
+	if anchored && bstart < anchor {
+		e.makeValid()
+		_, _, _, y := e.locate(adjustOffset(anchor, bstart, removed, n, false))
+		e.scrollAbs(e.scrollOff.X, y-dy)
+	}
+}
+
+// scrollAnchor returns the byte offset of the start of the first
+// visible line, and the distance from the top of the viewport to its
+// baseline. It reports false if the editor has not been laid out.
+func (e *Editor) scrollAnchor() (idx, dy int, ok bool) {
+	if e.shaper == nil || e.SingleLine || e.Mask != 0 {
+		return 0, 0, false
+	}
+	e.makeValid()
+	if line := e.lineAt(e.scrollOff.Y); line < e.lines.len() {
+		idx = e.lines.atLine(line).m.bytes
+	} else {
+		idx = e.lines.total().bytes
+	}
+	_, _, _, y := e.locate(idx)
+	return idx, y - e.scrollOff.Y, true
+}
+
+// append replaces the selection, if any, with s.
 func (e *Editor) append(s string) {
-	e.prepend(s)
-	e.rr.caret += len(s)
+	start, end := e.selectionBytes()
+	e.replace(start, end, s)
+}
+
+// replace replaces the text between the byte offsets start and end
+// with s, recording the change in the undo history. The caret is
+// moved to the end of the inserted text.
+func (e *Editor) replace(start, end int, s string) {
+	if start > end {
+		start, end = end, start
+	}
+	if e.readOnlyEdit(start, end) {
+		return
+	}
+	s = normalizeNewlines(s)
+	if e.SingleLine {
+		s = strings.ReplaceAll(s, "\n", " ")
+	}
+	removed := e.rr.substring(start, end)
+	if e.MaxLen > 0 {
+		var truncated bool
+		s, truncated = e.limitLen(s, utf8.RuneCountInString(removed))
+		if truncated {
+			e.events = append(e.events, MaxLenEvent{})
+		}
+	}
+	if start == end && s == "" {
+		return
+	}
+	e.history.record(modification{
+		start:    start,
+		removed:  removed,
+		inserted: s,
+		caret:    e.rr.caret,
+		time:     e.now,
+		before:   e.rev.cur,
+	}, undoPolicy{gap: e.UndoGap, words: e.UndoWords})
+	e.modify(start, end, s)
+	e.history.undo[len(e.history.undo)-1].rev = e.rev.cur
+}
+
+// modify replaces the text between the byte offsets start and end
+// with s, and updates the state depending on the contents.
+func (e *Editor) modify(start, end int, s string) {
+	e.hideRevealed()
+	e.adjustRanges(start, end, s)
+	for i, c := range e.carets {
+		e.carets[i] = adjustOffset(c, start, end-start, len(s), false)
+	}
+	e.rr.replace(start, end, s)
+	e.rev.cur = e.rev.next()
+	e.find.stale = true
+	e.caret.anchor = e.rr.caret
+	e.caret.xoff = 0
+	e.relayout.edit(start, end, len(s))
+	e.valid = false
+}
+
+// adjustRanges updates the rune ranges attached to the text, such as
+// spans and marks, for the replacement of the text between the byte
+// offsets start and end with s. It also generates the DeltaEvent of
+// the replacement if Deltas is set.
+func (e *Editor) adjustRanges(start, end int, s string) {
+	if !e.Deltas && len(e.spans) == 0 && len(e.marks) == 0 && len(e.decorations) == 0 && len(e.hover.ranges) == 0 && !e.snippet.active && len(e.readOnly) == 0 {
+		return
+	}
+	rstart := e.rr.runeOffset(start)
+	removed := utf8.RuneCountInString(e.rr.substring(start, end))
+	inserted := utf8.RuneCountInString(s)
+	if e.Deltas {
+		e.events = append(e.events, DeltaEvent{Start: rstart, Removed: removed, Inserted: s})
+	}
+	e.adjustSpans(rstart, removed, inserted)
+	e.adjustMarks(rstart, removed, inserted)
+	e.adjustDecorations(rstart, removed, inserted)
+	e.adjustHover(rstart, removed, inserted)
+	e.adjustSnippet(rstart, removed, inserted)
+	e.adjustReadOnly(rstart, removed, inserted)
+}
+
+// filter removes the runes of s not allowed by Filter.
+func (e *Editor) filter(s string) string {
+	if e.Number.Mode != NumberNone {
+		s = e.filterNumber(s)
+	}
+	if e.Filter == "" {
+		return s
+	}
+	return strings.Map(func(r rune) rune {
+		if !strings.ContainsRune(e.Filter, r) {
+			return -1
+		}
+		return r
+	}, s)
+}
+
+// limitLen truncates s such that the contents stay within MaxLen
+// runes when s replaces removed runes. It reports whether s was
+// truncated.
+func (e *Editor) limitLen(s string, removed int) (string, bool) {
+	avail := e.MaxLen - e.rr.runeOffset(e.rr.len()) + removed
+	if utf8.RuneCountInString(s) <= avail {
+		return s, false
+	}
+	n := 0
+	for ; avail > 0; avail-- {
+		_, size := utf8.DecodeRuneInString(s[n:])
+		n += size
+	}
+	return s[:n], true
+}
+
+// Undo reverts the most recent change to the editor contents and
+// reports whether there was a change to revert.
+func (e *Editor) Undo() bool {
+	h := &e.history
+	if len(h.undo) == 0 {
+		return false
+	}
+	h.seal()
+	for {
+		m := h.undo[len(h.undo)-1]
+		h.undo = h.undo[:len(h.undo)-1]
+		h.redo = append(h.redo, m)
+		e.modify(m.start, m.start+len(m.inserted), m.removed)
+		e.rr.caret = m.caret
+		e.caret.anchor = m.caret
+		e.rev.cur = m.before
+		if !m.joined || len(h.undo) == 0 {
+			break
+		}
+	}
+	e.caret.scroll = true
+	return true
+}
+
+// Redo re-applies the most recently undone change and reports
+// whether there was a change to re-apply.
+func (e *Editor) Redo() bool {
+	h := &e.history
+	if len(h.redo) == 0 {
+		return false
+	}
+	h.seal()
+	for {
+		m := h.redo[len(h.redo)-1]
+		h.redo = h.redo[:len(h.redo)-1]
+		h.undo = append(h.undo, m)
+		e.modify(m.start, m.start+len(m.removed), m.inserted)
+		e.rev.cur = m.rev
+		if len(h.redo) == 0 || !h.redo[len(h.redo)-1].joined {
+			break
+		}
+	}
+	e.caret.scroll = true
+	return true
 }
 
 func (e *Editor) prepend(s string) {
+	s = normalizeNewlines(s)
 	if e.SingleLine {
 		s = strings.ReplaceAll(s, "\n", " ")
 	}
+	if e.MaxLen > 0 {
+		s, _ = e.limitLen(s, 0)
+	}
 	e.rr.prepend(s)
+	e.find.stale = true
 	e.caret.xoff = 0
 	e.invalidate()
 }
@@ -680,23 +2765,16 @@ func (e *Editor) prepend(s string) {
 func (e *Editor) movePages(pages int) {
 	e.makeValid()
 	y := e.caret.y + pages*e.viewSize.Y
-	var (
-		prevDesc fixed.Int26_6
-		carLine2 int
-	)
-	y2 := e.lines[0].Ascent.Ceil()
-	for i := 1; i < len(e.lines); i++ {
-		if y2 >= y {
-			break
-		}
-		l := e.lines[i]
-		h := (prevDesc + l.Ascent).Ceil()
-		prevDesc = l.Descent
-		if y2+h-y >= y-y2 {
-			break
+	// Find the line with the baseline closest to y, starting with
+	// the last line with its baseline at or above y.
+	l, _, s := e.lines.find(func(s lineSum) bool { return s.entries == 0 || s.mark().y <= y })
+	carLine2 := s.lines
+	if l.run != nil {
+		carLine2 += l.runLine(s.mark(), y)
+	} else if carLine2 > 0 {
+		if y2 := s.mark().baseline(l.Line); y2 > y && y2-y >= y-s.mark().y {
+			carLine2--
 		}
-		y2 += h
-		carLine2++
 	}
 	e.moveToLine(e.caret.x+e.caret.xoff, carLine2)
 }
@@ -706,41 +2784,45 @@ func (e *Editor) moveToLine(x fixed.Int26_6, line int) {
 	if line < 0 {
 		line = 0
 	}
-	if line >= len(e.lines) {
-		line = len(e.lines) - 1
+	if n := e.lines.len(); line >= n {
+		line = n - 1
 	}
 
-	prevDesc := e.lines[line].Descent
-	for e.caret.line < line {
-		e.moveEnd()
-		l := e.lines[e.caret.line]
-		_, s := e.rr.runeAt(e.rr.caret)
-		e.rr.caret += s
-		e.caret.y += (prevDesc + l.Ascent).Ceil()
-		e.caret.col = 0
-		prevDesc = l.Descent
-		e.caret.line++
-	}
-	for e.caret.line > line {
-		e.moveStart()
-		l := e.lines[e.caret.line]
-		_, s := e.rr.runeBefore(e.rr.caret)
-		e.rr.caret -= s
-		e.caret.y -= (prevDesc + l.Ascent).Ceil()
-		prevDesc = l.Descent
-		e.caret.line--
-		l = e.lines[e.caret.line]
-		e.caret.col = len(l.Layout.Advances) - 1
+	it := e.lines.atLine(line)
+	if l, m, _ := it.next(); l.run != nil {
+		// Shape the estimated paragraph of the line, and move to
+		// its first line, or its last line if the line ends the
+		// run.
+		r := l.run
+		o := line - m.line
+		p := m.para + o*r.paras/r.lines
+		e.shapeRun(p, p+1)
+		line = e.lines.atPara(p).m.line
+		if o > 0 && o == r.lines-1 {
+			line = e.lines.len() - 1
+			if p < e.lines.total().newlines {
+				line = e.lines.atPara(p+1).m.line - 1
+			}
+		}
 	}
 
-	e.moveStart()
-	l := e.lines[line]
-	e.caret.x = align(e.Alignment, l.Width, e.viewSize.X)
+	// Move to the start of the line.
+	it = e.lines.atLine(line)
+	l, m, _ := it.next()
+	e.rr.caret = e.rr.byteOffset(m.runes)
+	e.caret.line = line
+	e.caret.col = 0
+	e.caret.y = m.baseline(l.Line)
+	e.caret.x = e.lineAlign(l)
 	// Only move past the end of the last line
 	end := 0
-	if line < len(e.lines)-1 {
+	if line < e.lines.len()-1 {
 		end = 1
 	}
+	if l.bidi != nil {
+		e.moveToX(l, x, len(l.Layout.Advances)-end)
+		return
+	}
 	// Move to rune closest to x.
 	for i := 0; i < len(l.Layout.Advances)-end; i++ {
 		adv := l.Layout.Advances[i]
@@ -758,9 +2840,39 @@ func (e *Editor) moveToLine(x fixed.Int26_6, line int) {
 	e.caret.xoff = x - e.caret.x
 }
 
+// moveToX moves the caret from the start of its reordered line l to
+// the caret position closest to x, among the first n+1.
+func (e *Editor) moveToX(l lineEntry, x fixed.Int26_6, n int) {
+	b := l.bidi
+	adv := l.Layout.Advances
+	left := b.edges(adv)
+	a := e.lineAlign(l)
+	col, dist := 0, fixed.Int26_6(-1)
+	for i := 0; i <= n; i++ {
+		d := a + b.caretX(adv, left, i) - x
+		if d < 0 {
+			d = -d
+		}
+		if dist == -1 || d < dist {
+			col, dist = i, d
+		}
+	}
+	for ; e.caret.col < col; e.caret.col++ {
+		_, s := e.rr.runeAt(e.rr.caret)
+		e.rr.caret += s
+	}
+	e.caret.x = a + b.caretX(adv, left, col)
+	e.caret.xoff = x - e.caret.x
+}
+
 // Move the caret: positive distance moves forward, negative distance moves
-// backward.
+// backward. Move clears the selection.
 func (e *Editor) Move(distance int) {
+	e.move(distance)
+	e.clearSelection()
+}
+
+func (e *Editor) move(distance int) {
 	e.makeValid()
 	for ; distance < 0 && e.rr.caret > 0; distance++ {
 		if e.caret.col == 0 {
@@ -768,17 +2880,17 @@ func (e *Editor) Move(distance int) {
 			e.moveToLine(fixed.I(e.maxWidth), e.caret.line-1)
 			continue
 		}
-		l := e.lines[e.caret.line].Layout
+		l := e.lines.entry(e.caret.line).Layout
 		_, s := e.rr.runeBefore(e.rr.caret)
 		e.rr.caret -= s
 		e.caret.col--
 		e.caret.x -= l.Advances[e.caret.col]
 	}
 	for ; distance > 0 && e.rr.caret < e.rr.len(); distance-- {
-		l := e.lines[e.caret.line].Layout
+		l := e.lines.entry(e.caret.line).Layout
 		// Only move past the end of the last line
 		end := 0
-		if e.caret.line < len(e.lines)-1 {
+		if e.caret.line < e.lines.len()-1 {
 			end = 1
 		}
 		if e.caret.col >= len(l.Advances)-end {
@@ -791,12 +2903,14 @@ func (e *Editor) Move(distance int) {
 		e.rr.caret += s
 		e.caret.col++
 	}
+	e.visualCaret()
 	e.caret.xoff = 0
 }
 
 func (e *Editor) moveStart() {
 	e.makeValid()
-	layout := e.lines[e.caret.line].Layout
+	l := e.lines.entry(e.caret.line)
+	layout := l.Layout
 	for i := e.caret.col - 1; i >= 0; i-- {
 		_, s := e.rr.runeBefore(e.rr.caret)
 		e.rr.caret -= s
@@ -804,14 +2918,18 @@ func (e *Editor) moveStart() {
 	}
 	e.caret.col = 0
 	e.caret.xoff = -e.caret.x
+	if l.bidi != nil {
+		e.visualCaret()
+		e.caret.xoff = 0
+	}
 }
 
 func (e *Editor) moveEnd() {
 	e.makeValid()
-	l := e.lines[e.caret.line]
+	l := e.lines.entry(e.caret.line)
 	// Only move past the end of the last line
 	end := 0
-	if e.caret.line < len(e.lines)-1 {
+	if e.caret.line < e.lines.len()-1 {
 		end = 1
 	}
 	layout := l.Layout
@@ -822,8 +2940,12 @@ func (e *Editor) moveEnd() {
 		e.caret.x += adv
 		e.caret.col++
 	}
-	a := align(e.Alignment, l.Width, e.viewSize.X)
+	a := e.lineAlign(l)
 	e.caret.xoff = l.Width + a - e.caret.x
+	if l.bidi != nil {
+		e.visualCaret()
+		e.caret.xoff = 0
+	}
 }
 
 // moveWord moves the caret to the next word in the specified direction.
@@ -831,34 +2953,26 @@ func (e *Editor) moveEnd() {
 // Absolute values greater than one will skip that many words.
 func (e *Editor) moveWord(distance int) {
 	e.makeValid()
-	// split the distance information into constituent parts to be
-	// used independently.
-	words, direction := distance, 1
-	if distance < 0 {
-		words, direction = distance*-1, -1
-	}
-	// atEnd if caret is at either side of the buffer.
-	atEnd := func() bool {
-		return e.rr.caret == 0 || e.rr.caret == e.rr.len()
-	}
-	// next returns the appropriate rune given the direction.
-	next := func() (r rune) {
-		if direction < 0 {
-			r, _ = e.rr.runeBefore(e.rr.caret)
-		} else {
-			r, _ = e.rr.runeAt(e.rr.caret)
+	idx := e.rr.caret
+	for ; distance > 0; distance-- {
+		for idx < e.rr.len() {
+			if r, _ := e.rr.runeAt(idx); !unicode.IsSpace(r) {
+				break
+			}
+			idx = e.segmentEnd(idx)
 		}
-		return r
+		idx = e.segmentEnd(idx)
 	}
-	for ii := 0; ii < words; ii++ {
-		for r := next(); unicode.IsSpace(r) && !atEnd(); r = next() {
-			e.Move(direction)
-		}
-		e.Move(direction)
-		for r := next(); !unicode.IsSpace(r) && !atEnd(); r = next() {
-			e.Move(direction)
+	for ; distance < 0; distance++ {
+		for idx > 0 {
+			if r, _ := e.rr.runeBefore(idx); !unicode.IsSpace(r) {
+				break
+			}
+			idx = e.segmentStart(idx)
 		}
+		idx = e.segmentStart(idx)
 	}
+	e.setCaret(idx)
 }
 
 // deleteWord the next word(s) in the specified direction.
@@ -867,61 +2981,41 @@ func (e *Editor) moveWord(distance int) {
 // Absolute values greater than one will delete that many words.
 func (e *Editor) deleteWord(distance int) {
 	e.makeValid()
-	// split the distance information into constituent parts to be
-	// used independently.
-	words, direction := distance, 1
-	if distance < 0 {
-		words, direction = distance*-1, -1
-	}
-	// atEnd if offset is at or beyond either side of the buffer.
-	atEnd := func(offset int) bool {
-		idx := e.rr.caret + offset*direction
-		return idx <= 0 || idx >= e.rr.len()
-	}
-	// next returns the appropriate rune given the direction and offset.
-	next := func(offset int) (r rune) {
-		idx := e.rr.caret + offset*direction
-		if idx < 0 {
-			idx = 0
-		} else if idx > e.rr.len() {
-			idx = e.rr.len()
-		}
-		if direction < 0 {
-			r, _ = e.rr.runeBefore(idx)
-		} else {
-			r, _ = e.rr.runeAt(idx)
-		}
-		return r
+	if distance == 0 {
+		return
 	}
-	var runes = 1
-	for ii := 0; ii < words; ii++ {
-		if r := next(runes); unicode.IsSpace(r) {
-			for r := next(runes); unicode.IsSpace(r) && !atEnd(runes); r = next(runes) {
-				runes += 1
-			}
-		} else {
-			for r := next(runes); !unicode.IsSpace(r) && !atEnd(runes); r = next(runes) {
-				runes += 1
-			}
+	// The rune next to the caret is always deleted.
+	idx := e.rr.caret
+	if distance > 0 {
+		idx = e.rr.moveRunes(idx, 1)
+		for ; distance > 0; distance-- {
+			idx = e.segmentEnd(idx)
+		}
+	} else {
+		idx = e.rr.moveRunes(idx, -1)
+		for ; distance < 0; distance++ {
+			idx = e.segmentStart(idx)
 		}
 	}
-	e.Delete(runes * direction)
+	e.Delete(e.rr.runeOffset(idx) - e.rr.runeOffset(e.rr.caret))
 }
 
-func (e *Editor) scrollToCaret() {
+func (e *Editor) scrollToCaret(gtx layout.Context) {
 	e.makeValid()
-	l := e.lines[e.caret.line]
-	if e.SingleLine {
+	l := e.lines.entry(e.caret.line).Line
+	mx, my := e.caretMargins(gtx, l)
+	if e.SingleLine || e.WrapPolicy == WrapNone {
 		var dist int
-		if d := e.caret.x.Floor() - e.scrollOff.X; d < 0 {
+		if d := e.caret.x.Floor() - mx - e.scrollOff.X; d < 0 {
 			dist = d
-		} else if d := e.caret.x.Ceil() - (e.scrollOff.X + e.viewSize.X); d > 0 {
+		} else if d := e.caret.x.Ceil() + mx - (e.scrollOff.X + e.viewSize.X); d > 0 {
 			dist = d
 		}
 		e.scrollRel(dist, 0)
-	} else {
-		miny := e.caret.y - l.Ascent.Ceil()
-		maxy := e.caret.y + l.Descent.Ceil()
+	}
+	if !e.SingleLine {
+		miny := e.caret.y - l.Ascent.Ceil() - my
+		maxy := e.caret.y + l.Descent.Ceil() + my
 		var dist int
 		if d := miny - e.scrollOff.Y; d < 0 {
 			dist = d
@@ -932,10 +3026,63 @@ func (e *Editor) scrollToCaret() {
 	}
 }
 
+// caretMargins returns the horizontal and vertical CaretMargin in
+// pixels, reduced to leave room for the caret line l.
+func (e *Editor) caretMargins(gtx layout.Context, l text.Line) (x, y int) {
+	m := e.CaretMargin
+	h := (l.Ascent + l.Descent).Ceil()
+	x = gtx.Px(m.Horizontal)
+	y = gtx.Px(m.Vertical) + m.Lines*h
+	if max := e.viewSize.X / 2; x > max {
+		x = max
+	}
+	if max := (e.viewSize.Y - h) / 2; y > max {
+		y = max
+	}
+	if x < 0 {
+		x = 0
+	}
+	if y < 0 {
+		y = 0
+	}
+	return x, y
+}
+
 // NumLines returns the number of lines in the editor.
 func (e *Editor) NumLines() int {
 	e.makeValid()
-	return len(e.lines)
+	return e.lines.len()
+}
+
+// drawHighlight paints the highlight h for selected text.
+func drawHighlight(gtx layout.Context, h Highlight, r image.Rectangle) {
+	defer op.Push(gtx.Ops).Pop()
+	if rad := float32(gtx.Px(h.CornerRadius)); rad > 0 {
+		clip.UniformRRect(layout.FRect(r), rad).Add(gtx.Ops)
+	} else {
+		clip.Rect(r).Add(gtx.Ops)
+	}
+	paint.ColorOp{Color: h.color()}.Add(gtx.Ops)
+	paint.PaintOp{}.Add(gtx.Ops)
+}
+
+// color returns the color of the highlight.
+func (h Highlight) color() color.NRGBA {
+	if h.Color == (color.NRGBA{}) {
+		return color.NRGBA{B: 0xff, A: 0x40}
+	}
+	return h.Color
+}
+
+// inactiveColor returns the color of the highlight while the editor
+// is unfocused.
+func (h Highlight) inactiveColor() color.NRGBA {
+	if h.InactiveColor != (color.NRGBA{}) {
+		return h.InactiveColor
+	}
+	col := h.color()
+	col.A /= 2
+	return col
 }
 
 func nullLayout(r io.Reader) ([]text.Line, error) {
@@ -962,5 +3109,10 @@ func nullLayout(r io.Reader) ([]text.Line, error) {
 	}, rerr
 }
 
-func (s ChangeEvent) isEditorEvent() {}
-func (s SubmitEvent) isEditorEvent() {}
+func (s ChangeEvent) isEditorEvent()   {}
+func (s DeltaEvent) isEditorEvent()    {}
+func (s SubmitEvent) isEditorEvent()   {}
+func (s SelectEvent) isEditorEvent()   {}
+func (s MaxLenEvent) isEditorEvent()   {}
+func (s TraverseEvent) isEditorEvent() {}
+func (s CaretEvent) isEditorEvent()    {}
@@ -3,22 +3,36 @@
 package widget
 
 import (
+	"bytes"
 	"fmt"
 	"image"
+	"image/color"
+	"io"
 	"math/rand"
 	"reflect"
+	"regexp"
+	"strings"
 	"testing"
+	"testing/iotest"
 	"testing/quick"
+	"time"
 	"unicode"
+	"unicode/utf8"
 
 	"gioui.org/f32"
 	"gioui.org/font/gofont"
+	"gioui.org/io/clipboard"
 	"gioui.org/io/event"
 	"gioui.org/io/key"
+	"gioui.org/io/pointer"
+	"gioui.org/io/router"
+	"gioui.org/io/transfer"
 	"gioui.org/layout"
 	"gioui.org/op"
 	"gioui.org/text"
 	"gioui.org/unit"
+
+	"golang.org/x/image/math/fixed"
 )
 
 func TestEditor(t *testing.T) {
@@ -61,7 +75,7 @@ func TestEditor(t *testing.T) {
 	assertCaret(t, e, 0, 3, len("æbc"))
 
 	// When a password mask is applied, it should replace all visible glyphs
-	for i, line := range e.lines {
+	for i, line := range e.lines.slice() {
 		for j, r := range line.Layout.Text {
 			if r != e.Mask && !unicode.IsSpace(r) {
 				t.Errorf("glyph at (%d, %d) is unmasked rune %d", i, j, r)
@@ -70,233 +84,3385 @@ func TestEditor(t *testing.T) {
 	}
 }
 
-func TestEditorDimensions(t *testing.T) {
-	e := new(Editor)
-	tq := &testQueue{
-		events: []event.Event{
-			key.EditEvent{Text: "A"},
-		},
+func TestEditBuffer(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	var (
+		b    editBuffer
+		want string
+	)
+	words := []string{"", "a", "ö", "日本", "\n", "hello ", "😀"}
+	// offset returns a random rune boundary of want.
+	offset := func() int {
+		n := rng.Intn(utf8.RuneCountInString(want) + 1)
+		return len(string([]rune(want)[:n]))
+	}
+	for i := 0; i < 1000; i++ {
+		start, end := offset(), offset()
+		if start > end {
+			start, end = end, start
+		}
+		s := words[rng.Intn(len(words))]
+		if rng.Intn(2) == 0 {
+			// Type at the previous insertion.
+			start, end = b.caret, b.caret
+		}
+		b.replace(start, end, s)
+		want = want[:start] + s + want[end:]
+		if got := b.String(); got != want {
+			t.Fatalf("step %d: got %q, want %q", i, got, want)
+		}
+		if b.len() != len(want) || b.caret != start+len(s) {
+			t.Fatalf("step %d: got length %d and caret %d, want %d and %d", i, b.len(), b.caret, len(want), start+len(s))
+		}
+		idx := offset()
+		if got, want := b.runeOffset(idx), utf8.RuneCountInString(want[:idx]); got != want {
+			t.Fatalf("step %d: got rune offset %d, want %d", i, got, want)
+		}
+		r, n := b.runeAt(idx)
+		if wr, wn := utf8.DecodeRuneInString(want[idx:]); idx < len(want) && (r != wr || n != wn) {
+			t.Fatalf("step %d: got rune %q at %d, want %q", i, r, idx, wr)
+		}
+		r, n = b.runeBefore(idx)
+		if wr, wn := utf8.DecodeLastRuneInString(want[:idx]); idx > 0 && (r != wr || n != wn) {
+			t.Fatalf("step %d: got rune %q before %d, want %q", i, r, idx, wr)
+		}
+		if got, want := b.substring(idx, len(want)), want[idx:]; got != want {
+			t.Fatalf("step %d: got substring %q, want %q", i, got, want)
+		}
+		n = strings.Count(want[:idx], "\n")
+		if got := b.linesBefore(idx); got != n {
+			t.Fatalf("step %d: got %d newlines before %d, want %d", i, got, idx, n)
+		}
+		if got, want := b.lineStart(n), strings.LastIndexByte(want[:idx], '\n')+1; got != want {
+			t.Fatalf("step %d: got start %d of line %d, want %d", i, got, n, want)
+		}
+		if got, want := b.newlines(), strings.Count(want, "\n"); got != want {
+			t.Fatalf("step %d: got %d newlines, want %d", i, got, want)
+		}
+	}
+	b.Reset()
+	var buf bytes.Buffer
+	if _, err := io.Copy(&buf, &b); err != nil || buf.String() != want {
+		t.Errorf("read %q, %v, want %q", buf.String(), err, want)
 	}
+}
+
+func TestEditorRelayout(t *testing.T) {
 	gtx := layout.Context{
 		Ops:         new(op.Ops),
-		Constraints: layout.Constraints{Max: image.Pt(100, 100)},
-		Queue:       tq,
+		Constraints: layout.Exact(image.Pt(60, 100)),
 	}
 	cache := text.NewCache(gofont.Collection())
-	fontSize := unit.Px(10)
-	font := text.Font{}
-	dims := e.Layout(gtx, cache, font, fontSize)
-	if dims.Size.X == 0 {
-		t.Errorf("EditEvent was not reflected in Editor width")
+	rng := rand.New(rand.NewSource(1))
+	words := []string{"", "a", "\n", "hello world ", "\tx", "日本"}
+	for _, wrap := range []WrapPolicy{WrapWords, WrapRunes} {
+		e := &Editor{WrapPolicy: wrap}
+		e.SetText("one two three\nfour\n\nfive six seven eight")
+		e.Layout(gtx, cache, text.Font{}, unit.Px(10))
+		for i := 0; i < 200; i++ {
+			for j := rng.Intn(3); j >= 0; j-- {
+				start := e.rr.moveRunes(0, rng.Intn(e.Len()+1))
+				end := e.rr.moveRunes(start, rng.Intn(4))
+				e.replace(start, end, words[rng.Intn(len(words))])
+			}
+			e.makeValid()
+			if !reflect.DeepEqual(e.lines.slice(), e.layoutText(e.shaper)) {
+				t.Fatalf("%v, step %d: relayout of %q differs from layout", wrap, i, e.Text())
+			}
+		}
 	}
 }
 
-type testQueue struct {
-	events []event.Event
-}
-
-func (q *testQueue) Events(_ event.Tag) []event.Event {
-	return q.events
-}
-
-// assertCaret asserts that the editor caret is at a particular line
-// and column, and that the byte position matches as well.
-func assertCaret(t *testing.T, e *Editor, line, col, bytes int) {
-	t.Helper()
-	gotLine, gotCol := e.CaretPos()
-	if gotLine != line || gotCol != col {
-		t.Errorf("caret at (%d, %d), expected (%d, %d)", gotLine, gotCol, line, col)
+func TestEditorLazyLayout(t *testing.T) {
+	gtx := layout.Context{
+		Ops:         new(op.Ops),
+		Constraints: layout.Exact(image.Pt(100, 50)),
 	}
-	if bytes != e.rr.caret {
-		t.Errorf("caret at buffer position %d, expected %d", e.rr.caret, bytes)
+	cache := text.NewCache(gofont.Collection())
+	var b strings.Builder
+	for i := 0; i < 1000; i++ {
+		fmt.Fprintf(&b, "line %d\n", i)
+	}
+	e := &Editor{LazyLayout: true}
+	e.SetText(b.String())
+	e.Layout(gtx, cache, text.Font{}, unit.Px(10))
+	shaped := func() (first, last int) {
+		first, last = -1, -1
+		it := e.lines.atLine(0)
+		for {
+			l, m, ok := it.next()
+			if !ok {
+				break
+			}
+			if l.run == nil {
+				if first == -1 {
+					first = m.line
+				}
+				last = m.line
+			}
+		}
+		return first, last
+	}
+	if first, last := shaped(); first != 0 || last < 5 || last > 100 {
+		t.Errorf("got shaped lines %d-%d, want the lines near the top", first, last)
+	}
+	e.ScrollToLine(900)
+	e.Layout(gtx, cache, text.Font{}, unit.Px(10))
+	if got, want := e.NumLines(), 1001; got != want {
+		t.Errorf("got %d lines, want %d", got, want)
+	}
+	full := new(Editor)
+	full.SetText(b.String())
+	full.Layout(gtx, cache, text.Font{}, unit.Px(10))
+	first, last := e.VisibleLines()
+	if first != 900 {
+		t.Errorf("got first visible line %d, want 900", first)
+	}
+	for i := first; i <= last; i++ {
+		if l := e.lines.entry(i); l.run != nil || !reflect.DeepEqual(l.Line, full.lines.entry(i).Line) {
+			t.Errorf("visible line %d is not shaped", i)
+		}
+	}
+	if e.lines.entry(500).run == nil {
+		t.Error("line 500 is shaped, want a run")
+	}
+	// The unshaped paragraphs are held by a few runs.
+	if total := e.lines.total(); total.entries-total.shaped > 3 {
+		t.Errorf("got %d runs, want at most 3", total.entries-total.shaped)
 	}
 }
 
-type editMutation int
-
-const (
-	setText editMutation = iota
-	moveRune
-	moveLine
-	movePage
-	moveStart
-	moveEnd
-	moveCoord
-	moveWord
-	deleteWord
-	moveLast // Mark end; never generated.
-)
-
-func TestEditorCaretConsistency(t *testing.T) {
+func TestEditorVirtualLayout(t *testing.T) {
 	gtx := layout.Context{
 		Ops:         new(op.Ops),
-		Constraints: layout.Exact(image.Pt(100, 100)),
+		Constraints: layout.Exact(image.Pt(200, 50)),
 	}
 	cache := text.NewCache(gofont.Collection())
-	fontSize := unit.Px(10)
-	font := text.Font{}
-	for _, a := range []text.Alignment{text.Start, text.Middle, text.End} {
-		e := &Editor{
-			Alignment: a,
+	var b strings.Builder
+	for i := 0; i < 10000; i++ {
+		fmt.Fprintf(&b, "line %d\n", i)
+	}
+	e := &Editor{LazyLayout: true, LineNumbers: true}
+	e.SetText(b.String())
+	full := &Editor{LineNumbers: true}
+	full.SetText(b.String())
+	full.Layout(gtx, cache, text.Font{}, unit.Px(10))
+	for line := 0; line < 10000; line += 23 {
+		e.ScrollToLine(line)
+		e.Layout(gtx, cache, text.Font{}, unit.Px(10))
+		total := e.lines.total()
+		if shaped := total.shaped; shaped > 2*maxShaped {
+			t.Fatalf("line %d: %d shaped lines, want at most %d", line, shaped, 2*maxShaped)
 		}
-		e.Layout(gtx, cache, font, fontSize)
-
-		consistent := func() error {
-			t.Helper()
-			gotLine, gotCol := e.CaretPos()
-			gotCoords := e.CaretCoords()
-			wantLine, wantCol, wantX, wantY := e.layoutCaret()
-			wantCoords := f32.Pt(float32(wantX)/64, float32(wantY))
-			if wantLine == gotLine && wantCol == gotCol && gotCoords == wantCoords {
-				return nil
+		first, last := e.VisibleLines()
+		if first != line && line < 9990 {
+			t.Fatalf("got first visible line %d, want %d", first, line)
+		}
+		for i := first; i <= last; i++ {
+			if !reflect.DeepEqual(e.lines.entry(i).Line, full.lines.entry(i).Line) {
+				t.Fatalf("visible line %d is not shaped", i)
 			}
-			return fmt.Errorf("caret (%d,%d) pos %s, want (%d,%d) pos %s", gotLine, gotCol, gotCoords, wantLine, wantCol, wantCoords)
 		}
-		if err := consistent(); err != nil {
-			t.Errorf("initial editor inconsistency (alignment %s): %v", a, err)
+	}
+	// Line marks must match the state of a full iteration.
+	var (
+		want     lineMark
+		prevDesc fixed.Int26_6
+	)
+	it := e.lines.atLine(0)
+	for i := 0; ; i++ {
+		l, m, ok := it.next()
+		if !ok {
+			break
 		}
-
-		move := func(mutation editMutation, str string, distance int8, x, y uint16) bool {
-			switch mutation {
-			case setText:
-				e.SetText(str)
-				e.Layout(gtx, cache, font, fontSize)
-			case moveRune:
-				e.Move(int(distance))
-			case moveLine:
-				e.moveLines(int(distance))
-			case movePage:
-				e.movePages(int(distance))
-			case moveStart:
-				e.moveStart()
-			case moveEnd:
-				e.moveEnd()
-			case moveCoord:
-				e.moveCoord(image.Pt(int(x), int(y)))
-			case moveWord:
-				e.moveWord(int(distance))
-			case deleteWord:
-				e.deleteWord(int(distance))
-			default:
-				return false
+		if m != want {
+			t.Fatalf("line %d: got mark %+v, want %+v", i, m, want)
+		}
+		if i%97 == 0 {
+			if got := e.lines.atLine(want.line).m; got != want {
+				t.Fatalf("line %d: got seek mark %+v, want %+v", i, got, want)
 			}
-			if err := consistent(); err != nil {
-				t.Error(err)
-				return false
+			if got := e.lines.atRune(want.runes).m; got != want {
+				t.Fatalf("line %d: got rune seek mark %+v, want %+v", i, got, want)
 			}
-			return true
 		}
-		if err := quick.Check(move, nil); err != nil {
-			t.Errorf("editor inconsistency (alignment %s): %v", a, err)
+		want.y += (prevDesc + l.Ascent).Ceil()
+		want.prevDesc, prevDesc = l.Descent, l.Descent
+		if r := l.run; r != nil {
+			want.line += r.lines
+			want.cont = r.paras > r.newlines
+			want.para += r.newlines
+			want.runes += r.runes
+			want.bytes += r.bytes
+			continue
 		}
+		want.line++
+		want.cont = !strings.HasSuffix(l.Layout.Text, "\n")
+		if !want.cont {
+			want.para++
+		}
+		want.runes += len(l.Layout.Advances)
+		want.bytes += len(l.Layout.Text)
 	}
 }
 
-func TestEditorMoveWord(t *testing.T) {
-	type Test struct {
-		Text  string
-		Start int
-		Skip  int
-		Want  int
-	}
-	tests := []Test{
-		{"", 0, 0, 0},
-		{"", 0, -1, 0},
-		{"", 0, 1, 0},
-		{"hello", 0, -1, 0},
-		{"hello", 0, 1, 5},
-		{"hello world", 3, 1, 5},
-		{"hello world", 3, -1, 0},
-		{"hello world", 8, -1, 6},
-		{"hello world", 8, 1, 11},
-		{"hello    world", 3, 1, 5},
-		{"hello    world", 3, 2, 14},
-		{"hello    world", 8, 1, 14},
-		{"hello    world", 8, -1, 0},
-		{"hello brave new world", 0, 3, 15},
+func TestLineTree(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	texts := []string{"", "a", "bc\n", "d e ", "\n"}
+	// newLine returns a line of random text and metrics.
+	newLine := func() lineEntry {
+		txt := texts[rng.Intn(len(texts))]
+		l := lineEntry{}
+		l.Layout.Text = txt
+		l.Layout.Advances = make([]fixed.Int26_6, len([]rune(txt)))
+		l.Ascent = fixed.Int26_6(rng.Intn(1000))
+		l.Descent = fixed.Int26_6(rng.Intn(500))
+		l.Width = fixed.Int26_6(rng.Intn(5000))
+		return l
 	}
-	setup := func(t string) *Editor {
-		e := new(Editor)
-		gtx := layout.Context{
-			Ops:         new(op.Ops),
-			Constraints: layout.Exact(image.Pt(100, 100)),
+	var (
+		tree lineTree
+		want []lineEntry
+	)
+	for i := 0; i < 500; i++ {
+		start := rng.Intn(len(want) + 1)
+		end := start + rng.Intn(len(want)-start+1)
+		if end-start > 20 {
+			end = start + 20
+		}
+		var lines []lineEntry
+		for j := rng.Intn(30); j > 0; j-- {
+			lines = append(lines, newLine())
+		}
+		tree.replace(start, end, lines)
+		want = append(want[:start], append(lines, want[end:]...)...)
+		if got := tree.slice(); !reflect.DeepEqual(got, want) && len(got)+len(want) > 0 {
+			t.Fatalf("step %d: got %d lines, want %d", i, len(got), len(want))
+		}
+		var (
+			m     lineMark
+			width fixed.Int26_6
+		)
+		for j, l := range want {
+			if j%7 == 0 {
+				if got := tree.atLine(j).m; got != m {
+					t.Fatalf("step %d, line %d: got mark %+v, want %+v", i, j, got, m)
+				}
+				if !m.cont {
+					if got := tree.atPara(m.para).m; got != m {
+						t.Fatalf("step %d, line %d: got paragraph mark %+v, want %+v", i, j, got, m)
+					}
+				}
+			}
+			m = m.add(l.sum())
+			if l.Width > width {
+				width = l.Width
+			}
+		}
+		s := tree.total()
+		if got := s.mark(); got != m || s.width != width {
+			t.Fatalf("step %d: got total mark %+v and width %v, want %+v and %v", i, got, s.width, m, width)
 		}
-		cache := text.NewCache(gofont.Collection())
-		fontSize := unit.Px(10)
-		font := text.Font{}
-		e.SetText(t)
-		e.Layout(gtx, cache, font, fontSize)
-		return e
 	}
-	for ii, tt := range tests {
-		e := setup(tt.Text)
-		e.Move(tt.Start)
-		e.moveWord(tt.Skip)
-		if e.rr.caret != tt.Want {
-			t.Fatalf("[%d] moveWord: bad caret position: got %d, want %d", ii, e.rr.caret, tt.Want)
+	// Nodes must not fragment.
+	var nodes int
+	var count func(n *lineNode)
+	count = func(n *lineNode) {
+		if n != nil {
+			nodes++
+			count(n.left)
+			count(n.right)
 		}
 	}
+	count(tree.root)
+	if max := 2*len(want)/maxChunk + 2; nodes > max {
+		t.Errorf("got %d nodes for %d lines, want at most %d", nodes, len(want), max)
+	}
 }
 
-func TestEditorDeleteWord(t *testing.T) {
-	type Test struct {
-		Text   string
-		Start  int
-		Delete int
-
-		Want   int
-		Result string
+func TestEditorReadFrom(t *testing.T) {
+	const txt = "héllo\r\nwörld\r\n€"
+	e := new(Editor)
+	n, err := e.ReadFrom(iotest.OneByteReader(strings.NewReader(txt)))
+	if err != nil || n != int64(len(txt)) {
+		t.Fatalf("ReadFrom returned %d, %v", n, err)
 	}
-	tests := []Test{
-		{"", 0, 0, 0, ""},
-		{"", 0, -1, 0, ""},
-		{"", 0, 1, 0, ""},
-		{"hello", 0, -1, 0, "hello"},
-		{"hello", 0, 1, 0, ""},
-		{"hello world", 3, 1, 3, "hel world"},
-		{"hello world", 3, -1, 0, "lo world"},
-		{"hello world", 8, -1, 6, "hello rld"},
-		{"hello world", 8, 1, 8, "hello wo"},
-		{"hello    world", 3, 1, 3, "hel    world"},
-		{"hello    world", 3, 2, 3, "helworld"},
-		{"hello    world", 8, 1, 8, "hello   "},
-		{"hello    world", 8, -1, 5, "hello world"},
-		{"hello brave new world", 0, 3, 0, " new world"},
+	if got := e.Text(); got != txt {
+		t.Errorf("got text %q, want %q", got, txt)
 	}
-	setup := func(t string) *Editor {
-		e := new(Editor)
-		gtx := layout.Context{
-			Ops:         new(op.Ops),
-			Constraints: layout.Exact(image.Pt(100, 100)),
-		}
-		cache := text.NewCache(gofont.Collection())
-		fontSize := unit.Px(10)
-		font := text.Font{}
-		e.SetText(t)
-		e.Layout(gtx, cache, font, fontSize)
-		return e
+	if got := e.LineEnding(); got != LineEndingCRLF {
+		t.Errorf("got line ending %v, want CRLF", got)
 	}
-	for ii, tt := range tests {
-		e := setup(tt.Text)
-		e.Move(tt.Start)
-		e.deleteWord(tt.Delete)
-		if e.rr.caret != tt.Want {
-			t.Fatalf("[%d] deleteWord: bad caret position: got %d, want %d", ii, e.rr.caret, tt.Want)
+	if got, want := e.rr.String(), "héllo\nwörld\n€"; got != want {
+		t.Errorf("got buffer %q, want %q", got, want)
+	}
+}
+
+func TestEditorSetReaderAt(t *testing.T) {
+	gtx := layout.Context{
+		Ops:         new(op.Ops),
+		Constraints: layout.Exact(image.Pt(100, 100)),
+	}
+	cache := text.NewCache(gofont.Collection())
+	var b strings.Builder
+	for b.Len() < 5*loadChunk/2 {
+		fmt.Fprintf(&b, "línea %d\n", b.Len())
+	}
+	txt := b.String()
+	e := &Editor{LazyLayout: true}
+	e.SetReaderAt(strings.NewReader(txt), int64(len(txt)))
+	frames := 0
+	for loaded := false; !loaded; frames++ {
+		if frames > 10 {
+			t.Fatal("contents not loaded")
 		}
-		if e.Text() != tt.Result {
-			t.Fatalf("[%d] deleteWord: invalid result: got %q, want %q", ii, e.Text(), tt.Result)
+		gtx.Ops.Reset()
+		e.Layout(gtx, cache, text.Font{}, unit.Px(10))
+		for _, evt := range e.Events() {
+			if evt, ok := evt.(LoadEvent); ok {
+				if evt.Err != nil {
+					t.Fatal(evt.Err)
+				}
+				loaded = true
+			}
 		}
 	}
+	if frames != 3 {
+		t.Errorf("loaded in %d frames, want 3", frames)
+	}
+	if e.Text() != txt {
+		t.Error("loaded text differs")
+	}
+	if got, want := e.NumLines(), strings.Count(txt, "\n")+1; got != want {
+		t.Errorf("got %d lines, want %d", got, want)
+	}
+	// The contents are paged in, and edited in memory.
+	if n := len(e.rr.buf); n != 0 {
+		t.Errorf("buffer holds %d bytes, want none", n)
+	}
+	e.SetCaret(1000)
+	e.Insert("x")
+	r := []rune(txt)
+	if want := string(r[:1000]) + "x" + string(r[1000:]); e.Text() != want {
+		t.Error("edited text differs")
+	}
+	if n := len(e.rr.file.cache); n > maxCachedBlocks {
+		t.Errorf("%d cached blocks, want at most %d", n, maxCachedBlocks)
+	}
 }
 
-func TestEditorNoLayout(t *testing.T) {
-	var e Editor
-	e.SetText("hi!\n")
-	e.Move(1)
+func TestEditorSetReaderAtInvalidUTF8(t *testing.T) {
+	gtx := layout.Context{
+		Ops:         new(op.Ops),
+		Constraints: layout.Exact(image.Pt(100, 100)),
+	}
+	cache := text.NewCache(gofont.Collection())
+	txt := strings.Repeat("\x80", maxPieceLen+10) + "\n" + strings.Repeat("\xff", maxPieceLen)
+	e := new(Editor)
+	e.SetReaderAt(strings.NewReader(txt), int64(len(txt)))
+	for frames := 0; e.Len() < len(txt); frames++ {
+		if frames > 10 {
+			t.Fatal("contents not loaded")
+		}
+		gtx.Ops.Reset()
+		e.Layout(gtx, cache, text.Font{}, unit.Px(10))
+	}
+	if e.Text() != txt {
+		t.Error("loaded text differs")
+	}
+	if got, want := e.rr.runes, utf8.RuneCountInString(txt); got != want {
+		t.Errorf("got %d runes, want %d", got, want)
+	}
 }
 
-// Generate generates a value of itself, for testing/quick.
-func (editMutation) Generate(rand *rand.Rand, size int) reflect.Value {
-	t := editMutation(rand.Intn(int(moveLast)))
+func TestEditorFilter(t *testing.T) {
+	e := &Editor{Filter: "0123456789"}
+	tq := &testQueue{events: []event.Event{
+		key.EditEvent{Text: "a1b2"},
+		clipboard.Event{Text: "3x\n4"},
+		key.EditEvent{Text: "yz"},
+	}}
+	gtx := layout.Context{
+		Ops:         new(op.Ops),
+		Constraints: layout.Exact(image.Pt(100, 100)),
+		Queue:       tq,
+	}
+	cache := text.NewCache(gofont.Collection())
+	e.Layout(gtx, cache, text.Font{}, unit.Px(10))
+	if got, want := e.Text(), "1234"; got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+	assertCaret(t, e, 0, 4, 4)
+	// Filtered text replaces the selection only if some of it is
+	// allowed.
+	e.SetSelection(1, 3)
+	tq.events = []event.Event{key.EditEvent{Text: "x"}, clipboard.Event{Text: "yz"}}
+	e.Layout(gtx, cache, text.Font{}, unit.Px(10))
+	if got, want := e.SelectedText(), "23"; got != want {
+		t.Errorf("got selection %q, want %q", got, want)
+	}
+	tq.events = []event.Event{clipboard.Event{Text: "x5"}}
+	e.Layout(gtx, cache, text.Font{}, unit.Px(10))
+	if got, want := e.Text(), "154"; got != want {
+		t.Errorf("got %q after paste, want %q", got, want)
+	}
+	assertCaret(t, e, 0, 2, 2)
+}
+
+func TestEditorCut(t *testing.T) {
+	e := new(Editor)
+	e.SetText("one\ntwo\nthree")
+	r := new(router.Router)
+	gtx := layout.Context{Ops: new(op.Ops), Queue: r}
+	cut := func() string {
+		gtx.Ops.Reset()
+		e.command(gtx, key.Event{Name: "X", Modifiers: key.ModShortcut})
+		r.Frame(gtx.Ops)
+		txt, _ := r.WriteClipboard()
+		return txt
+	}
+	e.SetSelection(1, 5)
+	if got, want := cut(), "ne\nt"; got != want {
+		t.Errorf("got cut text %q, want %q", got, want)
+	}
+	if got, want := e.Text(), "owo\nthree"; got != want {
+		t.Errorf("got %q after cut, want %q", got, want)
+	}
+	// Without a selection, nothing is cut unless CopyLine is set.
+	e.SetCaret(1)
+	if got := cut(); got != "" {
+		t.Errorf("got cut text %q without a selection", got)
+	}
+	e.CopyLine = true
+	if got, want := cut(), "owo\n"; got != want {
+		t.Errorf("got cut line %q, want %q", got, want)
+	}
+	if got, want := e.Text(), "three"; got != want {
+		t.Errorf("got %q after cutting a line, want %q", got, want)
+	}
+	// The last line has no line terminator to cut.
+	e.SetCaret(2)
+	if got, want := cut(), "three"; got != want {
+		t.Errorf("got cut line %q, want %q", got, want)
+	}
+	if got := e.Text(); got != "" {
+		t.Errorf("got %q after cutting the last line, want \"\"", got)
+	}
+	e.Undo()
+	if got, want := e.Text(), "three"; got != want {
+		t.Errorf("got %q after undo, want %q", got, want)
+	}
+}
+
+func TestEditorCopy(t *testing.T) {
+	e := new(Editor)
+	e.SetText("one\ntwo")
+	r := new(router.Router)
+	gtx := layout.Context{Ops: new(op.Ops), Queue: r}
+	copyText := func() (string, bool) {
+		gtx.Ops.Reset()
+		e.command(gtx, key.Event{Name: "C", Modifiers: key.ModShortcut})
+		r.Frame(gtx.Ops)
+		return r.WriteClipboard()
+	}
+	e.SetSelection(5, 2)
+	if got, ok := copyText(); !ok || got != "e\nt" {
+		t.Errorf("got copied text %q, %v, want %q", got, ok, "e\nt")
+	}
+	// Without a selection, nothing is copied unless CopyLine is
+	// set.
+	e.SetCaret(1)
+	if got, ok := copyText(); ok {
+		t.Errorf("got copied text %q without a selection", got)
+	}
+	e.CopyLine = true
+	if got, _ := copyText(); got != "one\n" {
+		t.Errorf("got copied line %q, want %q", got, "one\n")
+	}
+	e.SetCaret(e.Len())
+	if got, _ := copyText(); got != "two" {
+		t.Errorf("got copied last line %q, want %q", got, "two")
+	}
+	if got, want := e.Text(), "one\ntwo"; got != want {
+		t.Errorf("got %q after copying, want %q", got, want)
+	}
+	if start, end := e.Selection(); start != e.Len() || end != e.Len() {
+		t.Errorf("got selection (%d, %d) after copying, want the caret at the end", start, end)
+	}
+}
+
+func TestEditorCopyHTML(t *testing.T) {
+	e := &Editor{CopyHTML: true}
+	e.SetText("a<b\nc d")
+	e.SetSpans([]Span{
+		{Start: 1, End: 3, Font: text.Font{Weight: text.Bold}},
+		{Start: 4, End: 5, Color: color.NRGBA{R: 255, A: 255}, Underline: true},
+	})
+	e.SetSelection(0, 6)
+	r := new(router.Router)
+	gtx := layout.Context{Ops: new(op.Ops), Queue: r}
+	e.Copy(gtx)
+	r.Frame(gtx.Ops)
+	if txt, _ := r.WriteClipboard(); txt != "a<b\nc " {
+		t.Errorf("got copied text %q", txt)
+	}
+	want := `<div style="white-space: pre-wrap">a<span style="font-weight: 600">&lt;b</span><br>` +
+		`<span style="color: rgba(255, 0, 0, 1); text-decoration: underline">c</span> </div>`
+	if got := r.ClipboardHTML(); got != want {
+		t.Errorf("got HTML\n%s\nwant\n%s", got, want)
+	}
+}
+
+func TestEditorExport(t *testing.T) {
+	e := new(Editor)
+	e.SetText("one\r\ntwo\r\n")
+	e.SetCaret(3)
+	e.Insert(" and a half\nthree")
+	e.SetCaret(0)
+	e.Insert("zero\n")
+	want := e.Text()
+	r := e.Reader()
+	e.Insert("changed")
+	var got bytes.Buffer
+	if _, err := got.ReadFrom(iotest.OneByteReader(r)); err != nil {
+		t.Fatal(err)
+	}
+	if got.String() != want {
+		t.Errorf("Reader: got %q, want %q", got.String(), want)
+	}
+	got.Reset()
+	n, err := e.WriteTo(&got)
+	if err != nil || n != int64(e.Len()) || got.String() != e.Text() {
+		t.Errorf("WriteTo: got %q (%d, %v), want %q", got.String(), n, err, e.Text())
+	}
+	if got, want := e.Segment(12, 2), "ro\r\nchanged"; got != want {
+		t.Errorf("Segment: got %q, want %q", got, want)
+	}
+}
+
+func TestEditorDeltas(t *testing.T) {
+	e := &Editor{Deltas: true}
+	var model []rune
+	apply := func() {
+		for _, evt := range e.Events() {
+			if d, ok := evt.(DeltaEvent); ok {
+				ins := []rune(d.Inserted)
+				model = append(model[:d.Start], append(ins, model[d.Start+d.Removed:]...)...)
+			}
+		}
+		if got, want := string(model), e.rr.String(); got != want {
+			t.Fatalf("deltas give %q, want %q", got, want)
+		}
+	}
+	e.SetText("héllo\r\nwörld")
+	apply()
+	r := rand.New(rand.NewSource(1))
+	for i := 0; i < 200; i++ {
+		switch r.Intn(5) {
+		case 0:
+			e.SetCaret(r.Intn(20))
+			e.Insert("ab\nç")
+		case 1:
+			e.SetSelection(r.Intn(20), r.Intn(20))
+			e.Delete(r.Intn(3) - 1)
+		case 2:
+			e.Undo()
+		case 3:
+			e.Redo()
+		case 4:
+			e.SetSelection(r.Intn(20), r.Intn(20))
+			e.Insert("€")
+		}
+		apply()
+	}
+	e.SetText("")
+	apply()
+}
+
+func TestEditorApplyEdit(t *testing.T) {
+	gtx := layout.Context{
+		Ops:         new(op.Ops),
+		Constraints: layout.Exact(image.Pt(200, 100)),
+	}
+	cache := text.NewCache(gofont.Collection())
+	var b strings.Builder
+	for i := 0; i < 100; i++ {
+		fmt.Fprintf(&b, "line %d\n", i)
+	}
+	txt := b.String()
+	e := new(Editor)
+	e.SetText(txt)
+	e.Layout(gtx, cache, text.Font{}, unit.Px(10))
+	// A local edit to undo after the remote edit.
+	e.SetCaret(strings.Index(txt, "line 60"))
+	e.Insert("abc")
+	e.SetSelection(5, 8)
+	e.ScrollToLine(50)
+	e.Layout(gtx, cache, text.Font{}, unit.Px(10))
+	first, _ := e.VisibleLines()
+
+	e.ApplyEdit(0, 0, "new\nnew\n", true)
+	if got, want := e.Text(), "new\nnew\n"+strings.Replace(txt, "line 60", "abcline 60", 1); got != want {
+		t.Fatalf("got text %q, want %q", got, want)
+	}
+	if start, end := e.Selection(); start != 13 || end != 16 {
+		t.Errorf("got selection %d-%d, want 13-16", start, end)
+	}
+	e.Layout(gtx, cache, text.Font{}, unit.Px(10))
+	if f, _ := e.VisibleLines(); f != first+2 {
+		t.Errorf("got first visible line %d, want %d", f, first+2)
+	}
+	if !e.Undo() {
+		t.Fatal("nothing to undo")
+	}
+	if got, want := e.Text(), "new\nnew\n"+txt; got != want {
+		t.Errorf("after undo, got text %q, want %q", got, want)
+	}
+	if e.Undo() {
+		t.Error("the remote edit was undone")
+	}
+
+	// An overlapping remote edit clears the history.
+	e.Redo()
+	i := strings.Index(e.Text(), "abc")
+	e.ApplyEdit(i+1, i+5, "", true)
+	if e.Undo() {
+		t.Error("undo after overlapping remote edit")
+	}
+}
+
+func TestEditorDirty(t *testing.T) {
+	e := new(Editor)
+	if e.Dirty() {
+		t.Error("new editor is dirty")
+	}
+	e.SetText("hello")
+	if !e.Dirty() {
+		t.Error("editor is clean after SetText")
+	}
+	e.MarkClean()
+	e.SetCaret(5)
+	e.Insert(" world")
+	if !e.Dirty() {
+		t.Error("editor is clean after Insert")
+	}
+	e.Undo()
+	if e.Dirty() {
+		t.Error("editor is dirty after undoing to the clean contents")
+	}
+	e.Redo()
+	rev := e.Revision()
+	e.MarkClean()
+	e.Undo()
+	if !e.Dirty() {
+		t.Error("editor is clean after undo")
+	}
+	e.Redo()
+	if e.Dirty() || e.Revision() != rev {
+		t.Errorf("got revision %d after redo, want clean revision %d", e.Revision(), rev)
+	}
+	e.ApplyEdit(0, 0, "<", true)
+	if !e.Dirty() {
+		t.Error("editor is clean after remote edit")
+	}
+	e.Undo()
+	e.Redo()
+	if !e.Dirty() {
+		t.Error("editor is clean after undo and redo across a remote edit")
+	}
+	e.compose("x")
+	e.endComposition()
+	e.ApplyEdit(0, 1, "", true)
+	e.MarkClean()
+	e.compose("x")
+	if !e.Dirty() {
+		t.Error("editor is clean while composing")
+	}
+	e.endComposition()
+	if e.Dirty() {
+		t.Error("editor is dirty after cancelled composition")
+	}
+}
+
+func TestEditorContinuation(t *testing.T) {
+	gtx := layout.Context{
+		Ops:         new(op.Ops),
+		Constraints: layout.Exact(image.Pt(60, 100)),
+	}
+	cache := text.NewCache(gofont.Collection())
+	e := &Editor{ShowWhitespace: WhitespaceWraps}
+	e.SetText("one two three four\nfive")
+	e.Layout(gtx, cache, text.Font{}, unit.Px(10))
+	e.PaintWhitespace(gtx)
+	n := e.NumLines()
+	if n < 3 {
+		t.Fatalf("got %d lines, want wrapped lines", n)
+	}
+	if e.Continuation(0) || !e.Continuation(1) || e.Continuation(n-1) {
+		t.Errorf("wrong continuation lines")
+	}
+}
+
+func TestEditorLineStyle(t *testing.T) {
+	gtx := layout.Context{
+		Ops:         new(op.Ops),
+		Constraints: layout.Exact(image.Pt(60, 100)),
+	}
+	cache := text.NewCache(gofont.Collection())
+	var styled []int
+	e := &Editor{
+		LineStyle: func(line int) LineStyle {
+			styled = append(styled, line)
+			return LineStyle{Background: color.NRGBA{R: 0xff, A: 0xff}}
+		},
+	}
+	e.SetText("one two three four\nfive\nsix")
+	e.Layout(gtx, cache, text.Font{}, unit.Px(10))
+	e.PaintLineBackgrounds(gtx)
+	var want []int
+	for i := 0; i < e.NumLines(); i++ {
+		want = append(want, e.logicalLine(i))
+	}
+	if !reflect.DeepEqual(styled, want) || want[len(want)-1] != 2 {
+		t.Errorf("styled lines %v, want %v", styled, want)
+	}
+}
+
+func TestEditorMaskReveal(t *testing.T) {
+	e := &Editor{Mask: '*', MaskReveal: time.Second}
+	tq := &testQueue{
+		events: []event.Event{
+			key.EditEvent{Text: "a"},
+			key.EditEvent{Text: "b"},
+		},
+	}
+	gtx := layout.Context{
+		Ops:         new(op.Ops),
+		Constraints: layout.Exact(image.Pt(100, 100)),
+		Queue:       tq,
+		Now:         time.Now(),
+	}
+	cache := text.NewCache(gofont.Collection())
+	e.Layout(gtx, cache, text.Font{}, unit.Px(10))
+	if got, want := e.lines.entry(0).Layout.Text, "*b"; got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+	tq.events = nil
+	gtx.Now = gtx.Now.Add(2 * time.Second)
+	e.Layout(gtx, cache, text.Font{}, unit.Px(10))
+	if got, want := e.lines.entry(0).Layout.Text, "**"; got != want {
+		t.Errorf("got %q after the reveal duration, want %q", got, want)
+	}
+	if got, want := e.Text(), "ab"; got != want {
+		t.Errorf("got text %q, want %q", got, want)
+	}
+}
+
+func TestEditorNumber(t *testing.T) {
+	e := &Editor{Number: NumberFormat{Mode: NumberDecimal, Signed: true, Decimal: ',', Group: '.'}}
+	tq := &testQueue{}
+	for _, s := range []string{"-", "1", "-", "2", "3", "4", ",", "5", ",", "x"} {
+		tq.events = append(tq.events, key.EditEvent{Text: s})
+	}
+	gtx := layout.Context{
+		Ops:         new(op.Ops),
+		Constraints: layout.Exact(image.Pt(100, 100)),
+		Queue:       tq,
+	}
+	cache := text.NewCache(gofont.Collection())
+	e.Layout(gtx, cache, text.Font{}, unit.Px(10))
+	if got, want := e.Text(), "-1234,5"; got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+	if v, err := e.Value(); err != nil || v != -1234.5 {
+		t.Errorf("got value %v, %v, want -1234.5", v, err)
+	}
+	tq.events = []event.Event{key.FocusEvent{Focus: false}}
+	e.Layout(gtx, cache, text.Font{}, unit.Px(10))
+	if got, want := e.Text(), "-1.234,5"; got != want {
+		t.Errorf("got %q after blur, want %q", got, want)
+	}
+	if v, err := e.Value(); err != nil || v != -1234.5 {
+		t.Errorf("got value %v, %v after blur, want -1234.5", v, err)
+	}
+	e.SetValue(1234567.25)
+	if got, want := e.Text(), "1.234.567,25"; got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+	e.Number = NumberFormat{Mode: NumberInteger}
+	e.SetValue(-2.6)
+	if got, want := e.Text(), "0"; got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+	e.SetText("")
+	if _, err := e.Value(); err == nil {
+		t.Error("empty editor has a value")
+	}
+}
+
+func TestEditorSubmitModifiers(t *testing.T) {
+	e := &Editor{Submit: true, SubmitModifiers: key.ModCtrl}
+	tq := &testQueue{
+		events: []event.Event{
+			key.FocusEvent{Focus: true},
+			key.Event{Name: key.NameReturn},
+			key.Event{Name: key.NameReturn, Modifiers: key.ModCtrl},
+		},
+	}
+	gtx := layout.Context{
+		Ops:         new(op.Ops),
+		Constraints: layout.Exact(image.Pt(100, 100)),
+		Queue:       tq,
+	}
+	cache := text.NewCache(gofont.Collection())
+	e.Layout(gtx, cache, text.Font{}, unit.Px(10))
+	var submits []SubmitEvent
+	for _, evt := range e.Events() {
+		if evt, ok := evt.(SubmitEvent); ok {
+			submits = append(submits, evt)
+		}
+	}
+	if len(submits) != 1 || submits[0].Text != "\n" {
+		t.Errorf("got submits %v, want one of a newline", submits)
+	}
+}
+
+func TestEditorClearOnSubmit(t *testing.T) {
+	e := &Editor{Submit: true, ClearOnSubmit: true}
+	tq := &testQueue{
+		events: []event.Event{
+			key.FocusEvent{Focus: true},
+			key.EditEvent{Text: "hi"},
+			key.Event{Name: key.NameReturn},
+		},
+	}
+	gtx := layout.Context{
+		Ops:         new(op.Ops),
+		Constraints: layout.Exact(image.Pt(100, 100)),
+		Queue:       tq,
+	}
+	cache := text.NewCache(gofont.Collection())
+	e.Layout(gtx, cache, text.Font{}, unit.Px(10))
+	var submitted []string
+	for _, evt := range e.Events() {
+		if evt, ok := evt.(SubmitEvent); ok {
+			submitted = append(submitted, evt.Text)
+		}
+	}
+	if len(submitted) != 1 || submitted[0] != "hi" {
+		t.Errorf("got submitted %q, want \"hi\"", submitted)
+	}
+	if e.Len() != 0 || e.NumLines() != 1 || len(e.lines.entry(0).Layout.Text) != 0 {
+		t.Errorf("editor not cleared after submit")
+	}
+	if !e.Undo() || e.Text() != "hi" {
+		t.Errorf("got %q after undo, want \"hi\"", e.Text())
+	}
+}
+
+func TestEditorTraverseOnTab(t *testing.T) {
+	r := new(router.Router)
+	gtx := layout.Context{
+		Ops:         new(op.Ops),
+		Constraints: layout.Exact(image.Pt(100, 100)),
+		Queue:       r,
+	}
+	cache := text.NewCache(gofont.Collection())
+	fields := []*Editor{{SingleLine: true}, {TraverseOnTab: true}}
+	frame := func() {
+		gtx.Ops.Reset()
+		for _, e := range fields {
+			stack := op.Push(gtx.Ops)
+			e.Layout(gtx, cache, text.Font{}, unit.Px(10))
+			stack.Pop()
+		}
+		r.Frame(gtx.Ops)
+	}
+	fields[0].Focus()
+	frame()
+	frame()
+	for i, mods := range []key.Modifiers{0, key.ModShift} {
+		r.Add(key.Event{Name: key.NameTab, Modifiers: mods})
+		frame()
+		// Move the focus in response to the TraverseEvent.
+		for j, e := range fields {
+			for _, evt := range e.Events() {
+				if evt, ok := evt.(TraverseEvent); ok {
+					if j != i || evt.Backward != (mods != 0) {
+						t.Errorf("field %d: unexpected %+v", j, evt)
+					}
+					fields[1-j].Focus()
+				}
+			}
+		}
+		frame()
+		frame()
+		if want := 1 - i; !fields[want].Focused() || fields[i].Focused() {
+			t.Errorf("Tab with modifiers %v didn't move the focus to field %d", mods, want)
+		}
+	}
+	for i, e := range fields {
+		if e.Len() != 0 {
+			t.Errorf("field %d: got %q, want no tab inserted", i, e.Text())
+		}
+	}
+	// Without another field focused, traversing releases the focus.
+	r.Add(key.Event{Name: key.NameTab})
+	frame()
+	frame()
+	if fields[0].Focused() || fields[1].Focused() {
+		t.Errorf("focus not released by Tab")
+	}
+}
+
+func TestEditorSelectEvent(t *testing.T) {
+	e := new(Editor)
+	e.SetText("ab\ncd")
+	e.SetCaret(1)
+	shiftRight := key.Event{Name: key.NameRightArrow, Modifiers: key.ModShift}
+	tq := &testQueue{
+		events: []event.Event{
+			key.FocusEvent{Focus: true},
+			shiftRight, shiftRight, shiftRight,
+			key.Event{Name: key.NameLeftArrow},
+		},
+	}
+	gtx := layout.Context{
+		Ops:         new(op.Ops),
+		Constraints: layout.Exact(image.Pt(100, 100)),
+		Queue:       tq,
+	}
+	cache := text.NewCache(gofont.Collection())
+	e.Layout(gtx, cache, text.Font{}, unit.Px(10))
+	var sels []SelectEvent
+	for _, evt := range e.Events() {
+		if evt, ok := evt.(SelectEvent); ok {
+			sels = append(sels, evt)
+		}
+	}
+	if len(sels) != 3 {
+		t.Fatalf("got %d SelectEvents, want 3", len(sels))
+	}
+	want := SelectEvent{Text: "b\nc", Start: 1, End: 4, StartCol: 1, EndLine: 1, EndCol: 1}
+	if got := sels[2]; got != want {
+		t.Errorf("got %+v, want %+v", got, want)
+	}
+}
+
+func TestEditorShapedLines(t *testing.T) {
+	gtx := layout.Context{
+		Ops:         new(op.Ops),
+		Constraints: layout.Exact(image.Pt(100, 100)),
+	}
+	cache := text.NewCache(gofont.Collection())
+	e := new(Editor)
+	e.SetText(strings.Repeat("line\n", 600))
+	e.Layout(gtx, cache, text.Font{}, unit.Px(10))
+	var lines []ShapedLine
+	it := e.ShapedLines(0)
+	for l, ok := it.Next(); ok; l, ok = it.Next() {
+		lines = append(lines, l)
+	}
+	if len(lines) != e.NumLines() {
+		t.Fatalf("got %d lines, want %d", len(lines), e.NumLines())
+	}
+	for i, l := range lines {
+		// The text is ASCII, so rune and byte offsets agree.
+		_, _, _, y := e.locate(l.Offset)
+		if l.Index != i || l.Offset != 5*i || l.Baseline != y {
+			t.Errorf("line %d: got %+v, want baseline %d", i, l, y)
+			break
+		}
+	}
+	top := lines[400].Baseline
+	it = e.ShapedLines(top)
+	if l, _ := it.Next(); l.Index != 400 {
+		t.Errorf("got first line %d below %d, want 400", l.Index, top)
+	}
+}
+
+func TestEditorVerticalAlignment(t *testing.T) {
+	gtx := layout.Context{
+		Ops:         new(op.Ops),
+		Constraints: layout.Exact(image.Pt(100, 50)),
+	}
+	cache := text.NewCache(gofont.Collection())
+	bounds := make(map[layout.Alignment]image.Rectangle)
+	for _, a := range []layout.Alignment{layout.Start, layout.Middle, layout.End, layout.Baseline} {
+		e := &Editor{SingleLine: true, VerticalAlignment: a}
+		e.SetText("text")
+		dims := e.Layout(gtx, cache, text.Font{}, unit.Px(10))
+		r := e.CompositionRect()
+		if base := dims.Size.Y - dims.Baseline; base <= r.Min.Y || base >= r.Max.Y {
+			t.Errorf("alignment %v: got baseline %d outside the caret bounds %v", a, base, r)
+		}
+		bounds[a] = r
+	}
+	if r := bounds[layout.Start]; r.Min.Y != 0 {
+		t.Errorf("got top aligned caret bounds %v", r)
+	}
+	if r := bounds[layout.End]; r.Max.Y != 50 {
+		t.Errorf("got bottom aligned caret bounds %v", r)
+	}
+	if r := bounds[layout.Middle]; r.Min.Y+r.Max.Y < 49 || r.Min.Y+r.Max.Y > 51 {
+		t.Errorf("got middle aligned caret bounds %v", r)
+	}
+	if r := bounds[layout.Baseline]; r.Min.Y <= bounds[layout.Middle].Min.Y {
+		t.Errorf("got baseline aligned caret bounds %v above the middle aligned %v", r, bounds[layout.Middle])
+	}
+}
+
+func TestEditorDimensions(t *testing.T) {
+	e := new(Editor)
+	tq := &testQueue{
+		events: []event.Event{
+			key.EditEvent{Text: "A"},
+		},
+	}
+	gtx := layout.Context{
+		Ops:         new(op.Ops),
+		Constraints: layout.Constraints{Max: image.Pt(100, 100)},
+		Queue:       tq,
+	}
+	cache := text.NewCache(gofont.Collection())
+	fontSize := unit.Px(10)
+	font := text.Font{}
+	dims := e.Layout(gtx, cache, font, fontSize)
+	if dims.Size.X == 0 {
+		t.Errorf("EditEvent was not reflected in Editor width")
+	}
+}
+
+type testQueue struct {
+	events []event.Event
+}
+
+func (q *testQueue) Events(_ event.Tag) []event.Event {
+	return q.events
+}
+
+// assertCaret asserts that the editor caret is at a particular line
+// and column, and that the byte position matches as well.
+func assertCaret(t *testing.T, e *Editor, line, col, bytes int) {
+	t.Helper()
+	gotLine, gotCol := e.CaretPos()
+	if gotLine != line || gotCol != col {
+		t.Errorf("caret at (%d, %d), expected (%d, %d)", gotLine, gotCol, line, col)
+	}
+	if bytes != e.rr.caret {
+		t.Errorf("caret at buffer position %d, expected %d", e.rr.caret, bytes)
+	}
+}
+
+type editMutation int
+
+const (
+	setText editMutation = iota
+	moveRune
+	moveLine
+	movePage
+	moveStart
+	moveEnd
+	moveCoord
+	moveWord
+	deleteWord
+	moveLast // Mark end; never generated.
+)
+
+func TestEditorCaretConsistency(t *testing.T) {
+	gtx := layout.Context{
+		Ops:         new(op.Ops),
+		Constraints: layout.Exact(image.Pt(100, 100)),
+	}
+	cache := text.NewCache(gofont.Collection())
+	fontSize := unit.Px(10)
+	font := text.Font{}
+	for _, a := range []text.Alignment{text.Start, text.Middle, text.End} {
+		e := &Editor{
+			Alignment: a,
+		}
+		e.Layout(gtx, cache, font, fontSize)
+
+		consistent := func() error {
+			t.Helper()
+			gotLine, gotCol := e.CaretPos()
+			gotCoords := e.CaretCoords()
+			wantLine, wantCol, wantX, wantY := e.layoutCaret()
+			wantCoords := f32.Pt(float32(wantX)/64, float32(wantY))
+			if wantLine == gotLine && wantCol == gotCol && gotCoords == wantCoords {
+				return nil
+			}
+			return fmt.Errorf("caret (%d,%d) pos %s, want (%d,%d) pos %s", gotLine, gotCol, gotCoords, wantLine, wantCol, wantCoords)
+		}
+		if err := consistent(); err != nil {
+			t.Errorf("initial editor inconsistency (alignment %s): %v", a, err)
+		}
+
+		move := func(mutation editMutation, str string, distance int8, x, y uint16) bool {
+			switch mutation {
+			case setText:
+				e.SetText(str)
+				e.Layout(gtx, cache, font, fontSize)
+			case moveRune:
+				e.Move(int(distance))
+			case moveLine:
+				e.moveLines(int(distance))
+			case movePage:
+				e.movePages(int(distance))
+			case moveStart:
+				e.moveStart()
+			case moveEnd:
+				e.moveEnd()
+			case moveCoord:
+				e.moveCoord(image.Pt(int(x), int(y)))
+			case moveWord:
+				e.moveWord(int(distance))
+			case deleteWord:
+				e.deleteWord(int(distance))
+			default:
+				return false
+			}
+			if err := consistent(); err != nil {
+				t.Error(err)
+				return false
+			}
+			return true
+		}
+		if err := quick.Check(move, nil); err != nil {
+			t.Errorf("editor inconsistency (alignment %s): %v", a, err)
+		}
+	}
+}
+
+func TestEditorMoveWord(t *testing.T) {
+	type Test struct {
+		Text  string
+		Start int
+		Skip  int
+		Want  int
+	}
+	tests := []Test{
+		{"", 0, 0, 0},
+		{"", 0, -1, 0},
+		{"", 0, 1, 0},
+		{"hello", 0, -1, 0},
+		{"hello", 0, 1, 5},
+		{"hello world", 3, 1, 5},
+		{"hello world", 3, -1, 0},
+		{"hello world", 8, -1, 6},
+		{"hello world", 8, 1, 11},
+		{"hello    world", 3, 1, 5},
+		{"hello    world", 3, 2, 14},
+		{"hello    world", 8, 1, 14},
+		{"hello    world", 8, -1, 0},
+		{"hello brave new world", 0, 3, 15},
+	}
+	setup := func(t string) *Editor {
+		e := new(Editor)
+		gtx := layout.Context{
+			Ops:         new(op.Ops),
+			Constraints: layout.Exact(image.Pt(100, 100)),
+		}
+		cache := text.NewCache(gofont.Collection())
+		fontSize := unit.Px(10)
+		font := text.Font{}
+		e.SetText(t)
+		e.Layout(gtx, cache, font, fontSize)
+		return e
+	}
+	for ii, tt := range tests {
+		e := setup(tt.Text)
+		e.Move(tt.Start)
+		e.moveWord(tt.Skip)
+		if e.rr.caret != tt.Want {
+			t.Fatalf("[%d] moveWord: bad caret position: got %d, want %d", ii, e.rr.caret, tt.Want)
+		}
+	}
+}
+
+func TestEditorDeleteWord(t *testing.T) {
+	type Test struct {
+		Text   string
+		Start  int
+		Delete int
+
+		Want   int
+		Result string
+	}
+	tests := []Test{
+		{"", 0, 0, 0, ""},
+		{"", 0, -1, 0, ""},
+		{"", 0, 1, 0, ""},
+		{"hello", 0, -1, 0, "hello"},
+		{"hello", 0, 1, 0, ""},
+		{"hello world", 3, 1, 3, "hel world"},
+		{"hello world", 3, -1, 0, "lo world"},
+		{"hello world", 8, -1, 6, "hello rld"},
+		{"hello world", 8, 1, 8, "hello wo"},
+		{"hello    world", 3, 1, 3, "hel    world"},
+		{"hello    world", 3, 2, 3, "helworld"},
+		{"hello    world", 8, 1, 8, "hello   "},
+		{"hello    world", 8, -1, 5, "hello world"},
+		{"hello brave new world", 0, 3, 0, " new world"},
+	}
+	setup := func(t string) *Editor {
+		e := new(Editor)
+		gtx := layout.Context{
+			Ops:         new(op.Ops),
+			Constraints: layout.Exact(image.Pt(100, 100)),
+		}
+		cache := text.NewCache(gofont.Collection())
+		fontSize := unit.Px(10)
+		font := text.Font{}
+		e.SetText(t)
+		e.Layout(gtx, cache, font, fontSize)
+		return e
+	}
+	for ii, tt := range tests {
+		e := setup(tt.Text)
+		e.Move(tt.Start)
+		e.deleteWord(tt.Delete)
+		if e.rr.caret != tt.Want {
+			t.Fatalf("[%d] deleteWord: bad caret position: got %d, want %d", ii, e.rr.caret, tt.Want)
+		}
+		if e.Text() != tt.Result {
+			t.Fatalf("[%d] deleteWord: invalid result: got %q, want %q", ii, e.Text(), tt.Result)
+		}
+	}
+}
+
+func TestEditorWordSegments(t *testing.T) {
+	tests := []struct {
+		text      string
+		wordChars string
+		idx       int
+		start     int
+		end       int
+	}{
+		{"foo(bar)", "", 0, 0, 3},
+		{"foo(bar)", "", 3, 3, 4},
+		{"foo.bar()", "", 0, 0, 7},
+		{"foo.bar()", "", 7, 7, 9},
+		{"can't stop", "", 1, 0, 5},
+		{"pi = 3.14;", "", 5, 5, 9},
+		{"a-b c", "", 0, 0, 1},
+		{"a-b c", "-", 0, 0, 3},
+		{"日本語", "", 3, 3, 6},
+		{"カタカナ語", "", 3, 0, 12},
+	}
+	for _, tc := range tests {
+		e := &Editor{WordChars: tc.wordChars}
+		e.SetText(tc.text)
+		if start, end := e.wordBounds(tc.idx); start != tc.start || end != tc.end {
+			t.Errorf("%q at %d: got word %d-%d, want %d-%d", tc.text, tc.idx, start, end, tc.start, tc.end)
+		}
+	}
+}
+
+func TestEditorUndo(t *testing.T) {
+	e := new(Editor)
+	e.SetText("hello")
+	e.Move(5)
+	for _, s := range []string{" ", "w", "o", "r", "l", "d"} {
+		e.append(s)
+	}
+	e.Delete(-1)
+	e.Delete(-1)
+	if got, want := e.Text(), "hello wor"; got != want {
+		t.Fatalf("got text %q, want %q", got, want)
+	}
+	e.Undo()
+	if got, want := e.Text(), "hello world"; got != want {
+		t.Errorf("undo deletion: got text %q, want %q", got, want)
+	}
+	e.Undo()
+	if got, want := e.Text(), "hello"; got != want {
+		t.Errorf("undo typing: got text %q, want %q", got, want)
+	}
+	if e.rr.caret != len("hello") {
+		t.Errorf("undo typing: got caret %d, want %d", e.rr.caret, len("hello"))
+	}
+	if e.Undo() {
+		t.Error("undo with empty history succeeded")
+	}
+	e.Redo()
+	e.Redo()
+	if got, want := e.Text(), "hello wor"; got != want {
+		t.Errorf("redo: got text %q, want %q", got, want)
+	}
+	if e.Redo() {
+		t.Error("redo with empty history succeeded")
+	}
+	e.Insert("ld")
+	e.Undo()
+	if got, want := e.Text(), "hello wor"; got != want {
+		t.Errorf("undo insert: got text %q, want %q", got, want)
+	}
+}
+
+func TestEditorUndoPolicy(t *testing.T) {
+	e := &Editor{UndoWords: true, UndoGap: time.Second}
+	for _, s := range []string{"a", "b", " ", "c", "d"} {
+		e.append(s)
+	}
+	e.Undo()
+	if got, want := e.Text(), "ab "; got != want {
+		t.Errorf("UndoWords: got text %q, want %q", got, want)
+	}
+	e.append("e")
+	e.now = e.now.Add(2 * time.Second)
+	e.append("f")
+	e.Undo()
+	if got, want := e.Text(), "ab e"; got != want {
+		t.Errorf("UndoGap: got text %q, want %q", got, want)
+	}
+	e.BeginGroup()
+	e.Insert("g")
+	e.BeginGroup()
+	e.Delete(-2)
+	e.EndGroup()
+	e.Insert("h")
+	e.EndGroup()
+	if got, want := e.Text(), "ab h"; got != want {
+		t.Fatalf("group: got text %q, want %q", got, want)
+	}
+	e.Undo()
+	if got, want := e.Text(), "ab e"; got != want {
+		t.Errorf("undo group: got text %q, want %q", got, want)
+	}
+	e.Redo()
+	if got, want := e.Text(), "ab h"; got != want {
+		t.Errorf("redo group: got text %q, want %q", got, want)
+	}
+}
+
+func TestEditorSelection(t *testing.T) {
+	e := new(Editor)
+	gtx := layout.Context{
+		Ops:         new(op.Ops),
+		Constraints: layout.Exact(image.Pt(100, 100)),
+	}
+	cache := text.NewCache(gofont.Collection())
+	e.SetText("æbc\naøå•")
+	e.Layout(gtx, cache, text.Font{}, unit.Px(10))
+	e.SetSelection(1, 6)
+	if start, end := e.Selection(); start != 1 || end != 6 {
+		t.Errorf("got selection (%d, %d), want (1, 6)", start, end)
+	}
+	if got, want := e.SelectedText(), "bc\naø"; got != want {
+		t.Errorf("got selected text %q, want %q", got, want)
+	}
+	assertCaret(t, e, 1, 2, len("æbc\naø"))
+	if n := len(e.regions(e.selectionBytes())); n != 2 {
+		t.Errorf("got %d selection regions, want 2", n)
+	}
+	e.SetSelection(100, 0)
+	if got, want := e.SelectedText(), e.Text(); got != want {
+		t.Errorf("got selected text %q, want %q", got, want)
+	}
+	e.Move(1)
+	if got := e.SelectedText(); got != "" {
+		t.Errorf("Move did not clear selection, got %q", got)
+	}
+}
+
+func TestEditorWordLineBounds(t *testing.T) {
+	e := new(Editor)
+	e.SetText("hello  world\nfoo")
+	tests := []struct {
+		idx                int
+		wordStart, wordEnd int
+		lineStart, lineEnd int
+	}{
+		{0, 0, 5, 0, 12},
+		{3, 0, 5, 0, 12},
+		{6, 5, 7, 0, 12},
+		{9, 7, 12, 0, 12},
+		{12, 7, 12, 0, 12},
+		{13, 13, 16, 13, 16},
+		{16, 13, 16, 13, 16},
+	}
+	for _, tt := range tests {
+		if start, end := e.wordBounds(tt.idx); start != tt.wordStart || end != tt.wordEnd {
+			t.Errorf("wordBounds(%d) = (%d, %d), want (%d, %d)", tt.idx, start, end, tt.wordStart, tt.wordEnd)
+		}
+		if start, end := e.lineBounds(tt.idx); start != tt.lineStart || end != tt.lineEnd {
+			t.Errorf("lineBounds(%d) = (%d, %d), want (%d, %d)", tt.idx, start, end, tt.lineStart, tt.lineEnd)
+		}
+	}
+}
+
+func TestEditorReplaceSelection(t *testing.T) {
+	e := new(Editor)
+	e.SetText("hello world")
+	e.SetSelection(6, 11)
+	e.append("g")
+	e.append("o")
+	if got, want := e.Text(), "hello go"; got != want {
+		t.Errorf("typing over selection: got %q, want %q", got, want)
+	}
+	e.SetSelection(0, 6)
+	e.Delete(1)
+	if got, want := e.Text(), "go"; got != want {
+		t.Errorf("deleting selection: got %q, want %q", got, want)
+	}
+	e.Undo()
+	e.Undo()
+	if got, want := e.Text(), "hello world"; got != want {
+		t.Errorf("undo: got %q, want %q", got, want)
+	}
+}
+
+func TestEditorBufferGrowth(t *testing.T) {
+	e := new(Editor)
+	e.SetText("abcdef")
+	// Move the gap to the middle of the text, then grow the buffer
+	// while inserting after the gap.
+	e.SetCaret(2)
+	e.Insert("x")
+	e.SetCaret(5)
+	long := strings.Repeat("y", 1000)
+	e.Insert(long)
+	if got, want := e.Text(), "abxcd"+long+"ef"; got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
+
+func TestEditBufferOffsets(t *testing.T) {
+	rnd := rand.New(rand.NewSource(1))
+	// Insert text longer than a piece, with multi-byte runes at
+	// the piece boundaries.
+	ref := strings.Repeat("aé€", maxPieceLen/3)
+	var b editBuffer
+	b.replace(0, 0, ref)
+	runes := []string{"x", "é", "€", "😀", "\n"}
+	for i := 0; i < 500; i++ {
+		start := b.byteOffset(rnd.Intn(utf8.RuneCountInString(ref) + 1))
+		end := b.moveRunes(start, rnd.Intn(3))
+		var s strings.Builder
+		for n := rnd.Intn(4); n > 0; n-- {
+			s.WriteString(runes[rnd.Intn(len(runes))])
+		}
+		b.replace(start, end, s.String())
+		ref = ref[:start] + s.String() + ref[end:]
+	}
+	if got := b.String(); got != ref {
+		t.Fatal("buffer text differs from the reference")
+	}
+	if got, want := b.runes, utf8.RuneCountInString(ref); got != want {
+		t.Errorf("got %d runes, want %d", got, want)
+	}
+	r := 0
+	for idx := range ref {
+		if got := b.runeOffset(idx); got != r {
+			t.Fatalf("runeOffset(%d) = %d, want %d", idx, got, r)
+		}
+		if got := b.byteOffset(r); got != idx {
+			t.Fatalf("byteOffset(%d) = %d, want %d", r, got, idx)
+		}
+		r++
+	}
+	if got := b.moveRunes(0, r+10); got != len(ref) {
+		t.Errorf("moveRunes past the end = %d, want %d", got, len(ref))
+	}
+	if got := b.moveRunes(len(ref), -r); got != 0 {
+		t.Errorf("moveRunes to the start = %d, want 0", got)
+	}
+}
+
+func TestEditBufferInvalidUTF8(t *testing.T) {
+	// Text without rune starts must still be split into pieces.
+	ref := strings.Repeat("\x80", maxPieceLen+10) + "a" + strings.Repeat("\xff", maxPieceLen)
+	e := new(Editor)
+	e.SetText(ref)
+	if got := e.Text(); got != ref {
+		t.Fatal("editor text differs from the reference")
+	}
+	if got, want := e.rr.runes, utf8.RuneCountInString(ref); got != want {
+		t.Errorf("got %d runes, want %d", got, want)
+	}
+}
+
+func TestEditorMaxLen(t *testing.T) {
+	e := &Editor{MaxLen: 5}
+	e.SetText("æbcdefg")
+	if got, want := e.Text(), "æbcde"; got != want {
+		t.Errorf("SetText: got %q, want %q", got, want)
+	}
+	e.SetSelection(1, 3)
+	e.Insert("øå•")
+	if got, want := e.Text(), "æøåde"; got != want {
+		t.Errorf("Insert: got %q, want %q", got, want)
+	}
+	if evts := e.Events(); len(evts) != 1 || evts[0] != (MaxLenEvent{}) {
+		t.Errorf("got events %v, want a single MaxLenEvent", evts)
+	}
+	e.Insert("x")
+	if got, want := e.Text(), "æøåde"; got != want {
+		t.Errorf("Insert into full editor: got %q, want %q", got, want)
+	}
+}
+
+func TestEditorSpans(t *testing.T) {
+	e := new(Editor)
+	gtx := layout.Context{
+		Ops:         new(op.Ops),
+		Constraints: layout.Exact(image.Pt(100, 100)),
+	}
+	cache := text.NewCache(gofont.Collection())
+	e.SetText("hello world")
+	e.SetSpans([]Span{{Start: 0, End: 5, Underline: true}, {Start: 6, End: 11}})
+	e.Layout(gtx, cache, text.Font{}, unit.Px(10))
+	e.PaintText(gtx)
+	e.SetSelection(3, 8)
+	e.Insert("p, w")
+	want := []Span{{Start: 0, End: 3, Underline: true}, {Start: 7, End: 10}}
+	if got := e.Spans(); !reflect.DeepEqual(got, want) {
+		t.Errorf("got spans %+v, want %+v", got, want)
+	}
+}
+
+func TestEditorSpanCursor(t *testing.T) {
+	e := new(Editor)
+	e.SetText("hello world")
+	e.SetSpans([]Span{{Start: 0, End: 6}, {Start: 2, End: 4}, {Start: 3, End: 8}, {Start: 9, End: 10}})
+	// want[r] is the index of the span covering the rune offset r.
+	want := []int{0, 0, 1, 2, 2, 2, 2, 2, -1, 3, -1}
+	check := func(c *spanCursor, r int) {
+		t.Helper()
+		got := c.at(r)
+		switch w := want[r]; {
+		case w == -1 && got != nil:
+			t.Errorf("at(%d) = %+v, want nil", r, *got)
+		case w != -1 && got != &e.spans[w]:
+			t.Errorf("at(%d) = %+v, want %+v", r, got, e.spans[w])
+		}
+	}
+	c := e.spanCursor(0)
+	for r := range want {
+		check(&c, r)
+	}
+	// Right-to-left text is painted in decreasing rune order.
+	c = e.spanCursor(len(want) - 1)
+	for r := len(want) - 1; r >= 0; r-- {
+		check(&c, r)
+	}
+	for r := range want {
+		c := e.spanCursor(r)
+		check(&c, r)
+	}
+}
+
+func TestEditorLineNumbers(t *testing.T) {
+	e := &Editor{LineNumbers: true}
+	gtx := layout.Context{
+		Ops:         new(op.Ops),
+		Constraints: layout.Exact(image.Pt(100, 100)),
+	}
+	cache := text.NewCache(gofont.Collection())
+	e.SetText("a\nb\nc")
+	dims := e.Layout(gtx, cache, text.Font{}, unit.Px(10))
+	if dims.Size.X != 100 {
+		t.Errorf("got width %d, want 100", dims.Size.X)
+	}
+	if e.gutter.width == 0 || e.viewSize.X != 100-e.gutter.width {
+		t.Errorf("got gutter width %d and view width %d", e.gutter.width, e.viewSize.X)
+	}
+	for i := 0; i < e.NumLines(); i++ {
+		if got := e.logicalLine(i); got != i {
+			t.Errorf("logicalLine(%d) = %d", i, got)
+		}
+	}
+	e.PaintLineNumbers(gtx)
+}
+
+func TestEditorGutterColumns(t *testing.T) {
+	var painted []int
+	e := &Editor{
+		LineNumbers: true,
+		Gutter: []GutterColumn{
+			{Width: unit.Px(8)},
+			{Width: unit.Px(6), Layout: func(gtx layout.Context, l ShapedLine) {
+				if gtx.Constraints.Min.X != 6 {
+					t.Errorf("got column width %d, want 6", gtx.Constraints.Min.X)
+				}
+				painted = append(painted, l.Index)
+			}},
+		},
+	}
+	r := new(router.Router)
+	gtx := layout.Context{
+		Ops:         new(op.Ops),
+		Constraints: layout.Exact(image.Pt(100, 100)),
+		Queue:       r,
+	}
+	cache := text.NewCache(gofont.Collection())
+	e.SetText("a\nb\nc")
+	e.Layout(gtx, cache, text.Font{}, unit.Px(10))
+	numbers := e.gutter.width
+	e.Gutter = e.Gutter[:1]
+	e.Layout(gtx, cache, text.Font{}, unit.Px(10))
+	if got, want := e.gutter.width, numbers-6; got != want {
+		t.Errorf("got gutter width %d, want %d", got, want)
+	}
+	e.Gutter = e.Gutter[:2:2]
+	frame := func() {
+		// Let pointer events through the cursor area of the
+		// editor.
+		stack := op.Push(gtx.Ops)
+		pointer.PassOp{Pass: true}.Add(gtx.Ops)
+		e.Layout(gtx, cache, text.Font{}, unit.Px(10))
+		stack.Pop()
+	}
+	gtx.Ops.Reset()
+	frame()
+	e.PaintGutter(gtx)
+	if !reflect.DeepEqual(painted, []int{0, 1, 2}) {
+		t.Errorf("got painted lines %v, want [0 1 2]", painted)
+	}
+	r.Frame(gtx.Ops)
+	it := e.ShapedLines(0)
+	it.Next()
+	l, _ := it.Next()
+	y := float32(l.Baseline)
+	for _, tc := range []struct {
+		x      float32
+		column int
+	}{{2, 0}, {10, 1}, {float32(numbers - 1), -1}} {
+		r.Add(
+			pointer.Event{Type: pointer.Press, Source: pointer.Mouse, Buttons: pointer.ButtonLeft, Position: f32.Point{X: tc.x, Y: y}},
+			pointer.Event{Type: pointer.Release, Source: pointer.Mouse, Position: f32.Point{X: tc.x, Y: y}},
+		)
+		gtx.Ops.Reset()
+		frame()
+		r.Frame(gtx.Ops)
+		var got []GutterClickEvent
+		for _, evt := range e.Events() {
+			if c, ok := evt.(GutterClickEvent); ok {
+				got = append(got, c)
+			}
+		}
+		want := []GutterClickEvent{{Line: 1, Index: 1, Column: tc.column}}
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("click at x %v: got %+v, want %+v", tc.x, got, want)
+		}
+	}
+}
+
+func TestEditorTabs(t *testing.T) {
+	e := &Editor{SoftTabs: true}
+	gtx := layout.Context{
+		Ops:         new(op.Ops),
+		Constraints: layout.Exact(image.Pt(100, 100)),
+	}
+	cache := text.NewCache(gofont.Collection())
+	e.SetText("ab")
+	e.Layout(gtx, cache, text.Font{}, unit.Px(10))
+	e.SetSelection(1, 1)
+	e.insertTab()
+	if got, want := e.Text(), "a   b"; got != want {
+		t.Errorf("soft tab: got %q, want %q", got, want)
+	}
+	e.SoftTabs = false
+	e.SetText("a\tb")
+	e.Layout(gtx, cache, text.Font{}, unit.Px(10))
+	adv := e.lines.entry(0).Layout.Advances
+	space := cache.LayoutString(text.Font{}, fixed.I(10), inf, " ")[0].Width
+	if got, want := adv[0]+adv[1], 4*space; got != want {
+		t.Errorf("tab stop: got %v, want %v", got, want)
+	}
+	e.TabStops = []unit.Value{unit.Px(30)}
+	e.SetText("a\tb\tc")
+	e.Layout(gtx, cache, text.Font{}, unit.Px(10))
+	adv = e.lines.entry(0).Layout.Advances
+	if got, want := adv[0]+adv[1], fixed.I(30); got != want {
+		t.Errorf("explicit tab stop: got %v, want %v", got, want)
+	}
+	if got, want := adv[0]+adv[1]+adv[2]+adv[3], 4*space*((fixed.I(30)+adv[2])/(4*space)+1); got != want {
+		t.Errorf("tab stop after the explicit stops: got %v, want %v", got, want)
+	}
+}
+
+func TestEditorWrapPolicy(t *testing.T) {
+	gtx := layout.Context{
+		Ops:         new(op.Ops),
+		Constraints: layout.Exact(image.Pt(30, 100)),
+	}
+	cache := text.NewCache(gofont.Collection())
+	e := &Editor{WrapPolicy: WrapRunes}
+	e.SetText("abcdefghij")
+	e.Layout(gtx, cache, text.Font{}, unit.Px(10))
+	if n := e.NumLines(); n < 2 {
+		t.Fatalf("WrapRunes: got %d lines, want at least 2", n)
+	}
+	for i, l := range e.lines.slice() {
+		if l.Width > fixed.I(30) {
+			t.Errorf("WrapRunes: line %d is %v wide", i, l.Width)
+		}
+	}
+	e.WrapPolicy = WrapNone
+	e.Layout(gtx, cache, text.Font{}, unit.Px(10))
+	if n := e.NumLines(); n != 1 {
+		t.Fatalf("WrapNone: got %d lines, want 1", n)
+	}
+	e.SetSelection(e.Len(), e.Len())
+	e.caret.scroll = true
+	e.Layout(gtx, cache, text.Font{}, unit.Px(10))
+	if e.scrollOff.X <= 0 {
+		t.Errorf("WrapNone: got horizontal scroll %d, want > 0", e.scrollOff.X)
+	}
+}
+
+func TestEditorFind(t *testing.T) {
+	e := new(Editor)
+	e.SetText("ab ❤ab\nab")
+	got := e.Find("ab")
+	want := []Range{{0, 2}, {4, 6}, {7, 9}}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("Find: got %v, want %v", got, want)
+	}
+	e.SetSelection(1, 1)
+	if !e.FindNext() {
+		t.Fatal("FindNext found no match")
+	}
+	if start, end := e.Selection(); start != 4 || end != 6 {
+		t.Errorf("FindNext: got selection (%d, %d), want (4, 6)", start, end)
+	}
+	e.FindPrev()
+	if start, end := e.Selection(); start != 0 || end != 2 {
+		t.Errorf("FindPrev: got selection (%d, %d), want (0, 2)", start, end)
+	}
+	e.FindPrev()
+	if start, end := e.Selection(); start != 7 || end != 9 {
+		t.Errorf("FindPrev: got selection (%d, %d), want (7, 9)", start, end)
+	}
+	e.SetText("xab")
+	if got := e.runeRanges(e.findMatches()); !reflect.DeepEqual(got, []Range{{1, 3}}) {
+		t.Errorf("matches after edit: got %v", got)
+	}
+}
+
+func TestEditorFindRegexp(t *testing.T) {
+	e := new(Editor)
+	e.SetText("a=1, ❤=22, c")
+	got := e.FindRegexp(regexp.MustCompile(`(.)=(\d+)|(c)`))
+	want := [][]Range{
+		{{0, 3}, {0, 1}, {2, 3}, {-1, -1}},
+		{{5, 9}, {5, 6}, {7, 9}, {-1, -1}},
+		{{11, 12}, {-1, -1}, {-1, -1}, {11, 12}},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("FindRegexp: got %v, want %v", got, want)
+	}
+	e.SetSelection(0, 3)
+	if !e.Replace("$2:$1") {
+		t.Fatal("Replace didn't replace the selected match")
+	}
+	if got, want := e.Text(), "1:a, ❤=22, c"; got != want {
+		t.Errorf("Replace: got %q, want %q", got, want)
+	}
+	if start, end := e.Selection(); start != 5 || end != 9 {
+		t.Errorf("Replace: got selection (%d, %d), want (5, 9)", start, end)
+	}
+	if n := e.ReplaceAll("<$1$3>"); n != 2 {
+		t.Errorf("ReplaceAll: got %d replacements, want 2", n)
+	}
+	if got, want := e.Text(), "1:a, <❤>, <c>"; got != want {
+		t.Errorf("ReplaceAll: got %q, want %q", got, want)
+	}
+}
+
+func TestEditorSetCaret(t *testing.T) {
+	gtx := layout.Context{
+		Ops:         new(op.Ops),
+		Constraints: layout.Exact(image.Pt(100, 100)),
+	}
+	cache := text.NewCache(gofont.Collection())
+	e := new(Editor)
+	e.SetText("a❤\nbc")
+	e.Layout(gtx, cache, text.Font{}, unit.Px(10))
+	e.SetSelection(0, 2)
+	e.SetCaret(4)
+	if got := e.Caret(); got != 4 {
+		t.Errorf("got caret %d, want 4", got)
+	}
+	if line, col := e.CaretPos(); line != 1 || col != 1 {
+		t.Errorf("got caret position (%d, %d), want (1, 1)", line, col)
+	}
+	if e.SelectedText() != "" {
+		t.Error("SetCaret didn't clear the selection")
+	}
+	e.SetCaret(100)
+	if got := e.Caret(); got != 5 {
+		t.Errorf("got caret %d, want 5", got)
+	}
+}
+
+func TestEditorMoveTo(t *testing.T) {
+	e := new(Editor)
+	e.SetText("ab\n❤cd\ne")
+	tests := []struct {
+		line, col, caret int
+	}{
+		{0, 1, 1},
+		{1, 2, 5},
+		{1, 10, 6},
+		{-1, 0, 0},
+		{5, 5, 8},
+	}
+	for _, tc := range tests {
+		e.MoveTo(tc.line, tc.col, true)
+		if got := e.Caret(); got != tc.caret {
+			t.Errorf("MoveTo(%d, %d): got caret %d, want %d", tc.line, tc.col, got, tc.caret)
+		}
+	}
+}
+
+func TestEditorCaretMargin(t *testing.T) {
+	gtx := layout.Context{
+		Ops:         new(op.Ops),
+		Constraints: layout.Exact(image.Pt(100, 60)),
+	}
+	cache := text.NewCache(gofont.Collection())
+	scroll := func(margin CaretMargin) int {
+		e := &Editor{CaretMargin: margin}
+		e.SetText(strings.Repeat("line\n", 20))
+		e.Layout(gtx, cache, text.Font{}, unit.Px(10))
+		e.MoveTo(10, 0, true)
+		e.Layout(gtx, cache, text.Font{}, unit.Px(10))
+		return e.ScrollOff().Y
+	}
+	plain := scroll(CaretMargin{})
+	if got := scroll(CaretMargin{Lines: 1}); got <= plain {
+		t.Errorf("got scroll offset %d with a margin, want more than %d", got, plain)
+	}
+	if got, want := scroll(CaretMargin{Vertical: unit.Px(5)}), plain+5; got != want {
+		t.Errorf("got scroll offset %d, want %d", got, want)
+	}
+	if got, huge := scroll(CaretMargin{Lines: 100}), scroll(CaretMargin{Lines: 1000}); got != huge {
+		t.Errorf("got scroll offsets %d and %d for huge margins", got, huge)
+	}
+}
+
+func TestEditorViewportFraction(t *testing.T) {
+	gtx := layout.Context{
+		Ops:         new(op.Ops),
+		Constraints: layout.Exact(image.Pt(100, 50)),
+	}
+	cache := text.NewCache(gofont.Collection())
+	e := new(Editor)
+	e.SetText(strings.Repeat("line\n", 19) + "line")
+	e.Layout(gtx, cache, text.Font{}, unit.Px(10))
+	start, end := e.ViewportFraction()
+	if h := e.ContentSize().Y; start != 0 || end != 50/float32(h) {
+		t.Errorf("got viewport %v-%v, want 0-%v", start, end, 50/float32(h))
+	}
+	e.SetScrollFraction(.5)
+	e.Layout(gtx, cache, text.Font{}, unit.Px(10))
+	if got, want := e.ScrollOff().Y, (e.ContentSize().Y+1)/2; got != want {
+		t.Errorf("got scroll offset %d, want %d", got, want)
+	}
+	e.SetScrollFraction(2)
+	e.Layout(gtx, cache, text.Font{}, unit.Px(10))
+	if _, end := e.ViewportFraction(); end != 1 {
+		t.Errorf("got viewport end %v after scrolling past the end, want 1", end)
+	}
+
+	r := new(router.Router)
+	gtx.Queue = r
+	gtx.Constraints = layout.Exact(image.Pt(10, 100))
+	gtx.Ops.Reset()
+	var sb Scrollbar
+	sb.Layout(gtx, layout.Vertical, 0, .25)
+	r.Frame(gtx.Ops)
+	r.Add(pointer.Event{
+		Type:     pointer.Press,
+		Source:   pointer.Mouse,
+		Buttons:  pointer.ButtonLeft,
+		Position: f32.Point{X: 5, Y: 75},
+	})
+	sb.Layout(gtx, layout.Vertical, 0, .25)
+	if got, want := sb.ScrollDistance(), float32(.75-.125); got != want {
+		t.Errorf("got scroll distance %v after pressing the track, want %v", got, want)
+	}
+}
+
+func TestEditorSmoothScroll(t *testing.T) {
+	start := time.Now()
+	gtx := layout.Context{
+		Ops:         new(op.Ops),
+		Constraints: layout.Exact(image.Pt(100, 30)),
+		Now:         start,
+	}
+	cache := text.NewCache(gofont.Collection())
+	e := &Editor{ScrollDuration: 100 * time.Millisecond}
+	e.SetText(strings.Repeat("line\n", 20))
+	e.Layout(gtx, cache, text.Font{}, unit.Px(10))
+	e.ScrollToLine(10)
+	e.Layout(gtx, cache, text.Font{}, unit.Px(10))
+	if got := e.ScrollOff().Y; got != 0 {
+		t.Errorf("got scroll offset %d at the start of the animation, want 0", got)
+	}
+	gtx.Now = start.Add(50 * time.Millisecond)
+	e.Layout(gtx, cache, text.Font{}, unit.Px(10))
+	mid := e.ScrollOff().Y
+	gtx.Now = start.Add(200 * time.Millisecond)
+	e.Layout(gtx, cache, text.Font{}, unit.Px(10))
+	end := e.ScrollOff().Y
+	if mid <= 0 || mid >= end {
+		t.Errorf("got scroll offsets %d and %d during and after the animation", mid, end)
+	}
+}
+
+func TestEditorScrollToLine(t *testing.T) {
+	gtx := layout.Context{
+		Ops:         new(op.Ops),
+		Constraints: layout.Exact(image.Pt(100, 30)),
+	}
+	cache := text.NewCache(gofont.Collection())
+	e := new(Editor)
+	e.SetText(strings.Repeat("line\n", 20))
+	e.Layout(gtx, cache, text.Font{}, unit.Px(10))
+	e.ScrollToLine(5)
+	e.Layout(gtx, cache, text.Font{}, unit.Px(10))
+	want := e.ScrollOff()
+	if want.Y <= 0 {
+		t.Fatalf("got scroll offset %v after ScrollToLine", want)
+	}
+	e2 := new(Editor)
+	e2.SetText(e.Text())
+	e2.SetScrollOff(want)
+	e2.Layout(gtx, cache, text.Font{}, unit.Px(10))
+	if got := e2.ScrollOff(); got != want {
+		t.Errorf("SetScrollOff: got %v, want %v", got, want)
+	}
+	if first, last := e.VisibleLines(); first != 5 || last <= first || last >= 20 {
+		t.Errorf("VisibleLines: got (%d, %d) after ScrollToLine(5)", first, last)
+	}
+	e.SetScrollOff(image.Pt(0, 1e6))
+	e.Layout(gtx, cache, text.Font{}, unit.Px(10))
+	if got := e.ScrollOff(); got.Y != e.scrollBounds().Max.Y {
+		t.Errorf("SetScrollOff: got %v, want clamped offset", got)
+	}
+}
+
+func TestEditorVisibleLines(t *testing.T) {
+	cache := text.NewCache(gofont.Collection())
+	layoutEditor := func(e *Editor, height int) {
+		gtx := layout.Context{
+			Ops:         new(op.Ops),
+			Constraints: layout.Exact(image.Pt(100, height)),
+		}
+		e.Layout(gtx, cache, text.Font{}, unit.Px(10))
+	}
+	e := new(Editor)
+	layoutEditor(e, 30)
+	if first, last := e.VisibleLines(); first != 0 || last != 0 {
+		t.Errorf("empty editor: got (%d, %d), want (0, 0)", first, last)
+	}
+	layoutEditor(e, 0)
+	if first, last := e.VisibleLines(); first <= last {
+		t.Errorf("empty viewport: got (%d, %d), want an empty range", first, last)
+	}
+
+	e.SetText(strings.Repeat("line\n", 20))
+	layoutEditor(e, 30)
+	// The 21 lines are of equal height.
+	h := e.dims.Size.Y / 21
+	if h*21 != e.dims.Size.Y {
+		t.Fatalf("text height %d is not a multiple of 21 lines", e.dims.Size.Y)
+	}
+	// Show two and a half lines.
+	view := 2*h + h/2
+	tests := []struct {
+		scroll      int
+		first, last int
+	}{
+		{0, 0, 2},
+		{3 * h, 3, 5},
+		{4*h - h/4, 3, 6},
+		{18*h + h/2, 18, 20},
+	}
+	for _, tt := range tests {
+		e.SetScrollOff(image.Pt(0, tt.scroll))
+		layoutEditor(e, view)
+		if first, last := e.VisibleLines(); first != tt.first || last != tt.last {
+			t.Errorf("scrolled to %d: got (%d, %d), want (%d, %d)", tt.scroll, first, last, tt.first, tt.last)
+		}
+	}
+}
+
+func TestEditorCarets(t *testing.T) {
+	gtx := layout.Context{
+		Ops:         new(op.Ops),
+		Constraints: layout.Exact(image.Pt(100, 100)),
+	}
+	cache := text.NewCache(gofont.Collection())
+	e := new(Editor)
+	e.SetText("ab\ncd\nef")
+	e.Layout(gtx, cache, text.Font{}, unit.Px(10))
+	e.SetCaret(8)
+	e.AddCaret(5)
+	e.AddCaret(2)
+	e.AddCaret(2)
+	e.AddCaret(8)
+	if got, want := e.Carets(), []int{2, 5}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("got carets %v, want %v", got, want)
+	}
+	e.forEachCaret(func() { e.append("❤") })
+	if got, want := e.Text(), "ab❤\ncd❤\nef❤"; got != want {
+		t.Errorf("typing: got %q, want %q", got, want)
+	}
+	e.forEachCaret(func() { e.Delete(-2) })
+	if got, want := e.Text(), "a\nc\ne"; got != want {
+		t.Errorf("deletion: got %q, want %q", got, want)
+	}
+	if got, want := e.Carets(), []int{1, 3}; !reflect.DeepEqual(got, want) {
+		t.Errorf("got carets %v after deletion, want %v", got, want)
+	}
+	e.forEachCaret(func() { e.moveKey(key.NameLeftArrow, false) })
+	if got, want := e.Carets(), []int{0, 2}; !reflect.DeepEqual(got, want) || e.Caret() != 4 {
+		t.Errorf("got carets %v and caret %d after move, want %v and 4", got, want, e.Caret())
+	}
+	e.Undo()
+	if got, want := e.Text(), "ab❤\ncd❤\nef❤"; got != want {
+		t.Errorf("undo: got %q, want %q", got, want)
+	}
+	e.CollapseCarets()
+	if got := e.Carets(); got != nil {
+		t.Errorf("got carets %v after CollapseCarets", got)
+	}
+}
+
+func TestEditorBlockSelection(t *testing.T) {
+	gtx := layout.Context{
+		Ops:         new(op.Ops),
+		Constraints: layout.Exact(image.Pt(100, 100)),
+	}
+	cache := text.NewCache(gofont.Collection())
+	e := new(Editor)
+	e.SetText("abcd\nefgh\nijkl")
+	e.Layout(gtx, cache, text.Font{Variant: "Mono"}, unit.Px(10))
+	_, _, x0, y0 := e.locate(1)
+	_, _, x1, y1 := e.locate(13)
+	e.block = block{
+		active: true,
+		start:  image.Pt(x0.Round(), y0),
+		end:    image.Pt(x1.Round(), y1),
+	}
+	if got, want := e.SelectedText(), "bc\nfg\njk"; got != want {
+		t.Errorf("got block text %q, want %q", got, want)
+	}
+	e.takeBlock()
+	e.forEachCaret(func() { e.append("X") })
+	if got, want := e.Text(), "aXd\neXh\niXl"; got != want {
+		t.Errorf("typing over block: got %q, want %q", got, want)
+	}
+}
+
+func TestEditorDropSelection(t *testing.T) {
+	tests := []struct {
+		drop      int
+		copy      bool
+		want      string
+		selection [2]int
+	}{
+		{drop: 11, want: "hello rldwo", selection: [2]int{9, 11}},
+		{drop: 0, want: "wohello rld", selection: [2]int{0, 2}},
+		{drop: 0, copy: true, want: "wohello world", selection: [2]int{0, 2}},
+		{drop: 7, want: "hello world", selection: [2]int{7, 7}},
+	}
+	for _, tc := range tests {
+		e := new(Editor)
+		e.SetText("hello world")
+		e.SetSelection(6, 8)
+		e.drag.drop = tc.drop
+		e.dropSelection(tc.copy)
+		if got := e.Text(); got != tc.want {
+			t.Errorf("drop at %d: got %q, want %q", tc.drop, got, tc.want)
+		}
+		if start, end := e.selectionBytes(); start != tc.selection[0] || end != tc.selection[1] {
+			t.Errorf("drop at %d: got selection (%d, %d), want %v", tc.drop, start, end, tc.selection)
+		}
+	}
+}
+
+func TestEditorDropText(t *testing.T) {
+	gtx := layout.Context{
+		Ops:         new(op.Ops),
+		Constraints: layout.Exact(image.Pt(100, 100)),
+	}
+	cache := text.NewCache(gofont.Collection())
+	e := &Editor{LineNumbers: true}
+	e.SetText("ab\ncd")
+	e.Layout(gtx, cache, text.Font{}, unit.Px(10))
+	_, _, x, y := e.locate(4)
+	pos := image.Pt(x.Round()+e.gutter.width, y)
+	e.DropText(pos, "xy")
+	if got, want := e.Text(), "ab\ncxyd"; got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+	if got, want := e.SelectedText(), "xy"; got != want {
+		t.Errorf("got selection %q, want %q", got, want)
+	}
+
+	// Drops routed through the window.
+	r := new(router.Router)
+	gtx.Queue = r
+	gtx.Ops.Reset()
+	e.Layout(gtx, cache, text.Font{}, unit.Px(10))
+	r.Frame(gtx.Ops)
+	_, _, x, y = e.locate(1)
+	r.Add(transfer.DropEvent{
+		Position: f32.Pt(float32(x.Round()+e.gutter.width), float32(y)),
+		Text:     "z",
+	})
+	gtx.Ops.Reset()
+	e.Layout(gtx, cache, text.Font{}, unit.Px(10))
+	if got, want := e.Text(), "azb\ncxyd"; got != want {
+		t.Errorf("routed drop: got %q, want %q", got, want)
+	}
+	if got, want := e.SelectedText(), "z"; got != want {
+		t.Errorf("routed drop: got selection %q, want %q", got, want)
+	}
+}
+
+func TestEditorComposition(t *testing.T) {
+	e := new(Editor)
+	e.SetText("ab")
+	e.SetCaret(1)
+	e.compose("x")
+	e.compose("xy")
+	if got, want := e.Text(), "axyb"; got != want {
+		t.Errorf("compose: got %q, want %q", got, want)
+	}
+	if len(e.history.undo) != 0 {
+		t.Errorf("composition recorded in undo history")
+	}
+	e.endComposition()
+	e.append("Z")
+	if got, want := e.Text(), "aZb"; got != want {
+		t.Errorf("commit: got %q, want %q", got, want)
+	}
+	e.compose("q")
+	e.commitComposition()
+	if got, want := e.Text(), "aZqb"; got != want {
+		t.Errorf("commit as typed: got %q, want %q", got, want)
+	}
+	e.Undo()
+	e.Undo()
+	if got, want := e.Text(), "ab"; got != want {
+		t.Errorf("undo: got %q, want %q", got, want)
+	}
+}
+
+func TestEditorCompositionRect(t *testing.T) {
+	gtx := layout.Context{
+		Ops:         new(op.Ops),
+		Constraints: layout.Exact(image.Pt(100, 100)),
+	}
+	cache := text.NewCache(gofont.Collection())
+	e := new(Editor)
+	e.SetText("ab")
+	e.SetCaret(2)
+	e.Layout(gtx, cache, text.Font{}, unit.Px(10))
+	if _, _, ok := e.Composition(); ok {
+		t.Error("composition active before composing")
+	}
+	caret := e.CompositionRect()
+	if caret.Dx() != 0 || caret.Dy() <= 0 || caret.Min.X <= 0 {
+		t.Errorf("got caret bounds %v", caret)
+	}
+	e.compose("xy")
+	start, end, ok := e.Composition()
+	if !ok || start != 2 || end != 4 {
+		t.Errorf("got composition (%d, %d, %v), want (2, 4, true)", start, end, ok)
+	}
+	r := e.CompositionRect()
+	if r.Min != caret.Min || r.Dx() <= 0 || r.Max.Y != caret.Max.Y {
+		t.Errorf("got composition bounds %v, want starting at %v", r, caret.Min)
+	}
+}
+
+func TestEditorEmacsKeymap(t *testing.T) {
+	gtx := layout.Context{Ops: new(op.Ops)}
+	e := &Editor{Keymap: EmacsKeymap}
+	e.SetText("hello big world")
+	ctrl := func(name string) {
+		e.command(gtx, key.Event{Name: name, Modifiers: key.ModCtrl})
+	}
+	ctrl("E")
+	if got, want := e.Caret(), 15; got != want {
+		t.Errorf("Ctrl+E: got caret %d, want %d", got, want)
+	}
+	ctrl("W")
+	if got, want := e.Text(), "hello big "; got != want {
+		t.Errorf("Ctrl+W: got %q, want %q", got, want)
+	}
+	ctrl("A")
+	ctrl("F")
+	ctrl("K")
+	if got, want := e.Text(), "h"; got != want {
+		t.Errorf("Ctrl+K: got %q, want %q", got, want)
+	}
+	e.command(gtx, key.Event{Name: "D", Modifiers: key.ModAlt})
+	if got, want := e.Text(), "h"; got != want {
+		t.Errorf("Alt+D at end: got %q, want %q", got, want)
+	}
+}
+
+func TestEditorVimKeymap(t *testing.T) {
+	gtx := layout.Context{
+		Ops:         new(op.Ops),
+		Constraints: layout.Exact(image.Pt(100, 100)),
+	}
+	cache := text.NewCache(gofont.Collection())
+	e := &Editor{Keymap: VimKeymap}
+	e.SetText("one two\nthree")
+	tests := []struct {
+		keys string
+		text string
+	}{
+		{"dw", "two\nthree"},
+		{"2x", "o\nthree"},
+		{"u", "two\nthree"},
+		{"jdd", "two"},
+		{"P", "three\ntwo"},
+		{"ggyyGp", "three\ntwo\nthree"},
+	}
+	for _, tc := range tests {
+		e.Layout(gtx, cache, text.Font{}, unit.Px(10))
+		e.vimInput(tc.keys)
+		if got := e.Text(); got != tc.text {
+			t.Errorf("%q: got %q, want %q", tc.keys, got, tc.text)
+		}
+	}
+	e.vimInput("ggcw")
+	if got, want := e.VimMode(), VimInsert; got != want {
+		t.Errorf("cw: got mode %v, want %v", got, want)
+	}
+	e.command(gtx, key.Event{Name: key.NameEscape})
+	if got, want := e.VimMode(), VimNormal; got != want {
+		t.Errorf("Escape: got mode %v, want %v", got, want)
+	}
+}
+
+func TestEditorPrimary(t *testing.T) {
+	r := new(router.Router)
+	gtx := layout.Context{
+		Ops:         new(op.Ops),
+		Constraints: layout.Exact(image.Pt(100, 100)),
+		Queue:       r,
+	}
+	cache := text.NewCache(gofont.Collection())
+	e := new(Editor)
+	frame := func() {
+		// Let pointer events through the cursor area of the
+		// editor.
+		stack := op.Push(gtx.Ops)
+		pointer.PassOp{Pass: true}.Add(gtx.Ops)
+		e.Layout(gtx, cache, text.Font{}, unit.Px(10))
+		stack.Pop()
+	}
+	e.SetText("hello world")
+	e.SetSelection(6, 11)
+	frame()
+	r.Frame(gtx.Ops)
+	if got, ok := r.WritePrimary(); !ok || got != "world" {
+		t.Errorf("got primary %q, %v, want %q", got, ok, "world")
+	}
+	r.Add(pointer.Event{
+		Type:     pointer.Press,
+		Source:   pointer.Mouse,
+		Buttons:  pointer.ButtonMiddle,
+		Position: f32.Point{X: 1, Y: 5},
+	})
+	gtx.Ops.Reset()
+	frame()
+	r.Frame(gtx.Ops)
+	if !r.ReadPrimary() {
+		t.Fatal("middle click didn't read the primary selection")
+	}
+	r.Add(clipboard.Event{Text: "big ", Primary: true})
+	gtx.Ops.Reset()
+	frame()
+	if got, want := e.Text(), "big hello world"; got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
+
+func TestEditorContextEvent(t *testing.T) {
+	r := new(router.Router)
+	start := time.Now()
+	gtx := layout.Context{
+		Ops:         new(op.Ops),
+		Constraints: layout.Exact(image.Pt(100, 100)),
+		Queue:       r,
+		Now:         start,
+	}
+	cache := text.NewCache(gofont.Collection())
+	e := new(Editor)
+	frame := func() []EditorEvent {
+		gtx.Ops.Reset()
+		stack := op.Push(gtx.Ops)
+		pointer.PassOp{Pass: true}.Add(gtx.Ops)
+		e.Layout(gtx, cache, text.Font{}, unit.Px(10))
+		stack.Pop()
+		r.Frame(gtx.Ops)
+		var events []EditorEvent
+		for _, evt := range e.Events() {
+			if _, ok := evt.(ContextEvent); ok {
+				events = append(events, evt)
+			}
+		}
+		return events
+	}
+	e.SetText("hello world")
+	// Deliver the initial focus event.
+	frame()
+	frame()
+	e.SetSelection(0, 11)
+	r.Add(pointer.Event{
+		Type:     pointer.Press,
+		Source:   pointer.Mouse,
+		Buttons:  pointer.ButtonRight,
+		Position: f32.Point{X: 5, Y: 5},
+	})
+	want := ContextEvent{Position: f32.Point{X: 5, Y: 5}, Selection: true}
+	if got := frame(); len(got) != 1 || got[0] != want {
+		t.Errorf("got %v after right click, want %v", got, want)
+	}
+
+	e.ClearSelection()
+	r.Add(pointer.Event{
+		Type:     pointer.Press,
+		Source:   pointer.Touch,
+		Position: f32.Point{X: 5, Y: 5},
+	})
+	if got := frame(); len(got) != 0 {
+		t.Errorf("got %v at the start of a long press", got)
+	}
+	gtx.Now = start.Add(longPressDuration)
+	want.Selection = false
+	if got := frame(); len(got) != 1 || got[0] != want {
+		t.Errorf("got %v after long press, want %v", got, want)
+	}
+}
+
+func TestEditorMarks(t *testing.T) {
+	e := new(Editor)
+	e.SetText("helo wrld again")
+	red := color.NRGBA{R: 0xff, A: 0xff}
+	e.SetMarks([]Mark{{Start: 0, End: 4, Color: red}, {Start: 5, End: 9, Color: red}})
+	e.SetCaret(8)
+	e.Insert("o")
+	want := []Mark{{Start: 0, End: 4, Color: red}}
+	if got := e.Marks(); !reflect.DeepEqual(got, want) {
+		t.Errorf("got marks %v, want %v", got, want)
+	}
+	var got []MarksEvent
+	for _, evt := range e.Events() {
+		if evt, ok := evt.(MarksEvent); ok {
+			got = append(got, evt)
+		}
+	}
+	if want := []MarksEvent{{Start: 5, End: 10}}; !reflect.DeepEqual(got, want) {
+		t.Errorf("got events %v, want %v", got, want)
+	}
+	e.SetCaret(0)
+	e.Insert("oh ")
+	if got := e.Marks(); len(got) != 0 {
+		t.Errorf("got marks %v after editing next to them, want none", got)
+	}
+}
+
+func TestEditorLinks(t *testing.T) {
+	for _, tc := range []struct {
+		text string
+		want []link
+	}{
+		{"see https://gioui.org.", []link{{4, 21}}},
+		{"(www.example.com) or ftp://x/y", []link{{1, 16}, {21, 30}}},
+		{"wiki https://en.wikipedia.org/wiki/Go_(game)!", []link{{5, 44}}},
+		{"no links here", nil},
+	} {
+		if got := findLinks(tc.text); !reflect.DeepEqual(got, tc.want) {
+			t.Errorf("%q: got links %v, want %v", tc.text, got, tc.want)
+		}
+	}
+
+	r := new(router.Router)
+	gtx := layout.Context{
+		Ops:         new(op.Ops),
+		Constraints: layout.Exact(image.Pt(200, 100)),
+		Queue:       r,
+	}
+	cache := text.NewCache(gofont.Collection())
+	e := &Editor{DetectLinks: true}
+	frame := func() {
+		gtx.Ops.Reset()
+		stack := op.Push(gtx.Ops)
+		pointer.PassOp{Pass: true}.Add(gtx.Ops)
+		e.Layout(gtx, cache, text.Font{}, unit.Px(10))
+		stack.Pop()
+		r.Frame(gtx.Ops)
+	}
+	e.SetText("https://gioui.org")
+	frame()
+	r.Add(
+		pointer.Event{Type: pointer.Press, Source: pointer.Touch, Position: f32.Point{X: 5, Y: 5}},
+		pointer.Event{Type: pointer.Release, Source: pointer.Touch, Position: f32.Point{X: 5, Y: 5}},
+	)
+	frame()
+	var got []LinkClickEvent
+	for _, evt := range e.Events() {
+		if evt, ok := evt.(LinkClickEvent); ok {
+			got = append(got, evt)
+		}
+	}
+	want := []LinkClickEvent{{URL: "https://gioui.org", Start: 0, End: 17}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got events %v after tapping a link, want %v", got, want)
+	}
+}
+
+func TestEditorDecorations(t *testing.T) {
+	gtx := layout.Context{
+		Ops:         new(op.Ops),
+		Constraints: layout.Exact(image.Pt(100, 100)),
+	}
+	cache := text.NewCache(gofont.Collection())
+	e := new(Editor)
+	e.SetText("hello world")
+	search := DecorationStyle{Kind: DecorationBackground | DecorationOutline, Tag: "search"}
+	cursor := DecorationStyle{Kind: DecorationBackground, Tag: "cursor"}
+	e.AddDecoration(6, 11, search)
+	e.AddDecoration(5, 5, cursor)
+	e.AddDecoration(0, 5, DecorationStyle{Kind: DecorationUnderline | DecorationStrikethrough | DecorationSquiggle})
+	e.Layout(gtx, cache, text.Font{}, unit.Px(10))
+	e.PaintText(gtx)
+
+	e.SetCaret(0)
+	e.Insert("oh, ")
+	e.SetSelection(0, 9)
+	e.Delete(1)
+	want := []decoration{
+		{start: 1, end: 6, style: search},
+		{start: 0, end: 0, style: cursor},
+	}
+	if got := e.decorations; !reflect.DeepEqual(got, want) {
+		t.Errorf("got decorations %v, want %v", got, want)
+	}
+	e.ClearDecorations("search")
+	if got := e.decorations; !reflect.DeepEqual(got, want[1:]) {
+		t.Errorf("got decorations %v after clearing, want %v", got, want[1:])
+	}
+	e.Layout(gtx, cache, text.Font{}, unit.Px(10))
+	e.PaintText(gtx)
+}
+
+func TestEditorHover(t *testing.T) {
+	r := new(router.Router)
+	gtx := layout.Context{
+		Ops:         new(op.Ops),
+		Constraints: layout.Exact(image.Pt(200, 100)),
+		Queue:       r,
+	}
+	cache := text.NewCache(gofont.Collection())
+	e := new(Editor)
+	e.SetText("hello world")
+	e.SetHoverRanges([]HoverRange{{Start: 6, End: 11, Tag: "world"}})
+	frame := func() []EditorEvent {
+		gtx.Ops.Reset()
+		stack := op.Push(gtx.Ops)
+		pointer.PassOp{Pass: true}.Add(gtx.Ops)
+		e.Layout(gtx, cache, text.Font{}, unit.Px(10))
+		stack.Pop()
+		r.Frame(gtx.Ops)
+		var events []EditorEvent
+		for _, evt := range e.Events() {
+			switch evt.(type) {
+			case HoverEvent, HoverRangeEvent:
+				events = append(events, evt)
+			}
+		}
+		return events
+	}
+	frame()
+	_, _, x, _ := e.locate(7)
+	pos := f32.Point{X: float32(x.Ceil()) + 1, Y: 5}
+	r.Add(pointer.Event{Type: pointer.Move, Source: pointer.Mouse, Position: pos})
+	want := []EditorEvent{
+		HoverEvent{Offset: 7, Position: pos},
+		HoverRangeEvent{Range: HoverRange{Start: 6, End: 11, Tag: "world"}, Enter: true},
+	}
+	if got := frame(); !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+	r.Add(pointer.Event{Type: pointer.Move, Source: pointer.Mouse, Position: f32.Point{X: 150, Y: 5}})
+	want = []EditorEvent{
+		HoverEvent{Offset: -1, Position: f32.Point{X: 150, Y: 5}},
+		HoverRangeEvent{Range: HoverRange{Start: 6, End: 11, Tag: "world"}},
+	}
+	if got := frame(); !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+}
+
+func TestEditorCaretAdvance(t *testing.T) {
+	gtx := layout.Context{
+		Ops:         new(op.Ops),
+		Constraints: layout.Exact(image.Pt(100, 100)),
+	}
+	cache := text.NewCache(gofont.Collection())
+	e := &Editor{CaretStyle: CaretBlock}
+	e.SetText("ab")
+	e.Layout(gtx, cache, text.Font{}, unit.Px(10))
+	if adv := e.caretAdvance(0, 0); adv != e.lines.entry(0).Layout.Advances[0] {
+		t.Errorf("got advance %v for the first rune, want %v", adv, e.lines.entry(0).Layout.Advances[0])
+	}
+	if got, want := e.caretAdvance(0, 2), fixed.I(5); got != want {
+		t.Errorf("got advance %v at the end, want %v", got, want)
+	}
+}
+
+func TestEditorHighlightTails(t *testing.T) {
+	gtx := layout.Context{
+		Ops:         new(op.Ops),
+		Constraints: layout.Exact(image.Pt(100, 100)),
+	}
+	cache := text.NewCache(gofont.Collection())
+	e := new(Editor)
+	e.SetText("ab\ncd")
+	e.Layout(gtx, cache, text.Font{}, unit.Px(10))
+	rects := e.lineRegions(1, 4, true)
+	if len(rects) != 2 {
+		t.Fatalf("got %d regions, want 2", len(rects))
+	}
+	if got, want := rects[0].Max.X, 100; got != want {
+		t.Errorf("got first line tail at %d, want %d", got, want)
+	}
+	if rects[1].Max.X >= 100 {
+		t.Errorf("last line extended to %d", rects[1].Max.X)
+	}
+}
+
+func TestWhitespaceMarker(t *testing.T) {
+	tests := []struct {
+		ws       Whitespace
+		r        rune
+		trailing bool
+		want     string
+	}{
+		{WhitespaceAll, ' ', false, "·"},
+		{WhitespaceAll, '\t', false, "→"},
+		{WhitespaceAll, '\n', false, "¶"},
+		{WhitespaceAll, 'a', false, ""},
+		{WhitespaceTrailing, ' ', false, ""},
+		{WhitespaceTrailing, ' ', true, "·"},
+		{WhitespaceTrailing, '\n', true, ""},
+		{WhitespaceSpaces, '\t', true, ""},
+	}
+	for _, tc := range tests {
+		if got := tc.ws.marker(tc.r, tc.trailing); got != tc.want {
+			t.Errorf("%b.marker(%q, %v) = %q, want %q", tc.ws, tc.r, tc.trailing, got, tc.want)
+		}
+	}
+}
+
+func TestEditorLineEnding(t *testing.T) {
+	e := new(Editor)
+	e.SetText("ab\r\ncd\re")
+	if got, want := e.LineEnding(), LineEndingCRLF; got != want {
+		t.Errorf("got line ending %v, want %v", got, want)
+	}
+	if got, want := e.rr.String(), "ab\ncd\ne"; got != want {
+		t.Errorf("got contents %q, want %q", got, want)
+	}
+	e.SetCaret(2)
+	e.Insert("x\r\n")
+	if got, want := e.Text(), "abx\r\n\r\ncd\r\ne"; got != want {
+		t.Errorf("got text %q, want %q", got, want)
+	}
+	if got, want := e.Len(), len(e.Text()); got != want {
+		t.Errorf("got length %d, want %d", got, want)
+	}
+	e.SetText("a\nb\r\n")
+	if got, want := e.LineEnding(), LineEndingLF; got != want {
+		t.Errorf("got line ending %v, want %v", got, want)
+	}
+}
+
+func TestEditorRTL(t *testing.T) {
+	gtx := layout.Context{
+		Ops:         new(op.Ops),
+		Constraints: layout.Exact(image.Pt(100, 100)),
+	}
+	cache := text.NewCache(gofont.Collection())
+	e := new(Editor)
+	e.SetText("abc\n1 שלום\n")
+	e.Layout(gtx, cache, text.Font{}, unit.Px(10))
+	var rtl []bool
+	for _, l := range e.lines.slice() {
+		rtl = append(rtl, l.rtl)
+	}
+	if got, want := rtl, []bool{false, true, false}; !reflect.DeepEqual(got, want) {
+		t.Errorf("got directions %v, want %v", got, want)
+	}
+	if x := e.lineAlign(e.lines.entry(0)); x != 0 {
+		t.Errorf("left-to-right line aligned at %v", x)
+	}
+	l := e.lines.entry(1)
+	if got, want := e.lineAlign(l), fixed.I(100)-l.Width; got.Floor() != want.Floor() {
+		t.Errorf("right-to-left line aligned at %v, want %v", got, want)
+	}
+}
+
+func TestBidiLevels(t *testing.T) {
+	tests := []struct {
+		text   string
+		rtl    bool
+		levels []uint8
+		order  []int
+	}{
+		{"ab אבג cd", false, []uint8{0, 0, 0, 1, 1, 1, 0, 0, 0}, []int{0, 1, 2, 5, 4, 3, 6, 7, 8}},
+		{"אב 12 ג", false, []uint8{1, 1, 1, 2, 2, 1, 1}, []int{6, 5, 3, 4, 2, 1, 0}},
+		{"אב cd ג\n", true, []uint8{1, 1, 1, 2, 2, 1, 1, 1}, []int{7, 6, 5, 3, 4, 2, 1, 0}},
+		{"12 ab", true, []uint8{2, 2, 1, 2, 2}, []int{3, 4, 2, 0, 1}},
+		{"ab 12 ", false, []uint8{0, 0, 0, 0, 0, 0}, []int{0, 1, 2, 3, 4, 5}},
+	}
+	for _, test := range tests {
+		levels := bidiLevels(test.text, test.rtl)
+		if !reflect.DeepEqual(levels, test.levels) {
+			t.Errorf("%q: got levels %v, want %v", test.text, levels, test.levels)
+			continue
+		}
+		if got := visualOrder(levels); !reflect.DeepEqual(got, test.order) {
+			t.Errorf("%q: got order %v, want %v", test.text, got, test.order)
+		}
+	}
+}
+
+func TestEditorBidi(t *testing.T) {
+	gtx := layout.Context{
+		Ops:         new(op.Ops),
+		Constraints: layout.Exact(image.Pt(200, 100)),
+	}
+	cache := text.NewCache(gofont.Collection())
+	e := new(Editor)
+	e.SetText("ab (אבג) cd")
+	e.Layout(gtx, cache, text.Font{}, unit.Px(10))
+	b := e.lines.entry(0).bidi
+	if b == nil {
+		t.Fatal("mixed line not reordered")
+	}
+	if got, want := b.visual.Text, "ab (גבא) cd"; got != want {
+		t.Errorf("got visual text %q, want %q", got, want)
+	}
+	if got := e.shapes[0].layout.Text; got != b.visual.Text {
+		t.Errorf("painted %q, want %q", got, b.visual.Text)
+	}
+	adv := e.lines.entry(0).Layout.Advances
+	// left returns the left edge of the rune at visual position v.
+	left := func(v int) fixed.Int26_6 {
+		var x fixed.Int26_6
+		for _, j := range b.order[:v] {
+			x += adv[j]
+		}
+		return x
+	}
+	offset := func(col int) int {
+		return len(string([]rune(e.Text())[:col]))
+	}
+	// The caret before א is at its right edge, the left edge of
+	// the closing parenthesis.
+	if _, _, x, _ := e.locate(offset(4)); x != left(7) {
+		t.Errorf("caret before right-to-left run at %v, want %v", x, left(7))
+	}
+	if _, _, x, _ := e.locate(offset(5)); x != left(6) {
+		t.Errorf("caret inside right-to-left run at %v, want %v", x, left(6))
+	}
+	e.SetCaret(5)
+	e.Move(1)
+	if got, want := e.caret.x, left(5); got != want {
+		t.Errorf("caret moved inside right-to-left run to %v, want %v", got, want)
+	}
+	// Clicking left of the middle of ב places the caret after it.
+	e.moveCoord(image.Pt((left(5) + adv[5]/4).Round(), 5))
+	if got, want := e.rr.caret, offset(6); got != want {
+		t.Errorf("click moved caret to %d, want %d", got, want)
+	}
+	// Selecting "בג)" covers two visual ranges.
+	regions := e.regions(offset(5), offset(8))
+	var xs [][2]int
+	for _, r := range regions {
+		xs = append(xs, [2]int{r.Min.X, r.Max.X})
+	}
+	want := [][2]int{
+		{left(4).Floor(), left(6).Ceil()},
+		{left(7).Floor(), left(8).Ceil()},
+	}
+	if !reflect.DeepEqual(xs, want) {
+		t.Errorf("got selection ranges %v, want %v", xs, want)
+	}
+	// Brackets in right-to-left runs are mirrored.
+	e.SetText("א (ב)")
+	e.Layout(gtx, cache, text.Font{}, unit.Px(10))
+	if got, want := e.lines.entry(0).bidi.visual.Text, "(ב) א"; got != want {
+		t.Errorf("got visual text %q, want %q", got, want)
+	}
+}
+
+func TestEditorKillRing(t *testing.T) {
+	gtx := layout.Context{Ops: new(op.Ops)}
+	e := &Editor{Keymap: EmacsKeymap}
+	e.SetText("one two three")
+	press := func(name string, mods key.Modifiers) {
+		e.command(gtx, key.Event{Name: name, Modifiers: mods})
+	}
+	// Consecutive kills accumulate.
+	press("E", key.ModCtrl)
+	press(key.NameDeleteBackward, key.ModAlt)
+	press(key.NameDeleteBackward, key.ModAlt)
+	if got, want := e.Text(), "one "; got != want {
+		t.Fatalf("got %q, want %q", got, want)
+	}
+	press("A", key.ModCtrl)
+	press("K", key.ModCtrl)
+	if got, want := e.kills.kills, []string{"two three", "one "}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("got kill ring %q, want %q", got, want)
+	}
+	press("Y", key.ModCtrl)
+	if got, want := e.Text(), "one "; got != want {
+		t.Errorf("yank: got %q, want %q", got, want)
+	}
+	press("Y", key.ModAlt)
+	if got, want := e.Text(), "two three"; got != want {
+		t.Errorf("yank pop: got %q, want %q", got, want)
+	}
+	press("Y", key.ModAlt)
+	if got, want := e.Text(), "one "; got != want {
+		t.Errorf("second yank pop: got %q, want %q", got, want)
+	}
+	press("F", key.ModCtrl)
+	press("Y", key.ModAlt)
+	if got, want := e.Text(), "one "; got != want {
+		t.Errorf("yank pop after motion: got %q, want %q", got, want)
+	}
+}
+
+func TestEditorCaretEvent(t *testing.T) {
+	gtx := layout.Context{
+		Ops:         new(op.Ops),
+		Constraints: layout.Exact(image.Pt(100, 100)),
+	}
+	cache := text.NewCache(gofont.Collection())
+	e := new(Editor)
+	e.SetText("ab\ncd")
+	e.Layout(gtx, cache, text.Font{}, unit.Px(10))
+	e.Events()
+	e.SetCaret(4)
+	e.Layout(gtx, cache, text.Font{}, unit.Px(10))
+	var got []CaretEvent
+	for _, evt := range e.Events() {
+		if c, ok := evt.(CaretEvent); ok {
+			got = append(got, c)
+		}
+	}
+	if want := []CaretEvent{{Line: 1, Col: 1, Offset: 4}}; !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+	e.Layout(gtx, cache, text.Font{}, unit.Px(10))
+	if evts := e.Events(); len(evts) != 0 {
+		t.Errorf("got events %v for unmoved caret", evts)
+	}
+}
+
+func TestEditorNoLayout(t *testing.T) {
+	var e Editor
+	e.SetText("hi!\n")
+	e.Move(1)
+}
+
+func TestEditorShortcutNames(t *testing.T) {
+	tests := []struct {
+		name, physical, want string
+	}{
+		{"C", "C", "C"},
+		{"С", "C", "C"}, // Cyrillic Es.
+		{"Χ", "X", "X"}, // Greek Chi.
+		{"ზ", "Z", "Z"}, // Georgian Zen.
+		{"Q", "A", "Q"}, // AZERTY.
+		{"É", "", "É"},
+		{key.NameTab, "", key.NameTab},
+	}
+	for _, tt := range tests {
+		if got := shortcutName(key.Event{Name: tt.name, Physical: tt.physical}); got != tt.want {
+			t.Errorf("shortcutName(%q, %q) = %q, want %q", tt.name, tt.physical, got, tt.want)
+		}
+	}
+
+	e := new(Editor)
+	e.SetText("hello")
+	r := new(router.Router)
+	gtx := layout.Context{Ops: new(op.Ops), Queue: r}
+	// Copy with a Latin and a Cyrillic key.
+	for _, name := range []string{"C", "С"} {
+		gtx.Ops.Reset()
+		e.SetSelection(0, 4)
+		e.command(gtx, key.Event{Name: name, Physical: "C", Modifiers: key.ModShortcut})
+		r.Frame(gtx.Ops)
+		if txt, _ := r.WriteClipboard(); txt != "hell" {
+			t.Errorf("%q: got copied text %q, want %q", name, txt, "hell")
+		}
+	}
+}
+
+// Generate generates a value of itself, for testing/quick.
+func (editMutation) Generate(rand *rand.Rand, size int) reflect.Value {
+	t := editMutation(rand.Intn(int(moveLast)))
 	return reflect.ValueOf(t)
 }
+
+func TestMinimap(t *testing.T) {
+	r := new(router.Router)
+	gtx := layout.Context{
+		Ops:         new(op.Ops),
+		Constraints: layout.Exact(image.Pt(100, 40)),
+		Queue:       r,
+	}
+	cache := text.NewCache(gofont.Collection())
+	e := new(Editor)
+	e.SetText(strings.Repeat("line\n", 100))
+	e.Layout(gtx, cache, text.Font{}, unit.Px(10))
+	m := &Minimap{Scale: .5}
+	mgtx := gtx
+	mgtx.Constraints = layout.Exact(image.Pt(20, 40))
+	m.Layout(mgtx, e)
+	if got, want := m.viewport, image.Rect(0, 0, 20, 20); got != want {
+		t.Errorf("got viewport %v, want %v", got, want)
+	}
+	// The overview scrolls along with the editor.
+	content := e.ContentSize().Y
+	e.SetScrollOff(image.Pt(0, content-40))
+	e.Layout(gtx, cache, text.Font{}, unit.Px(10))
+	m.Layout(mgtx, e)
+	if got, want := m.viewport, image.Rect(0, 20, 20, 40); got != want {
+		t.Errorf("got viewport %v at the end, want %v", got, want)
+	}
+	gtx.Ops.Reset()
+	m.Layout(mgtx, e)
+	r.Frame(gtx.Ops)
+	r.Add(pointer.Event{
+		Type:     pointer.Press,
+		Source:   pointer.Mouse,
+		Buttons:  pointer.ButtonLeft,
+		Position: f32.Point{X: 5, Y: 0},
+	})
+	off := m.off
+	m.Layout(mgtx, e)
+	want := int(float32(off)/.5+.5) - 20
+	if got := e.ScrollOff().Y; got != want {
+		t.Errorf("got scroll offset %d after pressing the minimap, want %d", got, want)
+	}
+}
+
+func TestDiffLines(t *testing.T) {
+	a := []string{"a", "b", "c", "d"}
+	b := []string{"a", "x", "c", "d", "e"}
+	akinds, bkinds := diffLines(a, b)
+	if want := []DiffLineKind{DiffUnchanged, DiffRemoved, DiffUnchanged, DiffUnchanged}; !reflect.DeepEqual(akinds, want) {
+		t.Errorf("got old kinds %v, want %v", akinds, want)
+	}
+	if want := []DiffLineKind{DiffUnchanged, DiffAdded, DiffUnchanged, DiffUnchanged, DiffAdded}; !reflect.DeepEqual(bkinds, want) {
+		t.Errorf("got new kinds %v, want %v", bkinds, want)
+	}
+	want := []DiffHunk{{1, 2, 1, 2}, {4, 4, 4, 5}}
+	if got := diffHunks(akinds, bkinds); !reflect.DeepEqual(got, want) {
+		t.Errorf("got hunks %v, want %v", got, want)
+	}
+	akinds, bkinds = diffLines([]string{"x", "a", "y", "b"}, []string{"a", "b", "z"})
+	want = []DiffHunk{{0, 1, 0, 0}, {2, 3, 1, 1}, {4, 4, 2, 3}}
+	if got := diffHunks(akinds, bkinds); !reflect.DeepEqual(got, want) {
+		t.Errorf("got hunks %v, want %v", got, want)
+	}
+}
+
+func TestDiffEditor(t *testing.T) {
+	gtx := layout.Context{
+		Ops:         new(op.Ops),
+		Constraints: layout.Exact(image.Pt(200, 30)),
+	}
+	cache := text.NewCache(gofont.Collection())
+	d := new(DiffEditor)
+	d.Old.SetText("a\nb\nc\nd\ne\nf")
+	d.New.SetText("a\nx\ny\nc\nd\ne\nf")
+	side := func(gtx layout.Context, e *Editor) layout.Dimensions {
+		return e.Layout(gtx, cache, text.Font{}, unit.Px(10))
+	}
+	d.Layout(gtx, side)
+	d.Layout(gtx, side)
+	baseline := func(e *Editor, line int) int {
+		it := e.ShapedLines(0)
+		for {
+			l, ok := it.Next()
+			if !ok {
+				t.Fatalf("line %d not found", line)
+			}
+			if l.Index == line {
+				return l.Baseline
+			}
+		}
+	}
+	if got, want := baseline(&d.Old, 2), baseline(&d.New, 3); got != want {
+		t.Errorf("got baseline %d of the unchanged line, want %d", got, want)
+	}
+	if got, want := d.Old.ContentSize().Y, d.New.ContentSize().Y; got != want {
+		t.Errorf("got old content height %d, want %d", got, want)
+	}
+	if d.Old.LineGap(2) == 0 {
+		t.Error("got no gap above the line following the removed line")
+	}
+	if got, want := d.LineKind(&d.New, 2), DiffAdded; got != want {
+		t.Errorf("got kind %v, want %v", got, want)
+	}
+	d.PaintLines(gtx, &d.New, DiffAdded)
+	d.PaintGaps(gtx, &d.Old)
+
+	d.Old.SetScrollOff(image.Pt(0, 10))
+	d.Layout(gtx, side)
+	if got, want := d.New.ScrollOff().Y, d.Old.ScrollOff().Y; got != want {
+		t.Errorf("got new scroll offset %d, want %d", got, want)
+	}
+}
+
+func TestEditorCompletion(t *testing.T) {
+	e := new(Editor)
+	gtx := layout.Context{
+		Ops:         new(op.Ops),
+		Constraints: layout.Exact(image.Pt(100, 100)),
+	}
+	cache := text.NewCache(gofont.Collection())
+	e.SetText("x := foo.ba")
+	e.Layout(gtx, cache, text.Font{}, unit.Px(10))
+	e.SetCaret(e.Len())
+	start, prefix := e.CompletionPrefix()
+	if start != 9 || prefix != "ba" {
+		t.Errorf("got prefix %q at %d, want %q at 9", prefix, start, "ba")
+	}
+	r := e.CaretRect()
+	if r.Dy() == 0 || r.Min.X != int(e.CaretCoords().X+.5) {
+		t.Errorf("got caret rect %v, caret coordinates %v", r, e.CaretCoords())
+	}
+	e.Complete(start, "bar()")
+	if got, want := e.Text(), "x := foo.bar()"; got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+	if got, want := e.Caret(), e.Len(); got != want {
+		t.Errorf("got caret %d, want %d", got, want)
+	}
+	e.Undo()
+	if got, want := e.Text(), "x := foo.ba"; got != want {
+		t.Errorf("got %q after undo, want %q", got, want)
+	}
+	e.SetCaret(9)
+	if _, prefix := e.CompletionPrefix(); prefix != "" {
+		t.Errorf("got prefix %q after punctuation, want none", prefix)
+	}
+}
+
+func TestEditorSnippet(t *testing.T) {
+	txt, stops := parseSnippet(`a\$b ${2:x${3}y} $1 \} $0c`)
+	if want := "a$b xy  } c"; txt != want {
+		t.Errorf("got snippet text %q, want %q", txt, want)
+	}
+	want := []snippetStop{{1, 7, 7}, {2, 4, 6}, {3, 5, 5}, {0, 10, 10}}
+	if !reflect.DeepEqual(stops, want) {
+		t.Errorf("got stops %v, want %v", stops, want)
+	}
+
+	r := new(router.Router)
+	gtx := layout.Context{
+		Ops:         new(op.Ops),
+		Constraints: layout.Exact(image.Pt(100, 100)),
+		Queue:       r,
+	}
+	cache := text.NewCache(gofont.Collection())
+	e := &Editor{SingleLine: true}
+	frame := func() {
+		gtx.Ops.Reset()
+		e.Layout(gtx, cache, text.Font{}, unit.Px(10))
+		r.Frame(gtx.Ops)
+	}
+	e.Focus()
+	frame()
+	frame()
+	e.InsertSnippet("f(${1:a}, ${2:b})$0;")
+	if got := e.SelectedText(); got != "a" {
+		t.Errorf("got selection %q, want %q", got, "a")
+	}
+	r.Add(key.EditEvent{Text: "xyz"})
+	frame()
+	for _, k := range []struct {
+		mods key.Modifiers
+		sel  string
+	}{{0, "b"}, {key.ModShift, "xyz"}, {0, "b"}} {
+		r.Add(key.Event{Name: key.NameTab, Modifiers: k.mods})
+		frame()
+		if got := e.SelectedText(); got != k.sel {
+			t.Errorf("got selection %q after Tab with modifiers %v, want %q", got, k.mods, k.sel)
+		}
+	}
+	r.Add(key.Event{Name: key.NameTab})
+	frame()
+	if got, want := e.Caret(), len("f(xyz, b)"); got != want || e.snippet.active {
+		t.Errorf("got caret %d, active %v at the end of the snippet, want %d", got, e.snippet.active, want)
+	}
+	if got, want := e.Text(), "f(xyz, b);"; got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
+
+func TestEditorRulers(t *testing.T) {
+	e := &Editor{Rulers: []Ruler{{Column: 4, Color: color.NRGBA{A: 0xff}}}}
+	gtx := layout.Context{
+		Ops:         new(op.Ops),
+		Constraints: layout.Exact(image.Pt(100, 100)),
+	}
+	cache := text.NewCache(gofont.Collection())
+	e.SetText("abcd")
+	e.Layout(gtx, cache, text.Font{}, unit.Px(10))
+	sp := cache.LayoutString(text.Font{}, fixed.I(10), inf, "    ")
+	if got, want := e.columnX(4), sp[0].Width.Round(); got != want {
+		t.Errorf("got ruler position %d, want %d", got, want)
+	}
+	e.PaintText(gtx)
+}
+
+func TestEditorAutoClose(t *testing.T) {
+	r := new(router.Router)
+	gtx := layout.Context{
+		Ops:         new(op.Ops),
+		Constraints: layout.Exact(image.Pt(100, 100)),
+		Queue:       r,
+	}
+	cache := text.NewCache(gofont.Collection())
+	e := &Editor{AutoClose: true}
+	frame := func() {
+		gtx.Ops.Reset()
+		e.Layout(gtx, cache, text.Font{}, unit.Px(10))
+		r.Frame(gtx.Ops)
+	}
+	e.Focus()
+	frame()
+	tests := []struct {
+		start, end int
+		typed      string
+		text       string
+		// sel is the selection after typing.
+		sel [2]int
+	}{
+		{0, 0, "(", "()", [2]int{1, 1}},
+		{1, 1, "x", "(x)", [2]int{2, 2}},
+		{2, 2, ")", "(x)", [2]int{3, 3}},
+		{1, 2, "[", "([x])", [2]int{2, 3}},
+		// Pairs are not closed before words, and quotes not
+		// after them.
+		{0, 0, "{", "{([x])", [2]int{1, 1}},
+		{6, 6, "b", "{([x])b", [2]int{7, 7}},
+		{7, 7, "'", "{([x])b'", [2]int{8, 8}},
+		{8, 8, " ", "{([x])b' ", [2]int{9, 9}},
+		{9, 9, `"`, `{([x])b' ""`, [2]int{10, 10}},
+		{10, 10, `"`, `{([x])b' ""`, [2]int{11, 11}},
+	}
+	for _, tc := range tests {
+		e.SetSelection(tc.start, tc.end)
+		r.Add(key.EditEvent{Text: tc.typed})
+		frame()
+		if got := e.Text(); got != tc.text {
+			t.Errorf("typing %q: got %q, want %q", tc.typed, got, tc.text)
+		}
+		if start, end := e.Selection(); start != tc.sel[0] || end != tc.sel[1] {
+			t.Errorf("typing %q: got selection %d-%d, want %v", tc.typed, start, end, tc.sel)
+		}
+	}
+}
+
+func TestEditorToggleComment(t *testing.T) {
+	gtx := layout.Context{
+		Ops:         new(op.Ops),
+		Constraints: layout.Exact(image.Pt(100, 100)),
+	}
+	e := &Editor{CommentToken: "//"}
+	const txt = "a\n  b\n\n  c\nd"
+	e.SetText(txt)
+	// Select from the middle of the first line to the start of
+	// the last line.
+	e.SetSelection(1, 11)
+	e.command(gtx, key.Event{Name: "/", Modifiers: key.ModShortcut})
+	if got, want := e.Text(), "// a\n//   b\n\n//   c\nd"; got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+	if start, end := e.Selection(); start != 4 || end != 20 {
+		t.Errorf("got selection %d-%d, want 4-20", start, end)
+	}
+	e.ToggleComment()
+	if got := e.Text(); got != txt {
+		t.Errorf("got %q after uncommenting, want %q", got, txt)
+	}
+	e.Undo()
+	if got, want := e.Text(), "// a\n//   b\n\n//   c\nd"; got != want {
+		t.Errorf("got %q after undo, want %q", got, want)
+	}
+	e.SetText("  x\n    y")
+	e.SetCaret(3)
+	e.ToggleComment()
+	if got, want := e.Text(), "  // x\n    y"; got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+	if got, want := e.Caret(), 6; got != want {
+		t.Errorf("got caret %d, want %d", got, want)
+	}
+}
+
+func TestEditorLineCommands(t *testing.T) {
+	gtx := layout.Context{
+		Ops:         new(op.Ops),
+		Constraints: layout.Exact(image.Pt(100, 100)),
+	}
+	e := &Editor{LineCommands: true}
+	e.SetText("a\nbb\ncc\nd")
+	// Select "b\nc" across the middle lines.
+	e.SetSelection(3, 6)
+	e.command(gtx, key.Event{Name: key.NameUpArrow, Modifiers: key.ModAlt})
+	if got, want := e.Text(), "bb\ncc\na\nd"; got != want {
+		t.Errorf("got %q after moving up, want %q", got, want)
+	}
+	if got := e.SelectedText(); got != "b\nc" {
+		t.Errorf("got selection %q after moving up, want %q", got, "b\nc")
+	}
+	// Moving past the first line does nothing.
+	e.MoveLines(-1)
+	if got, want := e.Text(), "bb\ncc\na\nd"; got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+	e.command(gtx, key.Event{Name: key.NameDownArrow, Modifiers: key.ModAlt})
+	e.command(gtx, key.Event{Name: key.NameDownArrow, Modifiers: key.ModAlt})
+	if got, want := e.Text(), "a\nd\nbb\ncc"; got != want {
+		t.Errorf("got %q after moving down, want %q", got, want)
+	}
+	e.Undo()
+	if got, want := e.Text(), "a\nbb\ncc\nd"; got != want {
+		t.Errorf("got %q after undo, want %q", got, want)
+	}
+	e.SetCaret(1)
+	e.command(gtx, key.Event{Name: "D", Modifiers: key.ModShortcut | key.ModShift})
+	if got, want := e.Text(), "a\na\nbb\ncc\nd"; got != want {
+		t.Errorf("got %q after duplicating, want %q", got, want)
+	}
+	if got, want := e.Caret(), 3; got != want {
+		t.Errorf("got caret %d after duplicating, want %d", got, want)
+	}
+}
+
+func TestEditorIndent(t *testing.T) {
+	gtx := layout.Context{
+		Ops:         new(op.Ops),
+		Constraints: layout.Exact(image.Pt(100, 100)),
+	}
+	e := &Editor{SoftTabs: true, TabWidth: 2}
+	e.SetText("a\n\nb\nc")
+	// Select from the middle of "a" to the start of "c", which is
+	// not indented.
+	e.SetSelection(1, 5)
+	e.command(gtx, key.Event{Name: key.NameTab})
+	if got, want := e.Text(), "  a\n\n  b\nc"; got != want {
+		t.Errorf("got %q after indenting, want %q", got, want)
+	}
+	if got, want := e.SelectedText(), "\n\n  b\n"; got != want {
+		t.Errorf("got selection %q after indenting, want %q", got, want)
+	}
+	e.command(gtx, key.Event{Name: key.NameTab, Modifiers: key.ModShift})
+	e.command(gtx, key.Event{Name: key.NameTab, Modifiers: key.ModShift})
+	if got, want := e.Text(), "a\n\nb\nc"; got != want {
+		t.Errorf("got %q after outdenting, want %q", got, want)
+	}
+	e.Undo()
+	if got, want := e.Text(), "  a\n\n  b\nc"; got != want {
+		t.Errorf("got %q after undo, want %q", got, want)
+	}
+	e.SoftTabs = false
+	e.SetText("\t a\n b")
+	e.SetSelection(0, e.Len())
+	e.Outdent()
+	if got, want := e.Text(), " a\nb"; got != want {
+		t.Errorf("got %q after outdenting tabs, want %q", got, want)
+	}
+	// A selection within a line is replaced.
+	e.SetSelection(0, 1)
+	e.command(gtx, key.Event{Name: key.NameTab})
+	if got, want := e.Text(), "\ta\nb"; got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
+
+func TestEditorTransform(t *testing.T) {
+	gtx := layout.Context{
+		Ops:         new(op.Ops),
+		Constraints: layout.Exact(image.Pt(100, 100)),
+	}
+	e := new(Editor)
+	e.SetText("hello wORLD, it's me")
+	e.SetSelection(12, 0)
+	e.TitleCase()
+	if got, want := e.Text(), "Hello World, it's me"; got != want {
+		t.Errorf("got %q after title casing, want %q", got, want)
+	}
+	if start, end := e.Selection(); start != 12 || end != 0 {
+		t.Errorf("got selection (%d, %d), want (12, 0)", start, end)
+	}
+	e.UpperCase()
+	e.LowerCase()
+	e.TransformSelection(func(s string) string { return s + "!" })
+	if got, want := e.Text(), "hello world,! it's me"; got != want {
+		t.Errorf("got %q after transforms, want %q", got, want)
+	}
+	if got, want := e.SelectedText(), "hello world,!"; got != want {
+		t.Errorf("got selection %q, want %q", got, want)
+	}
+	e.Undo()
+	if got, want := e.Text(), "hello world, it's me"; got != want {
+		t.Errorf("got %q after undo, want %q", got, want)
+	}
+
+	e.Keymap = EmacsKeymap
+	e.SetCaret(12)
+	e.command(gtx, key.Event{Name: "C", Modifiers: key.ModAlt})
+	e.command(gtx, key.Event{Name: "U", Modifiers: key.ModAlt})
+	if got, want := e.Text(), "hello world, It's ME"; got != want {
+		t.Errorf("got %q after word commands, want %q", got, want)
+	}
+	if got, want := e.Caret(), e.Len(); got != want {
+		t.Errorf("got caret %d, want %d", got, want)
+	}
+}
+
+func TestEditorReadOnlyRanges(t *testing.T) {
+	gtx := layout.Context{
+		Ops:         new(op.Ops),
+		Constraints: layout.Exact(image.Pt(100, 100)),
+	}
+	e := new(Editor)
+	e.SetText("> ls\n> ")
+	e.SetReadOnlyRanges([]ReadOnlyRange{{Start: 0, End: 2}, {Start: 5, End: 7}})
+	// Typing at the start of a prompt, inside it and across it is
+	// ignored.
+	for _, sel := range [][2]int{{0, 0}, {1, 1}, {3, 6}} {
+		e.SetSelection(sel[0], sel[1])
+		e.Insert("x")
+	}
+	e.SetCaret(6)
+	e.command(gtx, key.Event{Name: key.NameDeleteBackward})
+	if got, want := e.Text(), "> ls\n> "; got != want {
+		t.Fatalf("got %q after editing prompts, want %q", got, want)
+	}
+	e.SetCaret(2)
+	e.Insert("cd ")
+	e.SetCaret(e.Len())
+	e.Insert("pwd")
+	if got, want := e.Text(), "> cd ls\n> pwd"; got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+	want := []ReadOnlyRange{{Start: 0, End: 2}, {Start: 8, End: 10}}
+	if got := e.ReadOnlyRanges(); !reflect.DeepEqual(got, want) {
+		t.Errorf("got ranges %v, want %v", got, want)
+	}
+	// Ranges move with deleted and restored text before them.
+	e.SetCaret(7)
+	e.Delete(-5)
+	e.Undo()
+	if got, want := e.Text(), "> cd ls\n> pwd"; got != want {
+		t.Errorf("got %q after undo, want %q", got, want)
+	}
+	if got := e.ReadOnlyRanges(); !reflect.DeepEqual(got, want) {
+		t.Errorf("got ranges %v after undo, want %v", got, want)
+	}
+}
+
+func TestEditorKeepSelection(t *testing.T) {
+	tq := new(testQueue)
+	gtx := layout.Context{
+		Ops:         new(op.Ops),
+		Constraints: layout.Exact(image.Pt(100, 100)),
+		Queue:       tq,
+	}
+	cache := text.NewCache(gofont.Collection())
+	for _, keep := range []bool{false, true} {
+		e := &Editor{KeepSelection: keep}
+		e.SetText("hello")
+		tq.events = []event.Event{key.FocusEvent{Focus: true}}
+		e.Layout(gtx, cache, text.Font{}, unit.Px(10))
+		e.SetSelection(1, 4)
+		tq.events = []event.Event{key.FocusEvent{Focus: false}}
+		e.Layout(gtx, cache, text.Font{}, unit.Px(10))
+		want := ""
+		if keep {
+			want = "ell"
+		}
+		if got := e.SelectedText(); got != want {
+			t.Errorf("KeepSelection %v: got selection %q after blur, want %q", keep, got, want)
+		}
+	}
+	h := Highlight{Color: color.NRGBA{R: 0xff, A: 0x80}}
+	if got, want := h.inactiveColor(), (color.NRGBA{R: 0xff, A: 0x40}); got != want {
+		t.Errorf("got inactive color %v, want %v", got, want)
+	}
+}
+
+func TestEditorBlur(t *testing.T) {
+	r := new(router.Router)
+	gtx := layout.Context{
+		Ops:         new(op.Ops),
+		Constraints: layout.Exact(image.Pt(100, 100)),
+		Queue:       r,
+	}
+	cache := text.NewCache(gofont.Collection())
+	fields := []*Editor{new(Editor), new(Editor)}
+	frame := func() {
+		gtx.Ops.Reset()
+		for _, e := range fields {
+			stack := op.Push(gtx.Ops)
+			e.Layout(gtx, cache, text.Font{}, unit.Px(10))
+			stack.Pop()
+		}
+		r.Frame(gtx.Ops)
+	}
+	fields[0].Focus()
+	frame()
+	frame()
+	// Blurring an unfocused editor doesn't release the focus of
+	// another.
+	fields[1].Blur()
+	frame()
+	frame()
+	if !fields[0].Focused() {
+		t.Fatal("focus released by an unfocused editor")
+	}
+	fields[0].Blur()
+	frame()
+	if got := r.TextInputState(); got != router.TextInputClose {
+		t.Errorf("got text input state %v, want TextInputClose", got)
+	}
+	frame()
+	if fields[0].Focused() || fields[1].Focused() {
+		t.Error("focus not released by Blur")
+	}
+	// Blur cancels a focus request in the same frame.
+	fields[1].Focus()
+	fields[1].Blur()
+	frame()
+	frame()
+	if fields[1].Focused() {
+		t.Error("focus request not canceled by Blur")
+	}
+}
@@ -0,0 +1,114 @@
+// SPDX-License-Identifier: Unlicense OR MIT
+
+package widget
+
+import (
+	"image"
+	"image/color"
+	"math"
+
+	"gioui.org/f32"
+	"gioui.org/layout"
+	"gioui.org/op"
+	"gioui.org/op/clip"
+	"gioui.org/op/paint"
+	"gioui.org/unit"
+)
+
+// TextShadow is a shadow painted behind text, for keeping text
+// readable over images.
+type TextShadow struct {
+	// Color is the color of the shadow. A transparent color
+	// disables the shadow.
+	Color color.NRGBA
+	// X and Y offset the shadow from the text.
+	X, Y unit.Value
+	// Blur, if non-zero, approximates a blurred shadow by painting
+	// translucent copies of it spread over the distance.
+	Blur unit.Value
+}
+
+// TextOutline is a stroke around the glyphs of text.
+type TextOutline struct {
+	// Color is the color of the outline. A transparent color
+	// disables the outline.
+	Color color.NRGBA
+	// Width is the width of the outline.
+	Width unit.Value
+}
+
+// textPass paints copies of text shapes, such as the copies of a
+// shadow, in a color. The zero color paints the text in its own
+// colors.
+type textPass struct {
+	color   color.NRGBA
+	offsets []f32.Point
+}
+
+// textPasses returns the passes for painting text with its shadow
+// and outline, ending with the pass for the text itself.
+func textPasses(gtx layout.Context, shadow TextShadow, outline TextOutline) []textPass {
+	var passes []textPass
+	if shadow.Color.A > 0 {
+		off := f32.Point{X: float32(gtx.Px(shadow.X)), Y: float32(gtx.Px(shadow.Y))}
+		p := textPass{color: shadow.Color, offsets: []f32.Point{off}}
+		if b := float32(gtx.Px(shadow.Blur)); b > 0 {
+			p.offsets = append(p.offsets, ring(off, b/2, 8)...)
+			p.offsets = append(p.offsets, ring(off, b, 8)...)
+			// Choose the alpha of the copies such that all of them
+			// painted over each other add up to the shadow color.
+			a := 1 - math.Pow(1-float64(shadow.Color.A)/255, 1/float64(len(p.offsets)))
+			p.color.A = uint8(math.Ceil(a * 255))
+		}
+		passes = append(passes, p)
+	}
+	if w := float32(gtx.Px(outline.Width)); outline.Color.A > 0 && w > 0 {
+		p := textPass{color: outline.Color, offsets: ring(f32.Point{}, w, 16)}
+		if w > 2 {
+			p.offsets = append(p.offsets, ring(f32.Point{}, w/2, 8)...)
+		}
+		passes = append(passes, p)
+	}
+	return append(passes, textPass{offsets: []f32.Point{{}}})
+}
+
+// ring returns n points evenly spaced on the circle with the center
+// and radius.
+func ring(center f32.Point, radius float32, n int) []f32.Point {
+	pts := make([]f32.Point, n)
+	for i := range pts {
+		a := 2 * math.Pi * float64(i) / float64(n)
+		pts[i] = center.Add(f32.Point{
+			X: radius * float32(math.Cos(a)),
+			Y: radius * float32(math.Sin(a)),
+		})
+	}
+	return pts
+}
+
+// own reports whether the pass paints text in its own colors.
+func (p textPass) own() bool {
+	return p.color == (color.NRGBA{})
+}
+
+// paint fills the shape, a clip operation, at the offsets of the
+// pass.
+func (p textPass) paint(gtx layout.Context, shape op.CallOp) {
+	for _, off := range p.offsets {
+		stack := op.Push(gtx.Ops)
+		op.Offset(off).Add(gtx.Ops)
+		if !p.own() {
+			paint.ColorOp{Color: p.color}.Add(gtx.Ops)
+		}
+		shape.Add(gtx.Ops)
+		paint.PaintOp{}.Add(gtx.Ops)
+		stack.Pop()
+	}
+}
+
+// rectShape returns a clip operation for the rectangle r.
+func rectShape(gtx layout.Context, r image.Rectangle) op.CallOp {
+	m := op.Record(gtx.Ops)
+	clip.Rect(r).Add(gtx.Ops)
+	return m.Stop()
+}
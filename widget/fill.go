@@ -0,0 +1,124 @@
+// SPDX-License-Identifier: Unlicense OR MIT
+
+package widget
+
+import (
+	"math"
+	"unicode"
+
+	"gioui.org/text"
+
+	"golang.org/x/image/math/fixed"
+)
+
+// wrapLines breaks lines shaped without a width limit at maxWidth
+// according to the policy. WrapWords lines are broken by the shaper
+// and are returned unchanged.
+func wrapLines(lines []text.Line, policy WrapPolicy, maxWidth int) []text.Line {
+	switch policy {
+	case WrapRunes:
+		return breakRunes(lines, maxWidth)
+	case WrapParagraph:
+		return fillLines(lines, maxWidth)
+	default:
+		return lines
+	}
+}
+
+// fillLines breaks each of lines at spaces into lines no wider than
+// maxWidth, minimizing the sum of the squared free space at the end
+// of every line but the last, in the manner of the Knuth-Plass
+// algorithm without hyphenation. Words wider than maxWidth are
+// broken between runes.
+func fillLines(lines []text.Line, maxWidth int) []text.Line {
+	var res []text.Line
+	wide := false
+	for _, l := range lines {
+		if l.Width <= fixed.I(maxWidth) {
+			res = append(res, l)
+			continue
+		}
+		for _, fl := range fillLine(l, maxWidth) {
+			res = append(res, fl)
+			wide = wide || fl.Width > fixed.I(maxWidth)
+		}
+	}
+	if wide {
+		res = breakRunes(res, maxWidth)
+	}
+	return res
+}
+
+// fillBreak is a possible line break of fillLine, before the rune
+// with index rune at the byte offset idx.
+type fillBreak struct {
+	rune, idx int
+	// x is the distance from the start of the line to the break.
+	x fixed.Int26_6
+}
+
+// fillLine breaks the line l into lines of at most maxWidth.
+func fillLine(l text.Line, maxWidth int) []text.Line {
+	// Collect the breaks after runs of spaces, with the start and
+	// the end of the line as the first and last break.
+	breaks := []fillBreak{{}}
+	var x fixed.Int26_6
+	n := 0
+	prevSpace := false
+	for i, r := range l.Layout.Text {
+		space := unicode.IsSpace(r)
+		if prevSpace && !space {
+			breaks = append(breaks, fillBreak{rune: n, idx: i, x: x})
+		}
+		x += l.Layout.Advances[n]
+		prevSpace = space
+		n++
+	}
+	breaks = append(breaks, fillBreak{rune: n, idx: len(l.Layout.Text), x: x})
+
+	// cost[j] is the least cost of breaking the text before break
+	// j, and prev[j] the break starting its last line.
+	maxX := fixed.I(maxWidth)
+	last := len(breaks) - 1
+	cost := make([]float64, len(breaks))
+	prev := make([]int, len(breaks))
+	for j := 1; j <= last; j++ {
+		cost[j] = math.Inf(1)
+		for i := j - 1; i >= 0; i-- {
+			// Like the lines of the shaper, the width includes
+			// the spaces before the break.
+			w := breaks[j].x - breaks[i].x
+			if w > maxX && i < j-1 {
+				// Lines starting at earlier breaks are wider still.
+				break
+			}
+			var c float64
+			if j != last && w <= maxX {
+				free := float64(maxX-w) / 64
+				c = free * free
+			}
+			if c += cost[i]; c < cost[j] {
+				cost[j], prev[j] = c, i
+			}
+		}
+	}
+	var ends []int
+	for j := last; j > 0; j = prev[j] {
+		ends = append(ends, j)
+	}
+	res := make([]text.Line, 0, len(ends))
+	start := breaks[0]
+	for k := len(ends) - 1; k >= 0; k-- {
+		end := breaks[ends[k]]
+		fl := l
+		fl.Layout = text.Layout{
+			Text:     l.Layout.Text[start.idx:end.idx],
+			Advances: l.Layout.Advances[start.rune:end.rune:end.rune],
+		}
+		fl.Width = end.x - start.x
+		fl.Bounds.Max.X += fl.Width - l.Width
+		res = append(res, fl)
+		start = end
+	}
+	return res
+}
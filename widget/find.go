@@ -0,0 +1,251 @@
+// SPDX-License-Identifier: Unlicense OR MIT
+
+package widget
+
+import (
+	"regexp"
+	"strings"
+	"unicode/utf8"
+
+	"gioui.org/layout"
+	"gioui.org/op"
+	"gioui.org/op/clip"
+	"gioui.org/op/paint"
+)
+
+// A Range is the range of text between the rune offsets Start and
+// End.
+type Range struct {
+	Start, End int
+}
+
+// finder tracks the matches of the most recent Find.
+type finder struct {
+	query string
+	re    *regexp.Regexp
+	// matches are the byte ranges of the matches.
+	matches [][2]int
+	// groups are the byte offsets of the submatches of re, in the
+	// format of regexp.Regexp.FindAllStringSubmatchIndex.
+	groups [][]int
+	// stale is set when the text has changed since matches
+	// was computed.
+	stale bool
+}
+
+// Find returns the ranges of all non-overlapping occurrences of
+// query, and highlights them until the next call to Find or
+// FindRegexp. The highlighted matches are kept up to date when the
+// text changes. An empty query clears the highlights.
+func (e *Editor) Find(query string) []Range {
+	e.find = finder{query: query, stale: true}
+	return e.runeRanges(e.findMatches())
+}
+
+// FindRegexp is like Find, but for matches of a regular expression.
+// Each element of the result contains the range of the match
+// followed by the ranges of its submatches. Unmatched submatches
+// have the range {-1, -1}. Empty matches are ignored.
+func (e *Editor) FindRegexp(re *regexp.Regexp) [][]Range {
+	e.find = finder{re: re, stale: true}
+	ms := e.findMatches()
+	if len(ms) == 0 {
+		return nil
+	}
+	txt := e.rr.String()
+	res := make([][]Range, len(ms))
+	for i, r := range e.runeRanges(ms) {
+		g := e.find.groups[i]
+		rs := make([]Range, len(g)/2)
+		rs[0] = r
+		for j := 1; j < len(rs); j++ {
+			start, end := g[2*j], g[2*j+1]
+			if start == -1 {
+				rs[j] = Range{Start: -1, End: -1}
+				continue
+			}
+			rs[j] = Range{
+				Start: r.Start + utf8.RuneCountInString(txt[g[0]:start]),
+				End:   r.Start + utf8.RuneCountInString(txt[g[0]:end]),
+			}
+		}
+		res[i] = rs
+	}
+	return res
+}
+
+// Replace replaces the selection with repl if it is a match of the
+// last Find or FindRegexp, and selects the next match. For regular
+// expressions, repl may refer to submatches as described in
+// regexp.Regexp.Expand. Replace reports whether the selection was
+// replaced.
+func (e *Editor) Replace(repl string) bool {
+	start, end := e.selectionBytes()
+	for i, m := range e.findMatches() {
+		if m[0] == start && m[1] == end {
+			e.replace(start, end, e.expand(e.rr.String(), repl, i))
+			e.FindNext()
+			return true
+		}
+	}
+	return false
+}
+
+// ReplaceAll replaces all matches of the last Find or FindRegexp
+// with repl, expanded as in Replace. It returns the number of
+// replacements.
+func (e *Editor) ReplaceAll(repl string) int {
+	ms := e.findMatches()
+	if len(ms) == 0 {
+		return 0
+	}
+	txt := e.rr.String()
+	repls := make([]string, len(ms))
+	for i := range ms {
+		repls[i] = e.expand(txt, repl, i)
+	}
+	e.BeginGroup()
+	// Replace from the end to keep the offsets of the remaining
+	// matches valid.
+	for i := len(ms) - 1; i >= 0; i-- {
+		e.replace(ms[i][0], ms[i][1], repls[i])
+	}
+	e.EndGroup()
+	e.caret.scroll = true
+	return len(repls)
+}
+
+// expand returns the replacement for the match with index i in txt.
+func (e *Editor) expand(txt, repl string, i int) string {
+	if e.find.re == nil {
+		return repl
+	}
+	return string(e.find.re.ExpandString(nil, repl, txt, e.find.groups[i]))
+}
+
+// FindNext selects the first match at or after the end of the
+// selection, wrapping around to the first match in the text, and
+// scrolls it into view. It reports whether there were any matches.
+func (e *Editor) FindNext() bool {
+	ms := e.findMatches()
+	if len(ms) == 0 {
+		return false
+	}
+	_, end := e.selectionBytes()
+	m := ms[0]
+	for _, mm := range ms {
+		if mm[0] >= end {
+			m = mm
+			break
+		}
+	}
+	e.selectMatch(m)
+	return true
+}
+
+// FindPrev is like FindNext, but selects the last match before the
+// start of the selection.
+func (e *Editor) FindPrev() bool {
+	ms := e.findMatches()
+	if len(ms) == 0 {
+		return false
+	}
+	start, _ := e.selectionBytes()
+	m := ms[len(ms)-1]
+	for i := len(ms) - 1; i >= 0; i-- {
+		if ms[i][1] <= start {
+			m = ms[i]
+			break
+		}
+	}
+	e.selectMatch(m)
+	return true
+}
+
+func (e *Editor) selectMatch(m [2]int) {
+	e.setSelection(m[0], m[1])
+	e.jump()
+	e.caret.scroll = true
+}
+
+// findMatches returns the byte ranges of the matches of the current
+// query, searching the text again if it has changed.
+func (e *Editor) findMatches() [][2]int {
+	f := &e.find
+	if !f.stale {
+		return f.matches
+	}
+	f.stale = false
+	f.matches = f.matches[:0]
+	f.groups = f.groups[:0]
+	if f.re != nil {
+		for _, g := range f.re.FindAllStringSubmatchIndex(e.rr.String(), -1) {
+			if g[0] == g[1] {
+				continue
+			}
+			f.matches = append(f.matches, [2]int{g[0], g[1]})
+			f.groups = append(f.groups, g)
+		}
+		return f.matches
+	}
+	if f.query == "" {
+		return nil
+	}
+	txt := e.rr.String()
+	for off := 0; ; {
+		i := strings.Index(txt[off:], f.query)
+		if i == -1 {
+			break
+		}
+		start := off + i
+		off = start + len(f.query)
+		f.matches = append(f.matches, [2]int{start, off})
+	}
+	return f.matches
+}
+
+// runeRanges converts ordered byte ranges to rune ranges.
+func (e *Editor) runeRanges(ms [][2]int) []Range {
+	if len(ms) == 0 {
+		return nil
+	}
+	rs := make([]Range, len(ms))
+	idx, n := 0, 0
+	runes := func(off int) int {
+		for idx < off {
+			_, s := e.rr.runeAt(idx)
+			idx += s
+			n++
+		}
+		return n
+	}
+	for i, m := range ms {
+		rs[i] = Range{Start: runes(m[0]), End: runes(m[1])}
+	}
+	return rs
+}
+
+// PaintMatches paints the highlights of the matches of the last Find
+// in the current color.
+func (e *Editor) PaintMatches(gtx layout.Context) {
+	ms := e.findMatches()
+	if len(ms) == 0 {
+		return
+	}
+	defer op.Push(gtx.Ops).Pop()
+	op.Offset(e.textOffset()).Add(gtx.Ops)
+	cl := textPadding(e.lines.ends())
+	cl.Max = cl.Max.Add(e.viewSize)
+	for _, m := range ms {
+		for _, r := range e.regions(m[0], m[1]) {
+			r = r.Sub(e.scrollOff).Intersect(cl)
+			if r.Empty() {
+				continue
+			}
+			stack := op.Push(gtx.Ops)
+			clip.Rect(r).Add(gtx.Ops)
+			paint.PaintOp{}.Add(gtx.Ops)
+			stack.Pop()
+		}
+	}
+}
@@ -0,0 +1,107 @@
+// SPDX-License-Identifier: Unlicense OR MIT
+
+package widget
+
+import (
+	"strings"
+
+	"golang.org/x/image/math/fixed"
+)
+
+// SetLineGaps sets the heights in pixels of empty space above logical
+// lines, keyed by the zero-based index of the line. A gap keyed by
+// the number of logical lines is placed below the last line. Gaps
+// align the lines of editors displayed side by side, such as by
+// DiffEditor. Like the space added by LineHeight, a gap is part of
+// the line below it, or of the last line for the gap below it. Gaps
+// stay with their line indices when the text is edited.
+func (e *Editor) SetLineGaps(gaps map[int]int) {
+	same := len(gaps) == len(e.gaps)
+	for l, g := range gaps {
+		if g2, ok := e.gaps[l]; !ok || g2 != g {
+			same = false
+		}
+	}
+	if same {
+		return
+	}
+	e.gaps = make(map[int]int, len(gaps))
+	for l, g := range gaps {
+		if g > 0 {
+			e.gaps[l] = g
+		}
+	}
+	e.invalidate()
+}
+
+// LineGap returns the height of the gap above the logical line with
+// index line.
+func (e *Editor) LineGap(line int) int {
+	return e.gaps[line]
+}
+
+// applyGaps adds the line gaps to the metrics of lines, the lines of
+// the paragraphs starting at the paragraph with index para. Last
+// reports whether the lines end the text.
+func (e *Editor) applyGaps(lines []lineEntry, para int, last bool) []lineEntry {
+	if len(e.gaps) == 0 || len(lines) == 0 {
+		return lines
+	}
+	n := para
+	for i := range lines {
+		if i > 0 && strings.HasSuffix(lines[i-1].Layout.Text, "\n") {
+			n++
+		}
+		if i == 0 || strings.HasSuffix(lines[i-1].Layout.Text, "\n") {
+			lines[i].Ascent += fixed.I(e.gaps[n])
+		}
+	}
+	if last {
+		lines[len(lines)-1].Descent += fixed.I(e.gaps[n+1])
+	}
+	return lines
+}
+
+// logicalHeights returns the heights of the logical lines, excluding
+// their gaps. The height of a line is the distance from the baseline
+// of the previous line to its last baseline, and the last line
+// includes its descent.
+func (e *Editor) logicalHeights() []int {
+	e.makeValid()
+	var (
+		heights  []int
+		prevDesc fixed.Int26_6
+	)
+	it := e.lines.atLine(0)
+	for {
+		l, m, ok := it.next()
+		if !ok {
+			break
+		}
+		if r := l.run; r != nil {
+			// Give the paragraphs of the run but the first a line
+			// each, and the first the rest of the height.
+			n := len(heights)
+			for i := 0; i < r.paras; i++ {
+				heights = append(heights, -e.gaps[n+i])
+			}
+			heights[n] += (prevDesc + l.Ascent).Ceil()
+			for i := 1; i < r.paras; i++ {
+				d := r.pitch + e.gaps[n+i]
+				heights[n+i] += d
+				heights[n] -= d
+			}
+			prevDesc = l.Descent
+			continue
+		}
+		if !m.cont {
+			heights = append(heights, -e.gaps[len(heights)])
+		}
+		heights[len(heights)-1] += (prevDesc + l.Ascent).Ceil()
+		prevDesc = l.Descent
+	}
+	if n := len(heights); n > 0 {
+		heights[n-1] += prevDesc.Ceil() - e.gaps[n]
+	}
+	return heights
+}
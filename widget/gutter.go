@@ -0,0 +1,208 @@
+// SPDX-License-Identifier: Unlicense OR MIT
+
+package widget
+
+import (
+	"image"
+	"strconv"
+	"strings"
+
+	"gioui.org/f32"
+	"gioui.org/gesture"
+	"gioui.org/layout"
+	"gioui.org/op"
+	"gioui.org/op/clip"
+	"gioui.org/op/paint"
+	"gioui.org/text"
+	"gioui.org/unit"
+
+	"golang.org/x/image/math/fixed"
+)
+
+// A GutterClickEvent is generated when the gutter of an Editor is
+// clicked.
+type GutterClickEvent struct {
+	// Line is the zero-based index of the clicked logical line,
+	// that is, the number of line terminators before it.
+	Line int
+	// Index is the index of the clicked line, as counted by
+	// NumLines.
+	Index int
+	// Column is the index in Editor.Gutter of the clicked column,
+	// or -1 for the line numbers.
+	Column int
+}
+
+// A GutterColumn is a column of the gutter of an Editor, such as a
+// column of breakpoint markers or change bars.
+type GutterColumn struct {
+	// Width is the width of the column.
+	Width unit.Value
+	// Layout, if set, draws the column beside a line. It is called
+	// by PaintGutter for every visible line, with the origin at the
+	// top of the line and exact constraints of the column width and
+	// the line height.
+	Layout func(gtx layout.Context, line ShapedLine)
+}
+
+// gutter is the state of the gutter.
+type gutter struct {
+	// width is the width of the gutter, and pad the space on
+	// each side of the line numbers.
+	width, pad int
+	// columns are the widths of the columns of Editor.Gutter.
+	columns []int
+	clicker gesture.Click
+}
+
+// layoutGutter computes the gutter width for the current contents.
+func (e *Editor) layoutGutter(gtx layout.Context, sh text.Shaper, font text.Font, size fixed.Int26_6) {
+	e.gutter.width, e.gutter.pad = 0, 0
+	e.gutter.columns = e.gutter.columns[:0]
+	for _, c := range e.Gutter {
+		w := gtx.Px(c.Width)
+		e.gutter.columns = append(e.gutter.columns, w)
+		e.gutter.width += w
+	}
+	if !e.LineNumbers {
+		return
+	}
+	digits := len(strconv.Itoa(e.rr.newlines() + 1))
+	if digits < 2 {
+		digits = 2
+	}
+	var w int
+	if lines := sh.LayoutString(font, size, inf, strings.Repeat("0", digits)); len(lines) > 0 {
+		w = lines[0].Width.Ceil()
+	}
+	e.gutter.pad = size.Ceil() / 2
+	e.gutter.width += w + 2*e.gutter.pad
+}
+
+// processGutter converts gutter clicks to GutterClickEvents.
+func (e *Editor) processGutter(gtx layout.Context) {
+	for _, evt := range e.gutter.clicker.Events(gtx) {
+		if evt.Type != gesture.TypeClick {
+			continue
+		}
+		line := e.lineAt(int(evt.Position.Y) - e.valign + e.scrollOff.Y)
+		col := -1
+		x := int(evt.Position.X)
+		for i, w := range e.gutter.columns {
+			if x < w {
+				col = i
+				break
+			}
+			x -= w
+		}
+		e.events = append(e.events, GutterClickEvent{Line: e.logicalLine(line), Index: line, Column: col})
+	}
+}
+
+// PaintGutter draws the custom gutter columns of the visible lines.
+func (e *Editor) PaintGutter(gtx layout.Context) {
+	if len(e.Gutter) == 0 {
+		return
+	}
+	defer op.Push(gtx.Ops).Pop()
+	clip.Rect(image.Rectangle{Max: image.Point{X: e.gutter.width, Y: e.viewSize.Y}}).Add(gtx.Ops)
+	it := e.ShapedLines(e.scrollOff.Y)
+	for {
+		l, ok := it.Next()
+		if !ok || l.Baseline-l.Ascent.Ceil() > e.scrollOff.Y+e.viewSize.Y {
+			break
+		}
+		top := l.Baseline - l.Ascent.Ceil() - e.scrollOff.Y + e.valign
+		size := image.Point{Y: l.Ascent.Ceil() + l.Descent.Ceil()}
+		x := 0
+		for i, c := range e.Gutter {
+			size.X = e.gutter.columns[i]
+			if c.Layout != nil {
+				stack := op.Push(gtx.Ops)
+				op.Offset(layout.FPt(image.Point{X: x, Y: top})).Add(gtx.Ops)
+				cgtx := gtx
+				cgtx.Constraints = layout.Exact(size)
+				c.Layout(cgtx, l)
+				stack.Pop()
+			}
+			x += size.X
+		}
+	}
+}
+
+// textOffset returns the offset of the text area from the editor
+// origin.
+func (e *Editor) textOffset() f32.Point {
+	return f32.Point{X: float32(e.gutter.width), Y: float32(e.valign)}
+}
+
+// PaintLineNumbers paints the line numbers in the gutter, in the
+// current color. Soft-wrapped continuation lines are not numbered.
+func (e *Editor) PaintLineNumbers(gtx layout.Context) {
+	if !e.LineNumbers || e.gutter.width == 0 {
+		return
+	}
+	cl := image.Rectangle{Max: image.Point{X: e.gutter.width, Y: e.viewSize.Y}}
+	it := e.lines.atY(e.scrollOff.Y)
+	for {
+		l, m, ok := it.next()
+		if !ok {
+			break
+		}
+		y := m.baseline(l.Line)
+		if y+l.Descent.Ceil() < e.scrollOff.Y {
+			continue
+		}
+		if y-l.Ascent.Ceil() > e.scrollOff.Y+e.viewSize.Y {
+			break
+		}
+		if m.cont || l.run != nil {
+			continue
+		}
+		num := e.shaper.LayoutString(e.font, e.textSize, inf, strconv.Itoa(m.para+1))
+		if len(num) == 0 {
+			continue
+		}
+		off := image.Point{
+			X: e.gutter.width - e.gutter.pad - num[0].Width.Ceil(),
+			Y: y - e.scrollOff.Y + e.valign,
+		}
+		stack := op.Push(gtx.Ops)
+		op.Offset(layout.FPt(off)).Add(gtx.Ops)
+		clip.Rect(cl.Sub(off)).Add(gtx.Ops)
+		e.shaper.Shape(e.font, e.textSize, num[0].Layout).Add(gtx.Ops)
+		paint.PaintOp{}.Add(gtx.Ops)
+		stack.Pop()
+	}
+}
+
+// Continuation reports whether the line with index line, as counted
+// by NumLines, continues a soft-wrapped logical line. Custom gutters
+// use it to number only the first line of every logical line.
+func (e *Editor) Continuation(line int) bool {
+	e.makeValid()
+	if line < 0 || line >= e.lines.len() {
+		return false
+	}
+	return e.isContinuation(line)
+}
+
+// isContinuation reports whether the line with index i is the
+// continuation of a soft-wrapped logical line.
+func (e *Editor) isContinuation(i int) bool {
+	return e.lines.atLine(i).m.cont
+}
+
+// logicalLine returns the index of the logical line containing the
+// line with index i.
+func (e *Editor) logicalLine(i int) int {
+	it := e.lines.atLine(i)
+	l, m, _ := it.next()
+	if r := l.run; r != nil {
+		// Estimate the paragraph of the line in the run.
+		return m.para + (i-m.line)*r.paras/r.lines
+	}
+	return m.para
+}
+
+func (GutterClickEvent) isEditorEvent() {}
@@ -0,0 +1,134 @@
+// SPDX-License-Identifier: Unlicense OR MIT
+
+package widget
+
+import (
+	"image"
+	"math"
+
+	"gioui.org/f32"
+	"gioui.org/text"
+
+	"golang.org/x/image/math/fixed"
+)
+
+// LabelLayout records the positions of the runes of a Label for hit
+// testing, such as for looking up the word under a tap. Positions are
+// relative to the unrotated text, and the runes of vertical text are
+// not recorded.
+type LabelLayout struct {
+	lines []placedLine
+	runes []rune
+}
+
+// record records the positions of lines laid out with the alignment
+// and width.
+func (t *LabelLayout) record(lines []text.Line, alignment text.Alignment, width int) {
+	t.lines = placeLines(t.lines[:0], lines, alignment, width)
+	t.runes = t.runes[:0]
+	for _, l := range lines {
+		t.runes = append(t.runes, []rune(l.Layout.Text)...)
+	}
+}
+
+// Len returns the number of displayed runes.
+func (t *LabelLayout) Len() int {
+	return len(t.runes)
+}
+
+// RuneAt returns the offset of the rune at the position pos, if any.
+func (t *LabelLayout) RuneAt(pos f32.Point) (int, bool) {
+	px, py := int(math.Floor(float64(pos.X))), int(math.Floor(float64(pos.Y)))
+	for _, l := range t.lines {
+		if py < l.y-l.line.Ascent.Ceil() || py >= l.y+l.line.Descent.Ceil() {
+			continue
+		}
+		x := fixed.I(l.x)
+		for i, adv := range l.line.Layout.Advances {
+			if fixed.I(px) >= x && fixed.I(px) < x+adv {
+				return l.runes + i, true
+			}
+			x += adv
+		}
+		return 0, false
+	}
+	return 0, false
+}
+
+// CaretAt returns the offset of the boundary between runes closest to
+// the position pos.
+func (t *LabelLayout) CaretAt(pos f32.Point) int {
+	return caretAt(t.lines, pos)
+}
+
+// WordAt returns the rune offsets of the word at the position pos, if
+// any. Words are segmented like the words of an Editor.
+func (t *LabelLayout) WordAt(pos f32.Point) (start, end int, ok bool) {
+	r, ok := t.RuneAt(pos)
+	if !ok {
+		return 0, 0, false
+	}
+	switch runeWordClass(t.runes[r], "") {
+	case classSpace, classNewline, classPunct:
+		return 0, 0, false
+	}
+	start, end = r, r+1
+	for start > 0 && t.joined(start) {
+		start--
+	}
+	for end < len(t.runes) && t.joined(end) {
+		end++
+	}
+	return start, end, true
+}
+
+// joined reports whether the runes before and at offset i belong to
+// the same word.
+func (t *LabelLayout) joined(i int) bool {
+	a, b := t.runes[i-1], t.runes[i]
+	ca, cb := runeWordClass(a, ""), runeWordClass(b, "")
+	switch {
+	case ca == classIdeograph || cb == classIdeograph:
+		return false
+	case ca == cb:
+		return true
+	case i+1 < len(t.runes) && midWord(a, b, t.runes[i+1]):
+		// b joins the word, as the apostrophe of "can't".
+		return true
+	case i >= 2 && midWord(t.runes[i-2], a, b):
+		return true
+	}
+	return false
+}
+
+// RuneBounds returns the bounds of the rune at offset r, if it is
+// displayed.
+func (t *LabelLayout) RuneBounds(r int) (image.Rectangle, bool) {
+	rects := lineRegions(t.lines, r, r+1)
+	if len(rects) == 0 {
+		return image.Rectangle{}, false
+	}
+	return rects[0], true
+}
+
+// caretAt returns the rune offset of the boundary in lines closest to
+// the position pos.
+func caretAt(lines []placedLine, pos f32.Point) int {
+	if len(lines) == 0 {
+		return 0
+	}
+	px, py := int(math.Round(float64(pos.X))), int(math.Round(float64(pos.Y)))
+	i := 0
+	for i < len(lines)-1 && py > lines[i].y+lines[i].line.Descent.Ceil() {
+		i++
+	}
+	l := lines[i]
+	advs := l.line.Layout.Advances[:l.runeCount()]
+	x := fixed.I(l.x)
+	n := 0
+	for n < len(advs) && x+advs[n]/2 < fixed.I(px) {
+		x += advs[n]
+		n++
+	}
+	return l.runes + n
+}
@@ -0,0 +1,144 @@
+// SPDX-License-Identifier: Unlicense OR MIT
+
+package widget
+
+import (
+	"image"
+	"math"
+
+	"gioui.org/f32"
+	"gioui.org/io/pointer"
+	"gioui.org/layout"
+
+	"golang.org/x/image/math/fixed"
+)
+
+// A HoverEvent is generated when the pointer moves over a different
+// rune of the editor text.
+type HoverEvent struct {
+	// Offset is the rune offset of the rune under the pointer, or
+	// -1 if the pointer is not over text.
+	Offset int
+	// Position is the pointer position relative to the editor.
+	Position f32.Point
+}
+
+// A HoverRange is a range of text that generates HoverRangeEvents.
+type HoverRange struct {
+	// Start and End are the rune offsets of the range.
+	Start, End int
+	// Tag identifies the range in events.
+	Tag interface{}
+}
+
+// A HoverRangeEvent is generated when the pointer enters or leaves
+// a HoverRange.
+type HoverRangeEvent struct {
+	Range HoverRange
+	// Enter is set when the pointer entered the range, and unset
+	// when it left.
+	Enter bool
+}
+
+// hover is the state of the pointer hovering over the editor.
+type hover struct {
+	// over is set when the pointer is over the rune at the rune
+	// offset offset.
+	over   bool
+	offset int
+	ranges []HoverRange
+	// inside tracks whether the pointer is inside each range.
+	inside []bool
+}
+
+// SetHoverRanges replaces the hover ranges of the editor. Ranges are
+// adjusted when the text they cover is edited.
+func (e *Editor) SetHoverRanges(ranges []HoverRange) {
+	e.hover.ranges = append(e.hover.ranges[:0], ranges...)
+	e.hover.inside = make([]bool, len(ranges))
+}
+
+// HoverRanges returns the hover ranges of the editor.
+func (e *Editor) HoverRanges() []HoverRange {
+	return e.hover.ranges
+}
+
+// processHover converts pointer movement to hover events.
+func (e *Editor) processHover(gtx layout.Context) {
+	for _, evt := range gtx.Events(&e.hover) {
+		pe, ok := evt.(pointer.Event)
+		if !ok {
+			continue
+		}
+		off := -1
+		switch pe.Type {
+		case pointer.Move, pointer.Enter:
+			off = e.runeAtPoint(image.Point{
+				X: int(math.Round(float64(pe.Position.X))),
+				Y: int(math.Round(float64(pe.Position.Y))),
+			})
+		case pointer.Leave, pointer.Cancel:
+		default:
+			continue
+		}
+		if over := off != -1; over == e.hover.over && (!over || off == e.hover.offset) {
+			continue
+		}
+		e.hover.over, e.hover.offset = off != -1, off
+		e.events = append(e.events, HoverEvent{Offset: off, Position: pe.Position.Add(e.textOffset())})
+		for i, r := range e.hover.ranges {
+			in := r.Start <= off && off < r.End
+			if in != e.hover.inside[i] {
+				e.hover.inside[i] = in
+				e.events = append(e.events, HoverRangeEvent{Range: r, Enter: in})
+			}
+		}
+	}
+}
+
+// runeAtPoint returns the rune offset of the rune covering the text
+// area position pos, or -1 if there is none.
+func (e *Editor) runeAtPoint(pos image.Point) int {
+	pos = pos.Add(e.scrollOff)
+	if e.lines.len() == 0 || pos.Y < 0 {
+		return -1
+	}
+	i := e.lineAt(pos.Y)
+	if i >= e.lines.len() {
+		return -1
+	}
+	it := e.lines.atLine(i)
+	l, m, _ := it.next()
+	if l.run != nil {
+		return -1
+	}
+	off := m.runes
+	x := e.lineAlign(l)
+	px := fixed.I(pos.X)
+	for j, adv := range l.Layout.Advances {
+		if x <= px && px < x+adv {
+			return off + j
+		}
+		x += adv
+	}
+	return -1
+}
+
+// adjustHover updates the hover ranges for the replacement of
+// removed runes at the rune offset start with inserted runes.
+func (e *Editor) adjustHover(start, removed, inserted int) {
+	h := &e.hover
+	n := 0
+	for i, r := range h.ranges {
+		r.Start = adjustOffset(r.Start, start, removed, inserted, true)
+		r.End = adjustOffset(r.End, start, removed, inserted, false)
+		if r.Start < r.End {
+			h.ranges[n], h.inside[n] = r, h.inside[i]
+			n++
+		}
+	}
+	h.ranges, h.inside = h.ranges[:n], h.inside[:n]
+}
+
+func (HoverEvent) isEditorEvent()      {}
+func (HoverRangeEvent) isEditorEvent() {}
@@ -0,0 +1,79 @@
+// SPDX-License-Identifier: Unlicense OR MIT
+
+package widget
+
+import (
+	"fmt"
+	"html"
+	"strings"
+
+	"gioui.org/io/clipboard"
+	"gioui.org/text"
+)
+
+// clipboardOp returns the operation for copying the text between the
+// byte offsets start and end, with its HTML representation if
+// CopyHTML is set and the editor has styled spans.
+func (e *Editor) clipboardOp(start, end int) clipboard.WriteOp {
+	op := clipboard.WriteOp{Text: e.rr.substring(start, end)}
+	if e.CopyHTML && len(e.spans) > 0 {
+		op.HTML = e.html(start, end)
+	}
+	return op
+}
+
+// html returns the text between the byte offsets start and end as
+// HTML, with the styles of the spans covering it.
+func (e *Editor) html(start, end int) string {
+	var b strings.Builder
+	b.WriteString(`<div style="white-space: pre-wrap">`)
+	r := e.rr.runeOffset(start)
+	spans := e.spanCursor(r)
+	var cur *Span
+	for idx := start; idx < end; r++ {
+		c, s := e.rr.runeAt(idx)
+		idx += s
+		if sp := spans.at(r); sp != cur {
+			if cur != nil {
+				b.WriteString("</span>")
+			}
+			cur = sp
+			if cur != nil {
+				fmt.Fprintf(&b, `<span style="%s">`, spanStyle(*cur))
+			}
+		}
+		switch c {
+		case '\n':
+			b.WriteString("<br>")
+		default:
+			b.WriteString(html.EscapeString(string(c)))
+		}
+	}
+	if cur != nil {
+		b.WriteString("</span>")
+	}
+	b.WriteString("</div>")
+	return b.String()
+}
+
+// spanStyle returns the CSS declarations for the style of sp.
+func spanStyle(sp Span) string {
+	var decls []string
+	if c := sp.Color; c.A > 0 {
+		decls = append(decls, fmt.Sprintf("color: rgba(%d, %d, %d, %.3g)", c.R, c.G, c.B, float64(c.A)/255))
+	}
+	f := sp.Font
+	if f.Typeface != "" {
+		decls = append(decls, fmt.Sprintf("font-family: %q", string(f.Typeface)))
+	}
+	if f.Style == text.Italic {
+		decls = append(decls, "font-style: italic")
+	}
+	if f.Weight != text.Normal {
+		decls = append(decls, fmt.Sprintf("font-weight: %d", int(f.Weight)+400))
+	}
+	if sp.Underline {
+		decls = append(decls, "text-decoration: underline")
+	}
+	return html.EscapeString(strings.Join(decls, "; "))
+}
@@ -0,0 +1,137 @@
+// SPDX-License-Identifier: Unlicense OR MIT
+
+package widget
+
+import (
+	"strings"
+	"unicode"
+
+	"gioui.org/io/key"
+	"gioui.org/layout"
+)
+
+// Keymap selects the key bindings of an Editor.
+type Keymap uint8
+
+const (
+	// DefaultKeymap contains the standard key bindings of the
+	// platform.
+	DefaultKeymap Keymap = iota
+	// EmacsKeymap adds Emacs and readline style bindings to the
+	// default bindings, taking precedence over them:
+	//
+	//  Ctrl+A, Ctrl+E  start and end of line
+	//  Ctrl+F, Ctrl+B  forward and backward one rune
+	//  Alt+F, Alt+B    forward and backward one word
+	//  Ctrl+N, Ctrl+P  next and previous line
+	//  Ctrl+D, Ctrl+H  delete forward and backward
+	//  Ctrl+K          kill to end of line
+	//  Ctrl+U          kill to start of line
+	//  Ctrl+W          kill the previous whitespace separated word
+	//  Alt+D           kill the next word
+	//  Alt+Backspace   kill the previous word
+	//  Ctrl+Y          yank the most recent kill
+	//  Alt+Y           replace the yanked text with the kill before
+	//  Alt+U, Alt+L    upper and lower case the selection or next word
+	//  Alt+C           title case the selection or next word
+	//
+	// Killed text is added to a kill ring, and also copied to the
+	// clipboard. Consecutive kills accumulate into a single entry.
+	// Ctrl+Y pastes the clipboard if nothing was killed.
+	EmacsKeymap
+	// VimKeymap adds modal editing in the style of Vim. See
+	// VimMode for the supported modes and commands.
+	VimKeymap
+)
+
+// emacsCommand executes the Emacs key binding for k, if any, and
+// reports whether there was one.
+func (e *Editor) emacsCommand(gtx layout.Context, k key.Event) bool {
+	switch k.Modifiers {
+	case key.ModCtrl:
+		switch shortcutName(k) {
+		case "A":
+			e.moveCarets(key.NameHome, false, false)
+		case "E":
+			e.moveCarets(key.NameEnd, false, false)
+		case "F":
+			e.moveCarets(key.NameRightArrow, false, false)
+		case "B":
+			e.moveCarets(key.NameLeftArrow, false, false)
+		case "N":
+			e.moveCarets(key.NameDownArrow, false, false)
+		case "P":
+			e.moveCarets(key.NameUpArrow, false, false)
+		case "D":
+			e.forEachCaret(func() { e.Delete(1) })
+		case "H":
+			e.forEachCaret(func() { e.Delete(-1) })
+		case "K":
+			_, end := e.lineBounds(e.rr.caret)
+			if end == e.rr.caret && end < e.rr.len() {
+				// Kill the line terminator.
+				end++
+			}
+			e.kill(gtx, e.rr.caret, end, false)
+		case "U":
+			start, _ := e.lineBounds(e.rr.caret)
+			e.kill(gtx, start, e.rr.caret, true)
+		case "W":
+			e.kill(gtx, e.spaceWord(e.rr.caret, -1), e.rr.caret, true)
+		case "Y":
+			e.yankText(gtx)
+		default:
+			return false
+		}
+	case key.ModAlt:
+		switch shortcutName(k) {
+		case "F":
+			e.moveCarets(key.NameRightArrow, true, false)
+		case "B":
+			e.moveCarets(key.NameLeftArrow, true, false)
+		case "D":
+			e.kill(gtx, e.rr.caret, e.spaceWord(e.rr.caret, 1), false)
+		case key.NameDeleteBackward:
+			e.kill(gtx, e.spaceWord(e.rr.caret, -1), e.rr.caret, true)
+		case "Y":
+			if !e.yankPop() {
+				return false
+			}
+		case "U":
+			e.transformWord(strings.ToUpper)
+		case "L":
+			e.transformWord(strings.ToLower)
+		case "C":
+			e.transformWord(titleCase)
+		default:
+			return false
+		}
+	default:
+		return false
+	}
+	return true
+}
+
+// spaceWord returns the byte offset of the end of the whitespace
+// separated word from idx in the direction dir, skipping leading
+// whitespace.
+func (e *Editor) spaceWord(idx, dir int) int {
+	next := func() (rune, int) {
+		if dir < 0 {
+			r, s := e.rr.runeBefore(idx)
+			return r, -s
+		}
+		return e.rr.runeAt(idx)
+	}
+	inWord := false
+	for 0 < idx && dir < 0 || idx < e.rr.len() && dir > 0 {
+		r, s := next()
+		space := unicode.IsSpace(r)
+		if inWord && space {
+			break
+		}
+		inWord = inWord || !space
+		idx += s
+	}
+	return idx
+}
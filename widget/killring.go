@@ -0,0 +1,111 @@
+// SPDX-License-Identifier: Unlicense OR MIT
+
+package widget
+
+import (
+	"gioui.org/io/clipboard"
+	"gioui.org/layout"
+)
+
+// killRingSize is the maximum number of entries in a kill ring.
+const killRingSize = 60
+
+// killRing holds the text killed by the Emacs key bindings, most
+// recent last.
+type killRing struct {
+	kills []string
+	// last is the kind of the most recent command, and prev the
+	// kind of the command before it.
+	last, prev killCommand
+	// yank is the index of the most recently yanked entry, inserted
+	// between the byte offsets start and end.
+	yank, start, end int
+}
+
+// killCommand is a kind of command relevant to the kill ring.
+type killCommand uint8
+
+const (
+	killOther killCommand = iota
+	killKill
+	killYank
+)
+
+// next records the start of a command.
+func (k *killRing) next() {
+	k.prev, k.last = k.last, killOther
+}
+
+// add adds a killed text to the ring. Consecutive kills accumulate
+// into a single entry, with text killed backward prepended.
+func (k *killRing) add(s string, backward bool) {
+	k.last = killKill
+	if k.prev == killKill && len(k.kills) > 0 {
+		top := &k.kills[len(k.kills)-1]
+		if backward {
+			*top = s + *top
+		} else {
+			*top += s
+		}
+		return
+	}
+	if len(k.kills) == killRingSize {
+		k.kills = append(k.kills[:0], k.kills[1:]...)
+	}
+	k.kills = append(k.kills, s)
+}
+
+// kill deletes the text between the byte offsets start and end, adds
+// it to the kill ring and copies it to the clipboard. The caret is
+// before start if backward is set, and after end otherwise.
+func (e *Editor) kill(gtx layout.Context, start, end int, backward bool) {
+	if start == end {
+		return
+	}
+	s := e.rr.substring(start, end)
+	e.kills.add(s, backward)
+	clipboard.WriteOp{Text: e.kills.kills[len(e.kills.kills)-1]}.Add(gtx.Ops)
+	e.history.seal()
+	e.replace(start, end, "")
+	e.history.seal()
+}
+
+// yankText inserts the most recent kill at the caret. If the kill
+// ring is empty, the clipboard is pasted instead.
+func (e *Editor) yankText(gtx layout.Context) {
+	k := &e.kills
+	if len(k.kills) == 0 {
+		clipboard.ReadOp{Tag: &e.eventKey}.Add(gtx.Ops)
+		return
+	}
+	k.yank = len(k.kills) - 1
+	e.insertYank(e.rr.caret, e.rr.caret)
+}
+
+// yankPop replaces the text inserted by the previous yank with the
+// kill before it, cycling through the kill ring. It reports whether
+// the previous command was a yank.
+func (e *Editor) yankPop() bool {
+	k := &e.kills
+	if k.prev != killYank || len(k.kills) == 0 {
+		return false
+	}
+	k.yank--
+	if k.yank < 0 {
+		k.yank = len(k.kills) - 1
+	}
+	e.insertYank(k.start, k.end)
+	return true
+}
+
+// insertYank replaces the text between the byte offsets start and
+// end with the kill ring entry selected by yank.
+func (e *Editor) insertYank(start, end int) {
+	k := &e.kills
+	s := k.kills[k.yank]
+	e.history.seal()
+	e.replace(start, end, s)
+	e.history.seal()
+	k.start, k.end = start, e.rr.caret
+	k.last = killYank
+}
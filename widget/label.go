@@ -5,12 +5,12 @@ package widget
 import (
 	"fmt"
 	"image"
+	"image/color"
 	"unicode/utf8"
 
 	"gioui.org/layout"
 	"gioui.org/op"
 	"gioui.org/op/clip"
-	"gioui.org/op/paint"
 	"gioui.org/text"
 	"gioui.org/unit"
 
@@ -23,10 +23,93 @@ type Label struct {
 	Alignment text.Alignment
 	// MaxLines limits the number of lines. Zero means no limit.
 	MaxLines int
+	// WrapPolicy configures how lines too wide for the label are
+	// broken. WrapNone lines are clipped.
+	WrapPolicy WrapPolicy
+	// TabWidth is the distance between tab stops, measured in
+	// spaces. If zero, a width of 4 is used.
+	TabWidth int
+	// TabStops are the positions of the first tab stops, relative
+	// to the start of a line, for aligning columns. Past the last
+	// of them, tab stops are TabWidth spaces apart.
+	TabStops []unit.Value
+	// Vertical lays out the text in columns from top to bottom,
+	// progressing from right to left, as in Chinese and Japanese
+	// typography. Runes of horizontal scripts, such as Latin, are
+	// rotated clockwise. MaxLines limits the number of columns.
+	// Vertical text ignores the other options, and Selectable
+	// doesn't select it.
+	Vertical bool
+	// Rotation rotates the text by the angle in radians, clockwise
+	// as displayed, such as -math.Pi/2 for the label of a vertical
+	// chart axis. The dimensions are those of the bounding box of
+	// the rotated text, and text closer to vertical than horizontal
+	// wraps at the maximum height. Selectable input is rotated
+	// along with the text.
+	Rotation float32
+	// Baseline selects the line whose baseline is reported in the
+	// dimensions.
+	Baseline BaselineMode
+	// LineHeight, if non-zero, is the distance between the
+	// baselines of successive lines. Otherwise, LineHeightScale, if
+	// non-zero, scales the line height given by the font.
+	LineHeight      unit.Value
+	LineHeightScale float32
+	// LetterSpacing is added to the advance of every rune.
+	LetterSpacing unit.Value
+	// Truncator, if set, ends the last line when MaxLines truncates
+	// the text, such as "…". Runes are removed from the line until
+	// the truncator fits.
+	Truncator string
+	// Links are the links of the text, painted underlined, and in
+	// LinkColor if it is not transparent. Selectable reports clicks
+	// on links with LinkClickEvents.
+	Links     []LabelLink
+	LinkColor color.NRGBA
+	// Spans style ranges of the text. If spans overlap, the latest
+	// span in the slice takes precedence.
+	Spans []LabelSpan
+	// SpanRadius rounds the corners of span backgrounds.
+	SpanRadius unit.Value
+	// Shadow and Outline are painted behind the text.
+	Shadow  TextShadow
+	Outline TextOutline
+	// Cache, if set, caches the layout across frames. Selectable
+	// doesn't use it.
+	Cache *LabelCache
+	// HitTest, if set, records the positions of the runes of the
+	// text when it is laid out.
+	HitTest *LabelLayout
 }
 
+// LabelResult is the result of laying out a Label.
+type LabelResult struct {
+	layout.Dimensions
+	// Truncated is the number of runes of the text not displayed
+	// because of MaxLines, not counting the line terminator of the
+	// last displayed line.
+	Truncated int
+	// Overflow reports whether the text didn't fit, because it was
+	// truncated by MaxLines or clipped by the constraints.
+	Overflow bool
+}
+
+// BaselineMode selects the line whose baseline is reported as the
+// baseline of multi-line text, for aligning it with other widgets.
+type BaselineMode uint8
+
+const (
+	// BaselineFirst reports the baseline of the first line.
+	BaselineFirst BaselineMode = iota
+	// BaselineLast reports the baseline of the last line.
+	BaselineLast
+)
+
 type lineIterator struct {
-	Lines     []text.Line
+	Lines []text.Line
+	// Tree, if set, supplies the lines instead of Lines. Lines
+	// written right to left swap their Start and End alignments.
+	Tree      *lineIter
 	Clip      image.Rectangle
 	Alignment text.Alignment
 	Width     int
@@ -34,15 +117,45 @@ type lineIterator struct {
 
 	y, prevDesc fixed.Int26_6
 	txtOff      int
+	// runes is the number of runes in the lines consumed so far.
+	runes int
+	// runeOff is the rune offset of the layout returned by the
+	// most recent call to Next, and index the index of its line
+	// in Lines.
+	runeOff int
+	index   int
+	// entry and mark are the line of the most recent layout and its
+	// mark, if Tree is set.
+	entry lineEntry
+	mark  lineMark
+	// next is the index of the next line in Lines.
+	next int
 }
 
 const inf = 1e6
 
 func (l *lineIterator) Next() (text.Layout, image.Point, bool) {
-	for len(l.Lines) > 0 {
-		line := l.Lines[0]
-		l.Lines = l.Lines[1:]
-		x := align(l.Alignment, line.Width, l.Width) + fixed.I(l.Offset.X)
+	for {
+		var line text.Line
+		alignment := l.Alignment
+		if l.Tree != nil {
+			e, m, ok := l.Tree.next()
+			if !ok {
+				break
+			}
+			l.entry, l.mark, line = e, m, e.Line
+			l.txtOff, l.runes = m.bytes, m.runes
+			alignment = mirrorAlignment(alignment, e.rtl)
+		} else {
+			if len(l.Lines) == 0 {
+				break
+			}
+			line = l.Lines[0]
+			l.Lines = l.Lines[1:]
+		}
+		l.index = l.next
+		l.next++
+		x := align(alignment, line.Width, l.Width) + fixed.I(l.Offset.X)
 		l.y += l.prevDesc + line.Ascent
 		l.prevDesc = line.Descent
 		// Align baseline and line start to the pixel grid.
@@ -55,7 +168,10 @@ func (l *lineIterator) Next() (text.Layout, image.Point, bool) {
 		layout := line.Layout
 		start := l.txtOff
 		l.txtOff += len(line.Layout.Text)
-		if (off.Y + line.Bounds.Max.Y).Ceil() < l.Clip.Min.Y {
+		l.runeOff = l.runes
+		l.runes += len(line.Layout.Advances)
+		if (off.Y+line.Bounds.Max.Y).Ceil() < l.Clip.Min.Y || l.entry.run != nil {
+			// Runs of unshaped paragraphs are not drawn.
 			continue
 		}
 		for len(layout.Advances) > 0 {
@@ -68,6 +184,7 @@ func (l *lineIterator) Next() (text.Layout, image.Point, bool) {
 			layout.Text = layout.Text[n:]
 			layout.Advances = layout.Advances[1:]
 			start += n
+			l.runeOff++
 		}
 		end := start
 		endx := off.X
@@ -89,35 +206,233 @@ func (l *lineIterator) Next() (text.Layout, image.Point, bool) {
 }
 
 func (l Label) Layout(gtx layout.Context, s text.Shaper, font text.Font, size unit.Value, txt string) layout.Dimensions {
-	cs := gtx.Constraints
+	return l.LayoutResult(gtx, s, font, size, txt).Dimensions
+}
+
+// LayoutResult is like Layout, but also returns the details of the
+// layout.
+func (l Label) LayoutResult(gtx layout.Context, s text.Shaper, font text.Font, size unit.Value, txt string) LabelResult {
+	if c := l.Cache; c != nil {
+		return c.layout(gtx, l, s, font, size, txt)
+	}
+	return l.layoutUncached(gtx, s, font, size, txt)
+}
+
+// layoutUncached is LayoutResult without Cache.
+func (l Label) layoutUncached(gtx layout.Context, s text.Shaper, font text.Font, size unit.Value, txt string) LabelResult {
+	if l.Rotation != 0 {
+		return rotateText(gtx, l.Rotation, func(gtx layout.Context) LabelResult {
+			return l.layoutText(gtx, s, font, size, txt)
+		})
+	}
+	return l.layoutText(gtx, s, font, size, txt)
+}
+
+// layoutText lays out and paints the text, ignoring Rotation.
+func (l Label) layoutText(gtx layout.Context, s text.Shaper, font text.Font, size unit.Value, txt string) LabelResult {
 	textSize := fixed.I(gtx.Px(size))
-	lines := s.LayoutString(font, textSize, cs.Max.X, txt)
+	if l.Vertical {
+		if t := l.HitTest; t != nil {
+			t.record(nil, l.Alignment, 0)
+		}
+		return l.layoutVertical(gtx, s, font, textSize, txt)
+	}
+	lines, res := l.layoutLines(gtx, s, font, textSize, txt)
+	if t := l.HitTest; t != nil {
+		t.record(lines, l.Alignment, res.Size.X)
+	}
+	l.paintBackgrounds(gtx, lines, res.Size.X)
+	l.paintLines(gtx, s, font, textSize, lines, res.Size)
+	return res
+}
+
+// layoutLines shapes txt into the lines to display, and computes
+// the result of the layout.
+func (l Label) layoutLines(gtx layout.Context, s text.Shaper, font text.Font, textSize fixed.Int26_6, txt string) ([]text.Line, LabelResult) {
+	var res LabelResult
+	cs := gtx.Constraints
+	// Shape the lines unbroken for the policies that break them
+	// after shaping.
+	width := cs.Max.X
+	if l.WrapPolicy != WrapWords {
+		width = inf
+	}
+	var lines []text.Line
+	if len(l.Spans) > 0 {
+		lines = l.layoutSpans(gtx, s, font, textSize, width, txt)
+	} else {
+		lines = s.LayoutString(font, textSize, width, txt)
+	}
+	lines = newTabStops(gtx, l.TabWidth, l.TabStops).expand(s, font, textSize, lines)
+	sp := newSpacing(gtx, l.LineHeight, l.LineHeightScale, l.LetterSpacing)
+	lines = sp.apply(lines, width)
+	lines = wrapLines(lines, l.WrapPolicy, cs.Max.X)
 	if max := l.MaxLines; max > 0 && len(lines) > max {
 		lines = lines[:max]
+		shown := 0
+		for _, l := range lines {
+			shown += len(l.Layout.Advances)
+		}
+		res.Truncated = utf8.RuneCountInString(txt) - shown
+		if l.Truncator != "" {
+			t := sp.apply(s.LayoutString(font, textSize, inf, l.Truncator), inf)
+			if len(t) > 0 {
+				last, n := truncate(lines[max-1], t[0], cs.Max.X)
+				lines = append(lines[:max-1:max-1], last)
+				res.Truncated += n
+			}
+		}
 	}
 	dims := linesDimens(lines)
+	base := baselineY(lines, dims.Size.Y, l.Baseline)
+	res.Overflow = res.Truncated > 0 || dims.Size.X > cs.Max.X || dims.Size.Y > cs.Max.Y
 	dims.Size = cs.Constrain(dims.Size)
+	dims.Baseline = dims.Size.Y - base
+	res.Dimensions = dims
+	return lines, res
+}
+
+// paintLines paints lines within the size in the current color.
+func (l Label) paintLines(gtx layout.Context, s text.Shaper, font text.Font, textSize fixed.Int26_6, lines []text.Line, size image.Point) {
 	cl := textPadding(lines)
-	cl.Max = cl.Max.Add(dims.Size)
-	it := lineIterator{
-		Lines:     lines,
-		Clip:      cl,
-		Alignment: l.Alignment,
-		Width:     dims.Size.X,
+	cl.Max = cl.Max.Add(size)
+	for _, p := range textPasses(gtx, l.Shadow, l.Outline) {
+		it := lineIterator{
+			Lines:     lines,
+			Clip:      cl,
+			Alignment: l.Alignment,
+			Width:     size.X,
+		}
+		for {
+			lt, off, ok := it.Next()
+			if !ok {
+				break
+			}
+			stack := op.Push(gtx.Ops)
+			op.Offset(layout.FPt(off)).Add(gtx.Ops)
+			if p.own() {
+				clip.Rect(cl.Sub(off)).Add(gtx.Ops)
+			}
+			if len(l.Links) > 0 || len(l.Spans) > 0 {
+				l.paintLayout(gtx, s, font, textSize, lt, it.runeOff, p)
+			} else {
+				p.paint(gtx, s.Shape(font, textSize, lt))
+			}
+			stack.Pop()
+		}
 	}
-	for {
-		l, off, ok := it.Next()
-		if !ok {
+}
+
+// placedLine is a displayed line of a Label.
+type placedLine struct {
+	line text.Line
+	// x is the start and y the baseline of the line.
+	x, y int
+	// runes is the rune offset of the line.
+	runes int
+}
+
+// placeLines appends the positions of lines laid out with the
+// alignment and width to placed.
+func placeLines(placed []placedLine, lines []text.Line, alignment text.Alignment, width int) []placedLine {
+	var (
+		y        fixed.Int26_6
+		prevDesc fixed.Int26_6
+		runes    int
+	)
+	for _, l := range lines {
+		y += prevDesc + l.Ascent
+		prevDesc = l.Descent
+		y = fixed.I(y.Ceil())
+		placed = append(placed, placedLine{
+			line:  l,
+			x:     align(alignment, l.Width, width).Floor(),
+			y:     y.Floor(),
+			runes: runes,
+		})
+		runes += len(l.Layout.Advances)
+	}
+	return placed
+}
+
+// lineRegions returns the bounds of the text between the rune
+// offsets start and end on each line.
+func lineRegions(lines []placedLine, start, end int) []image.Rectangle {
+	if start >= end {
+		return nil
+	}
+	var rects []image.Rectangle
+	for _, l := range lines {
+		advs := l.line.Layout.Advances
+		lo, hi := start-l.runes, end-l.runes
+		if lo < 0 {
+			lo = 0
+		}
+		if hi > len(advs) {
+			hi = len(advs)
+		}
+		if lo >= hi {
+			continue
+		}
+		x0 := fixed.I(l.x)
+		for _, adv := range advs[:lo] {
+			x0 += adv
+		}
+		x1 := x0
+		for _, adv := range advs[lo:hi] {
+			x1 += adv
+		}
+		rects = append(rects, image.Rectangle{
+			Min: image.Point{X: x0.Floor(), Y: l.y - l.line.Ascent.Ceil()},
+			Max: image.Point{X: x1.Ceil(), Y: l.y + l.line.Descent.Ceil()},
+		})
+	}
+	return rects
+}
+
+// highlightRange highlights the text between the rune offsets start
+// and end of lines.
+func highlightRange(gtx layout.Context, h Highlight, lines []placedLine, start, end int) {
+	for _, r := range lineRegions(lines, start, end) {
+		drawHighlight(gtx, h, r)
+	}
+}
+
+// truncate removes runes from the end of the line l until the
+// truncator line t fits after them within maxWidth, and appends t.
+// The line terminator is always removed, but not counted. It returns
+// the resulting line and the number of removed runes.
+func truncate(l text.Line, t text.Line, maxWidth int) (text.Line, int) {
+	txt, advs := l.Layout.Text, l.Layout.Advances
+	w := l.Width
+	removed := 0
+	for len(advs) > 0 {
+		r, n := utf8.DecodeLastRuneInString(txt)
+		if r != '\n' && w+t.Width <= fixed.I(maxWidth) {
 			break
 		}
-		stack := op.Push(gtx.Ops)
-		op.Offset(layout.FPt(off)).Add(gtx.Ops)
-		s.Shape(font, textSize, l).Add(gtx.Ops)
-		clip.Rect(cl.Sub(off)).Add(gtx.Ops)
-		paint.PaintOp{}.Add(gtx.Ops)
-		stack.Pop()
+		w -= advs[len(advs)-1]
+		txt, advs = txt[:len(txt)-n], advs[:len(advs)-1]
+		if r != '\n' {
+			removed++
+		}
+	}
+	l.Layout = text.Layout{
+		Text:     txt + t.Layout.Text,
+		Advances: append(advs[:len(advs):len(advs)], t.Layout.Advances...),
 	}
-	return dims
+	l.Bounds.Max.X += w + t.Width - l.Width
+	l.Width = w + t.Width
+	return l, removed
+}
+
+// Measure returns the dimensions and the number of lines of txt laid
+// out with the font and size, wrapped at maxWidth. Measure doesn't
+// draw the text, for sizing layouts, such as table columns, ahead of
+// drawing.
+func Measure(s text.Shaper, font text.Font, size fixed.Int26_6, maxWidth int, txt string) (layout.Dimensions, int) {
+	lines := s.LayoutString(font, size, maxWidth, txt)
+	return linesDimens(lines), len(lines)
 }
 
 func textPadding(lines []text.Line) (padding image.Rectangle) {
@@ -167,6 +482,18 @@ func linesDimens(lines []text.Line) layout.Dimensions {
 	}
 }
 
+// baselineY returns the vertical position of the baseline selected by
+// mode, relative to the top of lines with total height h.
+func baselineY(lines []text.Line, h int, mode BaselineMode) int {
+	if len(lines) == 0 {
+		return 0
+	}
+	if mode == BaselineLast {
+		return h - lines[len(lines)-1].Descent.Ceil()
+	}
+	return lines[0].Ascent.Ceil()
+}
+
 func align(align text.Alignment, width fixed.Int26_6, maxWidth int) fixed.Int26_6 {
 	mw := fixed.I(maxWidth)
 	switch align {
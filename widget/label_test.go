@@ -0,0 +1,548 @@
+// SPDX-License-Identifier: Unlicense OR MIT
+
+package widget
+
+import (
+	"image"
+	"image/color"
+	"math"
+	"strings"
+	"testing"
+	"time"
+	"unicode/utf8"
+
+	"gioui.org/f32"
+	"gioui.org/font/gofont"
+	"gioui.org/io/key"
+	"gioui.org/io/pointer"
+	"gioui.org/io/router"
+	"gioui.org/layout"
+	"gioui.org/op"
+	"gioui.org/text"
+	"gioui.org/unit"
+
+	"golang.org/x/image/math/fixed"
+)
+
+func TestMeasure(t *testing.T) {
+	cache := text.NewCache(gofont.Collection())
+	const txt = "the quick brown fox"
+	gtx := layout.Context{
+		Ops:         new(op.Ops),
+		Constraints: layout.Constraints{Max: image.Pt(50, 1000)},
+	}
+	want := Label{}.Layout(gtx, cache, text.Font{}, unit.Px(10), txt)
+	dims, n := Measure(cache, text.Font{}, fixed.I(10), 50, txt)
+	if dims != want {
+		t.Errorf("got dimensions %v, want %v", dims, want)
+	}
+	if n < 2 {
+		t.Errorf("got %d lines, want the text wrapped", n)
+	}
+	if _, n := Measure(cache, text.Font{}, fixed.I(10), 1000, txt); n != 1 {
+		t.Errorf("got %d lines, want 1", n)
+	}
+}
+
+func TestLabelBaseline(t *testing.T) {
+	cache := text.NewCache(gofont.Collection())
+	gtx := layout.Context{
+		Ops:         new(op.Ops),
+		Constraints: layout.Exact(image.Pt(100, 100)),
+	}
+	first := Label{}.Layout(gtx, cache, text.Font{}, unit.Px(10), "a\nb\nc")
+	last := Label{Baseline: BaselineLast}.Layout(gtx, cache, text.Font{}, unit.Px(10), "a\nb\nc")
+	single := Label{}.Layout(gtx, cache, text.Font{}, unit.Px(10), "a")
+	if got := first.Baseline; got != single.Baseline {
+		t.Errorf("got first baseline %d, want %d", got, single.Baseline)
+	}
+	// The distance between the baselines is the height of the text
+	// without its first line.
+	h1, _ := Measure(cache, text.Font{}, fixed.I(10), 100, "a")
+	h3, _ := Measure(cache, text.Font{}, fixed.I(10), 100, "a\nb\nc")
+	if got, want := first.Baseline-last.Baseline, h3.Size.Y-h1.Size.Y; got != want {
+		t.Errorf("got baselines %d apart, want %d", got, want)
+	}
+
+	e := &Editor{Baseline: BaselineLast}
+	e.SetText("a\nb\nc")
+	dims := e.Layout(gtx, cache, text.Font{}, unit.Px(10))
+	if dims.Baseline != last.Baseline {
+		t.Errorf("got editor baseline %d, want %d", dims.Baseline, last.Baseline)
+	}
+}
+
+func TestLabelSpacing(t *testing.T) {
+	cache := text.NewCache(gofont.Collection())
+	gtx := layout.Context{
+		Ops:         new(op.Ops),
+		Constraints: layout.Constraints{Max: image.Pt(1000, 1000)},
+	}
+	plain := Label{}.Layout(gtx, cache, text.Font{}, unit.Px(10), "abc")
+	spaced := Label{LetterSpacing: unit.Px(2)}.Layout(gtx, cache, text.Font{}, unit.Px(10), "abc")
+	if got, want := spaced.Size.X, plain.Size.X+3*2; got != want {
+		t.Errorf("got width %d with letter spacing, want %d", got, want)
+	}
+	// The shaper cache must not be affected by the spacing.
+	if again := (Label{}).Layout(gtx, cache, text.Font{}, unit.Px(10), "abc"); again != plain {
+		t.Errorf("got dimensions %v after letter spacing, want %v", again, plain)
+	}
+	tall := Label{LineHeight: unit.Px(30)}.Layout(gtx, cache, text.Font{}, unit.Px(10), "a\nb\nc")
+	if got, want := tall.Size.Y, 3*30; got < want || got > want+3 {
+		t.Errorf("got height %d for line height 30, want %d", got, want)
+	}
+
+	e := &Editor{LineHeightScale: 2, LetterSpacing: unit.Px(2)}
+	e.SetText("ab\ncd")
+	e.Layout(gtx, cache, text.Font{}, unit.Px(10))
+	it := e.ShapedLines(0)
+	l0, _ := it.Next()
+	l1, _ := it.Next()
+	natural := plain.Size.Y
+	if d := l1.Baseline - l0.Baseline; d < 2*natural-2 || d > 2*natural+2 {
+		t.Errorf("got baselines %d apart, want about %d", d, 2*natural)
+	}
+	ab := Label{}.Layout(gtx, cache, text.Font{}, unit.Px(10), "ab")
+	if got, want := l1.Width.Ceil(), ab.Size.X+2*2; got < want-1 || got > want+1 {
+		t.Errorf("got line width %d with letter spacing, want %d", got, want)
+	}
+}
+
+func TestLabelTruncator(t *testing.T) {
+	cache := text.NewCache(gofont.Collection())
+	gtx := layout.Context{
+		Ops:         new(op.Ops),
+		Constraints: layout.Constraints{Max: image.Pt(1000, 1000)},
+	}
+	const txt = "first line\nsecond line\nthird"
+	res := Label{MaxLines: 2}.LayoutResult(gtx, cache, text.Font{}, unit.Px(10), txt)
+	if got, want := res.Truncated, len("third"); got != want {
+		t.Errorf("got %d truncated runes, want %d", got, want)
+	}
+	res = Label{MaxLines: 1, Truncator: "…"}.LayoutResult(gtx, cache, text.Font{}, unit.Px(10), txt)
+	if got, want := res.Truncated, len("second line\nthird"); got != want {
+		t.Errorf("got %d truncated runes, want %d", got, want)
+	}
+	if res := (Label{MaxLines: 3, Truncator: "…"}).LayoutResult(gtx, cache, text.Font{}, unit.Px(10), txt); res.Truncated != 0 {
+		t.Errorf("got %d truncated runes of untruncated text", res.Truncated)
+	}
+
+	// Narrow the label to force runes to make room for the truncator.
+	w, _ := Measure(cache, text.Font{}, fixed.I(10), 1000, "first line")
+	gtx.Constraints.Max.X = w.Size.X
+	res = Label{MaxLines: 1, Truncator: "…"}.LayoutResult(gtx, cache, text.Font{}, unit.Px(10), "first line\nsecond")
+	if res.Truncated <= len("second") {
+		t.Errorf("got %d truncated runes, want runes removed for the truncator", res.Truncated)
+	}
+	if res.Size.X > w.Size.X {
+		t.Errorf("got width %d, want at most %d", res.Size.X, w.Size.X)
+	}
+	trunc := utf8.RuneCountInString("first line\nsecond") - res.Truncated
+	lines := cache.LayoutString(text.Font{}, fixed.I(10), 1000, "first line")
+	if trunc >= len(lines[0].Layout.Advances) {
+		t.Errorf("got %d runes shown, want fewer than the first line", trunc)
+	}
+}
+
+func TestLabelOverflow(t *testing.T) {
+	cache := text.NewCache(gofont.Collection())
+	gtx := layout.Context{
+		Ops:         new(op.Ops),
+		Constraints: layout.Constraints{Max: image.Pt(1000, 1000)},
+	}
+	layoutLabel := func(l Label, max image.Point, txt string) bool {
+		gtx.Constraints.Max = max
+		return l.LayoutResult(gtx, cache, text.Font{}, unit.Px(10), txt).Overflow
+	}
+	if layoutLabel(Label{}, image.Pt(1000, 1000), "a\nb") {
+		t.Error("got overflow of fitting text")
+	}
+	if !layoutLabel(Label{MaxLines: 1}, image.Pt(1000, 1000), "a\nb") {
+		t.Error("no overflow of text truncated by MaxLines")
+	}
+	if !layoutLabel(Label{}, image.Pt(1000, 5), "a\nb") {
+		t.Error("no overflow of text taller than the constraints")
+	}
+	if !layoutLabel(Label{}, image.Pt(5, 1000), "unbreakable") {
+		t.Error("no overflow of text wider than the constraints")
+	}
+}
+
+func TestLabelTabStops(t *testing.T) {
+	cache := text.NewCache(gofont.Collection())
+	gtx := layout.Context{
+		Ops:         new(op.Ops),
+		Constraints: layout.Constraints{Max: image.Pt(1000, 1000)},
+	}
+	hits := new(LabelLayout)
+	Label{HitTest: hits, TabWidth: 8}.Layout(gtx, cache, text.Font{}, unit.Px(10), "a\tb\nabc\td")
+	first, _ := hits.RuneBounds(2)
+	second, _ := hits.RuneBounds(8)
+	if first.Min.X != second.Min.X {
+		t.Errorf("got columns at %d and %d, want aligned columns", first.Min.X, second.Min.X)
+	}
+	Label{HitTest: hits, TabStops: []unit.Value{unit.Px(50)}}.Layout(gtx, cache, text.Font{}, unit.Px(10), "a\tb")
+	if b, _ := hits.RuneBounds(2); b.Min.X != 50 {
+		t.Errorf("got column at %d, want the tab stop at 50", b.Min.X)
+	}
+	// Expanding tabs must not modify the lines cached by the shaper.
+	lines := cache.LayoutString(text.Font{}, fixed.I(10), 1000, "a\tb")
+	plain, _ := Measure(cache, text.Font{}, fixed.I(10), 1000, "a\tb")
+	if lines[0].Width.Ceil() != plain.Size.X {
+		t.Errorf("cached line was modified")
+	}
+}
+
+func TestLabelSpans(t *testing.T) {
+	cache := text.NewCache(gofont.Collection())
+	gtx := layout.Context{
+		Ops:         new(op.Ops),
+		Constraints: layout.Constraints{Max: image.Pt(1000, 1000)},
+	}
+	const txt = "small large"
+	plain := Label{}.Layout(gtx, cache, text.Font{}, unit.Px(10), txt)
+	big := []LabelSpan{{Start: 6, End: 11, Font: text.Font{Weight: text.Bold}, Size: unit.Px(20)}}
+	rich := Label{Spans: big}.Layout(gtx, cache, text.Font{}, unit.Px(10), txt)
+	large, _ := Measure(cache, text.Font{Weight: text.Bold}, fixed.I(20), 1000, "large")
+	if rich.Size.Y != large.Size.Y {
+		t.Errorf("got height %d, want the height %d of the larger span", rich.Size.Y, large.Size.Y)
+	}
+	if rich.Size.X <= plain.Size.X {
+		t.Errorf("got width %d, want wider than the unstyled width %d", rich.Size.X, plain.Size.X)
+	}
+
+	// Wrap the text between the runs.
+	small, _ := Measure(cache, text.Font{}, fixed.I(10), 1000, "small ")
+	gtx.Constraints.Max.X = small.Size.X + 1
+	if large.Size.X > small.Size.X {
+		gtx.Constraints.Max.X = large.Size.X + 1
+	}
+	wrapped := Label{Spans: big}.Layout(gtx, cache, text.Font{}, unit.Px(10), txt)
+	if want := small.Size.Y + large.Size.Y; wrapped.Size.Y < want-1 || wrapped.Size.Y > want+1 {
+		t.Errorf("got wrapped height %d, want about %d", wrapped.Size.Y, want)
+	}
+}
+
+// countingShaper counts the layouts of a shaper.
+type countingShaper struct {
+	text.Shaper
+	layouts int
+}
+
+func (s *countingShaper) LayoutString(font text.Font, size fixed.Int26_6, maxWidth int, str string) []text.Line {
+	s.layouts++
+	return s.Shaper.LayoutString(font, size, maxWidth, str)
+}
+
+func TestLabelCache(t *testing.T) {
+	sh := &countingShaper{Shaper: text.NewCache(gofont.Collection())}
+	gtx := layout.Context{
+		Ops:         new(op.Ops),
+		Constraints: layout.Constraints{Max: image.Pt(1000, 1000)},
+	}
+	cache := new(LabelCache)
+	l := Label{Cache: cache, Spans: []LabelSpan{{Start: 0, End: 2, Underline: true}}}
+	lay := func(txt string) LabelResult {
+		gtx.Ops.Reset()
+		return l.LayoutResult(gtx, sh, text.Font{}, unit.Px(10), txt)
+	}
+	first := lay("cached")
+	n := sh.layouts
+	if got := lay("cached"); got != first || sh.layouts != n {
+		t.Errorf("got %+v and %d layouts in the second frame, want %+v and no layouts", got, sh.layouts-n, first)
+	}
+	l.Spans[0].End = 3
+	lay("cached")
+	if sh.layouts == n {
+		t.Error("modifying a span didn't invalidate the cache")
+	}
+	n = sh.layouts
+	if lay("changed"); sh.layouts == n {
+		t.Error("changing the text didn't invalidate the cache")
+	}
+	n = sh.layouts
+	gtx.Constraints.Max.X = 20
+	if lay("changed"); sh.layouts == n {
+		t.Error("changing the constraints didn't invalidate the cache")
+	}
+}
+
+func TestLabelHitTest(t *testing.T) {
+	cache := text.NewCache(gofont.Collection())
+	gtx := layout.Context{
+		Ops:         new(op.Ops),
+		Constraints: layout.Constraints{Max: image.Pt(1000, 1000)},
+	}
+	hits := new(LabelLayout)
+	Label{HitTest: hits}.Layout(gtx, cache, text.Font{}, unit.Px(10), "can't stop\nnow")
+	if n := hits.Len(); n != 14 {
+		t.Errorf("got %d runes, want 14", n)
+	}
+	prefix, _ := Measure(cache, text.Font{}, fixed.I(10), 1000, "can't ")
+	b, ok := hits.RuneBounds(6)
+	if !ok || b.Min.X != prefix.Size.X || b.Min.Y != 0 {
+		t.Fatalf("got bounds %v of 's', want them starting at (%d, 0)", b, prefix.Size.X)
+	}
+	mid := layout.FPt(b.Min.Add(b.Max)).Mul(.5)
+	if r, ok := hits.RuneAt(mid); !ok || r != 6 {
+		t.Errorf("got rune %d at the center of its bounds", r)
+	}
+	if c := hits.CaretAt(f32.Pt(float32(b.Max.X), mid.Y)); c != 7 {
+		t.Errorf("got caret %d at the end of the rune, want 7", c)
+	}
+	if start, end, ok := hits.WordAt(f32.Pt(1, mid.Y)); !ok || start != 0 || end != 5 {
+		t.Errorf("got word (%d, %d), want \"can't\" at (0, 5)", start, end)
+	}
+	if _, ok := hits.RuneAt(f32.Pt(900, mid.Y)); ok {
+		t.Error("found a rune past the end of the line")
+	}
+	if _, _, ok := hits.WordAt(f32.Pt(float32(prefix.Size.X)-1, mid.Y)); ok {
+		t.Error("found a word at a space")
+	}
+}
+
+func TestFillLines(t *testing.T) {
+	cache := text.NewCache(gofont.Collection())
+	const txt = "The quick brown fox jumps over the lazy dog, and then " +
+		"it runs away into the forest where nobody can find it again.\nEnd"
+	raggedness := func(lines []text.Line, maxWidth int) float64 {
+		var sum float64
+		for _, l := range lines[:len(lines)-1] {
+			if strings.HasSuffix(l.Layout.Text, "\n") {
+				// Skip the last lines of paragraphs.
+				continue
+			}
+			free := float64(fixed.I(maxWidth)-l.Width) / 64
+			sum += free * free
+		}
+		return sum
+	}
+	for _, maxWidth := range []int{60, 90, 120, 150} {
+		greedy := cache.LayoutString(text.Font{}, fixed.I(10), maxWidth, txt)
+		filled := fillLines(cache.LayoutString(text.Font{}, fixed.I(10), inf, txt), maxWidth)
+		var b strings.Builder
+		for _, l := range filled {
+			if l.Width > fixed.I(maxWidth) {
+				t.Errorf("width %d: line %q is %v wide", maxWidth, l.Layout.Text, l.Width)
+			}
+			b.WriteString(l.Layout.Text)
+		}
+		if b.String() != txt {
+			t.Errorf("width %d: got text %q", maxWidth, b.String())
+		}
+		if g, f := raggedness(greedy, maxWidth), raggedness(filled, maxWidth); f > g {
+			t.Errorf("width %d: got raggedness %v, want at most the greedy %v", maxWidth, f, g)
+		}
+	}
+	// Words wider than the width are broken between runes.
+	lines := fillLines(cache.LayoutString(text.Font{}, fixed.I(10), inf, "a incomprehensibilities b"), 30)
+	for _, l := range lines {
+		if l.Width > fixed.I(30) {
+			t.Errorf("line %q is %v wide", l.Layout.Text, l.Width)
+		}
+	}
+}
+
+func TestLabelVertical(t *testing.T) {
+	cache := text.NewCache(gofont.Collection())
+	gtx := layout.Context{
+		Ops:         new(op.Ops),
+		Constraints: layout.Constraints{Max: image.Pt(1000, 1000)},
+	}
+	vertical := func(l Label, txt string) LabelResult {
+		l.Vertical = true
+		return l.LayoutResult(gtx, cache, text.Font{}, unit.Px(10), txt)
+	}
+	one := vertical(Label{}, "日本")
+	if one.Size.Y != 20 {
+		t.Errorf("got height %d, want two squares of the text size", one.Size.Y)
+	}
+	latin, _ := Measure(cache, text.Font{}, fixed.I(10), 1000, "ab")
+	if got := vertical(Label{}, "ab").Size; got.Y != latin.Size.X || got.X != one.Size.X {
+		t.Errorf("got size %v of rotated text, want (%d, %d)", got, one.Size.X, latin.Size.X)
+	}
+	if got := vertical(Label{}, "日本\n語").Size.X; got != 2*one.Size.X {
+		t.Errorf("got width %d of two paragraphs, want two columns of width %d", got, one.Size.X)
+	}
+	gtx.Constraints.Max.Y = 15
+	res := vertical(Label{MaxLines: 1}, "日本語")
+	if res.Size.X != one.Size.X || res.Truncated != 2 || !res.Overflow {
+		t.Errorf("got %+v, want one column with 2 runes truncated", res)
+	}
+}
+
+func TestLabelRotation(t *testing.T) {
+	cache := text.NewCache(gofont.Collection())
+	r := new(router.Router)
+	gtx := layout.Context{
+		Ops:         new(op.Ops),
+		Constraints: layout.Constraints{Max: image.Pt(1000, 1000)},
+		Queue:       r,
+	}
+	const txt = "hello world"
+	plain := Label{}.Layout(gtx, cache, text.Font{}, unit.Px(10), txt)
+	rotated := Label{Rotation: -math.Pi / 2}.Layout(gtx, cache, text.Font{}, unit.Px(10), txt)
+	if rotated.Size != image.Pt(plain.Size.Y, plain.Size.X) {
+		t.Errorf("got size %v, want %v transposed", rotated.Size, plain.Size)
+	}
+
+	// Drag along the rotated text to select the first word.
+	s := &Selectable{Label: Label{Rotation: math.Pi / 2}}
+	frame := func() {
+		gtx.Ops.Reset()
+		s.Layout(gtx, cache, text.Font{}, unit.Px(10), txt)
+		r.Frame(gtx.Ops)
+	}
+	frame()
+	w, _ := Measure(cache, text.Font{}, fixed.I(10), 1000, "hello")
+	x := float32(plain.Size.Y - 5)
+	r.Add(
+		pointer.Event{Type: pointer.Press, Source: pointer.Mouse, Buttons: pointer.ButtonLeft, Position: f32.Pt(x, 1)},
+		pointer.Event{Type: pointer.Move, Source: pointer.Mouse, Buttons: pointer.ButtonLeft, Position: f32.Pt(x, float32(w.Size.X))},
+		pointer.Event{Type: pointer.Release, Source: pointer.Mouse, Position: f32.Pt(x, float32(w.Size.X))},
+	)
+	frame()
+	if got := s.SelectedText(); got != "hello" {
+		t.Errorf("got selection %q after dragging along the rotated text, want \"hello\"", got)
+	}
+}
+
+func TestLineRegions(t *testing.T) {
+	cache := text.NewCache(gofont.Collection())
+	lines := cache.LayoutString(text.Font{}, fixed.I(10), 1000, "one two\nthree")
+	placed := placeLines(nil, lines, text.Start, 1000)
+	one, _ := Measure(cache, text.Font{}, fixed.I(10), 1000, "one ")
+	rects := lineRegions(placed, 4, 11)
+	if len(rects) != 2 {
+		t.Fatalf("got %d regions, want one on each line", len(rects))
+	}
+	if r := rects[0]; r.Min.X != one.Size.X || r.Min.Y != 0 {
+		t.Errorf("got first region %v, want it starting at (%d, 0)", r, one.Size.X)
+	}
+	if r := rects[1]; r.Min.X != 0 || r.Min.Y < rects[0].Max.Y-1 {
+		t.Errorf("got second region %v, want it at the start of the second line", r)
+	}
+	if rects := lineRegions(placed, 3, 3); len(rects) != 0 {
+		t.Errorf("got regions %v of an empty range", rects)
+	}
+}
+
+func TestTextPasses(t *testing.T) {
+	gtx := layout.Context{Ops: new(op.Ops)}
+	passes := textPasses(gtx, TextShadow{}, TextOutline{})
+	if len(passes) != 1 || !passes[0].own() {
+		t.Fatalf("got %+v without effects, want only the text", passes)
+	}
+	shadow := TextShadow{Color: color.NRGBA{A: 0x80}, X: unit.Px(2), Y: unit.Px(3), Blur: unit.Px(4)}
+	outline := TextOutline{Color: color.NRGBA{R: 0xff, A: 0xff}, Width: unit.Px(1)}
+	passes = textPasses(gtx, shadow, outline)
+	if len(passes) != 3 || !passes[2].own() {
+		t.Fatalf("got %d passes, want shadow, outline and text", len(passes))
+	}
+	if off := passes[0].offsets[0]; off != f32.Pt(2, 3) {
+		t.Errorf("got shadow offset %v, want (2, 3)", off)
+	}
+	if a := passes[0].color.A; a == 0 || a >= shadow.Color.A {
+		t.Errorf("got blurred shadow alpha %#x, want translucent copies", a)
+	}
+	if c := passes[1].color; c != outline.Color {
+		t.Errorf("got outline color %v, want %v", c, outline.Color)
+	}
+	// Painting with effects must not disturb the text layout.
+	cache := text.NewCache(gofont.Collection())
+	gtx.Constraints.Max = image.Pt(1000, 1000)
+	plain := Label{}.Layout(gtx, cache, text.Font{}, unit.Px(10), "text")
+	fx := Label{Shadow: shadow, Outline: outline}.Layout(gtx, cache, text.Font{}, unit.Px(10), "text")
+	if fx != plain {
+		t.Errorf("got dimensions %+v with effects, want %+v", fx, plain)
+	}
+}
+
+func TestSelectable(t *testing.T) {
+	cache := text.NewCache(gofont.Collection())
+	r := new(router.Router)
+	gtx := layout.Context{
+		Ops:         new(op.Ops),
+		Constraints: layout.Constraints{Max: image.Pt(1000, 1000)},
+		Queue:       r,
+	}
+	s := new(Selectable)
+	frame := func() {
+		gtx.Ops.Reset()
+		s.Layout(gtx, cache, text.Font{}, unit.Px(10), "hello world\nline")
+		r.Frame(gtx.Ops)
+	}
+	frame()
+	w, _ := Measure(cache, text.Font{}, fixed.I(10), 1000, "hello")
+	r.Add(
+		pointer.Event{Type: pointer.Press, Source: pointer.Mouse, Buttons: pointer.ButtonLeft, Position: f32.Pt(1, 5)},
+		pointer.Event{Type: pointer.Move, Source: pointer.Mouse, Buttons: pointer.ButtonLeft, Position: f32.Pt(float32(w.Size.X), 5)},
+		pointer.Event{Type: pointer.Release, Source: pointer.Mouse, Position: f32.Pt(float32(w.Size.X), 5)},
+	)
+	frame()
+	evts := s.Events()
+	if len(evts) != 1 {
+		t.Fatalf("got %+v after dragging, want a SelectEvent", evts)
+	}
+	if se, ok := evts[0].(SelectEvent); !ok || se.Text != "hello" || se.Start != 0 || se.End != 5 {
+		t.Fatalf("got %+v after dragging, want a selection of \"hello\"", evts[0])
+	}
+	r.Add(
+		key.Event{Name: key.NameRightArrow, Modifiers: key.ModShift},
+		key.Event{Name: "C", Modifiers: key.ModShortcut},
+	)
+	frame()
+	if txt, _ := r.WriteClipboard(); txt != "hello " {
+		t.Errorf("got copied %q, want \"hello \"", txt)
+	}
+	r.Add(key.Event{Name: "A", Modifiers: key.ModShortcut})
+	frame()
+	if got := s.SelectedText(); got != "hello world\nline" {
+		t.Errorf("got %q after selecting all", got)
+	}
+	evts = s.Events()
+	if last, ok := evts[len(evts)-1].(SelectEvent); !ok || last.EndLine != 1 || last.EndCol != 4 {
+		t.Errorf("got %+v, want end position (1, 4)", evts[len(evts)-1])
+	}
+}
+
+func TestSelectableLinks(t *testing.T) {
+	cache := text.NewCache(gofont.Collection())
+	r := new(router.Router)
+	gtx := layout.Context{
+		Ops:         new(op.Ops),
+		Constraints: layout.Constraints{Max: image.Pt(1000, 1000)},
+		Queue:       r,
+	}
+	s := new(Selectable)
+	s.Links = []LabelLink{{Start: 6, End: 10, Value: 42}}
+	frame := func() {
+		gtx.Ops.Reset()
+		s.Layout(gtx, cache, text.Font{}, unit.Px(10), "go to site now")
+		r.Frame(gtx.Ops)
+	}
+	frame()
+	var now time.Duration
+	click := func(x float32) {
+		// Space the clicks to avoid double clicks.
+		now += time.Second
+		r.Add(
+			pointer.Event{Type: pointer.Press, Source: pointer.Mouse, Buttons: pointer.ButtonLeft, Position: f32.Pt(x, 5), Time: now},
+			pointer.Event{Type: pointer.Release, Source: pointer.Mouse, Position: f32.Pt(x, 5), Time: now},
+		)
+		frame()
+	}
+	w, _ := Measure(cache, text.Font{}, fixed.I(10), 1000, "go to si")
+	click(float32(w.Size.X) - 1)
+	evts := s.Events()
+	if len(evts) != 1 {
+		t.Fatalf("got %+v, want a LinkClickEvent", evts)
+	}
+	if lc, ok := evts[0].(LinkClickEvent); !ok || lc.URL != "site" || lc.Value != 42 {
+		t.Errorf("got %+v, want a click on \"site\" with value 42", evts[0])
+	}
+	click(1)
+	if evts := s.Events(); len(evts) != 0 {
+		t.Errorf("got %+v after clicking outside the link", evts)
+	}
+}
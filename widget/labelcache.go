@@ -0,0 +1,74 @@
+// SPDX-License-Identifier: Unlicense OR MIT
+
+package widget
+
+import (
+	"reflect"
+
+	"gioui.org/layout"
+	"gioui.org/op"
+	"gioui.org/text"
+	"gioui.org/unit"
+)
+
+// LabelCache caches the layout and the painting operations of a
+// Label across frames. A Label with a cache laid out with the same
+// text, options and constraints as in the previous frame replays the
+// cached operations instead of laying out the text again, which
+// saves time in long lists of static labels. A LabelCache holds the
+// text of one label, so every label needs its own cache. The text
+// is painted in the current color.
+type LabelCache struct {
+	valid bool
+	key   labelKey
+	// label is a copy of the options of the cached label.
+	label Label
+	res   LabelResult
+	ops   op.Ops
+	call  op.CallOp
+}
+
+// labelKey is the comparable part of the inputs of a cached layout.
+type labelKey struct {
+	shaper text.Shaper
+	font   text.Font
+	size   unit.Value
+	txt    string
+	metric unit.Metric
+	cs     layout.Constraints
+}
+
+// layout lays out and paints l, reusing the cached operations if the
+// inputs are unchanged.
+func (c *LabelCache) layout(gtx layout.Context, l Label, s text.Shaper, font text.Font, size unit.Value, txt string) LabelResult {
+	l.Cache = nil
+	if len(l.Links) == 0 {
+		l.Links = nil
+	}
+	if len(l.Spans) == 0 {
+		l.Spans = nil
+	}
+	key := labelKey{shaper: s, font: font, size: size, txt: txt, metric: gtx.Metric, cs: gtx.Constraints}
+	if !c.valid || key != c.key || !reflect.DeepEqual(l, c.label) {
+		// Copy the slices of the options, in case they are
+		// modified in place.
+		l.Links = append([]LabelLink(nil), l.Links...)
+		l.Spans = append([]LabelSpan(nil), l.Spans...)
+		c.key, c.label = key, l
+		c.ops.Reset()
+		m := op.Record(&c.ops)
+		rgtx := gtx
+		rgtx.Ops = &c.ops
+		c.res = l.layoutUncached(rgtx, s, font, size, txt)
+		c.call = m.Stop()
+		c.valid = true
+	}
+	c.call.Add(gtx.Ops)
+	return c.res
+}
+
+// Invalidate discards the cached layout, such as when the fonts of
+// the shaper change.
+func (c *LabelCache) Invalidate() {
+	c.valid = false
+}
@@ -0,0 +1,327 @@
+// SPDX-License-Identifier: Unlicense OR MIT
+
+package widget
+
+import (
+	"strings"
+
+	"gioui.org/text"
+
+	"golang.org/x/image/math/fixed"
+)
+
+// lazyLayout is the state of the lazy layout of an Editor.
+type lazyLayout struct {
+	enabled bool
+	// metrics is a shaped line of a single digit, for estimating
+	// the size of runs, and font and size the font it was shaped
+	// with.
+	metrics text.Line
+	font    text.Font
+	size    fixed.Int26_6
+}
+
+// layoutLazy lays out the text as a single run. The paragraphs near
+// the viewport and the caret are shaped from the run by shapeVisible
+// and shapeCaret, so the cost of a layout doesn't depend on the
+// size of the text.
+func (e *Editor) layoutLazy() []lineEntry {
+	return []lineEntry{e.runEntry(0, e.rr.len())}
+}
+
+// isLazy reports whether the text is laid out lazily.
+func (e *Editor) isLazy() bool {
+	return e.LazyLayout && e.Mask == 0 && e.shaper != nil
+}
+
+// runEntry returns the run of the text between the byte offsets
+// start and end, which must be paragraph boundaries. The size of the
+// run is estimated by assuming every rune is as wide as a digit.
+func (e *Editor) runEntry(start, end int) lineEntry {
+	m := e.lazyMetrics()
+	r := &lineRun{
+		runes:    e.rr.runeOffset(end) - e.rr.runeOffset(start),
+		bytes:    end - start,
+		newlines: e.rr.linesBefore(end) - e.rr.linesBefore(start),
+	}
+	r.paras = r.newlines
+	if end == e.rr.len() {
+		r.paras++
+	}
+	r.lines = r.paras
+	r.pitch = (m.Ascent + m.Descent).Ceil()
+	if r.pitch < 1 {
+		r.pitch = 1
+	}
+	var w fixed.Int26_6
+	if adv := m.Width + e.spacing.letter; adv > 0 && r.paras > 0 {
+		// The runes of the paragraphs, excluding newlines.
+		runes := r.runes - r.newlines
+		perPara := (runes + r.paras - 1) / r.paras
+		if !e.SingleLine && e.WrapPolicy != WrapNone {
+			perLine := int(fixed.I(e.maxWidth) / adv)
+			if perLine < 1 {
+				perLine = 1
+			}
+			if n := (runes + perLine - 1) / perLine; n > r.lines {
+				r.lines = n
+			}
+			if perPara > perLine {
+				perPara = perLine
+			}
+		}
+		w = adv * fixed.Int26_6(perPara)
+	}
+	l := lineEntry{
+		Line: text.Line{
+			Width:   w,
+			Ascent:  m.Ascent + fixed.I((r.lines-1)*r.pitch),
+			Descent: m.Descent,
+		},
+		run: r,
+	}
+	if len(e.gaps) > 0 {
+		p := e.rr.linesBefore(start)
+		for q, g := range e.gaps {
+			if p <= q && q < p+r.paras {
+				l.Ascent += fixed.I(g)
+			}
+		}
+		if end == e.rr.len() {
+			l.Descent += fixed.I(e.gaps[p+r.paras])
+		}
+	}
+	l.Bounds = fixed.Rectangle26_6{
+		Min: fixed.Point26_6{Y: -l.Ascent},
+		Max: fixed.Point26_6{X: w, Y: l.Descent},
+	}
+	return l
+}
+
+// lazyMetrics returns the metrics of a line of a single digit.
+func (e *Editor) lazyMetrics() text.Line {
+	if e.lazy.font != e.font || e.lazy.size != e.textSize || e.lazy.metrics.Ascent == 0 {
+		e.lazy.font, e.lazy.size = e.font, e.textSize
+		if ls := e.shaper.LayoutString(e.font, e.textSize, inf, "0"); len(ls) > 0 {
+			e.lazy.metrics = ls[0]
+			e.spacing.adjustHeight(&e.lazy.metrics)
+		}
+	}
+	return e.lazy.metrics
+}
+
+// layoutParas shapes the paragraphs between the indices p0 and p1.
+func (e *Editor) layoutParas(p0, p1 int) []lineEntry {
+	start, end := e.rr.lineStart(p0), e.rr.lineStart(p1)
+	lines := e.shapeText(e.shaper, strings.NewReader(e.rr.substring(start, end)))
+	last := p1 > e.rr.newlines()
+	if !last {
+		// Drop the empty line following the final newline.
+		lines = lines[:len(lines)-1]
+	}
+	return e.applyGaps(lineEntries(lines), p0, last)
+}
+
+// spliceParas replaces the entries of the paragraphs between the
+// indices p0 and p1 with lines. The paragraphs after them are
+// renumbered by dp, the change in the number of newlines of their
+// text. Runs that extend past the paragraphs are cut to the
+// paragraphs outside.
+func (e *Editor) spliceParas(p0, p1, dp int, lines []lineEntry) {
+	first, i, s0 := e.lines.find(paraStart(p0))
+	last, j, s1 := e.lines.find(func(s lineSum) bool { return s.newlines < p1 })
+	var entries []lineEntry
+	if first.run != nil && s0.newlines < p0 {
+		entries = append(entries, e.runEntry(s0.bytes, e.rr.lineStart(p0)))
+	}
+	entries = append(entries, lines...)
+	if r := last.run; r != nil && s1.newlines+r.paras > p1 {
+		end := e.rr.len()
+		if r.paras == r.newlines {
+			end = e.rr.lineStart(s1.newlines + r.paras + dp)
+		}
+		entries = append(entries, e.runEntry(e.rr.lineStart(p1+dp), end))
+	}
+	e.lines.replace(i, j+1, entries)
+}
+
+// shapeRun shapes the paragraphs between the indices p0 and p1 of a
+// run. The scroll offset is adjusted for changes in the height of the
+// text above the viewport, to keep the visible text in place.
+func (e *Editor) shapeRun(p0, p1 int) {
+	p := e.paraAt(e.scrollOff.Y)
+	y := e.paraTop(p)
+	e.spliceParas(p0, p1, 0, e.layoutParas(p0, p1))
+	e.scrollOff.Y += e.paraTop(p) - y
+	e.dims = e.textDims()
+}
+
+// paraAt returns the index of the paragraph at the vertical position
+// y.
+func (e *Editor) paraAt(y int) int {
+	it := e.lines.atY(y)
+	l, ok := it.entry()
+	if !ok {
+		return 0
+	}
+	r := l.run
+	if r == nil {
+		return it.m.para
+	}
+	return it.m.para + l.runLine(it.m, y)*r.paras/r.lines
+}
+
+// runLine returns the index in the run l starting at m of the
+// estimated line at the vertical position y.
+func (l lineEntry) runLine(m lineMark, y int) int {
+	r := l.run
+	o := (y - (m.baseline(l.Line) - l.Ascent.Ceil())) / r.pitch
+	if o < 0 {
+		o = 0
+	}
+	if o >= r.lines {
+		o = r.lines - 1
+	}
+	return o
+}
+
+// paraTop returns the vertical position of the top of the paragraph
+// with index p, or of the bottom of the text if there is no such
+// paragraph.
+func (e *Editor) paraTop(p int) int {
+	if t := e.lines.total(); p > t.newlines {
+		return t.height()
+	}
+	it := e.lines.atPara(p)
+	l, _ := it.entry()
+	top := it.m.baseline(l.Line) - l.Ascent.Ceil()
+	if r := l.run; r != nil {
+		top += (p - it.m.para) * r.lines / r.paras * r.pitch
+	}
+	return top
+}
+
+// lazyWindow returns the vertical range of the text to shape: the
+// viewport extended by its height in both directions.
+func (e *Editor) lazyWindow() (top, bottom int) {
+	h := e.viewSize.Y
+	return e.scrollOff.Y - h, e.scrollOff.Y + 2*h
+}
+
+// shapeCaret shapes the paragraph of the caret, if it is in a run.
+func (e *Editor) shapeCaret() {
+	if t := e.lines.total(); t.shaped == t.entries || !e.isLazy() {
+		return
+	}
+	r := e.rr.runeOffset(e.rr.caret)
+	if l, _, _ := e.lines.find(func(s lineSum) bool { return s.runes <= r }); l.run != nil {
+		p := e.rr.linesBefore(e.rr.caret)
+		e.shapeRun(p, p+1)
+	}
+}
+
+// shapeVisible shapes the paragraphs of the runs near the viewport.
+func (e *Editor) shapeVisible() {
+	if t := e.lines.total(); t.shaped == t.entries || !e.isLazy() {
+		return
+	}
+	top, bottom := e.lazyWindow()
+	changed := false
+	it := e.lines.atY(top)
+	for {
+		l, m, ok := it.next()
+		if !ok {
+			break
+		}
+		y := m.baseline(l.Line)
+		if y-l.Ascent.Ceil() > bottom {
+			break
+		}
+		r := l.run
+		if r == nil || y+l.Descent.Ceil() < top {
+			continue
+		}
+		// Estimate the paragraphs of the run in the range.
+		p0 := m.para + l.runLine(m, top)*r.paras/r.lines
+		p1 := m.para + l.runLine(m, bottom)*r.paras/r.lines + 1
+		oldY := e.scrollOff.Y
+		e.shapeRun(p0, p1)
+		dy := e.scrollOff.Y - oldY
+		top, bottom = top+dy, bottom+dy
+		changed = true
+		it = e.lines.atY(top)
+	}
+	if !changed {
+		return
+	}
+	if e.lines.total().shaped > maxShaped {
+		h := e.viewSize.Y
+		e.evictShaped(top-4*h, bottom+4*h)
+	}
+	e.dims = e.textDims()
+	e.layoutLines()
+	e.scrollRel(0, 0)
+}
+
+// maxShaped is the number of shaped lines above which LazyLayout
+// discards the shaping of paragraphs far from the viewport.
+const maxShaped = 2000
+
+// maxEditShape is the size in bytes of the edited paragraphs above
+// which LazyLayout lays them out as a run instead of shaping them.
+const maxEditShape = 1 << 16
+
+// evictShaped replaces the shaped paragraphs outside the vertical
+// range between top and bottom with runs, except for the paragraph of
+// the caret.
+func (e *Editor) evictShaped(top, bottom int) {
+	p := e.paraAt(e.scrollOff.Y)
+	y := e.paraTop(p)
+	var (
+		entries []lineEntry
+		para    []lineEntry
+		pm      lineMark
+		// start is the byte offset of the run being collected, or
+		// -1.
+		start = -1
+	)
+	it := e.lines.atLine(0)
+	for {
+		l, m, ok := it.next()
+		if !ok {
+			break
+		}
+		if l.run != nil {
+			if start == -1 {
+				start = m.bytes
+			}
+			continue
+		}
+		if len(para) == 0 {
+			pm = m
+		}
+		para = append(para, l)
+		if _, ok := it.entry(); ok && !strings.HasSuffix(l.Layout.Text, "\n") {
+			continue
+		}
+		// The lines of para form a paragraph.
+		ptop := pm.baseline(para[0].Line) - para[0].Ascent.Ceil()
+		pbottom := it.m.y + it.m.prevDesc.Ceil()
+		caret := pm.bytes <= e.rr.caret && e.rr.caret <= it.m.bytes
+		if caret || (pbottom >= top && ptop <= bottom) {
+			if start != -1 {
+				entries = append(entries, e.runEntry(start, pm.bytes))
+				start = -1
+			}
+			entries = append(entries, para...)
+		} else if start == -1 {
+			start = pm.bytes
+		}
+		para = para[:0]
+	}
+	if start != -1 {
+		entries = append(entries, e.runEntry(start, e.rr.len()))
+	}
+	e.lines.set(entries)
+	e.scrollOff.Y += e.paraTop(p) - y
+}
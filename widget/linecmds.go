@@ -0,0 +1,234 @@
+// SPDX-License-Identifier: Unlicense OR MIT
+
+package widget
+
+import (
+	"strings"
+
+	"gioui.org/io/key"
+)
+
+// A Shortcut is a key combination that runs an Editor command.
+type Shortcut struct {
+	// Name is the name of the key, as in key.Event.
+	Name      string
+	Modifiers key.Modifiers
+}
+
+// matches reports whether k is the key combination of s.
+func (s Shortcut) matches(k key.Event) bool {
+	return shortcutName(k) == s.Name && k.Modifiers == s.Modifiers
+}
+
+// lineCommand executes the line command bound to k, if any, and
+// reports whether there was one.
+func (e *Editor) lineCommand(k key.Event) bool {
+	dup := e.DuplicateShortcut
+	if dup == (Shortcut{}) {
+		dup = Shortcut{Name: "D", Modifiers: key.ModShortcut | key.ModShift}
+	}
+	switch {
+	case k.Name == key.NameUpArrow && k.Modifiers == key.ModAlt:
+		e.MoveLines(-1)
+	case k.Name == key.NameDownArrow && k.Modifiers == key.ModAlt:
+		e.MoveLines(1)
+	case dup.matches(k):
+		e.DuplicateLines()
+	default:
+		return false
+	}
+	return true
+}
+
+// selectedLines returns the byte offsets of the starts of the logical
+// lines touched by the selection, or of the line of the caret. A
+// selection ending at the start of a line does not touch it.
+func (e *Editor) selectedLines() []int {
+	start, end := e.selectionBytes()
+	if end > start {
+		if r, s := e.rr.runeBefore(end); r == '\n' {
+			end -= s
+		}
+	}
+	var lines []int
+	l, _ := e.lineBounds(start)
+	for {
+		lines = append(lines, l)
+		_, le := e.lineBounds(l)
+		if le >= end || le >= e.rr.len() {
+			break
+		}
+		l = le + 1
+	}
+	return lines
+}
+
+// ToggleComment comments out the selected lines, or the line of the
+// caret, by prefixing them with CommentToken and a space, aligned
+// with the least indented line. If every non-blank line is already
+// commented out, the prefixes are removed instead. Blank lines are
+// left unchanged, and the change is undone as a single step.
+func (e *Editor) ToggleComment() {
+	tok := e.CommentToken
+	if tok == "" {
+		return
+	}
+	lines := e.selectedLines()
+	indent, comment := -1, false
+	for _, l := range lines {
+		txt, body := e.lineText(l)
+		if body == "" {
+			continue
+		}
+		if n := len(txt) - len(body); indent == -1 || n < indent {
+			indent = n
+		}
+		if !strings.HasPrefix(body, tok) {
+			comment = true
+		}
+	}
+	if indent == -1 {
+		// Only blank lines.
+		return
+	}
+	anchor, caret := e.caret.anchor, e.rr.caret
+	e.BeginGroup()
+	for i := len(lines) - 1; i >= 0; i-- {
+		l := lines[i]
+		txt, body := e.lineText(l)
+		if body == "" {
+			continue
+		}
+		pos, removed, ins := l+indent, 0, tok+" "
+		if !comment {
+			pos, ins = l+len(txt)-len(body), ""
+			removed = len(tok)
+			if strings.HasPrefix(body[len(tok):], " ") {
+				removed++
+			}
+		}
+		e.replace(pos, pos+removed, ins)
+		anchor = adjustOffset(anchor, pos, removed, len(ins), false)
+		caret = adjustOffset(caret, pos, removed, len(ins), false)
+	}
+	e.EndGroup()
+	e.moveCaret(anchor, caret)
+	e.caret.scroll = true
+}
+
+// lineText returns the text of the logical line starting at the byte
+// offset start, without its line terminator, and the text with its
+// indentation removed.
+func (e *Editor) lineText(start int) (txt, body string) {
+	_, end := e.lineBounds(start)
+	txt = e.rr.substring(start, end)
+	return txt, strings.TrimLeft(txt, " \t")
+}
+
+// MoveLines swaps the selected lines, or the line of the caret, with
+// the line above them if distance is negative, or with the line below
+// them otherwise. The selection moves along with the lines, and the
+// move is undone as a single step.
+func (e *Editor) MoveLines(distance int) {
+	lines := e.selectedLines()
+	first := lines[0]
+	_, last := e.lineBounds(lines[len(lines)-1])
+	anchor, caret := e.caret.anchor, e.rr.caret
+	var shift int
+	e.BeginGroup()
+	switch {
+	case distance < 0 && first > 0:
+		prev, _ := e.lineBounds(first - 1)
+		txt := e.rr.substring(prev, first-1)
+		e.replace(last, last, "\n"+txt)
+		e.replace(prev, first, "")
+		shift = prev - first
+	case distance > 0 && last < e.rr.len():
+		_, next := e.lineBounds(last + 1)
+		txt := e.rr.substring(last+1, next)
+		e.replace(first, first, txt+"\n")
+		shift = len(txt) + 1
+		e.replace(last+shift, next+shift, "")
+	}
+	e.EndGroup()
+	e.moveCaret(anchor+shift, caret+shift)
+	e.caret.scroll = true
+}
+
+// DuplicateLines inserts a copy of the selected lines, or of the line
+// of the caret, below them, and moves the selection to the copy.
+func (e *Editor) DuplicateLines() {
+	lines := e.selectedLines()
+	first := lines[0]
+	_, last := e.lineBounds(lines[len(lines)-1])
+	anchor, caret := e.caret.anchor, e.rr.caret
+	txt := e.rr.substring(first, last)
+	e.history.seal()
+	e.replace(last, last, "\n"+txt)
+	e.history.seal()
+	shift := len(txt) + 1
+	e.moveCaret(anchor+shift, caret+shift)
+	e.caret.scroll = true
+}
+
+// indentSelection reports whether Tab and Shift+Tab indent and outdent
+// the selected lines, which they do for a selection spanning lines.
+func (e *Editor) indentSelection() bool {
+	return len(e.carets) == 0 && !e.block.active && len(e.selectedLines()) > 1
+}
+
+// Indent indents the selected lines, or the line of the caret, by a
+// tab character, or by TabWidth spaces if SoftTabs is set. Blank lines
+// are left unchanged, and the change is undone as a single step.
+func (e *Editor) Indent() {
+	ins := "\t"
+	if e.SoftTabs {
+		ins = strings.Repeat(" ", e.tabWidth())
+	}
+	e.indentLines(func(txt string) (int, string) {
+		if strings.TrimLeft(txt, " \t") == "" {
+			return 0, ""
+		}
+		return 0, ins
+	})
+}
+
+// Outdent removes a level of indentation from the selected lines, or
+// from the line of the caret: a leading tab character, or up to
+// TabWidth leading spaces. The change is undone as a single step.
+func (e *Editor) Outdent() {
+	tw := e.tabWidth()
+	e.indentLines(func(txt string) (int, string) {
+		n := 0
+		for n < len(txt) && n < tw && txt[n] == ' ' {
+			n++
+		}
+		if n == 0 && strings.HasPrefix(txt, "\t") {
+			n = 1
+		}
+		return n, ""
+	})
+}
+
+// indentLines replaces the first removed bytes of the selected lines
+// with the inserted text returned by indent for their text, and
+// adjusts the selection.
+func (e *Editor) indentLines(indent func(txt string) (removed int, inserted string)) {
+	lines := e.selectedLines()
+	anchor, caret := e.caret.anchor, e.rr.caret
+	e.BeginGroup()
+	for i := len(lines) - 1; i >= 0; i-- {
+		l := lines[i]
+		txt, _ := e.lineText(l)
+		removed, ins := indent(txt)
+		if removed == 0 && ins == "" {
+			continue
+		}
+		e.replace(l, l+removed, ins)
+		anchor = adjustOffset(anchor, l, removed, len(ins), false)
+		caret = adjustOffset(caret, l, removed, len(ins), false)
+	}
+	e.EndGroup()
+	e.moveCaret(anchor, caret)
+	e.caret.scroll = true
+}
@@ -0,0 +1,83 @@
+// SPDX-License-Identifier: Unlicense OR MIT
+
+package widget
+
+import (
+	"golang.org/x/image/math/fixed"
+)
+
+// A ShapedLine describes the geometry of a line of an Editor, for
+// aligning companion widgets such as gutters and minimaps with it.
+type ShapedLine struct {
+	// Index is the index of the line, as counted by NumLines.
+	Index int
+	// Text is the text of the line as displayed, including its
+	// line terminator. The text of masked editors is masked.
+	Text string
+	// Offset is the rune offset of the start of the line.
+	Offset int
+	// Continuation reports whether the line continues a
+	// soft-wrapped logical line.
+	Continuation bool
+	// X is the horizontal offset of the start of the line due to
+	// its alignment.
+	X fixed.Int26_6
+	// Baseline is the vertical position of the baseline of the
+	// line, relative to the top of the text. Subtract the vertical
+	// ScrollOff for the position relative to the editor.
+	Baseline int
+	Width    fixed.Int26_6
+	Ascent   fixed.Int26_6
+	Descent  fixed.Int26_6
+}
+
+// A ShapedLineIterator iterates over the lines of an Editor. It is
+// invalidated by modifications and layouts of the editor.
+type ShapedLineIterator struct {
+	e  *Editor
+	it lineIter
+}
+
+// ShapedLines returns an iterator over the lines of the editor,
+// starting at the first line that extends below the vertical
+// position top, relative to the top of the text. Lines above top
+// are skipped without iterating over them, so the visible lines are
+// found efficiently by passing the vertical ScrollOff.
+func (e *Editor) ShapedLines(top int) ShapedLineIterator {
+	e.makeValid()
+	it := ShapedLineIterator{e: e, it: e.lines.atY(top)}
+	for {
+		l, ok := it.it.entry()
+		if !ok || it.it.m.baseline(l.Line)+l.Descent.Ceil() >= top {
+			break
+		}
+		it.it.next()
+	}
+	return it
+}
+
+// Next returns the next line, if any.
+func (it *ShapedLineIterator) Next() (ShapedLine, bool) {
+	if it.e == nil {
+		return ShapedLine{}, false
+	}
+	l, m, ok := it.it.next()
+	// Skip the runs of paragraphs not shaped by LazyLayout.
+	for ok && l.run != nil {
+		l, m, ok = it.it.next()
+	}
+	if !ok {
+		return ShapedLine{}, false
+	}
+	return ShapedLine{
+		Index:        m.line,
+		Text:         l.Layout.Text,
+		Offset:       m.runes,
+		Continuation: m.cont,
+		X:            it.e.lineAlign(l),
+		Baseline:     m.baseline(l.Line),
+		Width:        l.Width,
+		Ascent:       l.Ascent,
+		Descent:      l.Descent,
+	}, true
+}
@@ -0,0 +1,578 @@
+// SPDX-License-Identifier: Unlicense OR MIT
+
+package widget
+
+import (
+	"strings"
+
+	"gioui.org/text"
+
+	"golang.org/x/image/math/fixed"
+)
+
+// lineTree holds the laid out lines of an Editor in a treap, a
+// binary tree balanced by random node priorities. Every node holds a
+// chunk of consecutive lines and the totals of its subtree, so lines
+// are found by index, text offset and vertical position, and ranges
+// of lines are replaced, in logarithmic time. Nodes store no absolute
+// positions, so replacing lines doesn't shift the lines after them.
+type lineTree struct {
+	root *lineNode
+	// seed is the state of the generator of node priorities.
+	seed uint32
+}
+
+type lineNode struct {
+	left, right *lineNode
+	prio        uint32
+	lines       []lineEntry
+	// own is the total of lines, and sum the total of the subtree.
+	own, sum lineSum
+}
+
+// lineEntry is a laid out line.
+type lineEntry struct {
+	text.Line
+	// rtl reports whether the paragraph of the line is written right
+	// to left, and bidi is the visual order of the line, if it has
+	// right-to-left text.
+	rtl  bool
+	bidi *bidiLine
+	// run is set for entries that stand for a run of paragraphs not
+	// yet shaped by LazyLayout. The Line of a run is an estimate of
+	// the geometry of all of its lines, and has no Layout.
+	run *lineRun
+}
+
+// lineRun describes the text of a run entry.
+type lineRun struct {
+	runes, bytes int
+	// newlines is the number of newlines, and paras the number of
+	// paragraphs, which exceeds newlines if the run ends the text.
+	newlines, paras int
+	// lines is the estimated number of lines, and pitch the
+	// estimated distance between their baselines.
+	lines, pitch int
+}
+
+// lineSum is the total of a sequence of lines.
+type lineSum struct {
+	// entries is the number of entries, and lines the number of
+	// lines, counting the estimated lines of runs.
+	entries, lines int
+	// newlines is the number of lines ending in a newline, and soft
+	// the number of other lines with text.
+	newlines, soft int
+	runes, bytes   int
+	// open reports whether the last line doesn't end in a newline.
+	open bool
+	// shaped is the number of entries that are not runs.
+	shaped int
+	// span is the distance between the first and last baselines,
+	// asc the ascent of the first line and desc the descent of the
+	// last line.
+	span      int
+	asc, desc fixed.Int26_6
+	width     fixed.Int26_6
+}
+
+// maxChunk is the maximum number of entries of a node.
+const maxChunk = 64
+
+// sum returns the total of the line.
+func (l lineEntry) sum() lineSum {
+	s := lineSum{
+		entries: 1,
+		lines:   1,
+		shaped:  1,
+		runes:   len(l.Layout.Advances),
+		bytes:   len(l.Layout.Text),
+		asc:     l.Ascent,
+		desc:    l.Descent,
+		width:   l.Width,
+	}
+	if r := l.run; r != nil {
+		s.lines = r.lines
+		s.shaped = 0
+		s.runes, s.bytes = r.runes, r.bytes
+		s.newlines = r.newlines
+		s.soft = r.lines - r.paras
+		s.open = r.paras > r.newlines
+		// The estimated lines are spread over the ascent.
+		s.asc -= fixed.I((r.lines - 1) * r.pitch)
+		s.span = (r.lines - 1) * r.pitch
+		return s
+	}
+	switch txt := l.Layout.Text; {
+	case strings.HasSuffix(txt, "\n"):
+		s.newlines = 1
+	case txt != "":
+		s.soft = 1
+		s.open = true
+	default:
+		s.open = true
+	}
+	return s
+}
+
+// add returns the total of the lines of s followed by the lines of t.
+func (s lineSum) add(t lineSum) lineSum {
+	if s.entries == 0 {
+		return t
+	}
+	if t.entries == 0 {
+		return s
+	}
+	s.span += (s.desc + t.asc).Ceil() + t.span
+	s.desc = t.desc
+	s.open = t.open
+	s.entries += t.entries
+	s.lines += t.lines
+	s.shaped += t.shaped
+	s.newlines += t.newlines
+	s.soft += t.soft
+	s.runes += t.runes
+	s.bytes += t.bytes
+	if t.width > s.width {
+		s.width = t.width
+	}
+	return s
+}
+
+// height returns the height of the lines, from the top of the first
+// to the bottom of the last.
+func (s lineSum) height() int {
+	if s.entries == 0 {
+		return 0
+	}
+	return s.asc.Ceil() + s.span + s.desc.Ceil()
+}
+
+// mark returns the mark of the line following the lines of s.
+func (s lineSum) mark() lineMark {
+	m := lineMark{line: s.lines, para: s.newlines, cont: s.open, runes: s.runes, bytes: s.bytes}
+	if s.entries > 0 {
+		m.y = s.asc.Ceil() + s.span
+		m.prevDesc = s.desc
+	}
+	return m
+}
+
+// lineMark records the layout state at the start of a line, for
+// starting iterations over the lines there.
+type lineMark struct {
+	// line is the index of the line, and para the index of its
+	// paragraph.
+	line, para int
+	// cont reports whether the line continues a soft-wrapped
+	// paragraph.
+	cont bool
+	// y and prevDesc are the baseline and descent of the line
+	// before.
+	y        int
+	prevDesc fixed.Int26_6
+	// runes and bytes are the offsets of the line in the laid out
+	// text.
+	runes, bytes int
+}
+
+// baseline returns the baseline of the line l starting at m.
+func (m lineMark) baseline(l text.Line) int {
+	return m.y + (m.prevDesc + l.Ascent).Ceil()
+}
+
+func (n *lineNode) len() int {
+	if n == nil {
+		return 0
+	}
+	return n.sum.entries
+}
+
+func (n *lineNode) total() lineSum {
+	if n == nil {
+		return lineSum{}
+	}
+	return n.sum
+}
+
+// update recomputes the total of the subtree of n.
+func (n *lineNode) update() {
+	n.sum = n.left.total().add(n.own).add(n.right.total())
+}
+
+// updateOwn recomputes the totals of the lines of n.
+func (n *lineNode) updateOwn() {
+	var s lineSum
+	for i := range n.lines {
+		s = s.add(n.lines[i].sum())
+	}
+	n.own = s
+	n.update()
+}
+
+// newNode returns a node for the lines.
+func (t *lineTree) newNode(lines []lineEntry) *lineNode {
+	// Generate priorities with xorshift.
+	if t.seed == 0 {
+		t.seed = 2463534242
+	}
+	t.seed ^= t.seed << 13
+	t.seed ^= t.seed >> 17
+	t.seed ^= t.seed << 5
+	n := &lineNode{prio: t.seed, lines: lines}
+	n.updateOwn()
+	return n
+}
+
+// build returns a tree of the lines.
+func (t *lineTree) build(lines []lineEntry) *lineNode {
+	var (
+		root *lineNode
+		// spine is the right spine of the tree built so far.
+		spine []*lineNode
+	)
+	for len(lines) > 0 {
+		c := len(lines)
+		if c > maxChunk {
+			c = maxChunk
+		}
+		n := t.newNode(append([]lineEntry(nil), lines[:c]...))
+		lines = lines[c:]
+		// Insert n at the end of the tree, keeping the heap order
+		// of the priorities.
+		var last *lineNode
+		for len(spine) > 0 && spine[len(spine)-1].prio < n.prio {
+			last = spine[len(spine)-1]
+			spine = spine[:len(spine)-1]
+		}
+		n.left = last
+		if len(spine) > 0 {
+			spine[len(spine)-1].right = n
+		} else {
+			root = n
+		}
+		spine = append(spine, n)
+	}
+	// Update the totals bottom up.
+	var update func(n *lineNode)
+	update = func(n *lineNode) {
+		if n == nil {
+			return
+		}
+		update(n.left)
+		update(n.right)
+		n.update()
+	}
+	update(root)
+	return root
+}
+
+// set replaces all lines with lines.
+func (t *lineTree) set(lines []lineEntry) {
+	t.root = t.build(lines)
+}
+
+// total returns the total of all lines.
+func (t *lineTree) total() lineSum {
+	return t.root.total()
+}
+
+// len returns the number of lines.
+func (t *lineTree) len() int {
+	return t.total().lines
+}
+
+// entry returns the line with index i.
+func (t *lineTree) entry(i int) lineEntry {
+	e, _, _ := t.find(func(s lineSum) bool { return s.lines <= i })
+	return e
+}
+
+// ends returns the first and last lines, for textPadding and
+// baselineY.
+func (t *lineTree) ends() []text.Line {
+	switch n := t.len(); n {
+	case 0:
+		return nil
+	case 1:
+		return []text.Line{t.entry(0).Line}
+	default:
+		return []text.Line{t.entry(0).Line, t.entry(n - 1).Line}
+	}
+}
+
+// find returns the last entry, its index and the total of the
+// entries before it, such that pred is true for the total. Pred must
+// be true for the empty total, and true for the totals of a prefix
+// of the entries. Find returns the zero entry if the tree is empty.
+func (t *lineTree) find(pred func(s lineSum) bool) (lineEntry, int, lineSum) {
+	var (
+		best   lineEntry
+		idx    int
+		prefix lineSum
+		before lineSum
+		base   int
+	)
+	n := t.root
+	for n != nil {
+		s := before.add(n.left.total())
+		if !pred(s) {
+			n = n.left
+			continue
+		}
+		base += n.left.len()
+		for i := range n.lines {
+			if !pred(s) {
+				return best, idx, prefix
+			}
+			best, idx, prefix = n.lines[i], base+i, s
+			s = s.add(n.lines[i].sum())
+		}
+		before = s
+		base += len(n.lines)
+		n = n.right
+	}
+	return best, idx, prefix
+}
+
+// seek returns an iterator starting at the last entry for which pred
+// is true for the total of the entries before it, as for find.
+func (t *lineTree) seek(pred func(s lineSum) bool) lineIter {
+	_, idx, prefix := t.find(pred)
+	it := t.iterAt(idx)
+	it.m = prefix.mark()
+	return it
+}
+
+// iterAt returns an iterator starting at the entry with index idx.
+// The mark of the iterator is not set.
+func (t *lineTree) iterAt(idx int) lineIter {
+	var it lineIter
+	n := t.root
+	for n != nil {
+		l := n.left.len()
+		switch {
+		case idx < l:
+			it.stack = append(it.stack, lineFrame{node: n})
+			n = n.left
+		case idx < l+len(n.lines):
+			it.stack = append(it.stack, lineFrame{node: n, i: idx - l})
+			return it
+		default:
+			idx -= l + len(n.lines)
+			n = n.right
+		}
+	}
+	return it
+}
+
+// atLine returns an iterator starting at the line with index i.
+func (t *lineTree) atLine(i int) lineIter {
+	return t.seek(func(s lineSum) bool { return s.lines <= i })
+}
+
+// atRune returns an iterator starting at the line containing the
+// rune offset r. Offsets at the boundary of two lines are in the
+// second line.
+func (t *lineTree) atRune(r int) lineIter {
+	return t.seek(func(s lineSum) bool { return s.runes <= r })
+}
+
+// atY returns an iterator starting at the last line whose top is
+// above the vertical position y.
+func (t *lineTree) atY(y int) lineIter {
+	return t.seek(func(s lineSum) bool {
+		m := s.mark()
+		return s.entries == 0 || m.y+m.prevDesc.Ceil() < y
+	})
+}
+
+// atPara returns an iterator starting at the entry containing the
+// start of the paragraph with index p: its first line, or the run
+// containing it. The iterator starts at the last entry if there is no
+// such paragraph.
+func (t *lineTree) atPara(p int) lineIter {
+	return t.seek(paraStart(p))
+}
+
+// paraStart returns the predicate for finding the entry containing
+// the start of the paragraph with index p.
+func paraStart(p int) func(s lineSum) bool {
+	return func(s lineSum) bool {
+		return s.newlines < p || s.newlines == p && !s.open
+	}
+}
+
+// replace replaces the entries between the indices i and j with
+// lines.
+func (t *lineTree) replace(i, j int, lines []lineEntry) {
+	a, rest := t.split(t.root, i)
+	_, c := t.split(rest, j-i)
+	// Rebuild the nodes at the ends of the range along with the
+	// new lines, so edits don't fragment the tree into small nodes.
+	if n := t.lastChunk(a); n > 0 {
+		var tail *lineNode
+		a, tail = t.split(a, a.len()-n)
+		lines = append(entries(tail), lines...)
+	}
+	if n := t.firstChunk(c); n > 0 {
+		var head *lineNode
+		head, c = t.split(c, n)
+		lines = append(lines, entries(head)...)
+	}
+	t.root = t.merge(t.merge(a, t.build(lines)), c)
+}
+
+// entries returns the entries of the subtree of n.
+func entries(n *lineNode) []lineEntry {
+	var lines []lineEntry
+	var walk func(n *lineNode)
+	walk = func(n *lineNode) {
+		if n == nil {
+			return
+		}
+		walk(n.left)
+		lines = append(lines, n.lines...)
+		walk(n.right)
+	}
+	walk(n)
+	return lines
+}
+
+// lastChunk returns the number of entries of the last node of the
+// subtree of n.
+func (t *lineTree) lastChunk(n *lineNode) int {
+	if n == nil {
+		return 0
+	}
+	for n.right != nil {
+		n = n.right
+	}
+	return len(n.lines)
+}
+
+// firstChunk returns the number of entries of the first node of the
+// subtree of n.
+func (t *lineTree) firstChunk(n *lineNode) int {
+	if n == nil {
+		return 0
+	}
+	for n.left != nil {
+		n = n.left
+	}
+	return len(n.lines)
+}
+
+// split splits the subtree of n into the trees of the first k
+// entries and the rest.
+func (t *lineTree) split(n *lineNode, k int) (*lineNode, *lineNode) {
+	if n == nil {
+		return nil, nil
+	}
+	l := n.left.len()
+	switch {
+	case k <= l:
+		a, b := t.split(n.left, k)
+		n.left = b
+		n.update()
+		return a, n
+	case k >= l+len(n.lines):
+		a, b := t.split(n.right, k-l-len(n.lines))
+		n.right = a
+		n.update()
+		return n, b
+	default:
+		// Split the lines of n.
+		c := k - l
+		m := t.newNode(append([]lineEntry(nil), n.lines[c:]...))
+		right := n.right
+		n.lines = n.lines[:c:c]
+		n.right = nil
+		n.updateOwn()
+		return n, t.merge(m, right)
+	}
+}
+
+// merge returns the tree of the entries of a followed by the entries
+// of b.
+func (t *lineTree) merge(a, b *lineNode) *lineNode {
+	switch {
+	case a == nil:
+		return b
+	case b == nil:
+		return a
+	case a.prio > b.prio:
+		a.right = t.merge(a.right, b)
+		a.update()
+		return a
+	default:
+		b.left = t.merge(a, b.left)
+		b.update()
+		return b
+	}
+}
+
+// slice returns all lines.
+func (t *lineTree) slice() []lineEntry {
+	return entries(t.root)
+}
+
+// lineIter iterates over the entries of a lineTree in order.
+type lineIter struct {
+	stack []lineFrame
+	// m is the mark of the next entry.
+	m lineMark
+}
+
+// lineFrame is the position of an iterator in a node.
+type lineFrame struct {
+	node *lineNode
+	// i is the index of the next entry of node.
+	i int
+}
+
+// entry returns the next entry without advancing.
+func (it *lineIter) entry() (lineEntry, bool) {
+	if len(it.stack) == 0 {
+		return lineEntry{}, false
+	}
+	f := it.stack[len(it.stack)-1]
+	return f.node.lines[f.i], true
+}
+
+// next returns the next entry and its mark.
+func (it *lineIter) next() (lineEntry, lineMark, bool) {
+	e, ok := it.entry()
+	if !ok {
+		return lineEntry{}, lineMark{}, false
+	}
+	m := it.m
+	it.m = m.add(e.sum())
+	top := &it.stack[len(it.stack)-1]
+	top.i++
+	if top.i == len(top.node.lines) {
+		// Continue with the leftmost node of the right subtree,
+		// or the nearest ancestor not yet visited.
+		n := top.node.right
+		it.stack = it.stack[:len(it.stack)-1]
+		for ; n != nil; n = n.left {
+			it.stack = append(it.stack, lineFrame{node: n})
+		}
+	}
+	return e, m, true
+}
+
+// add returns the mark following the lines of s, starting at m.
+func (m lineMark) add(s lineSum) lineMark {
+	if s.entries == 0 {
+		return m
+	}
+	m.y += (m.prevDesc + s.asc).Ceil() + s.span
+	m.prevDesc = s.desc
+	m.cont = s.open
+	m.line += s.lines
+	m.para += s.newlines
+	m.runes += s.runes
+	m.bytes += s.bytes
+	return m
+}
@@ -0,0 +1,148 @@
+// SPDX-License-Identifier: Unlicense OR MIT
+
+package widget
+
+import (
+	"image"
+	"regexp"
+	"strings"
+	"unicode/utf8"
+
+	"gioui.org/layout"
+	"gioui.org/op"
+	"gioui.org/op/clip"
+	"gioui.org/op/paint"
+
+	"golang.org/x/image/math/fixed"
+)
+
+// A LinkClickEvent is generated when a link detected in the editor
+// contents is clicked with the shortcut modifier held, or tapped, or
+// when a link of a Selectable is clicked.
+type LinkClickEvent struct {
+	// URL is the text of the link.
+	URL string
+	// Start and End are the rune offsets of the link.
+	Start, End int
+	// Value is the Value of a LabelLink.
+	Value interface{}
+}
+
+// A LabelLink marks a range of the text of a Label as a link.
+type LabelLink struct {
+	// Start and End are the rune offsets of the link.
+	Start, End int
+	// Value is the metadata of the link, such as its target,
+	// reported in LinkClickEvents.
+	Value interface{}
+}
+
+// link is the range of a detected link in rune offsets.
+type link struct {
+	start, end int
+}
+
+// linkPattern matches URLs with a scheme or starting with "www.".
+var linkPattern = regexp.MustCompile(`(?i)\b(?:[a-z][a-z0-9+.-]*://|www\.)[^\s<>"]+`)
+
+// findLinks returns the links in s.
+func findLinks(s string) []link {
+	var links []link
+	for _, m := range linkPattern.FindAllStringIndex(s, -1) {
+		// Leave out trailing punctuation, which more likely ends
+		// the sentence than the link.
+		url := strings.TrimRight(s[m[0]:m[1]], ".,;:!?'")
+		if strings.HasSuffix(url, ")") && !strings.Contains(url, "(") {
+			url = url[:len(url)-1]
+		}
+		start := utf8.RuneCountInString(s[:m[0]])
+		links = append(links, link{start: start, end: start + utf8.RuneCountInString(url)})
+	}
+	return links
+}
+
+// layoutLinks detects the links in the editor contents.
+func (e *Editor) layoutLinks() {
+	e.links = e.links[:0]
+	if !e.DetectLinks || e.Mask != 0 {
+		return
+	}
+	e.links = findLinks(e.rr.String())
+}
+
+// linkAt returns the link containing the rune offset r, if any.
+func (e *Editor) linkAt(r int) (link, bool) {
+	for _, l := range e.links {
+		if l.start <= r && r < l.end {
+			return l, true
+		}
+	}
+	return link{}, false
+}
+
+// clickLink generates a LinkClickEvent if the text area position pos
+// is over a link, and reports whether it did.
+func (e *Editor) clickLink(pos image.Point) bool {
+	if len(e.links) == 0 {
+		return false
+	}
+	l, ok := e.linkAt(e.rr.runeOffset(e.offsetAt(pos)))
+	if !ok {
+		return false
+	}
+	start := e.rr.moveRunes(0, l.start)
+	end := e.rr.moveRunes(start, l.end-l.start)
+	e.events = append(e.events, LinkClickEvent{
+		URL:   e.rr.substring(start, end),
+		Start: l.start,
+		End:   l.end,
+	})
+	return true
+}
+
+// paintLinks underlines the links in a shaped line, in the current
+// color.
+func (e *Editor) paintLinks(gtx layout.Context, shape line) {
+	if len(e.links) == 0 {
+		return
+	}
+	thickness := (e.textSize / 16).Ceil()
+	if thickness < 1 {
+		thickness = 1
+	}
+	var x, start fixed.Int26_6
+	inLink := false
+	underline := func(end fixed.Int26_6) {
+		stack := op.Push(gtx.Ops)
+		clip.Rect(image.Rect(start.Floor(), thickness, end.Ceil(), 2*thickness)).Add(gtx.Ops)
+		paint.PaintOp{}.Add(gtx.Ops)
+		stack.Pop()
+	}
+	for i, adv := range shape.layout.Advances {
+		_, ok := e.linkAt(shape.rune(i))
+		switch {
+		case ok && !inLink:
+			start = x
+		case !ok && inLink:
+			underline(x)
+		}
+		inLink = ok
+		x += adv
+	}
+	if inLink {
+		underline(x)
+	}
+}
+
+// linkAt returns the link of the label containing the rune offset r,
+// if any. Later links take precedence.
+func (l Label) linkAt(r int) (LabelLink, bool) {
+	for i := len(l.Links) - 1; i >= 0; i-- {
+		if ll := l.Links[i]; ll.Start <= r && r < ll.End {
+			return ll, true
+		}
+	}
+	return LabelLink{}, false
+}
+
+func (LinkClickEvent) isEditorEvent() {}
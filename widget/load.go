@@ -0,0 +1,321 @@
+// SPDX-License-Identifier: Unlicense OR MIT
+
+package widget
+
+import (
+	"bytes"
+	"io"
+	"sort"
+	"strings"
+	"sync"
+	"unicode/utf8"
+
+	"gioui.org/layout"
+	"gioui.org/op"
+)
+
+// A LoadEvent is generated when the contents set by SetReaderAt have
+// been loaded, or loading failed, and when paging in the loaded
+// contents failed.
+type LoadEvent struct {
+	// Err is the error that stopped the loading or paging, or nil.
+	Err error
+}
+
+// loader is the state of the contents being loaded.
+type loader struct {
+	// r is the source of SetReaderAt, and off and size the range
+	// left to load.
+	r         io.ReaderAt
+	off, size int64
+	buf       []byte
+	// pending is the end of the previous chunk, held back because
+	// it may be completed by the next.
+	pending []byte
+	// detected tracks whether the line ending has been detected.
+	detected bool
+}
+
+// loadChunk is the number of bytes loaded per frame by SetReaderAt,
+// and read at a time by ReadFrom.
+const loadChunk = 1 << 20
+
+// SetReaderAt replaces the contents of the editor with the size bytes
+// of r, and clears the undo history. The contents are indexed
+// progressively, a chunk per frame, and laid out as they arrive. A
+// LoadEvent is generated when the loading completes.
+//
+// The editor doesn't keep the contents in memory. It reads them from
+// r when they are displayed or otherwise needed, and keeps only its
+// edits and recently read parts, so r may be larger than memory, such
+// as a memory-mapped file. Contents with carriage returns are the
+// exception, because their line terminators are converted, and are
+// copied into memory. The contents must not change while the editor
+// uses them. In particular, the contents of the editor must not be
+// written back to r while the editor reads from it. Use LazyLayout
+// to also shape only the displayed contents.
+//
+// The line ending convention is detected from the contents. Loading
+// is cancelled by SetText, ReadFrom or another SetReaderAt.
+func (e *Editor) SetReaderAt(r io.ReaderAt, size int64) {
+	e.SetText("")
+	e.load.r, e.load.size = r, size
+	e.rr.file = &pagedFile{r: r}
+}
+
+// ReadFrom replaces the contents of the editor with the data read
+// from r until EOF, and clears the undo history. The data is read in
+// chunks directly into the editor buffer. The line ending convention
+// is detected from the data.
+//
+// ReadFrom implements io.ReaderFrom.
+func (e *Editor) ReadFrom(r io.Reader) (int64, error) {
+	e.SetText("")
+	buf := make([]byte, loadChunk)
+	var n int64
+	for {
+		m, err := r.Read(buf)
+		n += int64(m)
+		e.appendChunk(buf[:m], false)
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			e.appendChunk(nil, true)
+			return n, err
+		}
+	}
+	e.appendChunk(nil, true)
+	return n, nil
+}
+
+// loadNext loads the next chunk of the contents set by SetReaderAt.
+func (e *Editor) loadNext(gtx layout.Context) {
+	if f := e.rr.file; f != nil {
+		if err := f.takeErr(); err != nil {
+			e.events = append(e.events, LoadEvent{Err: err})
+		}
+	}
+	l := &e.load
+	if l.r == nil {
+		return
+	}
+	n := l.size - l.off
+	if n > loadChunk {
+		n = loadChunk
+	}
+	if int64(cap(l.buf)) < n {
+		l.buf = make([]byte, n)
+	}
+	m, err := l.r.ReadAt(l.buf[:n], l.off)
+	done := err == io.EOF || (err == nil && l.off+int64(m) >= l.size)
+	p := l.buf[:m]
+	if !done {
+		p = p[:chunkEnd(p)]
+	}
+	e.appendFile(l.off, p, done)
+	l.off += int64(len(p))
+	if done {
+		err = nil
+		l.off = l.size
+	}
+	if err != nil || l.off == l.size {
+		e.appendChunk(nil, true)
+		e.load = loader{}
+		e.events = append(e.events, LoadEvent{Err: err})
+		return
+	}
+	op.InvalidateOp{}.Add(gtx.Ops)
+}
+
+// appendFile appends p, read from the offset off of the contents set
+// by SetReaderAt. Text that needs no conversion is paged in from the
+// contents when needed instead of copied; the other text is appended
+// by appendChunk. Final is set for the end of the contents.
+func (e *Editor) appendFile(off int64, p []byte, final bool) {
+	l := &e.load
+	if e.SingleLine || e.MaxLen > 0 || len(l.pending) > 0 || bytes.IndexByte(p, '\r') != -1 {
+		e.appendChunk(p, final)
+		return
+	}
+	if !l.detected && (final || bytes.IndexByte(p, '\n') != -1) {
+		// Without carriage returns, lines end in newlines.
+		e.lineEnding = LineEndingLF
+		l.detected = true
+	}
+	if len(p) == 0 {
+		return
+	}
+	end := e.rr.len()
+	e.adjustRanges(end, end, string(p))
+	e.rr.extendFile(int(off), p)
+	e.appended(end, len(p))
+}
+
+// chunkEnd returns the length of p without a trailing partial rune or
+// carriage return, which may be completed by the next chunk.
+func chunkEnd(p []byte) int {
+	if n := len(p); n > 0 && p[n-1] == '\r' {
+		return n - 1
+	}
+	i := len(p)
+	for j := 0; j < utf8.UTFMax && i > 0; j++ {
+		i--
+		if utf8.RuneStart(p[i]) {
+			if utf8.FullRune(p[i:]) {
+				return len(p)
+			}
+			return i
+		}
+	}
+	return len(p)
+}
+
+// appendChunk appends p to the contents, leaving the caret and the
+// undo history unchanged. Unless final is set, a trailing partial
+// rune or carriage return is held back until the next chunk.
+func (e *Editor) appendChunk(p []byte, final bool) {
+	l := &e.load
+	s := string(l.pending) + string(p)
+	l.pending = l.pending[:0]
+	if !final {
+		keep := len(s)
+		if strings.HasSuffix(s, "\r") {
+			keep = len(s) - 1
+		} else if !utf8.FullRuneInString(s[lastRuneStart(s):]) {
+			keep = lastRuneStart(s)
+		}
+		l.pending = append(l.pending, s[keep:]...)
+		s = s[:keep]
+	}
+	if !l.detected {
+		if strings.Contains(s, "\n") || final {
+			e.lineEnding = detectLineEnding(s)
+			l.detected = true
+		}
+	}
+	s = normalizeNewlines(s)
+	if e.SingleLine {
+		s = strings.ReplaceAll(s, "\n", " ")
+	}
+	if e.MaxLen > 0 {
+		s, _ = e.limitLen(s, 0)
+	}
+	if s == "" {
+		return
+	}
+	end := e.rr.len()
+	e.adjustRanges(end, end, s)
+	e.rr.extend(s)
+	e.appended(end, len(s))
+}
+
+// appended records that n bytes were appended at the byte offset
+// end.
+func (e *Editor) appended(end, n int) {
+	e.rev.cur = e.rev.next()
+	e.find.stale = true
+	e.relayout.edit(end, end, n)
+	e.valid = false
+}
+
+// lastRuneStart returns the index of the start of the last rune in s,
+// which may be incomplete.
+func lastRuneStart(s string) int {
+	i := len(s)
+	for j := 0; j < utf8.UTFMax && i > 0; j++ {
+		i--
+		if utf8.RuneStart(s[i]) {
+			return i
+		}
+	}
+	return len(s)
+}
+
+// pagedFile is the source of the text of the pieces of an editBuffer
+// referring to the contents set by SetReaderAt. The text is read a
+// block at a time, and the most recently used blocks are cached.
+type pagedFile struct {
+	r io.ReaderAt
+
+	// mu guards the fields below, because readers of the buffer
+	// may read from other goroutines.
+	mu sync.Mutex
+	// blocks are the ranges of the file read at a time, in order.
+	// Pieces never span blocks.
+	blocks []fileBlock
+	// cache holds the text of recently used blocks, the most
+	// recently used last.
+	cache []cachedBlock
+	// err is the first unreported read error.
+	err error
+}
+
+type fileBlock struct {
+	off, len int
+}
+
+type cachedBlock struct {
+	block int
+	text  []byte
+}
+
+// maxCachedBlocks is the number of blocks cached by a pagedFile.
+const maxCachedBlocks = 32
+
+// addBlock adds the block of n bytes at off, which must follow the
+// other blocks.
+func (f *pagedFile) addBlock(off, n int) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.blocks = append(f.blocks, fileBlock{off: off, len: n})
+}
+
+// slice returns the n bytes at off, which must be within a block.
+// Text that can't be read is returned as zero bytes.
+func (f *pagedFile) slice(off, n int) []byte {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	i := sort.Search(len(f.blocks), func(i int) bool {
+		b := f.blocks[i]
+		return off < b.off+b.len
+	})
+	b := f.blocks[i]
+	return f.text(i)[off-b.off : off-b.off+n]
+}
+
+// text returns the text of the block with index i, reading it if it
+// is not cached.
+func (f *pagedFile) text(i int) []byte {
+	for j := len(f.cache) - 1; j >= 0; j-- {
+		if c := f.cache[j]; c.block == i {
+			copy(f.cache[j:], f.cache[j+1:])
+			f.cache[len(f.cache)-1] = c
+			return c.text
+		}
+	}
+	b := f.blocks[i]
+	txt := make([]byte, b.len)
+	if n, err := f.r.ReadAt(txt, int64(b.off)); n < len(txt) && f.err == nil {
+		if err == nil || err == io.EOF {
+			err = io.ErrUnexpectedEOF
+		}
+		f.err = err
+	}
+	if len(f.cache) == maxCachedBlocks {
+		f.cache = append(f.cache[:0], f.cache[1:]...)
+	}
+	f.cache = append(f.cache, cachedBlock{block: i, text: txt})
+	return txt
+}
+
+// takeErr returns and clears the first unreported read error.
+func (f *pagedFile) takeErr() error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	err := f.err
+	f.err = nil
+	return err
+}
+
+func (LoadEvent) isEditorEvent() {}
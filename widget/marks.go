@@ -0,0 +1,124 @@
+// SPDX-License-Identifier: Unlicense OR MIT
+
+package widget
+
+import (
+	"image"
+	"image/color"
+
+	"gioui.org/f32"
+	"gioui.org/layout"
+	"gioui.org/op"
+	"gioui.org/op/clip"
+	"gioui.org/op/paint"
+)
+
+// A Mark flags a range of the editor contents, such as a misspelled
+// word, with a squiggly underline.
+type Mark struct {
+	// Start and End are the rune offsets of the marked text.
+	Start, End int
+	// Color is the color of the underline.
+	Color color.NRGBA
+}
+
+// A MarksEvent is generated when an edit touches marked text and
+// the touched marks are removed. Start and End are the rune offsets
+// of the text to check again: the edited text and the text of the
+// removed marks.
+type MarksEvent struct {
+	Start, End int
+}
+
+// SetMarks replaces the marks of the editor. Marks are moved when
+// the text before them is edited, and removed when the marked text
+// is edited.
+func (e *Editor) SetMarks(marks []Mark) {
+	e.marks = append(e.marks[:0], marks...)
+}
+
+// Marks returns the marks of the editor.
+func (e *Editor) Marks() []Mark {
+	return e.marks
+}
+
+// adjustMarks updates the marks for the replacement of removed runes
+// at the rune offset start with inserted runes.
+func (e *Editor) adjustMarks(start, removed, inserted int) {
+	marks := e.marks[:0]
+	evt := MarksEvent{Start: start, End: start + inserted}
+	touched := false
+	for _, m := range e.marks {
+		if m.Start <= start+removed && start <= m.End {
+			// Editing inside or next to a mark invalidates it.
+			touched = true
+			if s := adjustOffset(m.Start, start, removed, inserted, false); s < evt.Start {
+				evt.Start = s
+			}
+			if end := adjustOffset(m.End, start, removed, inserted, true); end > evt.End {
+				evt.End = end
+			}
+			continue
+		}
+		m.Start = adjustOffset(m.Start, start, removed, inserted, true)
+		m.End = adjustOffset(m.End, start, removed, inserted, false)
+		marks = append(marks, m)
+	}
+	e.marks = marks
+	if touched {
+		e.events = append(e.events, evt)
+	}
+}
+
+// paintMarks paints the squiggly underlines of the marks visible
+// through the clip rectangle cl.
+func (e *Editor) paintMarks(gtx layout.Context, cl image.Rectangle) {
+	if len(e.marks) == 0 {
+		return
+	}
+	defer op.Push(gtx.Ops).Pop()
+	clip.Rect(cl).Add(gtx.Ops)
+	thickness := (e.textSize / 16).Ceil()
+	if thickness < 1 {
+		thickness = 1
+	}
+	for _, m := range e.marks {
+		start := e.rr.moveRunes(0, m.Start)
+		end := e.rr.moveRunes(start, m.End-m.Start)
+		for _, r := range e.lineRegions(start, end, false) {
+			r = r.Sub(e.scrollOff)
+			if r.Intersect(cl).Empty() {
+				continue
+			}
+			drawSquiggle(gtx, m.Color, r.Min.X, r.Max.X, r.Max.Y-thickness, float32(thickness))
+		}
+	}
+}
+
+// drawSquiggle draws a zigzag line of width w from x0 to x1, with
+// the bottom peaks at y.
+func drawSquiggle(gtx layout.Context, col color.NRGBA, x0, x1, y int, w float32) {
+	defer op.Push(gtx.Ops).Pop()
+	amp := 1.5 * w
+	var p clip.Path
+	p.Begin(gtx.Ops)
+	p.MoveTo(f32.Point{X: float32(x0), Y: float32(y) - amp})
+	up := false
+	for x := float32(x0); x < float32(x1); {
+		x += 2 * amp
+		py := float32(y)
+		if up {
+			py -= amp
+		}
+		p.LineTo(f32.Point{X: x, Y: py})
+		up = !up
+	}
+	clip.Stroke{
+		Path:  p.End(),
+		Style: clip.StrokeStyle{Width: w},
+	}.Op().Add(gtx.Ops)
+	paint.ColorOp{Color: col}.Add(gtx.Ops)
+	paint.PaintOp{}.Add(gtx.Ops)
+}
+
+func (MarksEvent) isEditorEvent() {}
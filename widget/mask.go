@@ -0,0 +1,50 @@
+// SPDX-License-Identifier: Unlicense OR MIT
+
+package widget
+
+import (
+	"time"
+	"unicode/utf8"
+
+	"gioui.org/layout"
+	"gioui.org/op"
+)
+
+// maskReveal is the state of the rune shown unmasked because of
+// MaskReveal.
+type maskReveal struct {
+	active bool
+	// rune is the rune offset of the revealed rune.
+	rune  int
+	until time.Time
+}
+
+// revealTyped reveals the rune before the caret if s, the typed
+// text, is a single rune.
+func (e *Editor) revealTyped(gtx layout.Context, s string) {
+	if e.Mask == 0 || e.MaskReveal <= 0 || len(e.carets) > 0 || s == "\n" || utf8.RuneCountInString(s) != 1 {
+		return
+	}
+	e.reveal = maskReveal{
+		active: true,
+		rune:   e.rr.runeOffset(e.rr.caret) - 1,
+		until:  gtx.Now.Add(e.MaskReveal),
+	}
+	e.invalidate()
+	op.InvalidateOp{At: e.reveal.until}.Add(gtx.Ops)
+}
+
+// hideRevealed masks the revealed rune, if any.
+func (e *Editor) hideRevealed() {
+	if e.reveal.active {
+		e.reveal = maskReveal{}
+		e.invalidate()
+	}
+}
+
+// expireReveal masks the revealed rune when its time is up.
+func (e *Editor) expireReveal(gtx layout.Context) {
+	if e.reveal.active && !gtx.Now.Before(e.reveal.until) {
+		e.hideRevealed()
+	}
+}
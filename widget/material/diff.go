@@ -0,0 +1,67 @@
+// SPDX-License-Identifier: Unlicense OR MIT
+
+package material
+
+import (
+	"image/color"
+
+	"gioui.org/internal/f32color"
+	"gioui.org/layout"
+	"gioui.org/op"
+	"gioui.org/op/paint"
+	"gioui.org/text"
+	"gioui.org/unit"
+	"gioui.org/widget"
+)
+
+// DiffEditorStyle draws the editors of a DiffEditor, with the changed
+// lines and the line gaps tinted.
+type DiffEditorStyle struct {
+	Font     text.Font
+	TextSize unit.Value
+	// RemovedColor tints the lines removed from the old text.
+	RemovedColor color.NRGBA
+	// AddedColor tints the lines added to the new text.
+	AddedColor color.NRGBA
+	// GapColor tints the line gaps that align the editors.
+	GapColor color.NRGBA
+	Editor   *widget.DiffEditor
+
+	theme *Theme
+}
+
+func DiffEditor(th *Theme, editor *widget.DiffEditor) DiffEditorStyle {
+	return DiffEditorStyle{
+		Editor:       editor,
+		TextSize:     th.TextSize,
+		RemovedColor: color.NRGBA{R: 0xe0, G: 0x30, B: 0x30, A: 0x40},
+		AddedColor:   color.NRGBA{R: 0x30, G: 0xb0, B: 0x40, A: 0x40},
+		GapColor:     f32color.MulAlpha(th.Palette.Fg, 0x18),
+		theme:        th,
+	}
+}
+
+func (d DiffEditorStyle) Layout(gtx layout.Context) layout.Dimensions {
+	return d.Editor.Layout(gtx, func(gtx layout.Context, e *widget.Editor) layout.Dimensions {
+		es := Editor(d.theme, e, "")
+		es.Font = d.Font
+		es.TextSize = d.TextSize
+		// Lay out the editor before painting the line backgrounds
+		// behind it.
+		m := op.Record(gtx.Ops)
+		dims := es.Layout(gtx)
+		call := m.Stop()
+		kind, col := widget.DiffRemoved, d.RemovedColor
+		if e == &d.Editor.New {
+			kind, col = widget.DiffAdded, d.AddedColor
+		}
+		stack := op.Push(gtx.Ops)
+		paint.ColorOp{Color: col}.Add(gtx.Ops)
+		d.Editor.PaintLines(gtx, e, kind)
+		paint.ColorOp{Color: d.GapColor}.Add(gtx.Ops)
+		d.Editor.PaintGaps(gtx, e)
+		stack.Pop()
+		call.Add(gtx.Ops)
+		return dims
+	})
+}
@@ -19,57 +19,70 @@ type EditorStyle struct {
 	TextSize unit.Value
 	// Color is the text color.
 	Color color.NRGBA
-	// Hint contains the text displayed when the editor is empty.
+	// Hint contains the text displayed when the editor is empty
+	// and unfocused.
 	Hint string
 	// HintColor is the color of hint text.
 	HintColor color.NRGBA
-	Editor    *widget.Editor
+	// MatchColor is the highlight color of the matches of
+	// Editor.Find.
+	MatchColor color.NRGBA
+	// CaretColor is the color of the caret. A widget.CaretBlock
+	// caret is painted behind the text at half the alpha of
+	// CaretColor.
+	CaretColor color.NRGBA
+	Editor     *widget.Editor
 
 	shaper text.Shaper
 }
 
 func Editor(th *Theme, editor *widget.Editor, hint string) EditorStyle {
 	return EditorStyle{
-		Editor:    editor,
-		TextSize:  th.TextSize,
-		Color:     th.Palette.Fg,
-		shaper:    th.Shaper,
-		Hint:      hint,
-		HintColor: f32color.MulAlpha(th.Palette.Fg, 0xbb),
+		Editor:     editor,
+		TextSize:   th.TextSize,
+		Color:      th.Palette.Fg,
+		shaper:     th.Shaper,
+		Hint:       hint,
+		HintColor:  f32color.MulAlpha(th.Palette.Fg, 0xbb),
+		MatchColor: f32color.MulAlpha(th.Palette.ContrastBg, 0x60),
+		CaretColor: th.Palette.Fg,
 	}
 }
 
 func (e EditorStyle) Layout(gtx layout.Context) layout.Dimensions {
 	defer op.Push(gtx.Ops).Pop()
-	macro := op.Record(gtx.Ops)
-	paint.ColorOp{Color: e.HintColor}.Add(gtx.Ops)
-	var maxlines int
-	if e.Editor.SingleLine {
-		maxlines = 1
-	}
-	tl := widget.Label{Alignment: e.Editor.Alignment, MaxLines: maxlines}
-	dims := tl.Layout(gtx, e.shaper, e.Font, e.TextSize, e.Hint)
-	call := macro.Stop()
-	if w := dims.Size.X; gtx.Constraints.Min.X < w {
-		gtx.Constraints.Min.X = w
-	}
-	if h := dims.Size.Y; gtx.Constraints.Min.Y < h {
-		gtx.Constraints.Min.Y = h
-	}
-	dims = e.Editor.Layout(gtx, e.shaper, e.Font, e.TextSize)
+	e.Editor.Hint = e.Hint
+	dims := e.Editor.Layout(gtx, e.shaper, e.Font, e.TextSize)
 	disabled := gtx.Queue == nil
+	block := e.Editor.CaretStyle == widget.CaretBlock
+	if block && !disabled {
+		paint.ColorOp{Color: f32color.MulAlpha(e.CaretColor, 0x80)}.Add(gtx.Ops)
+		e.Editor.PaintCaret(gtx)
+	}
 	if e.Editor.Len() > 0 {
+		e.Editor.PaintLineBackgrounds(gtx)
+		paint.ColorOp{Color: e.MatchColor}.Add(gtx.Ops)
+		e.Editor.PaintMatches(gtx)
+		e.Editor.PaintSelection(gtx)
 		textColor := e.Color
 		if disabled {
 			textColor = f32color.MulAlpha(textColor, 150)
 		}
 		paint.ColorOp{Color: textColor}.Add(gtx.Ops)
 		e.Editor.PaintText(gtx)
+		paint.ColorOp{Color: e.HintColor}.Add(gtx.Ops)
+		e.Editor.PaintWhitespace(gtx)
 	} else {
-		call.Add(gtx.Ops)
+		paint.ColorOp{Color: e.HintColor}.Add(gtx.Ops)
+		e.Editor.PaintHint(gtx)
+	}
+	if e.Editor.LineNumbers {
+		paint.ColorOp{Color: e.HintColor}.Add(gtx.Ops)
+		e.Editor.PaintLineNumbers(gtx)
 	}
-	if !disabled {
-		paint.ColorOp{Color: e.Color}.Add(gtx.Ops)
+	e.Editor.PaintGutter(gtx)
+	if !disabled && !block {
+		paint.ColorOp{Color: e.CaretColor}.Add(gtx.Ops)
 		e.Editor.PaintCaret(gtx)
 	}
 	return dims
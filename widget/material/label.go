@@ -5,6 +5,7 @@ package material
 import (
 	"image/color"
 
+	"gioui.org/internal/f32color"
 	"gioui.org/layout"
 	"gioui.org/op/paint"
 	"gioui.org/text"
@@ -21,8 +22,51 @@ type LabelStyle struct {
 	Alignment text.Alignment
 	// MaxLines limits the number of lines. Zero means no limit.
 	MaxLines int
-	Text     string
-	TextSize unit.Value
+	// TabWidth is the distance between tab stops, measured in
+	// spaces. If zero, a width of 4 is used.
+	TabWidth int
+	// TabStops are the positions of the first tab stops.
+	TabStops []unit.Value
+	// Vertical lays out the text in columns from top to bottom,
+	// progressing from right to left.
+	Vertical bool
+	// Rotation rotates the text by the angle in radians, clockwise
+	// as displayed.
+	Rotation float32
+	// Baseline selects the line whose baseline is reported in the
+	// dimensions.
+	Baseline widget.BaselineMode
+	// LineHeight, if non-zero, is the distance between the
+	// baselines of successive lines. Otherwise, LineHeightScale, if
+	// non-zero, scales the line height given by the font.
+	LineHeight      unit.Value
+	LineHeightScale float32
+	// LetterSpacing is added to the advance of every rune.
+	LetterSpacing unit.Value
+	// Truncator, if set, ends the last line when MaxLines truncates
+	// the text, such as "…".
+	Truncator string
+	// Links are the links of the text, painted in LinkColor.
+	Links     []widget.LabelLink
+	LinkColor color.NRGBA
+	// Spans style ranges of the text.
+	Spans []widget.LabelSpan
+	// SpanRadius rounds the corners of span backgrounds.
+	SpanRadius unit.Value
+	// Shadow and Outline are painted behind the text.
+	Shadow  widget.TextShadow
+	Outline widget.TextOutline
+	// Cache, if set, caches the layout of the text across frames.
+	Cache *widget.LabelCache
+	// HitTest, if set, records the positions of the runes of the
+	// text.
+	HitTest *widget.LabelLayout
+	// State, if set, makes the text selectable.
+	State *widget.Selectable
+	// SelectionColor is the highlight color of selected text.
+	SelectionColor color.NRGBA
+	Text           string
+	TextSize       unit.Value
 
 	shaper text.Shaper
 }
@@ -65,15 +109,48 @@ func Caption(th *Theme, txt string) LabelStyle {
 
 func Label(th *Theme, size unit.Value, txt string) LabelStyle {
 	return LabelStyle{
-		Text:     txt,
-		Color:    th.Palette.Fg,
-		TextSize: size,
-		shaper:   th.Shaper,
+		Text:           txt,
+		Color:          th.Palette.Fg,
+		LinkColor:      th.Palette.ContrastBg,
+		SelectionColor: f32color.MulAlpha(th.Palette.ContrastBg, 0x60),
+		TextSize:       size,
+		shaper:         th.Shaper,
 	}
 }
 
 func (l LabelStyle) Layout(gtx layout.Context) layout.Dimensions {
+	return l.LayoutResult(gtx).Dimensions
+}
+
+// LayoutResult is like Layout, but also returns the details of the
+// layout, such as the number of runes truncated by MaxLines.
+func (l LabelStyle) LayoutResult(gtx layout.Context) widget.LabelResult {
 	paint.ColorOp{Color: l.Color}.Add(gtx.Ops)
-	tl := widget.Label{Alignment: l.Alignment, MaxLines: l.MaxLines}
-	return tl.Layout(gtx, l.shaper, l.Font, l.TextSize, l.Text)
+	tl := widget.Label{
+		Alignment:       l.Alignment,
+		MaxLines:        l.MaxLines,
+		TabWidth:        l.TabWidth,
+		TabStops:        l.TabStops,
+		Vertical:        l.Vertical,
+		Rotation:        l.Rotation,
+		Cache:           l.Cache,
+		HitTest:         l.HitTest,
+		Baseline:        l.Baseline,
+		LineHeight:      l.LineHeight,
+		LineHeightScale: l.LineHeightScale,
+		LetterSpacing:   l.LetterSpacing,
+		Truncator:       l.Truncator,
+		Links:           l.Links,
+		LinkColor:       l.LinkColor,
+		Spans:           l.Spans,
+		SpanRadius:      l.SpanRadius,
+		Shadow:          l.Shadow,
+		Outline:         l.Outline,
+	}
+	if s := l.State; s != nil {
+		s.Label = tl
+		s.Highlight.Color = l.SelectionColor
+		return s.LayoutResult(gtx, l.shaper, l.Font, l.TextSize, l.Text)
+	}
+	return tl.LayoutResult(gtx, l.shaper, l.Font, l.TextSize, l.Text)
 }
@@ -0,0 +1,42 @@
+// SPDX-License-Identifier: Unlicense OR MIT
+
+package material
+
+import (
+	"image/color"
+
+	"gioui.org/internal/f32color"
+	"gioui.org/layout"
+	"gioui.org/op"
+	"gioui.org/op/paint"
+	"gioui.org/widget"
+)
+
+// MinimapStyle draws a scaled overview of the text of an Editor.
+type MinimapStyle struct {
+	Minimap *widget.Minimap
+	Editor  *widget.Editor
+	// Color is the color of the text blocks.
+	Color color.NRGBA
+	// ViewportColor is the color of the area of the editor
+	// viewport.
+	ViewportColor color.NRGBA
+}
+
+func Minimap(th *Theme, minimap *widget.Minimap, editor *widget.Editor) MinimapStyle {
+	return MinimapStyle{
+		Minimap:       minimap,
+		Editor:        editor,
+		Color:         f32color.MulAlpha(th.Palette.Fg, 0x88),
+		ViewportColor: f32color.MulAlpha(th.Palette.Fg, 0x20),
+	}
+}
+
+func (m MinimapStyle) Layout(gtx layout.Context) layout.Dimensions {
+	defer op.Push(gtx.Ops).Pop()
+	paint.ColorOp{Color: m.Color}.Add(gtx.Ops)
+	dims := m.Minimap.Layout(gtx, m.Editor)
+	paint.ColorOp{Color: m.ViewportColor}.Add(gtx.Ops)
+	m.Minimap.PaintViewport(gtx)
+	return dims
+}
@@ -0,0 +1,160 @@
+// SPDX-License-Identifier: Unlicense OR MIT
+
+package widget
+
+import (
+	"image"
+	"math"
+	"unicode"
+
+	"gioui.org/gesture"
+	"gioui.org/io/pointer"
+	"gioui.org/layout"
+	"gioui.org/op"
+	"gioui.org/op/clip"
+	"gioui.org/op/paint"
+	"gioui.org/text"
+
+	"golang.org/x/image/math/fixed"
+)
+
+// Minimap holds the state of a scaled overview of the text of a
+// multi-line Editor. The overview draws the runs of non-space runes
+// of every line as blocks, which is much cheaper than drawing the
+// text, and keeps track of the viewport of the editor. Pressing or
+// dragging the minimap scrolls the editor to center its viewport on
+// the pointer.
+type Minimap struct {
+	// Scale is the size of the overview relative to the editor
+	// text. Zero means 1/8.
+	Scale float32
+
+	drag gesture.Drag
+	// off is the vertical offset of the overview, which scrolls
+	// along with the editor when the overview is taller than the
+	// minimap.
+	off int
+	// scale is the scale of the last Layout.
+	scale float32
+	// viewport is the area of the editor viewport.
+	viewport image.Rectangle
+}
+
+// Layout processes events and paints the overview of e in the current
+// color. The minimap fills the minimum constraints. Lay out the
+// editor before the minimap, for the minimap to reflect its current
+// contents and scroll offset.
+func (m *Minimap) Layout(gtx layout.Context, e *Editor) layout.Dimensions {
+	size := gtx.Constraints.Min
+	content := e.ContentSize().Y
+	view := e.viewSize.Y
+	for _, evt := range m.drag.Events(gtx.Metric, gtx, gesture.Vertical) {
+		switch evt.Type {
+		case pointer.Press, pointer.Drag:
+			if m.scale <= 0 {
+				continue
+			}
+			y := (evt.Position.Y + float32(m.off)) / m.scale
+			off := e.scrollOff
+			off.Y = int(y+.5) - view/2
+			e.SetScrollOff(off)
+		}
+	}
+
+	m.scale = m.Scale
+	if m.scale <= 0 {
+		m.scale = 1. / 8
+	}
+	scroll := e.scrollOff.Y
+	if max := content - view; scroll > max {
+		scroll = max
+	}
+	if scroll < 0 {
+		scroll = 0
+	}
+	m.off = 0
+	if h := m.px(content); h > size.Y && content > view {
+		m.off = int(float32(h-size.Y)*float32(scroll)/float32(content-view) + .5)
+	}
+	m.viewport = image.Rectangle{
+		Min: image.Point{Y: m.px(scroll) - m.off},
+		Max: image.Point{X: size.X, Y: m.px(scroll+view) - m.off},
+	}.Intersect(image.Rectangle{Max: size})
+
+	defer op.Push(gtx.Ops).Pop()
+	clip.Rect(image.Rectangle{Max: size}).Add(gtx.Ops)
+	it := e.ShapedLines(int(float32(m.off) / m.scale))
+	for {
+		l, ok := it.Next()
+		if !ok {
+			break
+		}
+		top := m.px(l.Baseline-l.Ascent.Ceil()) - m.off
+		if top >= size.Y {
+			break
+		}
+		// Leave the descent of lines empty, to separate them.
+		bottom := m.px(l.Baseline) - m.off
+		if bottom <= top {
+			bottom = top + 1
+		}
+		m.paintLine(gtx, e.lines.entry(l.Index).Line, l.X, top, bottom)
+	}
+	pointer.Rect(image.Rectangle{Max: size}).Add(gtx.Ops)
+	m.drag.Add(gtx.Ops)
+	return layout.Dimensions{Size: size}
+}
+
+// paintLine paints the runs of non-space runes of the line l between
+// the vertical positions top and bottom. The line starts at x in
+// editor coordinates.
+func (m *Minimap) paintLine(gtx layout.Context, l text.Line, x fixed.Int26_6, top, bottom int) {
+	start := x
+	inRun := false
+	n := 0
+	for _, r := range l.Layout.Text {
+		adv := l.Layout.Advances[n]
+		n++
+		space := unicode.IsSpace(r)
+		switch {
+		case !space && !inRun:
+			start, inRun = x, true
+		case space && inRun:
+			m.paintRun(gtx, start, x, top, bottom)
+			inRun = false
+		}
+		x += adv
+	}
+	if inRun {
+		m.paintRun(gtx, start, x, top, bottom)
+	}
+}
+
+// paintRun paints the block of a run of runes from x0 to x1 in editor
+// coordinates.
+func (m *Minimap) paintRun(gtx layout.Context, x0, x1 fixed.Int26_6, top, bottom int) {
+	r := image.Rectangle{
+		Min: image.Point{X: int(math.Floor(float64(x0) / 64 * float64(m.scale))), Y: top},
+		Max: image.Point{X: int(math.Ceil(float64(x1) / 64 * float64(m.scale))), Y: bottom},
+	}
+	stack := op.Push(gtx.Ops)
+	clip.Rect(r).Add(gtx.Ops)
+	paint.PaintOp{}.Add(gtx.Ops)
+	stack.Pop()
+}
+
+// px converts the editor distance v to the minimap.
+func (m *Minimap) px(v int) int {
+	return int(math.Round(float64(float32(v) * m.scale)))
+}
+
+// PaintViewport paints the area of the minimap that corresponds to
+// the viewport of the editor in the current color.
+func (m *Minimap) PaintViewport(gtx layout.Context) {
+	defer op.Push(gtx.Ops).Pop()
+	clip.Rect(m.viewport).Add(gtx.Ops)
+	paint.PaintOp{}.Add(gtx.Ops)
+}
+
+// Dragging reports whether the minimap is being dragged.
+func (m *Minimap) Dragging() bool { return m.drag.Dragging() }
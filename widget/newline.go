@@ -0,0 +1,63 @@
+// SPDX-License-Identifier: Unlicense OR MIT
+
+package widget
+
+import (
+	"strings"
+)
+
+// LineEnding is a line terminator convention.
+type LineEnding uint8
+
+const (
+	// LineEndingLF terminates lines with "\n".
+	LineEndingLF LineEnding = iota
+	// LineEndingCRLF terminates lines with "\r\n".
+	LineEndingCRLF
+)
+
+func (l LineEnding) String() string {
+	switch l {
+	case LineEndingLF:
+		return "LF"
+	case LineEndingCRLF:
+		return "CRLF"
+	default:
+		panic("invalid LineEnding")
+	}
+}
+
+// LineEnding returns the line terminator convention of the editor
+// contents, as detected by SetText or set by SetLineEnding.
+//
+// The editor stores lines terminated by "\n" regardless of the
+// convention. Rune offsets, such as those of SetSelection and Caret,
+// count every line terminator as one rune. Text and Len convert the
+// line terminators back to the convention.
+func (e *Editor) LineEnding() LineEnding {
+	return e.lineEnding
+}
+
+// SetLineEnding sets the line terminator convention used by Text.
+func (e *Editor) SetLineEnding(l LineEnding) {
+	e.lineEnding = l
+}
+
+// detectLineEnding returns the convention of the first line
+// terminator in s.
+func detectLineEnding(s string) LineEnding {
+	if i := strings.IndexByte(s, '\n'); i > 0 && s[i-1] == '\r' {
+		return LineEndingCRLF
+	}
+	return LineEndingLF
+}
+
+// normalizeNewlines replaces "\r\n" and lone "\r" line terminators
+// in s with "\n".
+func normalizeNewlines(s string) string {
+	if !strings.Contains(s, "\r") {
+		return s
+	}
+	s = strings.ReplaceAll(s, "\r\n", "\n")
+	return strings.ReplaceAll(s, "\r", "\n")
+}
@@ -0,0 +1,165 @@
+// SPDX-License-Identifier: Unlicense OR MIT
+
+package widget
+
+import (
+	"errors"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// NumberMode restricts the contents of an Editor to numbers.
+type NumberMode uint8
+
+const (
+	// NumberNone allows any text.
+	NumberNone NumberMode = iota
+	// NumberInteger allows integers.
+	NumberInteger
+	// NumberDecimal allows numbers with a fractional part.
+	NumberDecimal
+)
+
+// NumberFormat configures numeric input in an Editor.
+type NumberFormat struct {
+	Mode NumberMode
+	// Signed allows negative numbers.
+	Signed bool
+	// Decimal is the decimal separator. If zero, '.' is used.
+	Decimal rune
+	// Group, if not zero, separates groups of thousands. The
+	// groups are formatted when the editor loses focus, and by
+	// SetValue.
+	Group rune
+}
+
+// errNotNumber is returned by Value for contents that are not a
+// complete number.
+var errNotNumber = errors.New("widget: editor contents is not a number")
+
+// Value returns the number in the editor, according to the Number
+// format.
+func (e *Editor) Value() (float64, error) {
+	s := e.Number.normalize(e.rr.String())
+	if s == "" || s == "-" {
+		return 0, errNotNumber
+	}
+	v, err := strconv.ParseFloat(s, 64)
+	if err != nil {
+		return 0, errNotNumber
+	}
+	return v, nil
+}
+
+// SetValue replaces the contents of the editor with v, formatted
+// according to the Number format. Integer modes round v.
+func (e *Editor) SetValue(v float64) {
+	f := e.Number
+	prec := -1
+	if f.Mode == NumberInteger {
+		v = math.Round(v)
+		prec = 0
+	}
+	if !f.Signed && v < 0 {
+		v = 0
+	}
+	e.SetText(f.format(strconv.FormatFloat(v, 'f', prec, 64)))
+}
+
+// filterNumber returns s if inserting it in place of the selection
+// leaves a valid number, and the empty string otherwise. Group
+// separators in s are dropped.
+func (e *Editor) filterNumber(s string) string {
+	f := e.Number
+	if f.Group != 0 {
+		s = strings.Replace(s, string(f.Group), "", -1)
+	}
+	start, end := e.selectionBytes()
+	txt := e.rr.substring(0, start) + s + e.rr.substring(end, e.rr.len())
+	if !f.valid(txt) {
+		return ""
+	}
+	return s
+}
+
+// formatNumber formats the group separators of the contents.
+func (e *Editor) formatNumber() {
+	f := e.Number
+	if f.Mode == NumberNone || f.Group == 0 {
+		return
+	}
+	txt := e.rr.String()
+	if !f.valid(txt) {
+		return
+	}
+	if s := f.format(f.normalize(txt)); s != txt {
+		e.history.seal()
+		e.replace(0, e.rr.len(), s)
+		e.history.seal()
+	}
+}
+
+// decimal returns the decimal separator.
+func (f NumberFormat) decimal() rune {
+	if f.Decimal == 0 {
+		return '.'
+	}
+	return f.Decimal
+}
+
+// valid reports whether s is a number, or the start of one.
+func (f NumberFormat) valid(s string) bool {
+	if strings.HasPrefix(s, "-") && f.Signed {
+		s = s[1:]
+	}
+	fraction := false
+	for _, r := range s {
+		switch {
+		case r >= '0' && r <= '9':
+		case r == f.Group && f.Group != 0 && !fraction:
+		case r == f.decimal() && f.Mode == NumberDecimal && !fraction:
+			fraction = true
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+// normalize converts s to the format parsed by strconv.ParseFloat.
+func (f NumberFormat) normalize(s string) string {
+	return strings.Map(func(r rune) rune {
+		switch {
+		case r == f.Group && f.Group != 0:
+			return -1
+		case r == f.decimal():
+			return '.'
+		}
+		return r
+	}, s)
+}
+
+// format converts a number formatted by strconv.FormatFloat, or
+// normalized, to the format of f.
+func (f NumberFormat) format(s string) string {
+	sign := ""
+	if strings.HasPrefix(s, "-") {
+		sign, s = "-", s[1:]
+	}
+	integer, fraction := s, ""
+	if i := strings.IndexByte(s, '.'); i >= 0 {
+		integer, fraction = s[:i], string(f.decimal())+s[i+1:]
+	}
+	if f.Group != 0 {
+		var b strings.Builder
+		for i, r := range integer {
+			if i > 0 && (len(integer)-i)%3 == 0 {
+				b.WriteRune(f.Group)
+			}
+			b.WriteRune(r)
+		}
+		integer = b.String()
+	}
+	return sign + integer + fraction
+}
@@ -0,0 +1,54 @@
+// SPDX-License-Identifier: Unlicense OR MIT
+
+package widget
+
+import (
+	"image"
+	"math"
+
+	"gioui.org/io/clipboard"
+	"gioui.org/io/pointer"
+	"gioui.org/layout"
+)
+
+// primary is the state of the primary selection.
+type primary struct {
+	// start and end are the byte offsets of the selection last
+	// written to the primary selection.
+	start, end int
+}
+
+// processPrimary pastes the primary selection at middle clicks and
+// writes the selection to the primary selection when it changes.
+func (e *Editor) processPrimary(gtx layout.Context) {
+	for _, evt := range gtx.Events(&e.primary) {
+		pe, ok := evt.(pointer.Event)
+		if !ok || pe.Type != pointer.Press || pe.Buttons != pointer.ButtonMiddle {
+			continue
+		}
+		pos := image.Point{
+			X: int(math.Round(float64(pe.Position.X))),
+			Y: int(math.Round(float64(pe.Position.Y))),
+		}
+		e.commitComposition()
+		e.history.seal()
+		e.moveCoord(pos)
+		e.clearSelection()
+		e.carets = e.carets[:0]
+		e.requestFocus = true
+		e.caret.scroll = true
+		clipboard.ReadOp{Tag: &e.eventKey, Primary: true}.Add(gtx.Ops)
+	}
+	if e.drag.active || e.Mask != 0 {
+		return
+	}
+	start, end := e.selectionBytes()
+	if start == end && !e.block.active {
+		e.primary = primary{}
+		return
+	}
+	if start != e.primary.start || end != e.primary.end {
+		e.primary = primary{start: start, end: end}
+		clipboard.WriteOp{Text: e.SelectedText(), Primary: true}.Add(gtx.Ops)
+	}
+}
@@ -0,0 +1,75 @@
+// SPDX-License-Identifier: Unlicense OR MIT
+
+package widget
+
+// A ReadOnlyRange is a range of the editor contents that can't be
+// edited, such as the prompt of a terminal style editor. The caret
+// may still enter the range, and its text may be selected and
+// copied, but edits that remove or replace any of its text are
+// ignored, as is text inserted inside it. Text can be inserted at
+// the end of the range but not at its start, so that a prompt at the
+// start of a line can't be prefixed.
+type ReadOnlyRange struct {
+	// Start and End are the rune offsets of the range.
+	Start, End int
+}
+
+// SetReadOnlyRanges replaces the read-only ranges of the editor.
+// Ranges move with the text around them, and only restrict the edits
+// of the user and of methods such as Insert and Delete: SetText and
+// remote ApplyEdit changes may change them.
+func (e *Editor) SetReadOnlyRanges(ranges []ReadOnlyRange) {
+	e.readOnly = e.readOnly[:0]
+	for _, r := range ranges {
+		if r.Start > r.End {
+			r.Start, r.End = r.End, r.Start
+		}
+		if r.Start < r.End {
+			e.readOnly = append(e.readOnly, r)
+		}
+	}
+}
+
+// ReadOnlyRanges returns the read-only ranges of the editor.
+func (e *Editor) ReadOnlyRanges() []ReadOnlyRange {
+	return e.readOnly
+}
+
+// readOnlyEdit reports whether replacing the text between the byte
+// offsets start and end would modify a read-only range.
+func (e *Editor) readOnlyEdit(start, end int) bool {
+	if len(e.readOnly) == 0 {
+		return false
+	}
+	rstart, rend := e.rr.runeOffset(start), e.rr.runeOffset(end)
+	for _, r := range e.readOnly {
+		if rstart == rend {
+			if r.Start <= rstart && rstart < r.End {
+				return true
+			}
+		} else if rstart < r.End && r.Start < rend {
+			return true
+		}
+	}
+	return false
+}
+
+// adjustReadOnly updates the read-only ranges for the replacement of
+// removed runes at the rune offset start with inserted runes. Text
+// inserted at the start of a range is placed before it, and ranges
+// whose text is removed are dropped.
+func (e *Editor) adjustReadOnly(start, removed, inserted int) {
+	ranges := e.readOnly[:0]
+	for _, r := range e.readOnly {
+		if removed == 0 && r.Start == start {
+			r.Start += inserted
+		} else {
+			r.Start = adjustOffset(r.Start, start, removed, inserted, true)
+		}
+		r.End = adjustOffset(r.End, start, removed, inserted, false)
+		if r.Start < r.End {
+			ranges = append(ranges, r)
+		}
+	}
+	e.readOnly = ranges
+}
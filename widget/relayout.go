@@ -0,0 +1,63 @@
+// SPDX-License-Identifier: Unlicense OR MIT
+
+package widget
+
+// relayout tracks the edits since the last layout of the text, so
+// that only the paragraphs touched by the edits are shaped again.
+type relayout struct {
+	// full is set when all of the text must be laid out again.
+	full bool
+	// edited is set when the text between the byte offsets start
+	// and newEnd replaced the laid out text between start and
+	// oldEnd.
+	edited                bool
+	start, oldEnd, newEnd int
+}
+
+// edit records the replacement of the text between the byte offsets
+// start and end with inserted bytes.
+func (r *relayout) edit(start, end, inserted int) {
+	if !r.edited {
+		*r = relayout{full: r.full, edited: true, start: start, oldEnd: end, newEnd: start + inserted}
+		return
+	}
+	if start < r.start {
+		r.start = start
+	}
+	if end > r.newEnd {
+		r.oldEnd += end - r.newEnd
+		r.newEnd = end
+	}
+	r.newEnd += inserted - (end - start)
+}
+
+// relayoutEdit lays out the paragraphs touched by the recorded edits
+// and splices them into the lines, and reports whether it did. It
+// does nothing if all of the text must be laid out, or if the lines
+// have gaps, because edits may move lines across gaps.
+func (e *Editor) relayoutEdit() bool {
+	r := e.relayout
+	if r.full || !r.edited || e.shaper == nil || e.Mask != 0 || e.lines.len() == 0 || len(e.gaps) > 0 {
+		return false
+	}
+	total := e.lines.total()
+	// The lines may not match the text if the shaper replaced
+	// invalid UTF-8.
+	if total.bytes != e.rr.len()-(r.newEnd-r.oldEnd) {
+		return false
+	}
+	// Lay out the paragraphs containing the edit, numbered p0 to p1
+	// in the new text.
+	dp := e.rr.newlines() - total.newlines
+	p0 := e.rr.linesBefore(r.start)
+	p1 := e.rr.linesBefore(r.newEnd) + 1
+	var lines []lineEntry
+	if start, end := e.rr.lineStart(p0), e.rr.lineStart(p1); e.isLazy() && end-start > maxEditShape {
+		lines = []lineEntry{e.runEntry(start, end)}
+	} else {
+		lines = e.layoutParas(p0, p1)
+	}
+	e.spliceParas(p0, p1-dp, dp, lines)
+	e.dims = e.textDims()
+	return true
+}
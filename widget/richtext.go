@@ -0,0 +1,257 @@
+// SPDX-License-Identifier: Unlicense OR MIT
+
+package widget
+
+import (
+	"image"
+	"image/color"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"gioui.org/f32"
+	"gioui.org/layout"
+	"gioui.org/op"
+	"gioui.org/op/paint"
+	"gioui.org/text"
+	"gioui.org/unit"
+
+	"golang.org/x/image/math/fixed"
+)
+
+// A LabelSpan styles a range of the text of a Label.
+type LabelSpan struct {
+	// Start and End are the rune offsets of the styled text.
+	Start, End int
+	// Font is the font of the styled text.
+	Font text.Font
+	// Size is the text size, or zero for the size of the label.
+	Size unit.Value
+	// Color is the text color, or transparent for the current
+	// color.
+	Color color.NRGBA
+	// Background, if not transparent, highlights the text, such as
+	// the matches of a search.
+	Background color.NRGBA
+	// Underline draws a line under the text, and Strikethrough a
+	// line through it.
+	Underline, Strikethrough bool
+}
+
+// spanRun is a run of text shaped in the style of a span.
+type spanRun struct {
+	ascent, descent fixed.Int26_6
+	bounds          fixed.Rectangle26_6
+}
+
+// spanGlyph is a rune of text shaped by layoutSpans.
+type spanGlyph struct {
+	r   rune
+	adv fixed.Int26_6
+	// run is the index of the run of the rune.
+	run int
+}
+
+// spanAt returns the span of the label containing the rune offset r,
+// if any. Later spans take precedence.
+func (l Label) spanAt(r int) *LabelSpan {
+	for i := len(l.Spans) - 1; i >= 0; i-- {
+		if sp := &l.Spans[i]; sp.Start <= r && r < sp.End {
+			return sp
+		}
+	}
+	return nil
+}
+
+// spanFace returns the font and size of the text styled by the span
+// sp, which may be nil.
+func spanFace(gtx layout.Context, sp *LabelSpan, font text.Font, textSize fixed.Int26_6) (text.Font, fixed.Int26_6) {
+	if sp == nil {
+		return font, textSize
+	}
+	if sp.Size.V > 0 {
+		textSize = fixed.I(gtx.Px(sp.Size))
+	}
+	return sp.Font, textSize
+}
+
+// layoutSpans shapes txt into lines wrapped at maxWidth, with the
+// runes covered by spans shaped in the font and size of their span.
+// Runs of runes with the same span are shaped separately, and the
+// lines are broken after spaces like the lines of a text.Shaper.
+func (l Label) layoutSpans(gtx layout.Context, s text.Shaper, font text.Font, textSize fixed.Int26_6, maxWidth int, txt string) []text.Line {
+	var (
+		runs   []spanRun
+		glyphs []spanGlyph
+	)
+	for r := 0; len(txt) > 0; {
+		sp := l.spanAt(r)
+		n, size := 0, 0
+		for size < len(txt) && l.spanAt(r+n) == sp {
+			_, rs := utf8.DecodeRuneInString(txt[size:])
+			size += rs
+			n++
+		}
+		f, fs := spanFace(gtx, sp, font, textSize)
+		var run spanRun
+		for i, line := range s.LayoutString(f, fs, inf, txt[:size]) {
+			if line.Ascent > run.ascent {
+				run.ascent = line.Ascent
+			}
+			if line.Descent > run.descent {
+				run.descent = line.Descent
+			}
+			b := line.Bounds
+			b.Min.X, b.Max.X = 0, 0
+			if i == 0 {
+				run.bounds = b
+			} else {
+				run.bounds = run.bounds.Union(b)
+			}
+			advs := line.Layout.Advances
+			for j, c := range []rune(line.Layout.Text) {
+				g := spanGlyph{r: c, run: len(runs)}
+				if j < len(advs) {
+					g.adv = advs[j]
+				}
+				glyphs = append(glyphs, g)
+			}
+		}
+		runs = append(runs, run)
+		txt = txt[size:]
+		r += n
+	}
+	if len(glyphs) == 0 {
+		return s.LayoutString(font, textSize, maxWidth, "")
+	}
+	var lines []text.Line
+	endLine := func(gs []spanGlyph, metrics int) {
+		var (
+			b    strings.Builder
+			line text.Line
+		)
+		line.Layout.Advances = make([]fixed.Int26_6, len(gs))
+		runsSeen := false
+		addRun := func(run spanRun) {
+			if run.ascent > line.Ascent {
+				line.Ascent = run.ascent
+			}
+			if run.descent > line.Descent {
+				line.Descent = run.descent
+			}
+			if !runsSeen {
+				line.Bounds = run.bounds
+				runsSeen = true
+			} else {
+				line.Bounds = line.Bounds.Union(run.bounds)
+			}
+		}
+		for i, g := range gs {
+			b.WriteRune(g.r)
+			line.Layout.Advances[i] = g.adv
+			line.Width += g.adv
+			addRun(runs[g.run])
+		}
+		if len(gs) == 0 {
+			addRun(runs[metrics])
+		}
+		line.Layout.Text = b.String()
+		line.Bounds.Max.X = line.Width
+		lines = append(lines, line)
+	}
+	maxX := fixed.I(maxWidth)
+	var x fixed.Int26_6
+	start, word := 0, 0
+	for i := 0; i < len(glyphs); i++ {
+		g := glyphs[i]
+		if g.r == '\n' {
+			endLine(glyphs[start:i+1], g.run)
+			start, word, x = i+1, i+1, 0
+			continue
+		}
+		if i > start && x+g.adv > maxX {
+			// Break after the last space, or before the rune if
+			// the line contains no spaces.
+			brk := word
+			if brk == start {
+				brk = i
+			}
+			endLine(glyphs[start:brk], g.run)
+			start, word, x = brk, brk, 0
+			i = brk - 1
+			continue
+		}
+		x += g.adv
+		if unicode.IsSpace(g.r) {
+			word = i + 1
+		}
+	}
+	endLine(glyphs[start:], glyphs[len(glyphs)-1].run)
+	return lines
+}
+
+// paintBackgrounds paints the backgrounds of the spans of lines laid
+// out with the width, with the corner radius of SpanRadius.
+func (l Label) paintBackgrounds(gtx layout.Context, lines []text.Line, width int) {
+	var placed []placedLine
+	for _, sp := range l.Spans {
+		if sp.Background.A == 0 {
+			continue
+		}
+		if placed == nil {
+			placed = placeLines(nil, lines, l.Alignment, width)
+		}
+		h := Highlight{Color: sp.Background, CornerRadius: l.SpanRadius}
+		highlightRange(gtx, h, placed, sp.Start, sp.End)
+	}
+}
+
+// paintLayout paints the text layout lt with the pass p, starting at
+// the rune offset runeOff, split into runs of runes with the same
+// span and link. The runs are painted in the style of their span,
+// and links underlined and in LinkColor.
+func (l Label) paintLayout(gtx layout.Context, s text.Shaper, font text.Font, textSize fixed.Int26_6, lt text.Layout, runeOff int, p textPass) {
+	var x fixed.Int26_6
+	for len(lt.Advances) > 0 {
+		sp := l.spanAt(runeOff)
+		_, inLink := l.linkAt(runeOff)
+		n, size := 0, 0
+		var w fixed.Int26_6
+		for n < len(lt.Advances) {
+			if _, ok := l.linkAt(runeOff + n); ok != inLink || l.spanAt(runeOff+n) != sp {
+				break
+			}
+			_, rs := utf8.DecodeRuneInString(lt.Text[size:])
+			size += rs
+			w += lt.Advances[n]
+			n++
+		}
+		run := text.Layout{Text: lt.Text[:size], Advances: lt.Advances[:n]}
+		f, fs := spanFace(gtx, sp, font, textSize)
+		thickness := (fs / 16).Ceil()
+		if thickness < 1 {
+			thickness = 1
+		}
+		stack := op.Push(gtx.Ops)
+		op.Offset(f32.Point{X: float32(x) / 64}).Add(gtx.Ops)
+		if sp != nil && sp.Color.A > 0 && p.own() {
+			paint.ColorOp{Color: sp.Color}.Add(gtx.Ops)
+		}
+		if inLink && l.LinkColor.A > 0 && p.own() {
+			paint.ColorOp{Color: l.LinkColor}.Add(gtx.Ops)
+		}
+		if inLink || sp != nil && sp.Underline {
+			p.paint(gtx, rectShape(gtx, image.Rect(0, thickness, w.Ceil(), 2*thickness)))
+		}
+		if sp != nil && sp.Strikethrough {
+			// Strike through the middle of lowercase letters.
+			y := -(fs / 4).Round()
+			p.paint(gtx, rectShape(gtx, image.Rect(0, y, w.Ceil(), y+thickness)))
+		}
+		p.paint(gtx, s.Shape(f, fs, run))
+		stack.Pop()
+		lt = text.Layout{Text: lt.Text[size:], Advances: lt.Advances[n:]}
+		runeOff += n
+		x += w
+	}
+}
@@ -0,0 +1,61 @@
+// SPDX-License-Identifier: Unlicense OR MIT
+
+package widget
+
+import (
+	"image"
+	"math"
+
+	"gioui.org/f32"
+	"gioui.org/layout"
+	"gioui.org/op"
+)
+
+// rotateText lays out the text of w and paints it rotated by the
+// angle in radians, clockwise as displayed. The result describes the
+// bounding box of the rotated text, whose top left corner is placed
+// at the origin. Pointer input is transformed along with the text.
+func rotateText(gtx layout.Context, angle float32, w func(gtx layout.Context) LabelResult) LabelResult {
+	cs := gtx.Constraints
+	sin, cos := math.Sincos(float64(angle))
+	inner := gtx
+	inner.Constraints = layout.Constraints{Max: cs.Max}
+	if math.Abs(sin) > math.Abs(cos) {
+		// Text closer to vertical than horizontal wraps at the
+		// height of the constraints.
+		inner.Constraints.Max = image.Point{X: cs.Max.Y, Y: cs.Max.X}
+	}
+	m := op.Record(gtx.Ops)
+	res := w(inner)
+	call := m.Stop()
+
+	rot := f32.Affine2D{}.Rotate(f32.Point{}, angle)
+	sz := layout.FPt(res.Size)
+	corners := [...]f32.Point{{}, {X: sz.X}, {Y: sz.Y}, sz}
+	var bounds f32.Rectangle
+	for i, c := range corners {
+		c = rot.Transform(c)
+		if i == 0 {
+			bounds = f32.Rectangle{Min: c, Max: c}
+			continue
+		}
+		bounds.Min.X = float32(math.Min(float64(bounds.Min.X), float64(c.X)))
+		bounds.Min.Y = float32(math.Min(float64(bounds.Min.Y), float64(c.Y)))
+		bounds.Max.X = float32(math.Max(float64(bounds.Max.X), float64(c.X)))
+		bounds.Max.Y = float32(math.Max(float64(bounds.Max.Y), float64(c.Y)))
+	}
+	size := image.Point{
+		X: int(math.Ceil(float64(bounds.Dx()))),
+		Y: int(math.Ceil(float64(bounds.Dy()))),
+	}
+	stack := op.Push(gtx.Ops)
+	op.Affine(rot.Offset(bounds.Min.Mul(-1))).Add(gtx.Ops)
+	call.Add(gtx.Ops)
+	stack.Pop()
+
+	res.Overflow = res.Overflow || size.X > cs.Max.X || size.Y > cs.Max.Y
+	res.Size = cs.Constrain(size)
+	// Rotated text has no horizontal baseline.
+	res.Baseline = 0
+	return res
+}
@@ -0,0 +1,51 @@
+// SPDX-License-Identifier: Unlicense OR MIT
+
+package widget
+
+import (
+	"image"
+	"image/color"
+
+	"gioui.org/layout"
+	"gioui.org/unit"
+
+	"golang.org/x/image/math/fixed"
+)
+
+// A Ruler is a vertical guide at a column of an Editor, such as at
+// the maximum line width of a coding style.
+type Ruler struct {
+	// Column is the number of columns left of the guide. Columns
+	// are as wide as the advance of a space, so the guide is
+	// exact for monospaced fonts.
+	Column int
+	// Color is the color of the guide.
+	Color color.NRGBA
+	// Width is the width of the guide. If zero, a width of 1dp is
+	// used.
+	Width unit.Value
+}
+
+// paintRulers paints the rulers, in text coordinates adjusted for
+// scrolling. The rulers span the height of the editor.
+func (e *Editor) paintRulers(gtx layout.Context) {
+	for _, r := range e.Rulers {
+		w := gtx.Px(r.Width)
+		if r.Width.V == 0 {
+			w = gtx.Px(unit.Dp(1))
+		}
+		x := e.columnX(r.Column) - e.scrollOff.X
+		fillRect(gtx, r.Color, image.Rect(x, -e.valign, x+w, e.viewSize.Y-e.valign))
+	}
+}
+
+// columnX returns the horizontal position in text coordinates of the
+// column col, measured in advances of a space.
+func (e *Editor) columnX(col int) int {
+	sp := e.shaper.LayoutString(e.font, e.textSize, inf, " ")
+	if len(sp) == 0 {
+		return 0
+	}
+	space := sp[0].Width + e.spacing.letter
+	return (space * fixed.Int26_6(col)).Round()
+}
@@ -0,0 +1,78 @@
+// SPDX-License-Identifier: Unlicense OR MIT
+
+package widget
+
+import (
+	"image"
+
+	"gioui.org/gesture"
+	"gioui.org/io/pointer"
+	"gioui.org/layout"
+	"gioui.org/op"
+)
+
+// Scrollbar holds the state of a scrollbar for a scrollable widget
+// such as an Editor. The scrollbar works in fractions of the content
+// length, so it can be attached to any widget that reports its
+// viewport as such.
+type Scrollbar struct {
+	drag gesture.Drag
+	// delta is the distance scrolled since the last call to
+	// ScrollDistance.
+	delta float32
+	// pos is the position of the last press or drag event along
+	// the scrollbar axis.
+	pos    float32
+	length float32
+}
+
+// Layout processes events. The scrollbar fills the minimum
+// constraints along axis, and start and end are the viewport range
+// as fractions of the content length, as returned by
+// Editor.ViewportFraction.
+//
+// Pressing outside the indicator centers the viewport on the pressed
+// position; dragging moves the viewport by the dragged distance.
+func (s *Scrollbar) Layout(gtx layout.Context, axis layout.Axis, start, end float32) layout.Dimensions {
+	size := gtx.Constraints.Min
+	gaxis := gesture.Horizontal
+	s.length = float32(size.X)
+	if axis == layout.Vertical {
+		gaxis = gesture.Vertical
+		s.length = float32(size.Y)
+	}
+	for _, e := range s.drag.Events(gtx.Metric, gtx, gaxis) {
+		pos := e.Position.X
+		if axis == layout.Vertical {
+			pos = e.Position.Y
+		}
+		if s.length <= 0 {
+			continue
+		}
+		switch e.Type {
+		case pointer.Press:
+			if f := pos / s.length; f < start || f > end {
+				s.delta += f - (start+end)/2
+			}
+		case pointer.Drag:
+			s.delta += (pos - s.pos) / s.length
+		}
+		s.pos = pos
+	}
+
+	defer op.Push(gtx.Ops).Pop()
+	pointer.Rect(image.Rectangle{Max: size}).Add(gtx.Ops)
+	s.drag.Add(gtx.Ops)
+	return layout.Dimensions{Size: size}
+}
+
+// ScrollDistance returns the distance scrolled since the last call
+// to ScrollDistance, as a fraction of the content length.
+func (s *Scrollbar) ScrollDistance() float32 {
+	d := s.delta
+	s.delta = 0
+	return d
+}
+
+// Dragging reports whether the scrollbar is being dragged.
+func (s *Scrollbar) Dragging() bool { return s.drag.Dragging() }
@@ -0,0 +1,329 @@
+// SPDX-License-Identifier: Unlicense OR MIT
+
+package widget
+
+import (
+	"image"
+	"strings"
+
+	"gioui.org/f32"
+	"gioui.org/gesture"
+	"gioui.org/io/clipboard"
+	"gioui.org/io/key"
+	"gioui.org/io/pointer"
+	"gioui.org/layout"
+	"gioui.org/op"
+	"gioui.org/text"
+	"gioui.org/unit"
+
+	"golang.org/x/image/math/fixed"
+)
+
+// Selectable is a Label whose text can be selected with the mouse or
+// the keyboard, and copied to the clipboard. A double click selects
+// a line. Changing the text clears the selection. Clicks on the links
+// of the label generate LinkClickEvents.
+type Selectable struct {
+	Label
+	// Highlight configures the painting of the selection.
+	Highlight Highlight
+
+	eventKey     int
+	txt          string
+	lines        []placedLine
+	clicker      gesture.Click
+	dragger      gesture.Drag
+	dragging     bool
+	focused      bool
+	requestFocus bool
+	// anchor and caret are the rune offsets of the fixed and the
+	// moving end of the selection.
+	anchor, caret int
+	events        []LabelEvent
+}
+
+// LabelEvent is a SelectEvent or a LinkClickEvent of a Selectable.
+type LabelEvent interface {
+	isLabelEvent()
+}
+
+// Layout lays out and paints the text like Label.Layout, and
+// handles the selection input.
+func (s *Selectable) Layout(gtx layout.Context, sh text.Shaper, font text.Font, size unit.Value, txt string) layout.Dimensions {
+	return s.LayoutResult(gtx, sh, font, size, txt).Dimensions
+}
+
+// LayoutResult is like Layout, but also returns the details of the
+// layout.
+func (s *Selectable) LayoutResult(gtx layout.Context, sh text.Shaper, font text.Font, size unit.Value, txt string) LabelResult {
+	if s.Rotation != 0 {
+		return rotateText(gtx, s.Rotation, func(gtx layout.Context) LabelResult {
+			return s.layoutText(gtx, sh, font, size, txt)
+		})
+	}
+	return s.layoutText(gtx, sh, font, size, txt)
+}
+
+// layoutText lays out and paints the text and handles the input,
+// ignoring Rotation.
+func (s *Selectable) layoutText(gtx layout.Context, sh text.Shaper, font text.Font, size unit.Value, txt string) LabelResult {
+	if s.Vertical {
+		return s.Label.layoutText(gtx, sh, font, size, txt)
+	}
+	if txt != s.txt {
+		s.txt = txt
+		s.anchor, s.caret = 0, 0
+	}
+	s.processEvents(gtx)
+	textSize := fixed.I(gtx.Px(size))
+	lines, res := s.layoutLines(gtx, sh, font, textSize, txt)
+	s.lines = placeLines(s.lines[:0], lines, s.Alignment, res.Size.X)
+	if t := s.HitTest; t != nil {
+		t.record(lines, s.Alignment, res.Size.X)
+	}
+	if n := s.len(); s.caret > n || s.anchor > n {
+		s.anchor, s.caret = 0, 0
+	}
+	s.paintBackgrounds(gtx, lines, res.Size.X)
+	s.paintSelection(gtx)
+	s.paintLines(gtx, sh, font, textSize, lines, res.Size)
+
+	defer op.Push(gtx.Ops).Pop()
+	pointer.Rect(image.Rectangle{Max: res.Size}).Add(gtx.Ops)
+	pointer.CursorNameOp{Name: pointer.CursorText}.Add(gtx.Ops)
+	s.clicker.Add(gtx.Ops)
+	s.dragger.Add(gtx.Ops)
+	for _, l := range s.Links {
+		for _, r := range lineRegions(s.lines, l.Start, l.End) {
+			stack := op.Push(gtx.Ops)
+			pointer.Rect(r).Add(gtx.Ops)
+			pointer.CursorNameOp{Name: pointer.CursorPointer}.Add(gtx.Ops)
+			stack.Pop()
+		}
+	}
+	key.InputOp{Tag: &s.eventKey}.Add(gtx.Ops)
+	if s.requestFocus {
+		key.FocusOp{Focus: true}.Add(gtx.Ops)
+	}
+	s.requestFocus = false
+	return res
+}
+
+// Events returns the events since the last call to Events.
+func (s *Selectable) Events() []LabelEvent {
+	events := s.events
+	s.events = nil
+	return events
+}
+
+// Selection returns the rune offsets of the start and end of the
+// selection, with start <= end.
+func (s *Selectable) Selection() (start, end int) {
+	start, end = s.anchor, s.caret
+	if start > end {
+		start, end = end, start
+	}
+	return start, end
+}
+
+// SetSelection selects the text between the rune offsets start and
+// end. Offsets outside the displayed text are clamped to its bounds.
+func (s *Selectable) SetSelection(start, end int) {
+	s.anchor, s.caret = s.clamp(start), s.clamp(end)
+}
+
+// SelectedText returns the selected text, as displayed.
+func (s *Selectable) SelectedText() string {
+	return s.substring(s.Selection())
+}
+
+// substring returns the displayed text between the rune offsets
+// start and end.
+func (s *Selectable) substring(start, end int) string {
+	var b strings.Builder
+	for _, l := range s.lines {
+		n := 0
+		for _, r := range l.line.Layout.Text {
+			if off := l.runes + n; off >= start && off < end {
+				b.WriteRune(r)
+			}
+			n++
+		}
+	}
+	return b.String()
+}
+
+func (s *Selectable) processEvents(gtx layout.Context) {
+	for _, evt := range s.clicker.Events(gtx) {
+		if evt.Type == gesture.TypeClick && s.anchor == s.caret {
+			s.clickLink(evt.Position)
+		}
+		if evt.Type != gesture.TypePress {
+			continue
+		}
+		s.requestFocus = true
+		off := s.offsetAt(evt.Position)
+		if !evt.Modifiers.Contain(key.ModShift) {
+			s.anchor = off
+		}
+		s.caret = off
+		s.dragging = evt.Source == pointer.Mouse
+		if evt.NumClicks >= 2 && len(s.lines) > 0 {
+			// Select the line, without its terminator.
+			l := s.lines[s.lineOf(off)]
+			s.anchor, s.caret = l.runes, l.runes+l.runeCount()
+		}
+		if s.anchor != s.caret && !s.dragging {
+			s.selectEvent()
+		}
+	}
+	for _, evt := range s.dragger.Events(gtx.Metric, gtx, gesture.Both) {
+		if !s.dragging {
+			continue
+		}
+		switch evt.Type {
+		case pointer.Drag:
+			s.caret = s.offsetAt(evt.Position)
+		case pointer.Release, pointer.Cancel:
+			s.dragging = false
+			if s.anchor != s.caret {
+				s.selectEvent()
+			}
+		}
+	}
+	for _, ke := range gtx.Events(&s.eventKey) {
+		switch ke := ke.(type) {
+		case key.FocusEvent:
+			if s.focused && !ke.Focus {
+				s.anchor = s.caret
+			}
+			s.focused = ke.Focus
+		case key.Event:
+			if !s.focused || ke.State != key.Press {
+				break
+			}
+			s.command(gtx, ke)
+		}
+	}
+}
+
+// command executes the key command k.
+func (s *Selectable) command(gtx layout.Context, k key.Event) {
+	anchor, caret := s.anchor, s.caret
+	switch {
+	case k.Name == "C" && k.Modifiers == key.ModShortcut:
+		if s.anchor != s.caret {
+			clipboard.WriteOp{Text: s.SelectedText()}.Add(gtx.Ops)
+		}
+		return
+	case k.Name == "A" && k.Modifiers == key.ModShortcut:
+		s.anchor, s.caret = 0, s.len()
+	case k.Modifiers&^key.ModShift != 0:
+		return
+	case k.Name == key.NameLeftArrow:
+		s.caret = s.clamp(s.caret - 1)
+	case k.Name == key.NameRightArrow:
+		s.caret = s.clamp(s.caret + 1)
+	case k.Name == key.NameHome:
+		s.caret = 0
+	case k.Name == key.NameEnd:
+		s.caret = s.len()
+	default:
+		return
+	}
+	if k.Modifiers != key.ModShift && k.Name != "A" {
+		s.anchor = s.caret
+	}
+	if s.anchor != s.caret && (s.anchor != anchor || s.caret != caret) {
+		s.selectEvent()
+	}
+}
+
+// clickLink generates a LinkClickEvent if pos is over a link.
+func (s *Selectable) clickLink(pos f32.Point) {
+	if len(s.Links) == 0 {
+		return
+	}
+	l, ok := s.linkAt(s.offsetAt(pos))
+	if !ok {
+		return
+	}
+	s.events = append(s.events, LinkClickEvent{
+		URL:   s.substring(l.Start, l.End),
+		Start: l.Start,
+		End:   l.End,
+		Value: l.Value,
+	})
+}
+
+// selectEvent generates a SelectEvent for the selection.
+func (s *Selectable) selectEvent() {
+	start, end := s.Selection()
+	evt := SelectEvent{Text: s.SelectedText(), Start: start, End: end}
+	evt.StartLine, evt.StartCol = s.lineCol(start)
+	evt.EndLine, evt.EndCol = s.lineCol(end)
+	s.events = append(s.events, evt)
+}
+
+// lineCol returns the line and column of the rune offset off.
+func (s *Selectable) lineCol(off int) (line, col int) {
+	if len(s.lines) == 0 {
+		return 0, 0
+	}
+	line = s.lineOf(off)
+	return line, off - s.lines[line].runes
+}
+
+// lineOf returns the index of the line containing the rune offset
+// off.
+func (s *Selectable) lineOf(off int) int {
+	i := 0
+	for i < len(s.lines)-1 && off >= s.lines[i+1].runes {
+		i++
+	}
+	return i
+}
+
+// offsetAt returns the rune offset closest to the position pos.
+func (s *Selectable) offsetAt(pos f32.Point) int {
+	return caretAt(s.lines, pos)
+}
+
+// runeCount returns the number of runes of the line, excluding its
+// line terminator.
+func (l placedLine) runeCount() int {
+	n := len(l.line.Layout.Advances)
+	if strings.HasSuffix(l.line.Layout.Text, "\n") {
+		n--
+	}
+	return n
+}
+
+// len returns the number of displayed runes.
+func (s *Selectable) len() int {
+	if len(s.lines) == 0 {
+		return 0
+	}
+	l := s.lines[len(s.lines)-1]
+	return l.runes + len(l.line.Layout.Advances)
+}
+
+// clamp clamps the rune offset off to the displayed text.
+func (s *Selectable) clamp(off int) int {
+	if off < 0 {
+		return 0
+	}
+	if n := s.len(); off > n {
+		return n
+	}
+	return off
+}
+
+// paintSelection paints the highlight of the selection.
+func (s *Selectable) paintSelection(gtx layout.Context) {
+	start, end := s.Selection()
+	highlightRange(gtx, s.Highlight, s.lines, start, end)
+}
+
+func (SelectEvent) isLabelEvent()    {}
+func (LinkClickEvent) isLabelEvent() {}
@@ -0,0 +1,30 @@
+// SPDX-License-Identifier: Unlicense OR MIT
+
+package widget
+
+import (
+	"unicode/utf8"
+
+	"gioui.org/io/key"
+)
+
+// shortcutName returns the name used for matching k against
+// shortcuts such as copy and paste.
+//
+// Key names follow the active keyboard layout. That matches the key
+// labels for Latin layouts such as AZERTY or Dvorak, but layouts
+// without Latin letters would never produce the names of shortcut
+// keys. For keys with non-ASCII names, the name of the key at the
+// same physical position on a QWERTY keyboard is used instead, if
+// known.
+func shortcutName(k key.Event) string {
+	if k.Physical == "" {
+		return k.Name
+	}
+	for i := 0; i < len(k.Name); i++ {
+		if k.Name[i] >= utf8.RuneSelf {
+			return k.Physical
+		}
+	}
+	return k.Name
+}
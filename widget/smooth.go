@@ -0,0 +1,62 @@
+// SPDX-License-Identifier: Unlicense OR MIT
+
+package widget
+
+import (
+	"image"
+	"time"
+
+	"gioui.org/layout"
+	"gioui.org/op"
+)
+
+// smoothScroll is the state of an animated scroll.
+type smoothScroll struct {
+	// pending is set by jumps that should be animated, starting at
+	// the scroll offset from.
+	pending bool
+	// active is set while animating from from to to, starting at
+	// the time start.
+	active   bool
+	from, to image.Point
+	start    time.Time
+}
+
+// jump marks a jump that scrolls the editor, to be animated if
+// ScrollDuration is set.
+func (e *Editor) jump() {
+	if e.ScrollDuration <= 0 {
+		return
+	}
+	if !e.smooth.pending {
+		// Start from the current position, even if in the middle
+		// of another animation.
+		e.smooth = smoothScroll{pending: true, from: e.scrollOff}
+	}
+}
+
+// animateScroll starts animating a pending jump, and advances the
+// current animation.
+func (e *Editor) animateScroll(gtx layout.Context) {
+	s := &e.smooth
+	if s.pending {
+		s.pending = false
+		if to := e.scrollOff; to != s.from {
+			*s = smoothScroll{active: true, from: s.from, to: to, start: gtx.Now}
+		}
+	}
+	if !s.active {
+		return
+	}
+	t := float64(gtx.Now.Sub(s.start)) / float64(e.ScrollDuration)
+	if t >= 1 || e.ScrollDuration <= 0 {
+		s.active = false
+		e.scrollAbs(s.to.X, s.to.Y)
+		return
+	}
+	// Ease out: start fast and slow down towards the target.
+	t = 1 - (1-t)*(1-t)*(1-t)
+	d := s.to.Sub(s.from)
+	e.scrollAbs(s.from.X+int(float64(d.X)*t), s.from.Y+int(float64(d.Y)*t))
+	op.InvalidateOp{}.Add(gtx.Ops)
+}
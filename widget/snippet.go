@@ -0,0 +1,225 @@
+// SPDX-License-Identifier: Unlicense OR MIT
+
+package widget
+
+import (
+	"sort"
+	"strings"
+	"unicode/utf8"
+
+	"gioui.org/io/key"
+)
+
+// snippet is the state of an inserted snippet, whose placeholders
+// are visited with Tab and Shift+Tab.
+type snippet struct {
+	active bool
+	// stops are the placeholders in the order they are visited,
+	// as rune offsets. The last stop is the final caret position.
+	stops []snippetStop
+	cur   int
+}
+
+// snippetStop is a placeholder of a snippet.
+type snippetStop struct {
+	num        int
+	start, end int
+}
+
+// InsertSnippet replaces the selection with the snippet template and
+// selects its first placeholder. Placeholders are written $1, $2 and
+// so on, or ${1:text} with default text, and Tab and Shift+Tab select
+// the next and previous placeholders in the order of their numbers.
+// The caret is finally moved to $0, or to the end of the snippet if
+// the template has no $0. A backslash escapes $, } and backslash. A
+// placeholder number used more than once only makes a stop of its
+// first use. The snippet ends when the final position is reached, or
+// when Tab is pressed outside the selected placeholder.
+func (e *Editor) InsertSnippet(template string) {
+	txt, stops := parseSnippet(template)
+	start, _ := e.selectionBytes()
+	base := e.rr.runeOffset(start)
+	e.history.seal()
+	e.append(txt)
+	e.history.seal()
+	e.caret.scroll = true
+	e.snippet = snippet{}
+	if len(stops) == 0 {
+		return
+	}
+	// The inserted text may be shorter than txt, such as when it
+	// exceeds MaxLen.
+	n := e.Caret() - base
+	for i := range stops {
+		s := &stops[i]
+		if s.end > n {
+			s.end = n
+		}
+		if s.start > n {
+			s.start = n
+		}
+		s.start += base
+		s.end += base
+	}
+	e.snippet = snippet{active: true, stops: stops}
+	e.selectStop(0)
+}
+
+// snippetTab selects the next placeholder of the active snippet, or
+// the previous one if m is key.ModShift, and reports whether it did.
+// It ends the snippet if the caret is outside the selected
+// placeholder.
+func (e *Editor) snippetTab(m key.Modifiers) bool {
+	sn := &e.snippet
+	if !sn.active || m != 0 && m != key.ModShift {
+		return false
+	}
+	cur := sn.stops[sn.cur]
+	start, end := e.Selection()
+	if start < cur.start || end > cur.end {
+		*sn = snippet{}
+		return false
+	}
+	i := sn.cur + 1
+	if m == key.ModShift {
+		i = sn.cur - 1
+		if i < 0 {
+			i = 0
+		}
+	}
+	e.selectStop(i)
+	return true
+}
+
+// selectStop selects the placeholder with index i of the active
+// snippet, and ends the snippet at its last placeholder.
+func (e *Editor) selectStop(i int) {
+	sn := &e.snippet
+	sn.cur = i
+	s := sn.stops[i]
+	e.SetSelection(s.start, s.end)
+	if i == len(sn.stops)-1 {
+		*sn = snippet{}
+	}
+}
+
+// adjustSnippet updates the placeholders of the active snippet for
+// the replacement of removed runes at the rune offset start with
+// inserted runes. Text inserted at the end of the selected
+// placeholder extends it, and moves the empty placeholders there
+// that follow it.
+func (e *Editor) adjustSnippet(start, removed, inserted int) {
+	sn := &e.snippet
+	if !sn.active {
+		return
+	}
+	curEnd := sn.stops[sn.cur].end
+	for i := range sn.stops {
+		s := &sn.stops[i]
+		switch {
+		case i == sn.cur:
+			s.start = adjustOffset(s.start, start, removed, inserted, false)
+			if s.end >= start {
+				s.end = adjustOffset(s.end, start, removed, inserted, true)
+				if s.end == start {
+					s.end += inserted
+				}
+			}
+		case removed == 0 && s.start == start && start == curEnd:
+			s.start += inserted
+			s.end += inserted
+		default:
+			s.start = adjustOffset(s.start, start, removed, inserted, false)
+			s.end = adjustOffset(s.end, start, removed, inserted, false)
+		}
+	}
+}
+
+// parseSnippet expands the snippet template, and returns the text and
+// the placeholders in the order they are visited, with rune offsets
+// relative to the text.
+func parseSnippet(template string) (string, []snippetStop) {
+	var (
+		b     strings.Builder
+		stops []snippetStop
+		n     int
+	)
+	// parse expands s until the closing brace of a placeholder if
+	// nested is set, and returns the rest of s.
+	var parse func(s string, nested bool) string
+	parse = func(s string, nested bool) string {
+		for len(s) > 0 {
+			r, size := utf8.DecodeRuneInString(s)
+			switch {
+			case r == '\\' && len(s) > 1 && strings.ContainsRune("$}\\", rune(s[1])):
+				r, size = rune(s[1]), 2
+			case r == '}' && nested:
+				return s[1:]
+			case r == '$':
+				if num, l := parseDigits(s[1:]); l > 0 {
+					stops = append(stops, snippetStop{num: num, start: n, end: n})
+					s = s[1+l:]
+					continue
+				}
+				if !strings.HasPrefix(s, "${") {
+					break
+				}
+				num, l := parseDigits(s[2:])
+				if l == 0 {
+					break
+				}
+				switch rest := s[2+l:]; {
+				case strings.HasPrefix(rest, "}"):
+					stops = append(stops, snippetStop{num: num, start: n, end: n})
+					s = rest[1:]
+					continue
+				case strings.HasPrefix(rest, ":"):
+					i := len(stops)
+					stops = append(stops, snippetStop{num: num, start: n})
+					s = parse(rest[1:], true)
+					stops[i].end = n
+					continue
+				}
+			}
+			b.WriteRune(r)
+			n++
+			s = s[size:]
+		}
+		return s
+	}
+	parse(template, false)
+	if len(stops) == 0 {
+		return b.String(), nil
+	}
+	// Visit $1, $2 and so on, and $0 last.
+	order := func(num int) int {
+		if num == 0 {
+			return int(^uint(0) >> 1)
+		}
+		return num
+	}
+	sort.SliceStable(stops, func(i, j int) bool {
+		return order(stops[i].num) < order(stops[j].num)
+	})
+	uniq := stops[:1]
+	for _, s := range stops[1:] {
+		if s.num != uniq[len(uniq)-1].num {
+			uniq = append(uniq, s)
+		}
+	}
+	stops = uniq
+	if stops[len(stops)-1].num != 0 {
+		stops = append(stops, snippetStop{start: n, end: n})
+	}
+	return b.String(), stops
+}
+
+// parseDigits parses the decimal number at the start of s, and
+// returns it and its length in bytes.
+func parseDigits(s string) (num, n int) {
+	for n < len(s) && '0' <= s[n] && s[n] <= '9' {
+		num = num*10 + int(s[n]-'0')
+		n++
+	}
+	return num, n
+}
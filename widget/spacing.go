@@ -0,0 +1,94 @@
+// SPDX-License-Identifier: Unlicense OR MIT
+
+package widget
+
+import (
+	"gioui.org/layout"
+	"gioui.org/text"
+	"gioui.org/unit"
+
+	"golang.org/x/image/math/fixed"
+)
+
+// spacing is the line height and letter spacing of shaped text.
+type spacing struct {
+	// lineHeight is the distance between the baselines of lines,
+	// or zero for the height given by the font.
+	lineHeight fixed.Int26_6
+	// scale scales the height given by the font, if non-zero and
+	// lineHeight is zero.
+	scale float32
+	// letter is added to the advance of every rune but line
+	// terminators.
+	letter fixed.Int26_6
+}
+
+// newSpacing converts the line height and letter spacing options to
+// pixels.
+func newSpacing(gtx layout.Context, lineHeight unit.Value, scale float32, letter unit.Value) spacing {
+	var sp spacing
+	if lineHeight.V > 0 {
+		sp.lineHeight = fixed.I(gtx.Px(lineHeight))
+	}
+	if scale > 0 {
+		sp.scale = scale
+	}
+	if letter.V != 0 {
+		sp.letter = fixed.I(gtx.Px(letter))
+	}
+	return sp
+}
+
+// apply adjusts the metrics of lines to the spacing. Lines widened
+// beyond maxWidth by letter spacing are broken after the last rune
+// that fits. The lines are copied before they are adjusted, because
+// shapers may cache them.
+func (sp spacing) apply(lines []text.Line, maxWidth int) []text.Line {
+	if sp == (spacing{}) {
+		return lines
+	}
+	lines = append([]text.Line(nil), lines...)
+	wide := false
+	for i := range lines {
+		l := &lines[i]
+		sp.adjustHeight(l)
+		if sp.letter == 0 {
+			continue
+		}
+		advs := make([]fixed.Int26_6, len(l.Layout.Advances))
+		var w fixed.Int26_6
+		n := 0
+		for _, r := range l.Layout.Text {
+			advs[n] = l.Layout.Advances[n]
+			if r != '\n' {
+				advs[n] += sp.letter
+			}
+			w += advs[n]
+			n++
+		}
+		l.Layout.Advances = advs
+		l.Bounds.Max.X += w - l.Width
+		l.Width = w
+		wide = wide || w > fixed.I(maxWidth)
+	}
+	if wide {
+		lines = breakRunes(lines, maxWidth)
+	}
+	return lines
+}
+
+// adjustHeight adjusts the ascent and descent of l to the line
+// height, distributing the difference evenly between them.
+func (sp spacing) adjustHeight(l *text.Line) {
+	h := l.Ascent + l.Descent
+	target := sp.lineHeight
+	if target == 0 && sp.scale > 0 {
+		target = fixed.Int26_6(float32(h) * sp.scale)
+	}
+	if target == 0 {
+		return
+	}
+	extra := target - h
+	l.Ascent += extra / 2
+	l.Descent += extra - extra/2
+}
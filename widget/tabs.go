@@ -0,0 +1,103 @@
+// SPDX-License-Identifier: Unlicense OR MIT
+
+package widget
+
+import (
+	"strings"
+
+	"gioui.org/layout"
+	"gioui.org/text"
+	"gioui.org/unit"
+
+	"golang.org/x/image/math/fixed"
+)
+
+// tabStops are the positions that tab characters advance the text
+// to.
+type tabStops struct {
+	// width is the distance between regular tab stops in spaces,
+	// or zero for 4 spaces.
+	width int
+	// stops are the positions of the first tab stops, relative to
+	// the start of a line.
+	stops []fixed.Int26_6
+}
+
+// newTabStops converts the tab stop options to pixels.
+func newTabStops(gtx layout.Context, width int, stops []unit.Value) tabStops {
+	t := tabStops{width: width}
+	for _, s := range stops {
+		t.stops = append(t.stops, fixed.I(gtx.Px(s)))
+	}
+	return t
+}
+
+// equal reports whether t and t2 are the same tab stops.
+func (t tabStops) equal(t2 tabStops) bool {
+	if t.width != t2.width || len(t.stops) != len(t2.stops) {
+		return false
+	}
+	for i, s := range t.stops {
+		if s != t2.stops[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// next returns the first tab stop after x, for text with spaces of
+// the width space.
+func (t tabStops) next(x, space fixed.Int26_6) fixed.Int26_6 {
+	for _, s := range t.stops {
+		if s > x {
+			return s
+		}
+	}
+	w := t.width
+	if w <= 0 {
+		w = 4
+	}
+	every := space * fixed.Int26_6(w)
+	if every <= 0 {
+		return x
+	}
+	return (x/every + 1) * every
+}
+
+// expand adjusts the advances of tab characters in lines shaped with
+// the font and size to reach the next tab stop. Tab stops are applied
+// after line breaking, so lines with tabs may exceed the maximum
+// width. Lines with tabs are copied before they are adjusted,
+// because shapers may cache them.
+func (t tabStops) expand(s text.Shaper, font text.Font, size fixed.Int26_6, lines []text.Line) []text.Line {
+	var space fixed.Int26_6
+	copied := false
+	for i := range lines {
+		if !strings.ContainsRune(lines[i].Layout.Text, '\t') {
+			continue
+		}
+		if !copied {
+			lines = append([]text.Line(nil), lines...)
+			if sp := s.LayoutString(font, size, inf, " "); len(sp) > 0 {
+				space = sp[0].Width
+			}
+			copied = true
+		}
+		l := &lines[i]
+		advs := make([]fixed.Int26_6, len(l.Layout.Advances))
+		var x fixed.Int26_6
+		n := 0
+		for _, r := range l.Layout.Text {
+			advs[n] = l.Layout.Advances[n]
+			if r == '\t' {
+				advs[n] = t.next(x, space) - x
+			}
+			x += advs[n]
+			n++
+		}
+		l.Layout.Advances = advs
+		l.Bounds.Max.X += x - l.Width
+		l.Width = x
+	}
+	return lines
+}
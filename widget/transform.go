@@ -0,0 +1,101 @@
+// SPDX-License-Identifier: Unlicense OR MIT
+
+package widget
+
+import (
+	"strings"
+	"unicode"
+)
+
+// TransformSelection replaces the selected text with the result of
+// calling f with it, and selects the replacement. The change is
+// undone as a single step, and nothing happens if no text is
+// selected.
+func (e *Editor) TransformSelection(f func(string) string) {
+	start, end := e.selectionBytes()
+	if start == end {
+		return
+	}
+	forward := e.rr.caret >= e.caret.anchor
+	if !e.transform(start, end, f) {
+		return
+	}
+	end = e.rr.caret
+	if forward {
+		e.moveCaret(start, end)
+	} else {
+		e.moveCaret(end, start)
+	}
+}
+
+// UpperCase converts the selected text to upper case.
+func (e *Editor) UpperCase() {
+	e.TransformSelection(strings.ToUpper)
+}
+
+// LowerCase converts the selected text to lower case.
+func (e *Editor) LowerCase() {
+	e.TransformSelection(strings.ToLower)
+}
+
+// TitleCase converts the first letter of every word of the selected
+// text to title case, and the other letters to lower case.
+func (e *Editor) TitleCase() {
+	e.TransformSelection(titleCase)
+}
+
+// transformWord transforms the selected text with f if there is a
+// selection, or else the text from the caret to the end of the next
+// whitespace separated word, moving the caret after it.
+func (e *Editor) transformWord(f func(string) string) {
+	if start, end := e.selectionBytes(); start != end {
+		e.TransformSelection(f)
+		return
+	}
+	start := e.rr.caret
+	end := e.spaceWord(start, 1)
+	if !e.transform(start, end, f) {
+		e.moveCaret(end, end)
+	}
+}
+
+// transform replaces the text between the byte offsets start and end
+// with the result of calling f with it, as a single undo step, and
+// reports whether the text changed. The caret is moved to the end of
+// the replacement.
+func (e *Editor) transform(start, end int, f func(string) string) bool {
+	s := e.rr.substring(start, end)
+	t := f(s)
+	if t == s {
+		return false
+	}
+	e.history.seal()
+	e.replace(start, end, t)
+	e.history.seal()
+	e.caret.scroll = true
+	return true
+}
+
+// titleCase returns s with the first letter of every word in title
+// case and the other letters in lower case. Words are runs of letters,
+// digits and apostrophes.
+func titleCase(s string) string {
+	var b strings.Builder
+	b.Grow(len(s))
+	inWord := false
+	for _, r := range s {
+		switch {
+		case unicode.IsLetter(r) || unicode.IsDigit(r) || r == '\'' && inWord:
+			if inWord {
+				r = unicode.ToLower(r)
+			} else {
+				r = unicode.ToTitle(r)
+			}
+			inWord = true
+		default:
+			inWord = false
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
@@ -0,0 +1,182 @@
+// SPDX-License-Identifier: Unlicense OR MIT
+
+package widget
+
+import (
+	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
+)
+
+// editHistory records modifications of an Editor for undo and redo.
+type editHistory struct {
+	undo, redo []modification
+	// open tracks whether the most recent undo step may be
+	// extended by the next modification.
+	open bool
+	// group is the nesting depth of BeginGroup calls, and grouped
+	// the number of modifications recorded in the outermost group.
+	group, grouped int
+}
+
+// modification is a reversible change to the editor contents.
+type modification struct {
+	// start is the byte offset of the change.
+	start int
+	// removed is the text replaced by the change.
+	removed string
+	// inserted is the text inserted by the change.
+	inserted string
+	// caret is the caret byte offset before the change.
+	caret int
+	// before and rev are the revisions of the contents before and
+	// after the change.
+	before, rev int
+	// time is the time of the change.
+	time time.Time
+	// joined reports whether the modification is undone and
+	// redone together with the one before it.
+	joined bool
+}
+
+// undoPolicy configures the coalescing of modifications into undo
+// steps.
+type undoPolicy struct {
+	// gap is the longest pause between coalesced modifications.
+	// Zero means no limit.
+	gap time.Duration
+	// words ends undo steps at word boundaries while typing.
+	words bool
+}
+
+// record adds a modification to the undo stack and clears the redo
+// stack. Consecutive insertions and consecutive deletions are
+// coalesced into a single undo step according to p, unless the
+// history has been sealed in between.
+func (h *editHistory) record(m modification, p undoPolicy) {
+	h.redo = h.redo[:0]
+	if h.open && len(h.undo) > 0 && h.merge(&h.undo[len(h.undo)-1], m, p) {
+		return
+	}
+	if h.group > 0 {
+		m.joined = h.grouped > 0
+		h.grouped++
+	}
+	h.undo = append(h.undo, m)
+	h.open = true
+}
+
+// merge attempts to extend prev with m.
+func (h *editHistory) merge(prev *modification, m modification, p undoPolicy) bool {
+	if p.gap > 0 && m.time.Sub(prev.time) > p.gap {
+		return false
+	}
+	switch {
+	case m.removed == "" && prev.inserted != "":
+		// Typing: m continues where prev left off. A newline
+		// starts a new step.
+		if m.start != prev.start+len(prev.inserted) || strings.Contains(m.inserted, "\n") {
+			return false
+		}
+		if p.words {
+			// Start a new step at the first rune of a word.
+			last, _ := utf8.DecodeLastRuneInString(prev.inserted)
+			first, _ := utf8.DecodeRuneInString(m.inserted)
+			if unicode.IsSpace(last) && !unicode.IsSpace(first) {
+				return false
+			}
+		}
+		prev.inserted += m.inserted
+	case m.inserted == "" && prev.inserted == "":
+		switch {
+		case m.start+len(m.removed) == prev.start:
+			// Deleting backward.
+			prev.start = m.start
+			prev.removed = m.removed + prev.removed
+		case m.start == prev.start:
+			// Deleting forward.
+			prev.removed += m.removed
+		default:
+			return false
+		}
+	default:
+		return false
+	}
+	prev.time = m.time
+	return true
+}
+
+// seal prevents the next modification from being coalesced with
+// the current undo step.
+func (h *editHistory) seal() {
+	h.open = false
+}
+
+// BeginGroup starts a group of modifications that are undone and
+// redone as a single step. The group ends at the matching call to
+// EndGroup. Groups may be nested, in which case the outermost group
+// forms the undo step.
+func (e *Editor) BeginGroup() {
+	h := &e.history
+	if h.group == 0 {
+		h.seal()
+		h.grouped = 0
+	}
+	h.group++
+}
+
+// EndGroup ends the group started by the matching BeginGroup.
+func (e *Editor) EndGroup() {
+	h := &e.history
+	if h.group == 0 {
+		return
+	}
+	h.group--
+	if h.group == 0 {
+		h.seal()
+	}
+}
+
+// rebase transforms the history across a change made outside of it,
+// the replacement of the bytes between start and end with n bytes.
+// Modifications overlapping the change can no longer be reverted, so
+// the history is cleared if there are any.
+func (h *editHistory) rebase(start, end, n int) {
+	// Walk the undo stack back in time, tracking the change in the
+	// state after each modification.
+	s, e := start, end
+	for i := len(h.undo) - 1; i >= 0; i-- {
+		m := &h.undo[i]
+		mend := m.start + len(m.inserted)
+		switch {
+		case e <= m.start:
+			m.start += n - (e - s)
+		case s >= mend:
+			d := len(m.removed) - len(m.inserted)
+			s, e = s+d, e+d
+		default:
+			h.undo, h.redo = nil, nil
+			return
+		}
+		m.caret = adjustOffset(m.caret, s, e-s, n, false)
+	}
+	// Walk the redo stack forward in time.
+	s, e = start, end
+	for i := len(h.redo) - 1; i >= 0; i-- {
+		m := &h.redo[i]
+		m.caret = adjustOffset(m.caret, s, e-s, n, false)
+		mend := m.start + len(m.removed)
+		switch {
+		case e <= m.start:
+			m.start += n - (e - s)
+		case s >= mend:
+			d := len(m.inserted) - len(m.removed)
+			s, e = s+d, e+d
+		default:
+			h.redo = nil
+			return
+		}
+	}
+	h.seal()
+}
@@ -0,0 +1,168 @@
+// SPDX-License-Identifier: Unlicense OR MIT
+
+package widget
+
+import (
+	"image"
+	"math"
+	"unicode"
+
+	"gioui.org/f32"
+	"gioui.org/layout"
+	"gioui.org/op"
+	"gioui.org/op/paint"
+	"gioui.org/text"
+
+	"golang.org/x/image/math/fixed"
+)
+
+// vglyph is a rune of vertical text.
+type vglyph struct {
+	r rune
+	// adv is the horizontal advance of the rune.
+	adv     fixed.Int26_6
+	upright bool
+}
+
+// vcolumn is a column of vertical text.
+type vcolumn struct {
+	glyphs []vglyph
+	height fixed.Int26_6
+	// runes is the rune offset of the column.
+	runes int
+}
+
+// isUpright reports whether r belongs to a script that stays upright
+// in vertical text. Runes of other scripts are rotated clockwise.
+func isUpright(r rune) bool {
+	switch {
+	case unicode.In(r, unicode.Han, unicode.Hiragana, unicode.Katakana, unicode.Hangul, unicode.Bopomofo):
+		return true
+	case 0x3000 <= r && r <= 0x303f:
+		// CJK symbols and punctuation.
+		return true
+	case 0xff00 <= r && r <= 0xffef:
+		// Fullwidth and halfwidth forms.
+		return true
+	}
+	return false
+}
+
+// verticalAdvance returns the vertical advance of g in text of size
+// em.
+func (g vglyph) verticalAdvance(em fixed.Int26_6) fixed.Int26_6 {
+	if g.upright {
+		return em
+	}
+	return g.adv
+}
+
+// layoutVertical lays out and paints txt in columns from top to
+// bottom, progressing from right to left. Columns are broken between
+// any two runes when they are taller than the constraints.
+func (l Label) layoutVertical(gtx layout.Context, s text.Shaper, font text.Font, textSize fixed.Int26_6, txt string) LabelResult {
+	var res LabelResult
+	cs := gtx.Constraints
+	lines := s.LayoutString(font, textSize, inf, txt)
+	if len(lines) == 0 {
+		res.Size = cs.Constrain(image.Point{})
+		return res
+	}
+	asc, desc := lines[0].Ascent, lines[0].Descent
+	maxH := fixed.I(cs.Max.Y)
+	var (
+		cols  []vcolumn
+		runes int
+	)
+	for _, line := range lines {
+		col := vcolumn{runes: runes}
+		n := 0
+		for _, r := range line.Layout.Text {
+			adv := line.Layout.Advances[n]
+			n++
+			runes++
+			if r == '\n' {
+				continue
+			}
+			g := vglyph{r: r, adv: adv, upright: isUpright(r)}
+			va := g.verticalAdvance(textSize)
+			if len(col.glyphs) > 0 && col.height+va > maxH {
+				cols = append(cols, col)
+				col = vcolumn{runes: runes - 1}
+			}
+			col.glyphs = append(col.glyphs, g)
+			col.height += va
+		}
+		cols = append(cols, col)
+	}
+	if max := l.MaxLines; max > 0 && len(cols) > max {
+		res.Truncated = runes - cols[max].runes
+		cols = cols[:max]
+	}
+	cw := (asc + desc).Ceil()
+	var h fixed.Int26_6
+	for _, c := range cols {
+		if c.height > h {
+			h = c.height
+		}
+	}
+	size := image.Point{X: len(cols) * cw, Y: h.Ceil()}
+	res.Overflow = res.Truncated > 0 || size.X > cs.Max.X || size.Y > cs.Max.Y
+	res.Size = cs.Constrain(size)
+	for i, c := range cols {
+		x := size.X - (i+1)*cw
+		l.paintColumn(gtx, s, font, textSize, c, x, asc, desc)
+	}
+	return res
+}
+
+// paintColumn paints the column c at the horizontal position x.
+// Upright runes are centered in squares of the text size, and runs of
+// the other runes are rotated clockwise with their baseline a descent
+// from the left edge of the column.
+func (l Label) paintColumn(gtx layout.Context, s text.Shaper, font text.Font, textSize fixed.Int26_6, c vcolumn, x int, asc, desc fixed.Int26_6) {
+	cw := fixed.I((asc + desc).Ceil())
+	// The baseline of upright runes divides the square of the text
+	// size like the ascent and descent divide a line.
+	var base fixed.Int26_6
+	if h := asc + desc; h > 0 {
+		base = fixed.Int26_6(int64(textSize) * int64(asc) / int64(h))
+	}
+	var y fixed.Int26_6
+	for gs := c.glyphs; len(gs) > 0; {
+		if g := gs[0]; g.upright {
+			lt := text.Layout{Text: string(g.r), Advances: []fixed.Int26_6{g.adv}}
+			off := f32.Point{
+				X: float32(fixed.I(x)+(cw-g.adv)/2) / 64,
+				Y: float32(y+base) / 64,
+			}
+			stack := op.Push(gtx.Ops)
+			op.Offset(off).Add(gtx.Ops)
+			s.Shape(font, textSize, lt).Add(gtx.Ops)
+			paint.PaintOp{}.Add(gtx.Ops)
+			stack.Pop()
+			y += textSize
+			gs = gs[1:]
+			continue
+		}
+		var (
+			lt text.Layout
+			w  fixed.Int26_6
+		)
+		n := 0
+		for n < len(gs) && !gs[n].upright {
+			lt.Text += string(gs[n].r)
+			lt.Advances = append(lt.Advances, gs[n].adv)
+			w += gs[n].adv
+			n++
+		}
+		off := f32.Point{X: float32(x) + float32(desc)/64, Y: float32(y) / 64}
+		stack := op.Push(gtx.Ops)
+		op.Affine(f32.Affine2D{}.Rotate(f32.Point{}, math.Pi/2).Offset(off)).Add(gtx.Ops)
+		s.Shape(font, textSize, lt).Add(gtx.Ops)
+		paint.PaintOp{}.Add(gtx.Ops)
+		stack.Pop()
+		y += w
+		gs = gs[n:]
+	}
+}
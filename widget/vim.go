@@ -0,0 +1,516 @@
+// SPDX-License-Identifier: Unlicense OR MIT
+
+package widget
+
+import (
+	"strings"
+	"unicode"
+
+	"gioui.org/io/key"
+)
+
+// VimMode is the editing mode of an Editor using VimKeymap.
+//
+// In normal mode, typed text is interpreted as commands. The
+// supported commands are the motions h, j, k, l, w, b, e, 0, ^, $,
+// gg and G; the operators d, c and y followed by a motion, or
+// doubled to operate on whole lines; and i, a, I, A, o, O, x, X, D,
+// C, p, P, u, v and Ctrl+R. Motions and operators accept a count.
+//
+// In visual mode, motions extend the selection, and d, x, c and y
+// operate on it. Escape returns to normal mode.
+type VimMode uint8
+
+const (
+	VimNormal VimMode = iota
+	VimInsert
+	VimVisual
+)
+
+// vimState is the state of the Vim keymap.
+type vimState struct {
+	mode VimMode
+	// count is the count being typed, and opCount the count typed
+	// before the pending operator op.
+	count, opCount int
+	op             rune
+	// g is set after a g, the prefix of gg.
+	g bool
+	// reg is the unnamed register, and regLines reports whether
+	// it contains whole lines.
+	reg      string
+	regLines bool
+}
+
+func (m VimMode) String() string {
+	switch m {
+	case VimNormal:
+		return "Normal"
+	case VimInsert:
+		return "Insert"
+	case VimVisual:
+		return "Visual"
+	default:
+		panic("invalid VimMode")
+	}
+}
+
+// VimMode returns the current mode of an editor using VimKeymap.
+func (e *Editor) VimMode() VimMode {
+	return e.vim.mode
+}
+
+// vimCommand handles the keys that behave differently in the Vim
+// keymap, and reports whether k was handled.
+func (e *Editor) vimCommand(k key.Event) bool {
+	v := &e.vim
+	if k.Name == key.NameEscape && k.Modifiers == 0 {
+		e.vimEscape()
+		return true
+	}
+	if v.mode == VimInsert {
+		return false
+	}
+	if k.Modifiers == key.ModCtrl && k.Name == "R" {
+		e.Redo()
+		return true
+	}
+	if k.Modifiers != 0 {
+		return false
+	}
+	switch k.Name {
+	case key.NameReturn, key.NameEnter:
+		e.vimKey('j')
+	case key.NameDeleteBackward:
+		e.vimKey('h')
+	case key.NameDeleteForward:
+		e.vimKey('x')
+	case key.NameTab:
+	default:
+		return false
+	}
+	return true
+}
+
+// vimEscape returns to normal mode.
+func (e *Editor) vimEscape() {
+	v := &e.vim
+	switch v.mode {
+	case VimInsert:
+		e.history.seal()
+		if start, _ := e.lineBounds(e.rr.caret); e.rr.caret > start {
+			e.vimMove(e.rr.moveRunes(e.rr.caret, -1))
+		}
+	case VimVisual:
+		e.clearSelection()
+	}
+	v.mode = VimNormal
+	v.count, v.opCount, v.op, v.g = 0, 0, 0, false
+}
+
+// vimInput interprets typed text as normal or visual mode commands.
+func (e *Editor) vimInput(s string) {
+	for _, r := range s {
+		e.vimKey(r)
+	}
+}
+
+func (e *Editor) vimKey(r rune) {
+	v := &e.vim
+	if '1' <= r && r <= '9' || r == '0' && v.count > 0 {
+		v.count = v.count*10 + int(r-'0')
+		return
+	}
+	cmd := string(r)
+	switch {
+	case v.g:
+		v.g = false
+		if r != 'g' {
+			v.count, v.opCount, v.op = 0, 0, 0
+			return
+		}
+		cmd = "gg"
+	case r == 'g':
+		v.g = true
+		return
+	}
+	n := v.count
+	v.count = 0
+	if v.op != 0 {
+		op, opCount := v.op, v.opCount
+		v.op, v.opCount = 0, 0
+		count, hasCount := vimCount(opCount)*vimCount(n), opCount > 0 || n > 0
+		if cmd == string(op) {
+			e.vimLines(op, count)
+			return
+		}
+		start := e.rr.caret
+		end, lines, inclusive, ok := e.vimMotion(cmd, count, hasCount)
+		if !ok {
+			return
+		}
+		if start > end {
+			start, end = end, start
+		}
+		if inclusive {
+			end = e.rr.moveRunes(end, 1)
+		}
+		if lines {
+			e.vimLineRange(op, start, end)
+			return
+		}
+		e.vimDelete(op, start, end)
+		return
+	}
+	count := vimCount(n)
+	if v.mode == VimVisual {
+		switch r {
+		case 'd', 'x', 'c', 'y':
+			start, end := e.selectionBytes()
+			v.mode = VimNormal
+			op := r
+			if op == 'x' {
+				op = 'd'
+			}
+			e.vimDelete(op, start, end)
+		case 'v':
+			e.vimEscape()
+		default:
+			if target, _, _, ok := e.vimMotion(cmd, count, n > 0); ok {
+				e.vimMove(target)
+			}
+		}
+		return
+	}
+	idx := e.rr.caret
+	start, end := e.lineBounds(idx)
+	switch cmd {
+	case "d", "c", "y":
+		v.op, v.opCount = r, n
+	case "i":
+		v.mode = VimInsert
+	case "a":
+		if idx < end {
+			e.vimMove(e.rr.moveRunes(idx, 1))
+		}
+		v.mode = VimInsert
+	case "I":
+		e.vimMove(e.firstNonBlank(start))
+		v.mode = VimInsert
+	case "A":
+		e.vimMove(end)
+		v.mode = VimInsert
+	case "o":
+		e.history.seal()
+		e.replace(end, end, "\n")
+		v.mode = VimInsert
+	case "O":
+		e.history.seal()
+		e.replace(start, start, "\n")
+		e.vimMove(start)
+		v.mode = VimInsert
+	case "x":
+		e.vimDelete('d', idx, e.vimRunes(idx, count, end))
+	case "X":
+		e.vimDelete('d', e.vimRunes(idx, -count, start), idx)
+	case "D":
+		e.vimDelete('d', idx, end)
+	case "C":
+		e.vimDelete('c', idx, end)
+	case "p", "P":
+		e.vimPut(r == 'p', count)
+	case "u":
+		for i := 0; i < count; i++ {
+			e.Undo()
+		}
+		e.clearSelection()
+	case "v":
+		v.mode = VimVisual
+	default:
+		if target, _, _, ok := e.vimMotion(cmd, count, n > 0); ok {
+			e.vimMove(target)
+		}
+	}
+}
+
+// vimCount returns the effective value of the count n.
+func vimCount(n int) int {
+	if n == 0 {
+		return 1
+	}
+	return n
+}
+
+// vimMove moves the caret to the byte offset idx, extending the
+// selection in visual mode.
+func (e *Editor) vimMove(idx int) {
+	e.history.seal()
+	if e.vim.mode == VimVisual {
+		e.setSelection(e.caret.anchor, idx)
+	} else {
+		e.setSelection(idx, idx)
+	}
+	e.caret.scroll = true
+}
+
+// vimRunes returns the byte offset count runes from idx, stopping at
+// the byte offset limit.
+func (e *Editor) vimRunes(idx, count, limit int) int {
+	idx = e.rr.moveRunes(idx, count)
+	if count < 0 && idx < limit || count > 0 && idx > limit {
+		idx = limit
+	}
+	return idx
+}
+
+// vimMotion returns the target of the motion cmd repeated count
+// times, whether it moves by whole lines, whether the rune at the
+// target is included by operators, and whether cmd is a motion.
+func (e *Editor) vimMotion(cmd string, count int, hasCount bool) (target int, lines, inclusive, ok bool) {
+	idx := e.rr.caret
+	start, end := e.lineBounds(idx)
+	switch cmd {
+	case "h":
+		return e.vimRunes(idx, -count, start), false, false, true
+	case "l":
+		return e.vimRunes(idx, count, end), false, false, true
+	case "j", "k":
+		if cmd == "k" {
+			count = -count
+		}
+		caret, ridx := e.caret, e.rr.caret
+		e.moveLines(count)
+		target = e.rr.caret
+		e.caret, e.rr.caret = caret, ridx
+		return target, true, false, true
+	case "w":
+		for i := 0; i < count; i++ {
+			idx = e.vimWordStart(idx)
+		}
+		return idx, false, false, true
+	case "b":
+		for i := 0; i < count; i++ {
+			idx = e.vimWordBack(idx)
+		}
+		return idx, false, false, true
+	case "e":
+		for i := 0; i < count; i++ {
+			idx = e.vimWordEnd(idx)
+		}
+		return idx, false, true, true
+	case "0":
+		return start, false, false, true
+	case "^":
+		return e.firstNonBlank(start), false, false, true
+	case "$":
+		return end, false, false, true
+	case "gg", "G":
+		line := 0
+		switch {
+		case hasCount:
+			line = count - 1
+		case cmd == "G":
+			line = e.rr.newlines()
+		}
+		return e.firstNonBlank(e.lineOffset(line)), true, false, true
+	}
+	return 0, false, false, false
+}
+
+// vimClass classifies runes into whitespace, keyword runes and
+// punctuation.
+func vimClass(r rune) int {
+	switch {
+	case unicode.IsSpace(r):
+		return 0
+	case r == '_' || unicode.IsLetter(r) || unicode.IsDigit(r):
+		return 1
+	default:
+		return 2
+	}
+}
+
+// vimWordStart returns the offset of the start of the word after idx.
+func (e *Editor) vimWordStart(idx int) int {
+	n := e.rr.len()
+	if idx < n {
+		r, _ := e.rr.runeAt(idx)
+		if c := vimClass(r); c != 0 {
+			for idx < n {
+				r, s := e.rr.runeAt(idx)
+				if vimClass(r) != c {
+					break
+				}
+				idx += s
+			}
+		}
+	}
+	for idx < n {
+		r, s := e.rr.runeAt(idx)
+		if vimClass(r) != 0 {
+			break
+		}
+		idx += s
+	}
+	return idx
+}
+
+// vimWordBack returns the offset of the start of the word before
+// idx.
+func (e *Editor) vimWordBack(idx int) int {
+	for idx > 0 {
+		r, s := e.rr.runeBefore(idx)
+		if vimClass(r) != 0 {
+			break
+		}
+		idx -= s
+	}
+	if idx == 0 {
+		return 0
+	}
+	r, _ := e.rr.runeBefore(idx)
+	c := vimClass(r)
+	for idx > 0 {
+		r, s := e.rr.runeBefore(idx)
+		if vimClass(r) != c {
+			break
+		}
+		idx -= s
+	}
+	return idx
+}
+
+// vimWordEnd returns the offset of the last rune of the word after
+// idx.
+func (e *Editor) vimWordEnd(idx int) int {
+	n := e.rr.len()
+	idx = e.rr.moveRunes(idx, 1)
+	for idx < n {
+		r, s := e.rr.runeAt(idx)
+		if vimClass(r) != 0 {
+			break
+		}
+		idx += s
+	}
+	if idx == n {
+		return idx
+	}
+	r, _ := e.rr.runeAt(idx)
+	c := vimClass(r)
+	for {
+		next := e.rr.moveRunes(idx, 1)
+		if next == n {
+			return idx
+		}
+		if r, _ := e.rr.runeAt(next); vimClass(r) != c {
+			return idx
+		}
+		idx = next
+	}
+}
+
+// firstNonBlank returns the offset of the first rune that is not a
+// space or tab in the line starting at idx.
+func (e *Editor) firstNonBlank(idx int) int {
+	for idx < e.rr.len() {
+		r, s := e.rr.runeAt(idx)
+		if r != ' ' && r != '\t' {
+			break
+		}
+		idx += s
+	}
+	return idx
+}
+
+// vimLines applies the operator op to count lines from the caret.
+func (e *Editor) vimLines(op rune, count int) {
+	start, end := e.lineBounds(e.rr.caret)
+	for i := 1; i < count && end < e.rr.len(); i++ {
+		_, end = e.lineBounds(end + 1)
+	}
+	e.vimLineRange(op, start, end)
+}
+
+// vimLineRange applies the operator op to the lines covering the
+// byte offsets start and end.
+func (e *Editor) vimLineRange(op rune, start, end int) {
+	start, _ = e.lineBounds(start)
+	_, end = e.lineBounds(end)
+	if op == 'c' {
+		// Keep the line terminator and the indentation.
+		start = e.firstNonBlank(start)
+		e.vimOperate(op, start, end, e.rr.substring(start, end), false)
+		return
+	}
+	reg := e.rr.substring(start, end) + "\n"
+	if end < e.rr.len() {
+		end++
+	} else if start > 0 && op == 'd' {
+		// Remove the line terminator before the last line instead.
+		start--
+	}
+	e.vimOperate(op, start, end, reg, true)
+	if op == 'd' {
+		start, _ := e.lineBounds(e.rr.caret)
+		e.vimMove(e.firstNonBlank(start))
+	}
+}
+
+// vimDelete applies the operator op to the text between the byte
+// offsets start and end.
+func (e *Editor) vimDelete(op rune, start, end int) {
+	e.vimOperate(op, start, end, e.rr.substring(start, end), false)
+}
+
+// vimOperate applies the operator op to the text between the byte
+// offsets start and end, saving reg in the register.
+func (e *Editor) vimOperate(op rune, start, end int, reg string, lines bool) {
+	v := &e.vim
+	v.reg, v.regLines = reg, lines
+	switch op {
+	case 'y':
+		e.vimMove(start)
+	case 'd', 'c':
+		e.history.seal()
+		e.replace(start, end, "")
+		e.history.seal()
+		if op == 'c' {
+			v.mode = VimInsert
+		}
+		e.clearSelection()
+		e.caret.scroll = true
+	}
+}
+
+// vimPut inserts the register count times after the caret, or before
+// it if after is false. Registers with whole lines are put after or
+// before the current line.
+func (e *Editor) vimPut(after bool, count int) {
+	v := &e.vim
+	if v.reg == "" {
+		return
+	}
+	txt := strings.Repeat(v.reg, count)
+	idx := e.rr.caret
+	start, end := e.lineBounds(idx)
+	e.history.seal()
+	switch {
+	case v.regLines && !after:
+		e.replace(start, start, txt)
+		e.vimMove(e.firstNonBlank(start))
+	case v.regLines && end == e.rr.len():
+		// Put after the last line.
+		e.replace(end, end, "\n"+strings.TrimSuffix(txt, "\n"))
+		e.vimMove(e.firstNonBlank(end + 1))
+	case v.regLines:
+		e.replace(end+1, end+1, txt)
+		e.vimMove(e.firstNonBlank(end + 1))
+	default:
+		if after && idx < end {
+			idx = e.rr.moveRunes(idx, 1)
+		}
+		e.replace(idx, idx, txt)
+		e.vimMove(e.rr.moveRunes(e.rr.caret, -1))
+	}
+	e.history.seal()
+}
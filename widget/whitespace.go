@@ -0,0 +1,133 @@
+// SPDX-License-Identifier: Unlicense OR MIT
+
+package widget
+
+import (
+	"image"
+	"strings"
+	"unicode/utf8"
+
+	"gioui.org/layout"
+	"gioui.org/op"
+	"gioui.org/op/clip"
+	"gioui.org/op/paint"
+	"gioui.org/text"
+
+	"golang.org/x/image/math/fixed"
+)
+
+// Whitespace is a set of whitespace kinds made visible by an Editor.
+type Whitespace uint8
+
+const (
+	// WhitespaceSpaces marks spaces with a middle dot.
+	WhitespaceSpaces Whitespace = 1 << iota
+	// WhitespaceTabs marks tabs with an arrow.
+	WhitespaceTabs
+	// WhitespaceTrailing marks the spaces and tabs at the end of
+	// lines, even if WhitespaceSpaces or WhitespaceTabs is not set.
+	WhitespaceTrailing
+	// WhitespaceNewlines marks line terminators with a pilcrow.
+	WhitespaceNewlines
+	// WhitespaceWraps marks the ends of soft-wrapped lines with a
+	// hooked arrow.
+	WhitespaceWraps
+
+	// WhitespaceAll marks all whitespace.
+	WhitespaceAll = WhitespaceSpaces | WhitespaceTabs | WhitespaceNewlines
+)
+
+// PaintWhitespace paints markers for the whitespace selected by
+// ShowWhitespace, in the current color.
+func (e *Editor) PaintWhitespace(gtx layout.Context) {
+	if e.ShowWhitespace == 0 || len(e.shapes) == 0 {
+		return
+	}
+	markers := make(map[string]text.Layout)
+	defer op.Push(gtx.Ops).Pop()
+	op.Offset(e.textOffset()).Add(gtx.Ops)
+	cl := textPadding(e.lines.ends())
+	cl.Max = cl.Max.Add(e.viewSize)
+	runes := e.rr.runeOffset(e.rr.len())
+	for _, shape := range e.shapes {
+		l := shape.layout
+		// Reordered lines are shaped whole, and their logical
+		// text is the text of the line.
+		logical := l.Text
+		if shape.order != nil {
+			logical = e.lines.entry(shape.index).Layout.Text
+		}
+		// Lines broken by wrapping have no trailing whitespace.
+		wrapped := !strings.HasSuffix(logical, "\n") && shape.runeOff+len(l.Advances) != runes
+		trailing := len(l.Advances)
+		if !wrapped {
+			trailing = utf8.RuneCountInString(strings.TrimRight(logical, " \t\n"))
+		}
+		var x fixed.Int26_6
+		idx := 0
+		for i, adv := range l.Advances {
+			r, s := utf8.DecodeRuneInString(l.Text[idx:])
+			if ms := e.ShowWhitespace.marker(r, shape.rune(i)-shape.runeOff >= trailing); ms != "" {
+				m := e.whitespaceMarker(markers, ms)
+				mx := x
+				if r != '\n' {
+					// Center the marker in the whitespace.
+					mx += (adv - layoutWidth(m)) / 2
+				}
+				e.paintMarker(gtx, cl, m, shape.offset.Add(image.Point{X: mx.Round()}))
+			}
+			x += adv
+			idx += s
+		}
+		if wrapped && e.ShowWhitespace&WhitespaceWraps != 0 {
+			m := e.whitespaceMarker(markers, "↩")
+			e.paintMarker(gtx, cl, m, shape.offset.Add(image.Point{X: x.Round()}))
+		}
+	}
+}
+
+// whitespaceMarker returns the layout of the marker ms, shaping it
+// if it is not in markers.
+func (e *Editor) whitespaceMarker(markers map[string]text.Layout, ms string) text.Layout {
+	m, ok := markers[ms]
+	if !ok {
+		if l := e.shaper.LayoutString(e.font, e.textSize, inf, ms); len(l) > 0 {
+			m = l[0].Layout
+		}
+		markers[ms] = m
+	}
+	return m
+}
+
+// paintMarker paints the marker m at off, clipped to cl.
+func (e *Editor) paintMarker(gtx layout.Context, cl image.Rectangle, m text.Layout, off image.Point) {
+	stack := op.Push(gtx.Ops)
+	op.Offset(layout.FPt(off)).Add(gtx.Ops)
+	clip.Rect(cl.Sub(off)).Add(gtx.Ops)
+	e.shaper.Shape(e.font, e.textSize, m).Add(gtx.Ops)
+	paint.PaintOp{}.Add(gtx.Ops)
+	stack.Pop()
+}
+
+// layoutWidth returns the sum of the advances of l.
+func layoutWidth(l text.Layout) fixed.Int26_6 {
+	var w fixed.Int26_6
+	for _, a := range l.Advances {
+		w += a
+	}
+	return w
+}
+
+// marker returns the marker for the rune r, or the empty string if r
+// is not marked. trailing reports whether r is at the end of a line.
+func (ws Whitespace) marker(r rune, trailing bool) string {
+	switch {
+	case r == ' ' && (ws&WhitespaceSpaces != 0 || trailing && ws&WhitespaceTrailing != 0):
+		return "·"
+	case r == '\t' && (ws&WhitespaceTabs != 0 || trailing && ws&WhitespaceTrailing != 0):
+		return "→"
+	case r == '\n' && ws&WhitespaceNewlines != 0:
+		return "¶"
+	}
+	return ""
+}
@@ -0,0 +1,124 @@
+// SPDX-License-Identifier: Unlicense OR MIT
+
+package widget
+
+import (
+	"strings"
+	"unicode"
+)
+
+// wordClass classifies runes for word segmentation.
+type wordClass uint8
+
+const (
+	classSpace wordClass = iota
+	classNewline
+	// classWord covers letters, digits and connectors.
+	classWord
+	// classKatakana runs form words by themselves.
+	classKatakana
+	// classIdeograph runes are words by themselves.
+	classIdeograph
+	// classPunct runs form words by themselves.
+	classPunct
+)
+
+// wordClass returns the word segmentation class of r.
+func (e *Editor) wordClass(r rune) wordClass {
+	return runeWordClass(r, e.WordChars)
+}
+
+// runeWordClass returns the word segmentation class of r, treating
+// the runes of wordChars as letters. The classes follow the word
+// boundary rules of Unicode Standard Annex #29, except that runs of
+// punctuation form a single word.
+func runeWordClass(r rune, wordChars string) wordClass {
+	switch {
+	case r == '\n':
+		return classNewline
+	case strings.ContainsRune(wordChars, r):
+		return classWord
+	case unicode.IsSpace(r):
+		return classSpace
+	case unicode.In(r, unicode.Han, unicode.Hiragana):
+		return classIdeograph
+	case unicode.Is(unicode.Katakana, r):
+		return classKatakana
+	case unicode.IsLetter(r), unicode.IsDigit(r), unicode.IsMark(r), unicode.Is(unicode.Pc, r):
+		return classWord
+	default:
+		return classPunct
+	}
+}
+
+// midWord reports whether the rune m joins the runes a and b into a
+// single word, such as the apostrophe in "can't" or the period in
+// "3.14".
+func midWord(a, m, b rune) bool {
+	switch {
+	case unicode.IsLetter(a) && unicode.IsLetter(b):
+		return strings.ContainsRune("'.:·’", m)
+	case unicode.IsDigit(a) && unicode.IsDigit(b):
+		return strings.ContainsRune("',.;’", m)
+	}
+	return false
+}
+
+// segmentEnd returns the byte offset of the end of the word, run of
+// whitespace or line terminator starting at the byte offset idx.
+func (e *Editor) segmentEnd(idx int) int {
+	n := e.rr.len()
+	if idx >= n {
+		return n
+	}
+	r, s := e.rr.runeAt(idx)
+	c := e.wordClass(r)
+	end := idx + s
+	if c == classNewline || c == classIdeograph {
+		return end
+	}
+	for end < n {
+		next, s := e.rr.runeAt(end)
+		if e.wordClass(next) != c {
+			if c != classWord || end+s >= n {
+				break
+			}
+			after, _ := e.rr.runeAt(end + s)
+			if !midWord(r, next, after) {
+				break
+			}
+		}
+		r = next
+		end += s
+	}
+	return end
+}
+
+// segmentStart returns the byte offset of the start of the word, run
+// of whitespace or line terminator ending at the byte offset idx.
+func (e *Editor) segmentStart(idx int) int {
+	if idx <= 0 {
+		return 0
+	}
+	r, s := e.rr.runeBefore(idx)
+	c := e.wordClass(r)
+	start := idx - s
+	if c == classNewline || c == classIdeograph {
+		return start
+	}
+	for start > 0 {
+		prev, s := e.rr.runeBefore(start)
+		if e.wordClass(prev) != c {
+			if c != classWord || start-s <= 0 {
+				break
+			}
+			before, _ := e.rr.runeBefore(start - s)
+			if !midWord(before, prev, r) {
+				break
+			}
+		}
+		r = prev
+		start -= s
+	}
+	return start
+}